@@ -0,0 +1,66 @@
+// Package browser opens URLs in the user's default browser.
+package browser
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// Default pacing for OpenAll: browsers launched once per URL (anything but
+// a running Firefox) spike the system when forty start at once.
+const (
+	DefaultBatchSize  = 5
+	DefaultBatchDelay = 750 * time.Millisecond
+)
+
+// Open launches the platform's URL handler for url and returns once it
+// has started. It does not wait for the browser itself.
+func Open(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("browser: open %s: %w", url, err)
+	}
+	// Reap the launcher in the background; its exit status says nothing
+	// about whether the page loaded.
+	go cmd.Wait()
+	return nil
+}
+
+// OpenAll opens urls batchSize at a time, waiting delay between batches.
+// A failed launch doesn't stop the rest; all failures are returned joined
+// into one error. batchSize below 1 opens everything in one batch.
+func OpenAll(urls []string, batchSize int, delay time.Duration) error {
+	return openAll(urls, batchSize, delay, Open, time.Sleep)
+}
+
+// openAll is OpenAll with the launcher and the pause between batches
+// passed in.
+func openAll(urls []string, batchSize int, delay time.Duration, open func(string) error, sleep func(time.Duration)) error {
+	if batchSize < 1 {
+		batchSize = len(urls)
+	}
+	var errs []error
+	for i, url := range urls {
+		if i > 0 && i%batchSize == 0 {
+			sleep(delay)
+		}
+		if err := open(url); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("browser: %d of %d tabs failed to open: %w", len(errs), len(urls), errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package browser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenAllBatches(t *testing.T) {
+	urls := []string{"http://localhost:1", "http://localhost:2", "http://localhost:3", "http://localhost:4", "http://localhost:5"}
+	tests := []struct {
+		name      string
+		batchSize int
+		want      []string
+	}{
+		{name: "batches of two", batchSize: 2, want: []string{"1", "2", "sleep", "3", "4", "sleep", "5"}},
+		{name: "batch covers all", batchSize: 5, want: []string{"1", "2", "3", "4", "5"}},
+		{name: "zero is one batch", batchSize: 0, want: []string{"1", "2", "3", "4", "5"}},
+		{name: "one at a time", batchSize: 1, want: []string{"1", "sleep", "2", "sleep", "3", "sleep", "4", "sleep", "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			open := func(url string) error {
+				got = append(got, url[strings.LastIndex(url, ":")+1:])
+				return nil
+			}
+			sleep := func(d time.Duration) {
+				if d != DefaultBatchDelay {
+					t.Errorf("slept %v, want %v", d, DefaultBatchDelay)
+				}
+				got = append(got, "sleep")
+			}
+			if err := openAll(urls, tt.batchSize, DefaultBatchDelay, open, sleep); err != nil {
+				t.Fatalf("openAll: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("opened %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAllJoinsErrors(t *testing.T) {
+	errNoHandler := errors.New("exec: xdg-open: not found")
+	var opened []string
+	open := func(url string) error {
+		opened = append(opened, url)
+		if strings.HasSuffix(url, ":2") || strings.HasSuffix(url, ":4") {
+			return fmt.Errorf("browser: open %s: %w", url, errNoHandler)
+		}
+		return nil
+	}
+	urls := []string{"http://localhost:1", "http://localhost:2", "http://localhost:3", "http://localhost:4"}
+
+	err := openAll(urls, 2, 0, open, func(time.Duration) {})
+	if len(opened) != len(urls) {
+		t.Errorf("opened %d of %d after a failure, want all", len(opened), len(urls))
+	}
+	if err == nil {
+		t.Fatal("openAll = nil, want the launch failures")
+	}
+	if !errors.Is(err, errNoHandler) {
+		t.Errorf("openAll = %v, want it to wrap the launch errors", err)
+	}
+	msg := err.Error()
+	for _, want := range []string{"2 of 4 tabs failed", "localhost:2", "localhost:4"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q is missing %q", msg, want)
+		}
+	}
+	if strings.Contains(msg, "localhost:3") {
+		t.Errorf("error %q names a tab that opened", msg)
+	}
+}
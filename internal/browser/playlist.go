@@ -0,0 +1,96 @@
+package browser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RTSPStream is one camera stream reachable through a local tunnel.
+type RTSPStream struct {
+	Name      string // shown in the player, e.g. "10.0.0.5 Hikvision"
+	LocalPort int
+	Path      string // stream path after the port, without the leading slash
+}
+
+// URL returns the rtsp:// address of the stream on loopback, the bare
+// address when there is no path.
+func (s RTSPStream) URL() string {
+	url := fmt.Sprintf("rtsp://localhost:%d", s.LocalPort)
+	if path := strings.TrimPrefix(s.Path, "/"); path != "" {
+		url += "/" + path
+	}
+	return url
+}
+
+// rtspPaths maps a vendor name fragment to the main-stream path its
+// cameras serve. Cameras without a match get the bare root, which most
+// ONVIF devices redirect to their first profile.
+var rtspPaths = []struct {
+	vendor string
+	path   string
+}{
+	{"hikvision", "Streaming/Channels/101"},
+	{"dahua", "cam/realmonitor?channel=1&subtype=0"},
+	{"axis", "axis-media/media.amp"},
+	{"uniview", "unicast/c1/s0/live"},
+	{"hanwha", "profile2/media.smp"},
+	{"reolink", "h264Preview_01_main"},
+}
+
+// RTSPPath returns the stream path for a camera vendor, or "" if the
+// vendor has no known path.
+func RTSPPath(vendor string) string {
+	lower := strings.ToLower(vendor)
+	for _, p := range rtspPaths {
+		if strings.Contains(lower, p.vendor) {
+			return p.path
+		}
+	}
+	return ""
+}
+
+// WriteRTSPPlaylist writes streams as an extended M3U playlist, which VLC
+// and most other players open as one list. Line breaks in a name are
+// folded to spaces so it can't start a line of its own.
+func WriteRTSPPlaylist(streams []RTSPStream, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#EXTM3U\n")
+	for _, s := range streams {
+		fmt.Fprintf(bw, "#EXTINF:-1,%s\n%s\n", strings.Join(strings.Fields(s.Name), " "), s.URL())
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("browser: write playlist: %w", err)
+	}
+	return nil
+}
+
+// SaveRTSPPlaylist writes the playlist to ~/.lmtm/cameras.m3u, replacing
+// the previous one, and returns its path.
+func SaveRTSPPlaylist(streams []RTSPStream) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("browser: %w", err)
+	}
+	dir := filepath.Join(home, ".lmtm")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("browser: %w", err)
+	}
+
+	path := filepath.Join(dir, "cameras.m3u")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("browser: %w", err)
+	}
+	if err := WriteRTSPPlaylist(streams, f); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("browser: %w", err)
+	}
+	return path, nil
+}
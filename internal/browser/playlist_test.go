@@ -0,0 +1,74 @@
+package browser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveRTSPPlaylist(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	streams := []RTSPStream{
+		{Name: "192.168.1.64 Hikvision", LocalPort: 10554, Path: "Streaming/Channels/101"},
+		{Name: "192.168.1.65 Dahua", LocalPort: 10555, Path: "cam/realmonitor?channel=1&subtype=0"},
+		{Name: "192.168.1.66", LocalPort: 10556},
+		{Name: "lobby\ncam\r\n#EXTINF:-1,evil", LocalPort: 10557, Path: "/live"},
+	}
+
+	path, err := SaveRTSPPlaylist(streams)
+	if err != nil {
+		t.Fatalf("SaveRTSPPlaylist: %v", err)
+	}
+	if path != filepath.Join(home, ".lmtm", "cameras.m3u") {
+		t.Errorf("playlist saved to %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `#EXTM3U
+#EXTINF:-1,192.168.1.64 Hikvision
+rtsp://localhost:10554/Streaming/Channels/101
+#EXTINF:-1,192.168.1.65 Dahua
+rtsp://localhost:10555/cam/realmonitor?channel=1&subtype=0
+#EXTINF:-1,192.168.1.66
+rtsp://localhost:10556
+#EXTINF:-1,lobby cam #EXTINF:-1,evil
+rtsp://localhost:10557/live
+`
+	if string(data) != want {
+		t.Errorf("playlist =\n%s\nwant\n%s", data, want)
+	}
+
+	// A second save replaces the list rather than appending to it.
+	if _, err := SaveRTSPPlaylist(streams[:1]); err != nil {
+		t.Fatalf("SaveRTSPPlaylist: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if n := strings.Count(string(data), "#EXTINF"); n != 1 {
+		t.Errorf("playlist has %d entries after saving one, want 1", n)
+	}
+}
+
+func TestRTSPPath(t *testing.T) {
+	tests := []struct {
+		vendor string
+		want   string
+	}{
+		{"Hangzhou Hikvision Digital Technology Co.,Ltd.", "Streaming/Channels/101"},
+		{"Zhejiang Dahua Technology Co., Ltd.", "cam/realmonitor?channel=1&subtype=0"},
+		{"AXIS COMMUNICATIONS AB", "axis-media/media.amp"},
+		{"Zhejiang Uniview Technologies Co.,Ltd.", "unicast/c1/s0/live"},
+		{"Hanwha Techwin", "profile2/media.smp"},
+		{"Reolink Innovation Limited", "h264Preview_01_main"},
+		{"Ubiquiti Inc", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := RTSPPath(tt.vendor); got != tt.want {
+			t.Errorf("RTSPPath(%q) = %q, want %q", tt.vendor, got, tt.want)
+		}
+	}
+}
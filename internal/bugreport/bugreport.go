@@ -0,0 +1,124 @@
+// Package bugreport gathers anonymized session diagnostics into a single
+// text file the user can attach to a support request.
+package bugreport
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// logTailLines is how many of the most recent log lines are included.
+const logTailLines = 200
+
+// Info is the session state worth reporting. Addresses in it are
+// anonymized when the report is rendered.
+type Info struct {
+	State         string // wizard stage, e.g. "Tunnel Building"
+	GatewayType   string
+	ServerVersion string // SSH server banner, e.g. "SSH-2.0-ROSSSH"
+	SSHPort       string
+	HostKeyAlgs   []string
+	Tunnels       int
+	Active        int
+	Failed        int
+	LastError     string
+}
+
+// Write renders a report with the tail of logPath and saves it under
+// ~/.lmtm. Returns the report's path.
+func Write(info Info, logPath string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("bugreport: %w", err)
+	}
+	dir := filepath.Join(home, ".lmtm")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("bugreport: %w", err)
+	}
+
+	path := filepath.Join(dir, "bugreport-"+time.Now().Format("20060102-150405")+".txt")
+	report := Render(info, tailLines(logPath, logTailLines))
+	if err := os.WriteFile(path, []byte(report), 0600); err != nil {
+		return "", fmt.Errorf("bugreport: %w", err)
+	}
+	return path, nil
+}
+
+// Render formats the report. Every section is present even when empty so
+// support can tell "nothing logged" from "section missing".
+func Render(info Info, logLines []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "lmtm bug report, %s\n", time.Now().UTC().Format(time.RFC3339))
+	b.WriteString("Addresses are anonymized: IPv4 keeps the last octet, MACs keep the vendor prefix.\n")
+
+	b.WriteString("\n== System ==\n")
+	field(&b, "os", runtime.GOOS+"/"+runtime.GOARCH)
+	field(&b, "go", runtime.Version())
+	for _, env := range []string{"TERM", "COLORTERM", "TERM_PROGRAM", "LANG"} {
+		field(&b, strings.ToLower(env), os.Getenv(env))
+	}
+
+	b.WriteString("\n== Session ==\n")
+	field(&b, "stage", info.State)
+	field(&b, "gateway", info.GatewayType)
+	field(&b, "server", info.ServerVersion)
+	field(&b, "ssh port", info.SSHPort)
+	field(&b, "host key", strings.Join(info.HostKeyAlgs, ","))
+	field(&b, "tunnels", fmt.Sprintf("%d (%d active, %d failed)", info.Tunnels, info.Active, info.Failed))
+	field(&b, "error", Redact(info.LastError))
+
+	b.WriteString("\n== Recent log ==\n")
+	if len(logLines) == 0 {
+		b.WriteString("(empty)\n")
+	}
+	for _, line := range logLines {
+		b.WriteString(Redact(line))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// field writes one aligned "label: value" line.
+func field(b *strings.Builder, label, value string) {
+	fmt.Fprintf(b, "%-14s %s\n", label+":", value)
+}
+
+var (
+	ipv4Re   = regexp.MustCompile(`\b(\d{1,3})\.(\d{1,3})\.(\d{1,3})\.(\d{1,3})\b`)
+	macRe    = regexp.MustCompile(`(?i)\b([0-9a-f]{2}[:-][0-9a-f]{2}[:-][0-9a-f]{2})[:-][0-9a-f]{2}[:-][0-9a-f]{2}[:-][0-9a-f]{2}\b`)
+	secretRe = regexp.MustCompile(`(?i)\b(password|passwd|pass|secret|token)(\s*[=:]\s*)\S+`)
+)
+
+// Redact anonymizes addresses and blanks anything that looks like a
+// credential assignment.
+func Redact(s string) string {
+	s = secretRe.ReplaceAllString(s, "${1}${2}[redacted]")
+	s = macRe.ReplaceAllString(s, "${1}:xx:xx:xx")
+	return ipv4Re.ReplaceAllString(s, "x.x.x.${4}")
+}
+
+// tailLines returns up to n final lines of the file at path. A missing
+// file yields no lines.
+func tailLines(path string, n int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines
+}
@@ -0,0 +1,105 @@
+package bugreport
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"dial 192.168.88.10:443: refused", "dial x.x.x.10:443: refused"},
+		{"arp 00:0c:29:11:22:33 on bridge", "arp 00:0c:29:xx:xx:xx on bridge"},
+		{"mac 00-0C-29-11-22-33", "mac 00-0C-29:xx:xx:xx"},
+		{"login password=hunter2 ok", "login password=[redacted] ok"},
+		{"Token: abc.def", "Token: [redacted]"},
+		{"api secret = s3cr3t!", "api secret = [redacted]"},
+		{"no secrets here", "no secrets here"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderSections(t *testing.T) {
+	info := Info{
+		State:         "Tunnel Building",
+		GatewayType:   "mikrotik",
+		ServerVersion: "SSH-2.0-ROSSSH",
+		SSHPort:       "22",
+		HostKeyAlgs:   []string{"ssh-ed25519", "rsa-sha2-256"},
+		Tunnels:       3,
+		Active:        2,
+		Failed:        1,
+		LastError:     "auth to 203.0.113.7 failed: password=letmein",
+	}
+	report := Render(info, []string{
+		"connect admin@192.168.88.1 pass: letmein",
+		"tunnel :10443 -> 192.168.88.10:443 (00:0C:29:11:22:33) active",
+	})
+
+	for _, want := range []string{
+		"== System ==", "== Session ==", "== Recent log ==",
+		"stage:         Tunnel Building",
+		"host key:      ssh-ed25519,rsa-sha2-256",
+		"tunnels:       3 (2 active, 1 failed)",
+		"error:         auth to x.x.x.7 failed: password=[redacted]",
+		"connect admin@x.x.x.1 pass: [redacted]",
+		"tunnel :10443 -> x.x.x.10:443 (00:0C:29:xx:xx:xx) active",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report is missing %q:\n%s", want, report)
+		}
+	}
+	for _, leak := range []string{"letmein", "203.0.113", "192.168.88", "11:22:33"} {
+		if strings.Contains(report, leak) {
+			t.Errorf("report leaks %q:\n%s", leak, report)
+		}
+	}
+}
+
+func TestRenderEmptyLog(t *testing.T) {
+	report := Render(Info{}, nil)
+	if !strings.Contains(report, "== Recent log ==\n(empty)\n") {
+		t.Errorf("empty log not marked:\n%s", report)
+	}
+}
+
+func TestWriteTailsLog(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	logPath := filepath.Join(home, "lmtm.log")
+	var log strings.Builder
+	for i := 1; i <= logTailLines+50; i++ {
+		fmt.Fprintf(&log, "line %d\n", i)
+	}
+	if err := os.WriteFile(logPath, []byte(log.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := Write(Info{State: "Connect"}, logPath)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if filepath.Dir(path) != filepath.Join(home, ".lmtm") {
+		t.Errorf("report written to %s, want under ~/.lmtm", path)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("report mode = %v, want 0600", fi.Mode().Perm())
+	}
+	data, _ := os.ReadFile(path)
+	report := string(data)
+	if strings.Contains(report, "line 50\n") || !strings.Contains(report, "line 51\n") || !strings.Contains(report, fmt.Sprintf("line %d\n", logTailLines+50)) {
+		t.Errorf("report doesn't hold exactly the last %d log lines", logTailLines)
+	}
+}
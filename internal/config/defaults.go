@@ -0,0 +1,158 @@
+// Package config infers sensible defaults from the user's existing tool
+// configuration (OpenSSH, git, environment). lmtm has no site config of
+// its own (decision 001); besides reading what is already there, it only
+// remembers a few preferences (see Prefs).
+package config
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fallbackUsername is used when nothing else yields a username.
+const fallbackUsername = "admin"
+
+// DefaultUsername infers the SSH username for gateway, in order: the User
+// of the first matching Host block in ~/.ssh/config, $USER, $USERNAME, a
+// single-word user.name from ~/.gitconfig, then "admin".
+func DefaultUsername(gateway string) string {
+	home, _ := os.UserHomeDir()
+
+	if u := sshConfigValue(filepath.Join(home, ".ssh", "config"), gateway, "user"); u != "" {
+		return u
+	}
+	for _, env := range []string{"USER", "USERNAME"} {
+		if u := strings.TrimSpace(os.Getenv(env)); u != "" {
+			return u
+		}
+	}
+	if u := gitUserName(filepath.Join(home, ".gitconfig")); u != "" {
+		return u
+	}
+	return fallbackUsername
+}
+
+// DefaultIdentityFile returns the IdentityFile of the first Host block in
+// ~/.ssh/config that matches gateway, or "" if there is none. Paths using
+// ssh's %-tokens are skipped; a leading ~/ is left for the caller.
+func DefaultIdentityFile(gateway string) string {
+	home, _ := os.UserHomeDir()
+	f := sshConfigValue(filepath.Join(home, ".ssh", "config"), gateway, "identityfile")
+	if strings.Contains(f, "%") {
+		return ""
+	}
+	return f
+}
+
+// sshConfigValue returns the first argument of keyword, in any case, from
+// the first Host block in an OpenSSH config whose patterns match host.
+// Like ssh, the first value found wins. Match blocks and Include are not
+// evaluated.
+func sshConfigValue(path, host, keyword string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	keyword = strings.ToLower(keyword)
+	matching := true // options before the first Host apply to all hosts
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, args := splitSSHConfigLine(sc.Text())
+		switch strings.ToLower(key) {
+		case "host":
+			matching = hostMatches(args, host)
+		case "match":
+			matching = false
+		case keyword:
+			if matching && len(args) > 0 {
+				return strings.Trim(args[0], `"`)
+			}
+		}
+	}
+	return ""
+}
+
+// splitSSHConfigLine splits "Keyword arg1 arg2" or "Keyword=arg", ignoring
+// comments and blank lines. Like ssh, a double-quoted argument may
+// contain spaces.
+func splitSSHConfigLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+	line = strings.Replace(line, "=", " ", 1)
+	var fields []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if rest, ok := strings.CutPrefix(line, `"`); ok {
+			arg, after, _ := strings.Cut(rest, `"`)
+			fields = append(fields, arg)
+			line = after
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+	return fields[0], fields[1:]
+}
+
+// hostMatches applies ssh_config Host pattern semantics: any positive
+// pattern must match and no negated (!pattern) pattern may match.
+func hostMatches(patterns []string, host string) bool {
+	if host == "" {
+		host = "*"
+	}
+	matched := false
+	for _, p := range patterns {
+		negate := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		ok, _ := filepath.Match(p, host)
+		if ok && negate {
+			return false
+		}
+		if ok {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// gitUserName returns user.name from a gitconfig if it is a single word
+// (a full name like "Jane Doe" is not a usable login).
+func gitUserName(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	inUser := false
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "[") {
+			inUser = strings.EqualFold(strings.Trim(line, "[] \t"), "user")
+			continue
+		}
+		if !inUser {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(k), "name") {
+			continue
+		}
+		v = strings.Trim(strings.TrimSpace(v), `"`)
+		if v != "" && !strings.ContainsAny(v, " \t") {
+			return v
+		}
+		return ""
+	}
+	return ""
+}
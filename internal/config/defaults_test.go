@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSSHConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSSHConfigValue(t *testing.T) {
+	path := writeSSHConfig(t, `# site gateways
+Host gw
+    User alice
+    hostname 10.0.0.2
+
+Host lower
+    user bob
+
+Host=eq
+    User=carol
+
+Host 10.0.* !10.0.0.9
+    User dave
+
+Match host matched
+    User erin
+
+Host *
+    User fallback
+`)
+
+	tests := []struct {
+		host, keyword, want string
+	}{
+		{"gw", "user", "alice"},
+		{"gw", "User", "alice"},
+		{"gw", "hostname", "10.0.0.2"},
+		{"lower", "user", "bob"},
+		{"eq", "user", "carol"},
+		{"10.0.0.5", "user", "dave"},
+		{"10.0.0.9", "user", "fallback"},
+		{"matched", "user", "fallback"},
+		{"other", "user", "fallback"},
+		{"gw", "port", ""},
+	}
+	for _, tt := range tests {
+		if got := sshConfigValue(path, tt.host, tt.keyword); got != tt.want {
+			t.Errorf("sshConfigValue(%q, %q) = %q, want %q", tt.host, tt.keyword, got, tt.want)
+		}
+	}
+}
+
+func TestSSHConfigValueMissingFile(t *testing.T) {
+	if got := sshConfigValue(filepath.Join(t.TempDir(), "none"), "gw", "user"); got != "" {
+		t.Errorf("missing file: got %q, want empty", got)
+	}
+}
+
+func TestSplitSSHConfigLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		keyword string
+		args    []string
+	}{
+		{"", "", nil},
+		{"  # comment", "", nil},
+		{"User alice", "User", []string{"alice"}},
+		{"  User=alice", "User", []string{"alice"}},
+		{"Host gw\tgw2", "Host", []string{"gw", "gw2"}},
+		{`IdentityFile "~/my keys/id"`, "IdentityFile", []string{"~/my keys/id"}},
+	}
+	for _, tt := range tests {
+		keyword, args := splitSSHConfigLine(tt.line)
+		if keyword != tt.keyword || strings.Join(args, "|") != strings.Join(tt.args, "|") {
+			t.Errorf("splitSSHConfigLine(%q) = %q %q, want %q %q", tt.line, keyword, args, tt.keyword, tt.args)
+		}
+	}
+}
+
+func TestHostMatches(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		host     string
+		want     bool
+	}{
+		{[]string{"gw"}, "gw", true},
+		{[]string{"gw"}, "gw2", false},
+		{[]string{"*"}, "", true},
+		{[]string{"10.0.0.*"}, "10.0.0.1", true},
+		{[]string{"10.0.0.*", "!10.0.0.1"}, "10.0.0.1", false},
+		{[]string{"!10.0.0.1"}, "10.0.0.2", false},
+	}
+	for _, tt := range tests {
+		if got := hostMatches(tt.patterns, tt.host); got != tt.want {
+			t.Errorf("hostMatches(%v, %q) = %v, want %v", tt.patterns, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultIdentityFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "config"), []byte(`Host gw
+    IdentityFile ~/.ssh/site_ed25519
+
+Host tokens
+    IdentityFile ~/.ssh/%h_key
+
+Host quoted
+    identityfile "~/.ssh/quoted key"
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		gateway, want string
+	}{
+		{"gw", "~/.ssh/site_ed25519"},
+		{"tokens", ""},
+		{"quoted", "~/.ssh/quoted key"},
+		{"other", ""},
+	}
+	for _, tt := range tests {
+		if got := DefaultIdentityFile(tt.gateway); got != tt.want {
+			t.Errorf("DefaultIdentityFile(%q) = %q, want %q", tt.gateway, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultUsernameFromSSHConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USER", "local")
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "config"), []byte("Host gw\n    User alice\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := DefaultUsername("gw"); got != "alice" {
+		t.Errorf("DefaultUsername(gw) = %q, want alice", got)
+	}
+	if got := DefaultUsername("other"); got != "local" {
+		t.Errorf("DefaultUsername(other) = %q, want local", got)
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+)
+
+// Prefs are the few preferences lmtm remembers between launches. They
+// describe how the tool looks and behaves, never a site: nothing in them
+// names a gateway or a device, so decision 001 still holds.
+type Prefs struct {
+	Compact bool `json:"compact"` // dashboard shows one line per device
+
+	// MaxDialsPerSecond caps new forwarded connections across all
+	// tunnels. Zero, the default, leaves forwarding unlimited; set it for
+	// gateways that fall over when a browser opens many tabs at once.
+	MaxDialsPerSecond float64 `json:"max_dials_per_second"`
+}
+
+// PrefsPath returns where the preferences are kept.
+func PrefsPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".tunneler", "prefs.json")
+}
+
+// DefaultMaxDialsPerSecond leaves forwarding unlimited.
+const DefaultMaxDialsPerSecond = 0
+
+// DefaultPrefs returns the preferences used when none are saved.
+func DefaultPrefs() Prefs {
+	return Prefs{MaxDialsPerSecond: DefaultMaxDialsPerSecond}
+}
+
+// LoadPrefs returns the saved preferences, with defaults for any that
+// aren't saved, or just the defaults if the file can't be read.
+func LoadPrefs() Prefs {
+	p := DefaultPrefs()
+	if err := store.Load(PrefsPath(), &p); err != nil {
+		return DefaultPrefs()
+	}
+	if p.MaxDialsPerSecond < 0 {
+		p.MaxDialsPerSecond = DefaultMaxDialsPerSecond
+	}
+	return p
+}
+
+// UpdatePrefs applies fn to the saved preferences and writes them back,
+// so a change made by another lmtm window in the meantime is kept.
+func UpdatePrefs(fn func(*Prefs)) error {
+	p := DefaultPrefs()
+	return store.Update(PrefsPath(), &p, func() error {
+		fn(&p)
+		return nil
+	})
+}
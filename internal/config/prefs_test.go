@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrefsRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if p := LoadPrefs(); p.Compact {
+		t.Fatalf("LoadPrefs with no file = %+v, want defaults", p)
+	}
+	if err := UpdatePrefs(func(p *Prefs) { p.Compact = true }); err != nil {
+		t.Fatalf("UpdatePrefs: %v", err)
+	}
+	if p := LoadPrefs(); !p.Compact {
+		t.Errorf("LoadPrefs after saving compact = %+v", p)
+	}
+	if err := UpdatePrefs(func(p *Prefs) { p.Compact = false }); err != nil {
+		t.Fatalf("UpdatePrefs: %v", err)
+	}
+	if p := LoadPrefs(); p.Compact {
+		t.Errorf("LoadPrefs after clearing compact = %+v", p)
+	}
+}
+
+func TestPrefsDialRate(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want float64
+	}{
+		{name: "no file", want: DefaultMaxDialsPerSecond},
+		{name: "unset", file: `{"compact": true}`, want: DefaultMaxDialsPerSecond},
+		{name: "set", file: `{"max_dials_per_second": 5}`, want: 5},
+		{name: "negative", file: `{"max_dials_per_second": -1}`, want: DefaultMaxDialsPerSecond},
+		{name: "unreadable", file: `{`, want: DefaultMaxDialsPerSecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			if tt.file != "" {
+				if err := os.MkdirAll(filepath.Dir(PrefsPath()), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(PrefsPath(), []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := LoadPrefs().MaxDialsPerSecond; got != tt.want {
+				t.Errorf("MaxDialsPerSecond = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+)
+
+// SetBridgeFDB sets whether Scan also reads the gateway's bridge
+// forwarding database. On bridged setups a host can be in the FDB before
+// it is in ARP; its address then comes from the gateway's DHCP leases.
+// Gateways without a bridge table are unaffected.
+func (s *Scanner) SetBridgeFDB(on bool) {
+	s.bridgeFDB = on
+}
+
+// mergeBridgeHosts adds the gateway's bridge hosts on subnet that ARP
+// missed. Hosts with no lease are counted in the notice, since a MAC
+// alone can't be tunnelled to. Errors are non-fatal: ARP already ran.
+func (s *Scanner) mergeBridgeHosts(ctx context.Context, subnet string, arp []gateway.ARPEntry) []gateway.ARPEntry {
+	if !s.bridgeFDB {
+		return arp
+	}
+	r, ok := s.gw.(gateway.BridgeHostReader)
+	if !ok {
+		return arp
+	}
+	hosts, err := r.BridgeHosts(ctx)
+	if err != nil {
+		return arp
+	}
+
+	seen := make(map[string]bool, len(arp)*2)
+	for _, e := range arp {
+		seen[e.IP] = true
+		seen[strings.ToUpper(e.MAC)] = true
+	}
+	unresolved := 0
+	for _, h := range hosts {
+		if seen[h.MAC] || seen[h.IP] {
+			continue
+		}
+		if h.IP == "" {
+			unresolved++
+			continue
+		}
+		if subnet != "" && !strings.HasPrefix(h.IP, subnet+".") {
+			continue
+		}
+		seen[h.IP] = true
+		arp = append(arp, gateway.ARPEntry{IP: h.IP, MAC: h.MAC, Iface: h.Iface, Flags: "F"})
+	}
+	if unresolved > 0 {
+		note := fmt.Sprintf("%d MACs on the gateway's bridge have no ARP entry or DHCP lease and can't be listed.", unresolved)
+		s.notice = strings.TrimSpace(s.notice + "\n" + note)
+	}
+	return arp
+}
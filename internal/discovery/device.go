@@ -1,6 +1,9 @@
 package discovery
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+)
 
 // DeviceClass categorizes a discovered network device.
 type DeviceClass int
@@ -62,4 +65,28 @@ type DiscoveredDevice struct {
 	DeviceType   DeviceClass
 	DefaultPorts []int
 	Online       bool
+	RandomMAC    bool   // locally administered address, typically a phone or laptop
+	Subnet       string // scanned /24 prefix the device was found on, e.g. "10.0.20"
+
+	// SharedMAC is how many addresses answered with this device's MAC
+	// when that is over the scan's threshold, 0 otherwise: the host sits
+	// behind a NAT router or bridge whose MAC the gateway sees. Collapsed
+	// lists it under one heading with the others; see SharedMAC.
+	SharedMAC int
+	Collapsed bool
+}
+
+// OctetMap marks the last octet of every device's IPv4 address, giving a
+// quick picture of which parts of a /24 are populated. Non-IPv4 addresses
+// are ignored.
+func OctetMap(devices []DiscoveredDevice) [256]bool {
+	var m [256]bool
+	for _, d := range devices {
+		ip := net.ParseIP(d.IP).To4()
+		if ip == nil {
+			continue
+		}
+		m[ip[3]] = true
+	}
+	return m
 }
@@ -0,0 +1,38 @@
+package discovery
+
+import "testing"
+
+func TestOctetMap(t *testing.T) {
+	tests := []struct {
+		name string
+		ips  []string
+		want []int // octets expected to be marked
+	}{
+		{name: "empty", ips: nil, want: nil},
+		{name: "single", ips: []string{"192.168.1.5"}, want: []int{5}},
+		{name: "duplicates", ips: []string{"10.0.0.7", "10.0.0.7"}, want: []int{7}},
+		{name: "edges", ips: []string{"10.0.0.0", "10.0.0.255"}, want: []int{0, 255}},
+		{name: "other subnets share octets", ips: []string{"10.0.0.9", "172.16.4.9", "192.168.1.200"}, want: []int{9, 200}},
+		{name: "non-IPv4 ignored", ips: []string{"", "fe80::1", "not-an-ip", "10.0.0.3"}, want: []int{3}},
+		{name: "IPv4-mapped IPv6", ips: []string{"::ffff:10.0.0.42"}, want: []int{42}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices := make([]DiscoveredDevice, len(tt.ips))
+			for i, ip := range tt.ips {
+				devices[i] = DiscoveredDevice{IP: ip}
+			}
+			got := OctetMap(devices)
+
+			var want [256]bool
+			for _, o := range tt.want {
+				want[o] = true
+			}
+			for o := range got {
+				if got[o] != want[o] {
+					t.Errorf("octet %d = %v, want %v", o, got[o], want[o])
+				}
+			}
+		})
+	}
+}
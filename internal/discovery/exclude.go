@@ -0,0 +1,88 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+)
+
+// Exclusions are hosts that never appear in scan results, such as the
+// tech's own laptop. MACs may be full addresses or prefixes ("AA:BB:CC"
+// drops a whole vendor block). The file can be edited by hand.
+type Exclusions struct {
+	IPs  []string `json:"ips,omitempty"`
+	MACs []string `json:"macs,omitempty"`
+}
+
+// ExclusionsPath returns where exclusions are kept.
+func ExclusionsPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".tunneler", "cache", "exclusions.json")
+}
+
+// LoadExclusions returns the saved exclusions, or none if the file is
+// missing or can't be read.
+func LoadExclusions() Exclusions {
+	var ex Exclusions
+	if err := store.Load(ExclusionsPath(), &ex); err != nil {
+		return Exclusions{}
+	}
+	return ex
+}
+
+// AddExclusion excludes a device by MAC, or by IP when the MAC is unknown
+// or randomized (a randomized MAC changes, the lease usually doesn't).
+func AddExclusion(ip, mac string) error {
+	var ex Exclusions
+	return store.Update(ExclusionsPath(), &ex, func() error {
+		if mac != "" && !IsLocallyAdministered(mac) {
+			mac = normalizeMAC(mac)
+			if !slices.Contains(ex.MACs, mac) {
+				ex.MACs = append(ex.MACs, mac)
+			}
+		} else if !slices.Contains(ex.IPs, ip) {
+			ex.IPs = append(ex.IPs, ip)
+		}
+		return nil
+	})
+}
+
+// Excludes reports whether a host with this IP and MAC is excluded.
+func (e Exclusions) Excludes(ip, mac string) bool {
+	if slices.Contains(e.IPs, ip) {
+		return true
+	}
+	if mac == "" {
+		return false
+	}
+	mac = normalizeMAC(mac)
+	for _, prefix := range e.MACs {
+		if p := normalizeMAC(prefix); p != "" && strings.HasPrefix(mac, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns devices without the excluded ones.
+func (e Exclusions) Filter(devices []DiscoveredDevice) []DiscoveredDevice {
+	if len(e.IPs) == 0 && len(e.MACs) == 0 {
+		return devices
+	}
+	kept := make([]DiscoveredDevice, 0, len(devices))
+	for _, d := range devices {
+		if !e.Excludes(d.IP, d.MAC) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// normalizeMAC upper-cases a MAC or MAC prefix and uses colons, so
+// "aa-bb-cc" and "AA:BB:CC" match.
+func normalizeMAC(mac string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(mac), "-", ":"))
+}
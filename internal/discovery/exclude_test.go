@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"os"
+	"testing"
+)
+
+func TestExclusionsExcludes(t *testing.T) {
+	ex := Exclusions{
+		IPs:  []string{"10.0.0.50"},
+		MACs: []string{"aa-bb-cc", "11:22:33:44:55:66", " "},
+	}
+	tests := []struct {
+		name string
+		ip   string
+		mac  string
+		want bool
+	}{
+		{name: "excluded IP", ip: "10.0.0.50", want: true},
+		{name: "excluded IP with any MAC", ip: "10.0.0.50", mac: "00:00:00:00:00:01", want: true},
+		{name: "vendor prefix", ip: "10.0.0.2", mac: "AA:BB:CC:01:02:03", want: true},
+		{name: "prefix matches dashes and lower case", ip: "10.0.0.2", mac: "aa-bb-cc-01-02-03", want: true},
+		{name: "full MAC", ip: "10.0.0.3", mac: "11:22:33:44:55:66", want: true},
+		{name: "other MAC", ip: "10.0.0.3", mac: "11:22:33:44:55:67", want: false},
+		{name: "blank prefix matches nothing", ip: "10.0.0.4", mac: "DE:AD:BE:EF:00:01", want: false},
+		{name: "no MAC", ip: "10.0.0.4", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ex.Excludes(tt.ip, tt.mac); got != tt.want {
+				t.Errorf("Excludes(%q, %q) = %v, want %v", tt.ip, tt.mac, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExclusionsFilter(t *testing.T) {
+	devices := []DiscoveredDevice{
+		{IP: "10.0.0.1", MAC: "AA:BB:CC:00:00:01"},
+		{IP: "10.0.0.2", MAC: "00:11:22:00:00:02"},
+		{IP: "10.0.0.3"},
+	}
+	if got := (Exclusions{}).Filter(devices); len(got) != 3 {
+		t.Errorf("empty exclusions kept %d of 3 devices", len(got))
+	}
+	got := Exclusions{IPs: []string{"10.0.0.3"}, MACs: []string{"AA:BB:CC"}}.Filter(devices)
+	if len(got) != 1 || got[0].IP != "10.0.0.2" {
+		t.Errorf("Filter = %+v, want only 10.0.0.2", got)
+	}
+}
+
+func TestAddExclusion(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	steps := []struct{ ip, mac string }{
+		{"10.0.0.5", "00-1a-2b-3c-4d-5e"}, // global MAC: kept by MAC
+		{"10.0.0.6", "00:1A:2B:3C:4D:5E"}, // same MAC again: no duplicate
+		{"10.0.0.7", "02:00:00:00:00:01"}, // randomized MAC: kept by IP
+		{"10.0.0.8", ""},                  // no MAC: kept by IP
+	}
+	for _, s := range steps {
+		if err := AddExclusion(s.ip, s.mac); err != nil {
+			t.Fatalf("AddExclusion(%s, %s): %v", s.ip, s.mac, err)
+		}
+	}
+
+	ex := LoadExclusions()
+	if len(ex.MACs) != 1 || ex.MACs[0] != "00:1A:2B:3C:4D:5E" {
+		t.Errorf("MACs = %v, want [00:1A:2B:3C:4D:5E]", ex.MACs)
+	}
+	if len(ex.IPs) != 2 || ex.IPs[0] != "10.0.0.7" || ex.IPs[1] != "10.0.0.8" {
+		t.Errorf("IPs = %v, want [10.0.0.7 10.0.0.8]", ex.IPs)
+	}
+}
+
+func TestLoadExclusionsMissing(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if _, err := os.Stat(ExclusionsPath()); !os.IsNotExist(err) {
+		t.Fatalf("exclusions file already exists: %v", err)
+	}
+	if ex := LoadExclusions(); len(ex.IPs) != 0 || len(ex.MACs) != 0 {
+		t.Errorf("LoadExclusions = %+v, want empty", ex)
+	}
+}
@@ -0,0 +1,63 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+)
+
+// Favorite is a device the user starred. It is keyed by MAC so it follows
+// the device across DHCP changes; IP and gateway are where it was last
+// seen, used to warn when it is missing from that site's scan.
+type Favorite struct {
+	IP      string `json:"ip"`
+	Vendor  string `json:"vendor,omitempty"`
+	Gateway string `json:"gateway"`
+}
+
+func favoritesPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".tunneler", "cache", "favorites.json")
+}
+
+// LoadFavorites returns the starred devices keyed by upper-case MAC.
+// Returns an empty map if none are saved or the file can't be read.
+func LoadFavorites() map[string]Favorite {
+	favs := make(map[string]Favorite)
+	if err := store.Load(favoritesPath(), &favs); err != nil {
+		return make(map[string]Favorite)
+	}
+	return favs
+}
+
+// SetFavorite stars (fav non-nil) or unstars (nil) the device with mac.
+func SetFavorite(mac string, fav *Favorite) error {
+	mac = strings.ToUpper(mac)
+	favs := make(map[string]Favorite)
+	return store.Update(favoritesPath(), &favs, func() error {
+		if fav == nil {
+			delete(favs, mac)
+		} else {
+			favs[mac] = *fav
+		}
+		return nil
+	})
+}
+
+// MissingFavorites returns the favorites last seen behind gateway whose
+// MAC is not among devices.
+func MissingFavorites(favs map[string]Favorite, gateway string, devices []DiscoveredDevice) []Favorite {
+	seen := make(map[string]bool, len(devices))
+	for _, d := range devices {
+		seen[strings.ToUpper(d.MAC)] = true
+	}
+	var missing []Favorite
+	for mac, f := range favs {
+		if f.Gateway == gateway && !seen[mac] {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,146 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+)
+
+// ScanMethod is the discovery method a Scan ended up relying on, the
+// richest rung of its ladder that found anything.
+type ScanMethod string
+
+const (
+	MethodNmap        ScanMethod = "nmap"         // gateway-side nmap host discovery
+	MethodPing        ScanMethod = "ping sweep"   // gateway flood ping, then ARP
+	MethodClientSweep ScanMethod = "client sweep" // TCP connects from the client, then ARP
+	MethodARP         ScanMethod = "ARP only"     // what the gateway already knew
+)
+
+// pingFilteredNotice explains an empty scan after a ping sweep that ran
+// but left the gateway's ARP table empty.
+const pingFilteredNotice = "The ping sweep found no hosts (ICMP may be filtered) and the gateway's ARP table is empty -- " +
+	"press + to add a device by IP."
+
+// SetNmapRunner puts gateway-side nmap at the top of Scan's ladder,
+// using run to execute it. Without a runner the ladder starts at ping.
+func (s *Scanner) SetNmapRunner(run gateway.CommandRunner) {
+	s.run = run
+}
+
+// Method returns the method the last Scan landed on.
+func (s *Scanner) Method() ScanMethod {
+	return s.method
+}
+
+// Fallbacks returns why the last Scan stepped down each rung it skipped,
+// in order, e.g. "nmap: nmap is not installed on the gateway".
+func (s *Scanner) Fallbacks() []string {
+	return s.fallbacks
+}
+
+// fallback records a step down the ladder.
+func (s *Scanner) fallback(format string, args ...any) {
+	s.fallbacks = append(s.fallbacks, fmt.Sprintf(format, args...))
+}
+
+// populate walks the ladder from the richest method down and stops at the
+// first that yields hosts: nmap, then the gateway's ping sweep (or the
+// client sweep when scripting is disabled), then nothing at all, leaving
+// ARP as it was. It returns hosts nmap or the client sweep saw alive, so
+// Scan can list those missing from ARP.
+func (s *Scanner) populate(ctx context.Context, subnet string) []string {
+	s.method = ""
+	s.fallbacks = nil
+
+	// Rung 1: nmap.
+	if s.run != nil {
+		hosts, err := nmapHosts(ctx, s.gw, s.run, subnet)
+		switch {
+		case err != nil:
+			s.fallback("nmap: %v", err)
+		case len(hosts) == 0:
+			s.fallback("nmap: no hosts up")
+		default:
+			s.method = MethodNmap
+			return hosts
+		}
+	}
+
+	// Rung 2: ping sweep. It returns nothing itself, so its yield is the
+	// hosts it added to the ARP table.
+	before := s.arpIPs(ctx, subnet)
+	err := s.floodPingSampled(ctx, subnet)
+	switch {
+	case errors.Is(err, gateway.ErrScriptingDisabled):
+		s.notice = noPingNotice
+		if s.dial == nil {
+			s.fallback("ping: %v", err)
+			break
+		}
+		if alive := ClientSideSweep(ctx, s.dial, subnet); len(alive) > 0 {
+			s.method = MethodClientSweep
+			return alive
+		}
+		s.fallback("client sweep: no host accepted a connection")
+	case err != nil:
+		s.fallback("ping: %v", err)
+	case before == nil:
+		// ARP couldn't be read before the sweep; assume it did its job.
+		s.method = MethodPing
+		return nil
+	default:
+		for ip := range s.arpIPs(ctx, subnet) {
+			if !before[ip] {
+				s.method = MethodPing
+				return nil
+			}
+		}
+		s.fallback("ping: no new hosts in ARP")
+		// A table that already held every host looks the same, so only
+		// an empty one is worth a notice.
+		if len(before) == 0 {
+			s.notice = strings.TrimSpace(s.notice + "\n" + pingFilteredNotice)
+		}
+	}
+
+	// Rung 3: ARP only.
+	s.method = MethodARP
+	return nil
+}
+
+// arpIPs returns the addresses in the gateway's ARP table on subnet, or
+// nil if it can't be read.
+func (s *Scanner) arpIPs(ctx context.Context, subnet string) map[string]bool {
+	entries, err := s.gw.ARPTable(ctx, subnet)
+	if err != nil {
+		return nil
+	}
+	ips := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		ips[e.IP] = true
+	}
+	return ips
+}
+
+// addUnlisted appends the hosts nmap saw that ARP doesn't list, without
+// a MAC, as NmapScan does.
+func (s *Scanner) addUnlisted(devices []DiscoveredDevice, arp []gateway.ARPEntry, alive []string, subnet string) []DiscoveredDevice {
+	listed := make(map[string]bool, len(arp))
+	for _, e := range arp {
+		listed[e.IP] = true
+	}
+	for _, ip := range alive {
+		if listed[ip] || s.exclude.Excludes(ip, "") {
+			continue
+		}
+		listed[ip] = true
+		d := newDiscoveredDevice(ip, "")
+		d.Subnet = subnet
+		devices = append(devices, d)
+	}
+	return devices
+}
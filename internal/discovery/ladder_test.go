@@ -0,0 +1,115 @@
+package discovery
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+)
+
+// sweptRunner answers from replies, serving the ARP table as before
+// until the ping sweep has run and as after once it has.
+func sweptRunner(replies map[string]string, before, after string) gateway.CommandRunner {
+	var pinged atomic.Bool
+	reply := replyRunner(replies)
+	return func(ctx context.Context, cmd string) (string, error) {
+		switch {
+		case strings.HasPrefix(cmd, "for i in"):
+			pinged.Store(true)
+			return "", nil
+		case strings.HasPrefix(cmd, "ip neigh show"):
+			if pinged.Load() {
+				return after, nil
+			}
+			return before, nil
+		}
+		return reply(ctx, cmd)
+	}
+}
+
+func TestScanLadder(t *testing.T) {
+	const oneHost = "192.168.1.1 dev eth1 lladdr 00:0c:29:aa:bb:01 REACHABLE\n"
+	nmapMissing := "nmap: nmap is not installed on the gateway"
+
+	tests := []struct {
+		name          string
+		nmap          bool
+		before, after string
+		method        ScanMethod
+		fallbacks     []string
+		devices       int
+	}{
+		{
+			name: "nmap", nmap: true, before: edgeOSNeigh, after: edgeOSNeigh,
+			method: MethodNmap, devices: 3,
+		},
+		{
+			name: "nmap unavailable, ping adds hosts", before: oneHost, after: edgeOSNeigh,
+			method: MethodPing, fallbacks: []string{nmapMissing}, devices: 2,
+		},
+		{
+			name: "nmap unavailable, ping adds nothing", before: edgeOSNeigh, after: edgeOSNeigh,
+			method: MethodARP, fallbacks: []string{nmapMissing, "ping: no new hosts in ARP"}, devices: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replies := map[string]string{}
+			if tt.nmap {
+				replies["command -v nmap"] = "/usr/bin/nmap\n"
+				replies["nmap -sn"] = nmapGrepable
+			}
+			run := sweptRunner(replies, tt.before, tt.after)
+			gw, err := gateway.Detect(context.Background(), "SSH-2.0-OpenSSH_7.4", func(ctx context.Context, cmd string) (string, error) {
+				if cmd == "cat /etc/version" {
+					return "EdgeRouter.ER-e50.v2.0.9-hotfix.7.5622731.230615.0857", nil
+				}
+				return run(ctx, cmd)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewScanner(gw)
+			s.SetNmapRunner(run)
+
+			devices, err := s.Scan(context.Background(), "192.168.1", nil)
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if s.Method() != tt.method {
+				t.Errorf("method = %q, want %q", s.Method(), tt.method)
+			}
+			if !reflect.DeepEqual(s.Fallbacks(), tt.fallbacks) {
+				t.Errorf("fallbacks = %q, want %q", s.Fallbacks(), tt.fallbacks)
+			}
+			if len(devices) != tt.devices {
+				t.Errorf("found %d devices, want %d", len(devices), tt.devices)
+			}
+			if strings.Contains(s.Notice(), pingFilteredNotice) {
+				t.Errorf("notice = %q with hosts in ARP", s.Notice())
+			}
+		})
+	}
+}
+
+func TestScanLadderEmptyARP(t *testing.T) {
+	// RouterOS has no nmap and prints an empty ARP table as nothing at all.
+	replies := map[string]string{":for": "", "/ip arp print terse": ""}
+	s := NewScanner(routerOS(t, replies))
+	s.SetNmapRunner(replyRunner(replies))
+
+	devices, err := s.Scan(context.Background(), "192.168.88", nil)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	want := []string{"nmap: nmap is not installed on the gateway", "ping: no new hosts in ARP"}
+	if s.Method() != MethodARP || !reflect.DeepEqual(s.Fallbacks(), want) {
+		t.Errorf("method %q after %q, want %q after %q", s.Method(), s.Fallbacks(), MethodARP, want)
+	}
+	if len(devices) != 0 || !strings.Contains(s.Notice(), pingFilteredNotice) {
+		t.Errorf("%d devices, notice %q; want none with the ICMP-filtered guidance", len(devices), s.Notice())
+	}
+}
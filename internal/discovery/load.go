@@ -0,0 +1,90 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+)
+
+// DefaultLoadThreshold is the gateway Busy fraction at which a scan slows
+// its sweep. Override it per scanner with SetLoadThreshold.
+const DefaultLoadThreshold = 0.8
+
+// Pacing for a sweep on a loaded gateway, and how far into the sweep the
+// "during" sample is taken.
+const (
+	slowPingBatch   = 16
+	slowPingDelay   = time.Second
+	loadSampleDelay = 2 * time.Second
+)
+
+// LoadSample is one gateway load reading taken around a scan.
+type LoadSample struct {
+	Phase string // "before", "during" or "after" the sweep
+	Load  gateway.Load
+}
+
+// SetLoadThreshold sets the Busy fraction at which the sweep slows down.
+func (s *Scanner) SetLoadThreshold(threshold float64) {
+	s.loadThreshold = threshold
+}
+
+// Slowed reports whether the scanner has downshifted its sweep because
+// the gateway was under load. Once slowed it stays slowed.
+func (s *Scanner) Slowed() bool {
+	return s.slowed
+}
+
+// LoadSamples returns the load readings from the last Scan. Empty when
+// the gateway can't report its load.
+func (s *Scanner) LoadSamples() []LoadSample {
+	return s.samples
+}
+
+// sampleLoad records one reading, if the gateway supports it, and slows
+// later sweeps if it is over the threshold.
+func (s *Scanner) sampleLoad(ctx context.Context, phase string) {
+	sampler, ok := s.gw.(gateway.LoadSampler)
+	if !ok {
+		return
+	}
+	l, err := sampler.SampleLoad(ctx)
+	if err != nil {
+		return
+	}
+	s.samples = append(s.samples, LoadSample{Phase: phase, Load: l})
+
+	threshold := s.loadThreshold
+	if threshold <= 0 {
+		threshold = DefaultLoadThreshold
+	}
+	if s.slowed || l.Busy() < threshold {
+		return
+	}
+	if pacer, ok := s.gw.(gateway.PingPacer); ok {
+		pacer.SetPingPace(slowPingBatch, slowPingDelay)
+		s.slowed = true
+		s.loadNote = fmt.Sprintf("Gateway under load (%s) -- scan slowed.", l)
+	}
+}
+
+// floodPingSampled runs the sweep with a load sample taken part way
+// through it, if it lasts that long.
+func (s *Scanner) floodPingSampled(ctx context.Context, subnet string) error {
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		select {
+		case <-time.After(loadSampleDelay):
+			s.sampleLoad(ctx, "during")
+		case <-done:
+		}
+	}()
+	err := s.floodPing(ctx, subnet)
+	close(done)
+	<-sampled
+	return err
+}
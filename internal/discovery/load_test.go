@@ -0,0 +1,62 @@
+package discovery
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+)
+
+func TestSampleLoadThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		cpu       string
+		threshold float64
+		slowed    bool
+	}{
+		{name: "below default", cpu: "40%", slowed: false},
+		{name: "at default", cpu: "80%", slowed: true},
+		{name: "under a raised threshold", cpu: "85%", threshold: 0.9, slowed: false},
+		{name: "over a lowered threshold", cpu: "30%", threshold: 0.25, slowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sweeps []string
+			reply := replyRunner(map[string]string{
+				"/ip arp print terse":    routerOSARP,
+				"/system resource print": "cpu-count: 4\ncpu-load: " + tt.cpu + "\n",
+			})
+			gw, err := gateway.Detect(context.Background(), "SSH-2.0-ROSSSH", func(ctx context.Context, cmd string) (string, error) {
+				if strings.HasPrefix(cmd, ":for") {
+					sweeps = append(sweeps, cmd)
+					return "", nil
+				}
+				return reply(ctx, cmd)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewScanner(gw)
+			if tt.threshold > 0 {
+				s.SetLoadThreshold(tt.threshold)
+			}
+
+			if _, err := s.Scan(context.Background(), "192.168.88", nil); err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if s.Slowed() != tt.slowed {
+				t.Errorf("Slowed = %v, want %v", s.Slowed(), tt.slowed)
+			}
+			if len(s.LoadSamples()) == 0 || s.LoadSamples()[0].Phase != "before" {
+				t.Errorf("LoadSamples = %+v, want a before sample first", s.LoadSamples())
+			}
+			if len(sweeps) == 0 {
+				t.Fatal("no sweep ran")
+			}
+			if paced := strings.Contains(sweeps[0], ":delay"); paced != tt.slowed {
+				t.Errorf("sweep paced = %v, want %v: %s", paced, tt.slowed, sweeps[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+)
+
+// ErrNmapUnavailable means the gateway has no nmap binary (RouterOS never
+// does; EdgeOS only if it was installed).
+var ErrNmapUnavailable = errors.New("nmap is not installed on the gateway")
+
+// nmapUpRe matches a live host in nmap's grepable output:
+//
+//	Host: 10.0.0.7 ()	Status: Up
+var nmapUpRe = regexp.MustCompile(`(?m)^Host:\s+(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})\s.*Status:\s+Up`)
+
+// ParseNmapHosts returns the live hosts in `nmap -oG -` output.
+func ParseNmapHosts(output string) []string {
+	var hosts []string
+	for _, m := range nmapUpRe.FindAllStringSubmatch(output, -1) {
+		hosts = append(hosts, m[1])
+	}
+	return hosts
+}
+
+// NmapAvailable reports whether nmap can be run on the gateway.
+func NmapAvailable(ctx context.Context, gw gateway.Gateway, run gateway.CommandRunner) bool {
+	if gw.Type() == gateway.TypeMikroTik || run == nil {
+		return false
+	}
+	out, err := run(ctx, "command -v nmap")
+	return err == nil && strings.TrimSpace(out) != ""
+}
+
+// NmapScan is the escalation for when ARP and ping discovery come up short:
+// a gateway-side nmap host discovery of the subnet, which also tries TCP
+// and ICMP timestamp probes that catch hosts ignoring plain ping. The ARP
+// table is re-read afterwards for MACs; hosts nmap found that still aren't
+// in it are returned without a MAC.
+func NmapScan(ctx context.Context, gw gateway.Gateway, run gateway.CommandRunner, subnet string) ([]DiscoveredDevice, error) {
+	if err := gateway.ValidateSubnet(subnet); err != nil {
+		return nil, fmt.Errorf("nmap scan: %w", err)
+	}
+	hosts, err := nmapHosts(ctx, gw, run, subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	arpEntries, err := gw.ARPTable(ctx, subnet)
+	if err != nil {
+		return nil, fmt.Errorf("ARP table read failed: %w", err)
+	}
+	macs := make(map[string]string, len(arpEntries))
+	for _, e := range arpEntries {
+		macs[e.IP] = e.MAC
+	}
+
+	devices := make([]DiscoveredDevice, 0, len(hosts))
+	for _, ip := range hosts {
+		d := newDiscoveredDevice(ip, macs[ip])
+		d.Subnet = subnet
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// nmapHosts runs nmap host discovery on subnet and returns the hosts up.
+func nmapHosts(ctx context.Context, gw gateway.Gateway, run gateway.CommandRunner, subnet string) ([]string, error) {
+	if !NmapAvailable(ctx, gw, run) {
+		return nil, ErrNmapUnavailable
+	}
+	out, err := run(ctx, fmt.Sprintf("nmap -sn -n -T4 -oG - %s.1-254", subnet))
+	if err != nil {
+		return nil, fmt.Errorf("nmap scan: %w", err)
+	}
+	return ParseNmapHosts(out), nil
+}
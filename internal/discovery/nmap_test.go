@@ -0,0 +1,90 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// nmapGrepable is `nmap -sn -oG -` output with three hosts up, one of
+// them (.30) ignoring ping and so missing from ARP.
+const nmapGrepable = `# Nmap 7.80 scan initiated as: nmap -sn -n -T4 -oG - 192.168.1.1-254
+Host: 192.168.1.1 ()	Status: Up
+Host: 192.168.1.10 ()	Status: Up
+Host: 192.168.1.30 ()	Status: Up
+# Nmap done -- 254 IP addresses (3 hosts up) scanned in 2.41 seconds
+`
+
+const edgeOSNeigh = "192.168.1.1 dev eth1 lladdr 00:0c:29:aa:bb:01 REACHABLE\n" +
+	"192.168.1.10 dev eth1 lladdr 00:0c:29:11:22:33 STALE\n"
+
+func TestNmapScanMergesHostsMissingFromARP(t *testing.T) {
+	replies := map[string]string{
+		"command -v nmap": "/usr/bin/nmap\n",
+		"nmap -sn":        nmapGrepable,
+		"ip neigh show":   edgeOSNeigh,
+	}
+	devices, err := NmapScan(context.Background(), edgeOS(t, replies), replyRunner(replies), "192.168.1")
+	if err != nil {
+		t.Fatalf("NmapScan: %v", err)
+	}
+
+	want := map[string]string{
+		"192.168.1.1":  "00:0C:29:AA:BB:01",
+		"192.168.1.10": "00:0C:29:11:22:33",
+		"192.168.1.30": "",
+	}
+	if len(devices) != len(want) {
+		t.Fatalf("NmapScan found %d devices, want %d: %+v", len(devices), len(want), devices)
+	}
+	for _, d := range devices {
+		mac, ok := want[d.IP]
+		if !ok {
+			t.Errorf("unexpected device %s", d.IP)
+			continue
+		}
+		if d.MAC != mac {
+			t.Errorf("%s: MAC = %q, want %q", d.IP, d.MAC, mac)
+		}
+		if d.Subnet != "192.168.1" {
+			t.Errorf("%s: subnet = %q, want 192.168.1", d.IP, d.Subnet)
+		}
+	}
+}
+
+func TestNmapScanUnavailable(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies map[string]string
+		mikro   bool
+	}{
+		{name: "not installed", replies: map[string]string{"ip neigh show": edgeOSNeigh}},
+		{name: "RouterOS", replies: map[string]string{"command -v nmap": "/usr/bin/nmap\n"}, mikro: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := edgeOS(t, tt.replies)
+			if tt.mikro {
+				gw = routerOS(t, tt.replies)
+			}
+			_, err := NmapScan(context.Background(), gw, replyRunner(tt.replies), "192.168.1")
+			if !errors.Is(err, ErrNmapUnavailable) {
+				t.Errorf("NmapScan = %v, want ErrNmapUnavailable", err)
+			}
+		})
+	}
+}
+
+func TestParseNmapHosts(t *testing.T) {
+	out := nmapGrepable + "Host: 192.168.1.40 ()\tStatus: Down\n"
+	got := ParseNmapHosts(out)
+	want := []string{"192.168.1.1", "192.168.1.10", "192.168.1.30"}
+	if len(got) != len(want) {
+		t.Fatalf("ParseNmapHosts = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ParseNmapHosts[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+)
+
+// MaxNoteLen caps a device note, in characters. Notes are field
+// observations ("lens cracked"), not documents.
+const MaxNoteLen = 500
+
+// Note is a free-text note attached to a device. It is kept between
+// sessions so it is there on the next visit.
+type Note struct {
+	Text    string    `json:"text"`
+	Updated time.Time `json:"updated"`
+	IP      string    `json:"ip"`
+	Gateway string    `json:"gateway"`
+}
+
+func notesPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".tunneler", "cache", "notes.json")
+}
+
+// NoteKey identifies a device across sessions. The MAC is preferred;
+// without one the gateway is included since LAN addresses repeat between
+// sites.
+func NoteKey(gateway, ip, mac string) string {
+	if mac != "" {
+		return strings.ToUpper(mac)
+	}
+	return gateway + "/" + ip
+}
+
+// LoadNotes returns the saved device notes keyed by NoteKey. Returns an
+// empty map if none are saved or the file can't be read.
+func LoadNotes() map[string]Note {
+	notes := make(map[string]Note)
+	if err := store.Load(notesPath(), &notes); err != nil {
+		return make(map[string]Note)
+	}
+	return notes
+}
+
+// SetNote saves (note non-nil) or deletes (nil) the note for key.
+func SetNote(key string, note *Note) error {
+	notes := make(map[string]Note)
+	return store.Update(notesPath(), &notes, func() error {
+		if note == nil {
+			delete(notes, key)
+		} else {
+			notes[key] = *note
+		}
+		return nil
+	})
+}
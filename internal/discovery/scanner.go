@@ -2,11 +2,13 @@ package discovery
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/406-mot-acceptable/lmtm/internal/gateway"
 )
@@ -16,7 +18,50 @@ type ProgressFunc func(found int)
 
 // Scanner orchestrates device discovery on a gateway's LAN.
 type Scanner struct {
-	gw gateway.Gateway
+	gw      gateway.Gateway
+	dial    DialFunc // optional, for the client-side sweep fallback
+	notice  string   // guidance from the last Scan, empty if it ran normally
+	exclude Exclusions
+
+	// Gateway load sampling; see load.go.
+	loadThreshold float64
+	slowed        bool
+	loadNote      string
+	samples       []LoadSample
+
+	// Addresses sharing one MAC; see sharedmac.go.
+	sharedThreshold int
+	expandShared    bool
+	shared          []SharedMAC
+
+	bridgeFDB   bool // also read the bridge FDB; see bridgefdb.go
+	allowPublic bool // scan non-RFC1918 subnets; see CheckSubnet
+
+	// Method ladder; see ladder.go.
+	run       gateway.CommandRunner // for nmap; nil starts at ping
+	method    ScanMethod
+	fallbacks []string
+
+	sweepProgress SweepProgressFunc // chunks the ping sweep; see sweepprogress.go
+}
+
+// noPingNotice explains a scan that couldn't ping because the gateway
+// account lacks the privileges.
+const noPingNotice = "Gateway account lacks scanning privileges (not enough permissions to ping) -- " +
+	"ARP-only mode: listing devices the gateway already knows. " +
+	"Log in with a user whose group has the test policy for a full sweep."
+
+// ErrPublicSubnet is returned for a scan of a subnet outside the RFC1918
+// ranges. A survey that mistook the WAN for the LAN would otherwise ping
+// a block of someone else's public addresses.
+var ErrPublicSubnet = errors.New("subnet is not a private (RFC1918) network")
+
+// CheckSubnet refuses a public subnet unless allowPublic is set.
+func CheckSubnet(subnet string, allowPublic bool) error {
+	if allowPublic || gateway.IsPrivateSubnet(subnet) {
+		return nil
+	}
+	return fmt.Errorf("%s.0/24: %w", subnet, ErrPublicSubnet)
 }
 
 // NewScanner creates a Scanner that discovers devices through the given gateway.
@@ -24,44 +69,91 @@ func NewScanner(gw gateway.Gateway) *Scanner {
 	return &Scanner{gw: gw}
 }
 
+// SetDialer enables the client-side sweep fallback, used when the gateway
+// refuses to run its own ping sweep.
+func (s *Scanner) SetDialer(dial DialFunc) {
+	s.dial = dial
+}
+
+// SetExclusions drops matching hosts from Scan results.
+func (s *Scanner) SetExclusions(ex Exclusions) {
+	s.exclude = ex
+}
+
+// SetAllowPublic lets Scan sweep subnets outside the RFC1918 ranges,
+// once the user has confirmed the subnet really is theirs.
+func (s *Scanner) SetAllowPublic(on bool) {
+	s.allowPublic = on
+}
+
+// Notice returns guidance about how the last Scan was degraded, such as
+// falling back to ARP-only for a read-only account. Empty otherwise.
+func (s *Scanner) Notice() string {
+	return s.notice
+}
+
 // Scan performs full device discovery on the given subnet.
 //
+// A public subnet is refused with ErrPublicSubnet before anything is sent;
+// see SetAllowPublic.
+//
 // Flow:
-//  1. Flood ping to populate the ARP table (failure is non-fatal).
-//  2. Read the ARP table (required).
-//  3. For each entry: vendor lookup, classification, build DiscoveredDevice.
-//  4. Sort by IP (last octet, numerically).
+//  1. Populate the ARP table, stepping down a ladder until a method finds
+//     hosts: nmap (with SetNmapRunner), the gateway's flood ping, a sweep
+//     from the client if the gateway forbids scripting, then ARP only.
+//  2. Read the ARP table (required), plus bridge hosts ARP missed when
+//     SetBridgeFDB is on.
+//  3. For each entry not excluded: vendor lookup, classification, build
+//     DiscoveredDevice. Locally administered (randomized) MACs skip the lookup.
+//     Hosts nmap found that ARP lacks are listed without a MAC.
+//  4. Tag addresses sharing one MAC, the mark of a NAT router or bridge.
+//  5. Sort by IP (last octet, numerically).
 func (s *Scanner) Scan(ctx context.Context, subnet string, progress ProgressFunc) ([]DiscoveredDevice, error) {
-	// Step 1: flood ping to populate ARP -- best effort.
-	_ = s.gw.FloodPing(ctx, subnet)
+	if err := CheckSubnet(subnet, s.allowPublic); err != nil {
+		return nil, err
+	}
+
+	// Step 1: populate ARP -- best effort. Gateway load is sampled around
+	// it, and a loaded gateway gets a slower sweep.
+	s.notice = ""
+	s.samples = nil
+	s.shared = nil
+	s.sampleLoad(ctx, "before")
+	alive := s.populate(ctx, subnet)
+	s.sampleLoad(ctx, "after")
+	if s.loadNote != "" {
+		s.notice = strings.TrimSpace(s.notice + "\n" + s.loadNote)
+	}
 
 	// Step 2: read ARP table -- required.
 	arpEntries, err := s.gw.ARPTable(ctx, subnet)
 	if err != nil {
 		return nil, fmt.Errorf("ARP table read failed: %w", err)
 	}
+	arpEntries = s.mergeBridgeHosts(ctx, subnet, arpEntries)
 
 	// Step 3: build device list from ARP entries.
 	devices := make([]DiscoveredDevice, 0, len(arpEntries))
 	for i, entry := range arpEntries {
-		vendor := LookupVendor(entry.MAC)
-		class := ClassifyByVendor(vendor)
-
-		devices = append(devices, DiscoveredDevice{
-			IP:           entry.IP,
-			MAC:          entry.MAC,
-			Vendor:       vendor,
-			DeviceType:   class,
-			DefaultPorts: class.DefaultPorts(),
-			Online:       true,
-		})
+		if s.exclude.Excludes(entry.IP, entry.MAC) {
+			continue
+		}
+		d := newDiscoveredDevice(entry.IP, entry.MAC)
+		d.Subnet = subnet
+		devices = append(devices, d)
 
 		if progress != nil {
 			progress(i + 1)
 		}
 	}
+	if s.method == MethodNmap {
+		devices = s.addUnlisted(devices, arpEntries, alive, subnet)
+	}
 
-	// Step 4: sort by last octet of IP address.
+	// Step 4: tag addresses that share a bridge's MAC.
+	s.markSharedMACs(devices)
+
+	// Step 5: sort by last octet of IP address.
 	sort.Slice(devices, func(i, j int) bool {
 		return parseLastOctet(devices[i].IP) < parseLastOctet(devices[j].IP)
 	})
@@ -69,6 +161,31 @@ func (s *Scanner) Scan(ctx context.Context, subnet string, progress ProgressFunc
 	return devices, nil
 }
 
+// newDiscoveredDevice builds a device from an IP and MAC, looking up the
+// vendor and class. Randomized MACs have no OUI, so the lookup is skipped;
+// an empty MAC (host seen but not in ARP) is left Unknown.
+func newDiscoveredDevice(ip, mac string) DiscoveredDevice {
+	random := IsLocallyAdministered(mac)
+	vendor := "Unknown"
+	class := ClassUnknown
+	switch {
+	case random:
+		vendor = randomizedVendor
+	case mac != "":
+		vendor = LookupVendor(mac)
+		class = ClassifyByVendor(vendor)
+	}
+	return DiscoveredDevice{
+		IP:           ip,
+		MAC:          mac,
+		Vendor:       vendor,
+		DeviceType:   class,
+		DefaultPorts: class.DefaultPorts(),
+		Online:       true,
+		RandomMAC:    random,
+	}
+}
+
 // parseLastOctet extracts the last octet from an IPv4 address as an integer.
 // Returns 0 if the IP cannot be parsed.
 func parseLastOctet(ip string) int {
@@ -88,3 +205,45 @@ func parseLastOctet(ip string) int {
 	}
 	return 0
 }
+
+// Scan timeout model. The gateway sweeps one address at a time (MikroTik)
+// or in parallel with a per-host timeout (Linux), then reads ARP; both
+// slow down with link latency.
+const (
+	scanBaseTimeout = 10 * time.Second       // ARP read and command setup
+	scanPerHost     = 100 * time.Millisecond // sweep pacing per address
+	minScanTimeout  = 30 * time.Second
+	maxScanTimeout  = 5 * time.Minute
+)
+
+// EstimateScanTimeout returns how long a scan of hostCount addresses may
+// take over a link with the given round-trip time, clamped to between 30
+// seconds and 5 minutes. A hostCount below 1 is treated as a /24.
+func EstimateScanTimeout(hostCount int, rtt time.Duration) time.Duration {
+	if hostCount < 1 {
+		hostCount = 254
+	}
+	if rtt < 0 {
+		rtt = 0
+	}
+	d := scanBaseTimeout + 10*rtt + time.Duration(hostCount)*(scanPerHost+rtt/2)
+	return min(max(d, minScanTimeout), maxScanTimeout)
+}
+
+// ScanHostCount returns the number of addresses a scan of the LAN in cidr
+// covers. Scans stop at the /24 around the gateway (see
+// gateway.LANConfig.ScanWarning), so wider networks count as 254.
+func ScanHostCount(cidr string) int {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 254
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits != 32 || ones < 24 {
+		return 254
+	}
+	if ones >= 31 {
+		return 1 << (32 - ones)
+	}
+	return 1<<(32-ones) - 2
+}
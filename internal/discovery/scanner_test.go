@@ -0,0 +1,198 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+)
+
+func TestEstimateScanTimeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts int
+		rtt   time.Duration
+		want  time.Duration
+	}{
+		{name: "local /24", hosts: 254, rtt: 0, want: 35400 * time.Millisecond},
+		{name: "zero hosts is a /24", hosts: 0, rtt: 0, want: 35400 * time.Millisecond},
+		{name: "negative hosts is a /24", hosts: -1, rtt: 0, want: 35400 * time.Millisecond},
+		{name: "negative rtt is zero", hosts: 254, rtt: -time.Second, want: 35400 * time.Millisecond},
+		{name: "small LAN clamps to minimum", hosts: 14, rtt: time.Millisecond, want: minScanTimeout},
+		{name: "slow link", hosts: 254, rtt: 200 * time.Millisecond, want: 62800 * time.Millisecond},
+		{name: "very slow link clamps to maximum", hosts: 254, rtt: 2 * time.Second, want: maxScanTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateScanTimeout(tt.hosts, tt.rtt); got != tt.want {
+				t.Errorf("EstimateScanTimeout(%d, %v) = %v, want %v", tt.hosts, tt.rtt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanHostCount(t *testing.T) {
+	tests := []struct {
+		cidr string
+		want int
+	}{
+		{"192.168.1.0/24", 254},
+		{"10.0.0.0/16", 254},
+		{"10.0.0.0/28", 14},
+		{"10.0.0.0/30", 2},
+		{"10.0.0.0/31", 2},
+		{"10.0.0.1/32", 1},
+		{"fe80::/64", 254},
+		{"garbage", 254},
+	}
+	for _, tt := range tests {
+		if got := ScanHostCount(tt.cidr); got != tt.want {
+			t.Errorf("ScanHostCount(%q) = %d, want %d", tt.cidr, got, tt.want)
+		}
+	}
+}
+
+// errExit stands in for a remote command that ran and failed.
+var errExit = errors.New("Process exited with status 1")
+
+// replyRunner answers commands from replies, keyed by command prefix.
+// Anything else fails.
+func replyRunner(replies map[string]string) gateway.CommandRunner {
+	return func(_ context.Context, cmd string) (string, error) {
+		for prefix, out := range replies {
+			if strings.HasPrefix(cmd, prefix) {
+				return out, nil
+			}
+		}
+		return "", errExit
+	}
+}
+
+// detected returns the gateway Detect picks for banner and replies.
+func detected(t *testing.T, banner string, replies map[string]string) gateway.Gateway {
+	t.Helper()
+	gw, err := gateway.Detect(context.Background(), banner, replyRunner(replies))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gw
+}
+
+// routerOS returns a MikroTik gateway answering from replies.
+func routerOS(t *testing.T, replies map[string]string) gateway.Gateway {
+	t.Helper()
+	return detected(t, "SSH-2.0-ROSSSH", replies)
+}
+
+// edgeOS returns an EdgeOS gateway answering from replies.
+func edgeOS(t *testing.T, replies map[string]string) gateway.Gateway {
+	t.Helper()
+	replies["cat /etc/version"] = "EdgeRouter.ER-e50.v2.0.9-hotfix.7.5622731.230615.0857"
+	return detected(t, "SSH-2.0-OpenSSH_7.4", replies)
+}
+
+// routerOSARP is a terse ARP print of two LAN hosts.
+const routerOSARP = " 0 DC 192.168.88.10 00:0C:29:11:22:33 bridge\n" +
+	" 1 DC 192.168.88.20 B8:27:EB:44:55:66 bridge\n"
+
+func TestScanReadOnlyAccount(t *testing.T) {
+	tests := []struct {
+		name   string
+		denial string
+	}{
+		{name: "not enough permissions", denial: "failure: not enough permissions (9)"},
+		{name: "script policy", denial: "script error: no such policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner(routerOS(t, map[string]string{
+				":for":                tt.denial,
+				"/tool flood-ping":    tt.denial,
+				"/ip arp print terse": routerOSARP,
+			}))
+			devices, err := s.Scan(context.Background(), "192.168.88", nil)
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if len(devices) != 2 {
+				t.Errorf("found %d devices, want the 2 already in ARP", len(devices))
+			}
+			if s.Method() != MethodARP {
+				t.Errorf("method = %q, want %q", s.Method(), MethodARP)
+			}
+			if s.Notice() != noPingNotice {
+				t.Errorf("notice = %q, want the ARP-only guidance", s.Notice())
+			}
+		})
+	}
+}
+
+func TestScanFullAccountHasNoNotice(t *testing.T) {
+	s := NewScanner(routerOS(t, map[string]string{
+		":for":                "",
+		"/ip arp print terse": routerOSARP,
+	}))
+	if _, err := s.Scan(context.Background(), "192.168.88", nil); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if strings.Contains(s.Notice(), "permissions") {
+		t.Errorf("notice = %q for an account allowed to ping", s.Notice())
+	}
+}
+
+func TestCheckSubnet(t *testing.T) {
+	tests := []struct {
+		subnet      string
+		allowPublic bool
+		refused     bool
+	}{
+		{subnet: "192.168.88"},
+		{subnet: "10.20.30"},
+		{subnet: "172.16.5"},
+		{subnet: "172.31.255"},
+		{subnet: "172.32.0", refused: true},
+		{subnet: "203.0.113", refused: true},
+		{subnet: "8.8.8", refused: true},
+		{subnet: "203.0.113", allowPublic: true},
+	}
+	for _, tt := range tests {
+		err := CheckSubnet(tt.subnet, tt.allowPublic)
+		if tt.refused != errors.Is(err, ErrPublicSubnet) {
+			t.Errorf("CheckSubnet(%q, %v) = %v, refused want %v", tt.subnet, tt.allowPublic, err, tt.refused)
+		}
+	}
+}
+
+func TestScanPublicSubnet(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		reply := replyRunner(map[string]string{
+			":for":                "",
+			"/ip arp print terse": " 0 DC 203.0.113.10 00:0C:29:11:22:33 ether1\n",
+		})
+		var sent []string
+		gw, err := gateway.Detect(context.Background(), "SSH-2.0-ROSSSH", func(ctx context.Context, cmd string) (string, error) {
+			sent = append(sent, cmd)
+			return reply(ctx, cmd)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent = nil
+
+		s := NewScanner(gw)
+		s.SetAllowPublic(allow)
+		devices, err := s.Scan(context.Background(), "203.0.113", nil)
+		if !allow {
+			if !errors.Is(err, ErrPublicSubnet) || devices != nil || len(sent) != 0 {
+				t.Errorf("default scan of a public subnet = %v, %v after %q; want refused before sending anything", devices, err, sent)
+			}
+			continue
+		}
+		if err != nil || len(devices) != 1 {
+			t.Errorf("confirmed scan of a public subnet = %v, %v; want its one host", devices, err)
+		}
+	}
+}
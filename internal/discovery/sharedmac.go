@@ -0,0 +1,126 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultSharedMACThreshold is how many addresses may answer with one MAC
+// before the scan takes it for a NAT router or wireless bridge answering
+// for the hosts behind it. Override it per scanner with
+// SetSharedMACThreshold.
+const DefaultSharedMACThreshold = 4
+
+// SharedMAC is one MAC that answered for more addresses than the
+// threshold. The addresses are real hosts and tunnels to them work; only
+// the MAC, and so the vendor, belongs to the box in front of them.
+type SharedMAC struct {
+	MAC    string
+	Vendor string
+	IPs    []string // ordered by last octet
+}
+
+// String describes the group for the scan notice, e.g. "23 addresses
+// share 00:27:22:AA:BB:CC (Ubiquiti) -- likely a NAT router or wireless
+// bridge".
+func (g SharedMAC) String() string {
+	vendor := ""
+	if g.Vendor != "" && g.Vendor != "Unknown" {
+		vendor = " (" + g.Vendor + ")"
+	}
+	return fmt.Sprintf("%d addresses share %s%s -- likely a NAT router or wireless bridge",
+		len(g.IPs), g.MAC, vendor)
+}
+
+// SetSharedMACThreshold sets how many addresses may share a MAC before
+// they are tagged as sitting behind a bridge.
+func (s *Scanner) SetSharedMACThreshold(n int) {
+	s.sharedThreshold = n
+}
+
+// SetCollapseSharedMACs sets whether addresses behind one bridge are
+// listed together under a single heading (the default) or left in
+// address order with only their tag.
+func (s *Scanner) SetCollapseSharedMACs(on bool) {
+	s.expandShared = !on
+}
+
+// SharedMACs returns the bridge groups found by the last Scan.
+func (s *Scanner) SharedMACs() []SharedMAC {
+	return s.shared
+}
+
+// FindSharedMACs returns every MAC that more than threshold devices
+// answer with, ordered by the first address of each group. Empty and
+// randomized MACs are never grouped.
+func FindSharedMACs(devices []DiscoveredDevice, threshold int) []SharedMAC {
+	byMAC := make(map[string]*SharedMAC)
+	var order []string
+	for _, d := range devices {
+		if d.MAC == "" || d.RandomMAC {
+			continue
+		}
+		mac := strings.ToUpper(d.MAC)
+		g, ok := byMAC[mac]
+		if !ok {
+			g = &SharedMAC{MAC: mac, Vendor: LookupVendor(mac)}
+			byMAC[mac] = g
+			order = append(order, mac)
+		}
+		g.IPs = append(g.IPs, d.IP)
+	}
+
+	var groups []SharedMAC
+	for _, mac := range order {
+		g := byMAC[mac]
+		if len(g.IPs) <= threshold {
+			continue
+		}
+		sort.Slice(g.IPs, func(i, j int) bool {
+			return parseLastOctet(g.IPs[i]) < parseLastOctet(g.IPs[j])
+		})
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return parseLastOctet(groups[i].IPs[0]) < parseLastOctet(groups[j].IPs[0])
+	})
+	return groups
+}
+
+// markSharedMACs finds bridge groups in devices and tags their members.
+// The vendor lookup describes the bridge, not the host, so members lose
+// their vendor-based class and fall back to the Unknown ports.
+func (s *Scanner) markSharedMACs(devices []DiscoveredDevice) {
+	threshold := s.sharedThreshold
+	if threshold <= 0 {
+		threshold = DefaultSharedMACThreshold
+	}
+	s.shared = FindSharedMACs(devices, threshold)
+	if len(s.shared) == 0 {
+		return
+	}
+
+	size := make(map[string]int, len(s.shared))
+	for _, g := range s.shared {
+		size[g.MAC] = len(g.IPs)
+	}
+	for i := range devices {
+		d := &devices[i]
+		n, ok := size[strings.ToUpper(d.MAC)]
+		if !ok || d.RandomMAC {
+			continue
+		}
+		d.SharedMAC = n
+		d.Collapsed = !s.expandShared
+		d.DeviceType = ClassUnknown
+		d.DefaultPorts = ClassUnknown.DefaultPorts()
+	}
+
+	notes := make([]string, len(s.shared))
+	for i, g := range s.shared {
+		notes[i] = g.String()
+	}
+	notes = append(notes, "Tunnels to these addresses still work; their vendor and type are the bridge's.")
+	s.notice = strings.TrimSpace(s.notice + "\n" + strings.Join(notes, "\n"))
+}
@@ -0,0 +1,110 @@
+package discovery
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// bridgedARP is a RouterOS ARP table from a LAN with a point-to-point
+// wireless bridge on .2, answering by proxy ARP for the five hosts of a
+// remote building, a range extender answering for two, and ordinary hosts.
+const bridgedARP = ` 0   D address=192.168.88.10 mac-address=00:0C:29:11:22:33 interface=bridge published=no
+ 1   D address=192.168.88.2 mac-address=24:A4:3C:10:20:30 interface=bridge published=no
+ 2   D address=192.168.88.61 mac-address=24:a4:3c:10:20:30 interface=bridge published=no
+ 3   D address=192.168.88.50 mac-address=24:A4:3C:10:20:30 interface=bridge published=no
+ 4   D address=192.168.88.51 mac-address=24:A4:3C:10:20:30 interface=bridge published=no
+ 5   D address=192.168.88.20 mac-address=B8:27:EB:44:55:66 interface=bridge published=no
+ 6   D address=192.168.88.52 mac-address=24:A4:3C:10:20:30 interface=bridge published=no
+ 7   D address=192.168.88.60 mac-address=24:A4:3C:10:20:30 interface=bridge published=no
+ 8   D address=192.168.88.30 mac-address=50:C7:BF:01:02:03 interface=bridge published=no
+ 9   D address=192.168.88.31 mac-address=50:C7:BF:01:02:03 interface=bridge published=no
+10   D address=192.168.88.77 mac-address=DA:A1:19:00:00:01 interface=bridge published=no
+`
+
+// bridgedIPs are the addresses answering with the bridge's MAC.
+var bridgedIPs = []string{"192.168.88.2", "192.168.88.50", "192.168.88.51", "192.168.88.52", "192.168.88.60", "192.168.88.61"}
+
+func TestScanSharedMACs(t *testing.T) {
+	tests := []struct {
+		name      string
+		collapse  bool
+		threshold int
+		grouped   bool
+	}{
+		{name: "collapsed", collapse: true, grouped: true},
+		{name: "expanded", collapse: false, grouped: true},
+		{name: "threshold above the group", collapse: true, threshold: len(bridgedIPs), grouped: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner(routerOS(t, map[string]string{
+				":for":                "",
+				"/ip arp print terse": bridgedARP,
+			}))
+			s.SetCollapseSharedMACs(tt.collapse)
+			s.SetSharedMACThreshold(tt.threshold)
+			devices, err := s.Scan(context.Background(), "192.168.88", nil)
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if len(devices) != 11 {
+				t.Fatalf("found %d devices, want 11", len(devices))
+			}
+
+			groups := s.SharedMACs()
+			if !tt.grouped {
+				if len(groups) != 0 || strings.Contains(s.Notice(), "share") {
+					t.Errorf("groups %v, notice %q; want none", groups, s.Notice())
+				}
+				for _, d := range devices {
+					if d.SharedMAC != 0 || d.Collapsed {
+						t.Errorf("%s tagged as behind a bridge", d.IP)
+					}
+				}
+				return
+			}
+
+			want := SharedMAC{MAC: "24:A4:3C:10:20:30", Vendor: LookupVendor("24:A4:3C:10:20:30"), IPs: bridgedIPs}
+			if len(groups) != 1 || !reflect.DeepEqual(groups[0], want) {
+				t.Fatalf("groups = %+v, want [%+v]", groups, want)
+			}
+			if !strings.Contains(s.Notice(), want.String()) || !strings.Contains(s.Notice(), "Tunnels to these addresses still work") {
+				t.Errorf("notice = %q", s.Notice())
+			}
+
+			behind := make(map[string]bool)
+			for _, ip := range bridgedIPs {
+				behind[ip] = true
+			}
+			for _, d := range devices {
+				if !behind[d.IP] {
+					if d.SharedMAC != 0 || d.Collapsed {
+						t.Errorf("%s (%s) tagged as behind the bridge", d.IP, d.MAC)
+					}
+					continue
+				}
+				if d.SharedMAC != len(bridgedIPs) || d.Collapsed != tt.collapse {
+					t.Errorf("%s: SharedMAC %d, Collapsed %v; want %d, %v", d.IP, d.SharedMAC, d.Collapsed, len(bridgedIPs), tt.collapse)
+				}
+				if d.DeviceType != ClassUnknown || !reflect.DeepEqual(d.DefaultPorts, ClassUnknown.DefaultPorts()) {
+					t.Errorf("%s kept the bridge's class %v", d.IP, d.DeviceType)
+				}
+			}
+		})
+	}
+}
+
+func TestFindSharedMACsSkipsRandomized(t *testing.T) {
+	var devices []DiscoveredDevice
+	for _, ip := range []string{"10.0.0.5", "10.0.0.6", "10.0.0.7", "10.0.0.8", "10.0.0.9"} {
+		devices = append(devices,
+			newDiscoveredDevice(ip, "DA:A1:19:00:00:01"),
+			DiscoveredDevice{IP: ip},
+		)
+	}
+	if groups := FindSharedMACs(devices, DefaultSharedMACThreshold); len(groups) != 0 {
+		t.Errorf("grouped randomized or missing MACs: %+v", groups)
+	}
+}
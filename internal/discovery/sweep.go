@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// DialFunc opens a TCP connection through the gateway. ssh.Client.Dial
+// satisfies it; discovery takes a func so it doesn't depend on ssh.
+type DialFunc func(network, addr string) (net.Conn, error)
+
+const (
+	sweepPort        = 80
+	sweepConcurrency = 16
+	sweepDialTimeout = time.Second
+)
+
+// ClientSideSweep dials port 80 on every host in subnet (a /24 prefix such
+// as "10.0.0") through the SSH connection. ICMP can't be carried over an SSH
+// channel, but each direct-tcpip open makes the gateway resolve the target's
+// MAC, so live hosts land in its ARP table even when the port is closed.
+// It is slower than a gateway-side ping and is meant for gateways whose
+// policy forbids scripting. Returns the hosts that accepted the connection.
+func ClientSideSweep(ctx context.Context, dial DialFunc, subnet string) []string {
+	var (
+		mu    sync.Mutex
+		alive []string
+		wg    sync.WaitGroup
+	)
+	sem := make(chan struct{}, sweepConcurrency)
+
+	for i := 1; i <= 254; i++ {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return alive
+		case sem <- struct{}{}:
+		}
+
+		ip := fmt.Sprintf("%s.%d", subnet, i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if probeDial(ctx, dial, ip) {
+				mu.Lock()
+				alive = append(alive, ip)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	return alive
+}
+
+// probeDial attempts one connection with a timeout. The SSH dial itself
+// has no deadline, so a slow attempt is abandoned and its connection, if
+// it ever arrives, is closed in the background.
+func probeDial(ctx context.Context, dial DialFunc, ip string) bool {
+	result := make(chan net.Conn, 1)
+	go func() {
+		conn, err := dial("tcp", net.JoinHostPort(ip, fmt.Sprint(sweepPort)))
+		if err != nil {
+			conn = nil
+		}
+		result <- conn
+	}()
+
+	timer := time.NewTimer(sweepDialTimeout)
+	defer timer.Stop()
+
+	select {
+	case conn := <-result:
+		if conn == nil {
+			return false
+		}
+		conn.Close()
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	go func() {
+		if conn := <-result; conn != nil {
+			conn.Close()
+		}
+	}()
+	return false
+}
@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+)
+
+// SweepChunk is how many addresses each step of a chunked ping sweep
+// covers: four round trips for a /24.
+const SweepChunk = 64
+
+// sweepHosts is the number of host addresses a /24 sweep pings.
+const sweepHosts = 254
+
+// SweepProgressFunc is called between chunks of the gateway's ping sweep
+// with how many of the subnet's addresses have been pinged so far.
+type SweepProgressFunc func(pinged, total int)
+
+// SetSweepProgress makes Scan run the gateway's ping sweep SweepChunk
+// addresses at a time, calling fn after each chunk, on gateways that can
+// sweep part of a subnet. Nil runs the sweep as one command.
+func (s *Scanner) SetSweepProgress(fn SweepProgressFunc) {
+	s.sweepProgress = fn
+}
+
+// floodPing runs the gateway's ping sweep, in chunks when progress is
+// wanted. A MikroTik login without the script policy fails the first
+// chunk; FloodPing then gets to try its broadcast fallback.
+func (s *Scanner) floodPing(ctx context.Context, subnet string) error {
+	ranger, ok := s.gw.(gateway.RangePinger)
+	if s.sweepProgress == nil || !ok {
+		return s.gw.FloodPing(ctx, subnet)
+	}
+	for first := 1; first <= sweepHosts; first += SweepChunk {
+		last := min(first+SweepChunk-1, sweepHosts)
+		err := ranger.FloodPingRange(ctx, subnet, first, last)
+		if first == 1 && errors.Is(err, gateway.ErrScriptingDisabled) {
+			return s.gw.FloodPing(ctx, subnet)
+		}
+		if err != nil {
+			return err
+		}
+		s.sweepProgress(last, sweepHosts)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
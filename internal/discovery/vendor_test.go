@@ -0,0 +1,86 @@
+package discovery
+
+import (
+	"testing"
+
+	"github.com/endobit/oui"
+)
+
+func TestLookupVendorCache(t *testing.T) {
+	ClearVendorCache()
+	t.Cleanup(ClearVendorCache)
+
+	tests := []struct {
+		mac    string
+		prefix string // cache key, "" for forms looked up uncached
+	}{
+		{"00:0C:29:11:22:33", "00:0C:29"},
+		{"00:0c:29:aa:bb:cc", "00:0C:29"},
+		{"B8:27:EB:44:55:66", "B8:27:EB"},
+		{"00-0C-29-11-22-33", ""},
+		{"F2:00:00:00:00:01", "F2:00:00"},
+		{"zz", ""},
+	}
+	for _, tt := range tests {
+		want := oui.Vendor(tt.mac)
+		if want == "" {
+			want = "Unknown"
+		}
+		// Once to fill the cache, once to read it back.
+		for i := 0; i < 2; i++ {
+			if got := LookupVendor(tt.mac); got != want {
+				t.Errorf("LookupVendor(%q) #%d = %q, want %q", tt.mac, i+1, got, want)
+			}
+		}
+		if tt.prefix != "" && vendorCache[tt.prefix] != want {
+			t.Errorf("cache[%q] = %q after looking up %q, want %q", tt.prefix, vendorCache[tt.prefix], tt.mac, want)
+		}
+	}
+	if len(vendorCache) != 3 {
+		t.Errorf("cache holds %d prefixes, want 3: %v", len(vendorCache), vendorCache)
+	}
+
+	// A stale entry is served until the cache is cleared.
+	vendorCache["00:0C:29"] = "Stale"
+	if got := LookupVendor("00:0C:29:11:22:33"); got != "Stale" {
+		t.Errorf("LookupVendor bypassed the cache: %q", got)
+	}
+	ClearVendorCache()
+	if got := LookupVendor("00:0C:29:11:22:33"); got == "Stale" {
+		t.Error("ClearVendorCache left the stale entry")
+	}
+}
+
+func TestIsLocallyAdministered(t *testing.T) {
+	tests := []struct {
+		mac  string
+		want bool
+	}{
+		{"DA:A1:19:00:00:01", true},
+		{"f2:00:00:00:00:01", true},
+		{"02-00-00-00-00-01", true},
+		{"00:0C:29:11:22:33", false},
+		{"B8:27:EB:44:55:66", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsLocallyAdministered(tt.mac); got != tt.want {
+			t.Errorf("IsLocallyAdministered(%q) = %v, want %v", tt.mac, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkLookupVendor(b *testing.B) {
+	macs := []string{"00:0C:29:11:22:33", "B8:27:EB:44:55:66", "24:A4:3C:10:20:30", "64:D1:54:AA:BB:CC"}
+	b.Run("cached", func(b *testing.B) {
+		ClearVendorCache()
+		for i := 0; i < b.N; i++ {
+			LookupVendor(macs[i%len(macs)])
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			oui.Vendor(macs[i%len(macs)])
+		}
+	})
+}
@@ -13,6 +13,9 @@ import (
 //  2. Try `/system identity print` -- if it succeeds -> MikroTik
 //  3. Try `cat /etc/version` or `uname -a` -- if contains "EdgeOS" or "ubnt" -> Ubiquiti
 //  4. Default to Ubiquiti (Linux-based commands are more portable)
+//
+// Ubiquiti gateways then read their firmware version to pick a command
+// profile; see SelectProfile.
 func Detect(ctx context.Context, banner string, run CommandRunner) (Gateway, error) {
 	// Step 1: banner-based detection.
 	upper := strings.ToUpper(banner)
@@ -32,17 +35,23 @@ func Detect(ctx context.Context, banner string, run CommandRunner) (Gateway, err
 	if out, err := run(ctx, "cat /etc/version"); err == nil {
 		lower := strings.ToLower(out)
 		if strings.Contains(lower, "edgeos") || strings.Contains(lower, "ubnt") || strings.Contains(lower, "ubiquiti") {
-			return newUbiquiti(run), nil
+			return detectUbiquiti(ctx, run), nil
 		}
 	}
 
 	if out, err := run(ctx, "uname -a"); err == nil {
 		lower := strings.ToLower(out)
 		if strings.Contains(lower, "edgeos") || strings.Contains(lower, "ubnt") || strings.Contains(lower, "ubiquiti") {
-			return newUbiquiti(run), nil
+			return detectUbiquiti(ctx, run), nil
 		}
 	}
 
 	// Step 4: default to Ubiquiti -- Linux-based commands are more portable.
-	return newUbiquiti(run), nil
+	return detectUbiquiti(ctx, run), nil
+}
+
+// detectUbiquiti builds a Ubiquiti gateway with the command profile for
+// its firmware version.
+func detectUbiquiti(ctx context.Context, run CommandRunner) *ubiquitiGateway {
+	return newUbiquiti(run, SelectProfile(readFirmwareVersion(ctx, run)))
 }
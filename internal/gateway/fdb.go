@@ -0,0 +1,145 @@
+package gateway
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// BridgeHost is a MAC the gateway's bridge has learned on one of its
+// ports. IP is the address the gateway leased to that MAC, empty when it
+// has none; bridge hosts are often there before their ARP entry.
+type BridgeHost struct {
+	MAC   string
+	Iface string // bridge port the MAC was learned on
+	IP    string
+}
+
+// BridgeHostReader is implemented by gateways that can list their bridge
+// forwarding database, an extra discovery source on bridged setups.
+type BridgeHostReader interface {
+	BridgeHosts(ctx context.Context) ([]BridgeHost, error)
+}
+
+// resolveLeases fills in each host's IP from a MAC -> IP lease map.
+func resolveLeases(hosts []BridgeHost, leases map[string]string) []BridgeHost {
+	for i := range hosts {
+		hosts[i].IP = leases[hosts[i].MAC]
+	}
+	return hosts
+}
+
+// groupMAC reports whether mac is a multicast or broadcast address,
+// which a bridge lists but no host owns.
+func groupMAC(mac string) bool {
+	if len(mac) < 2 {
+		return true
+	}
+	first, err := strconv.ParseUint(mac[:2], 16, 8)
+	return err != nil || first&1 == 1
+}
+
+// terseFields splits one line of RouterOS terse output into its
+// key=value pairs, ignoring the index and flag columns.
+func terseFields(line string) map[string]string {
+	fields := make(map[string]string)
+	for _, tok := range strings.Fields(line) {
+		if k, v, ok := strings.Cut(tok, "="); ok {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// parseBridgeHostTerse reads `/interface bridge host print terse`,
+// skipping the bridge's own (local) MACs.
+// Example: " 0 D  mac-address=64:D1:54:AA:BB:CC on-interface=ether3 bridge=bridge"
+func parseBridgeHostTerse(out string) []BridgeHost {
+	var hosts []BridgeHost
+	for _, line := range strings.Split(out, "\n") {
+		f := terseFields(line)
+		mac := f["mac-address"]
+		if mac == "" || f["local"] == "yes" || groupMAC(mac) {
+			continue
+		}
+		flags := strings.Fields(line)
+		if len(flags) > 1 && !strings.Contains(flags[1], "=") && strings.Contains(flags[1], "L") {
+			continue
+		}
+		hosts = append(hosts, BridgeHost{MAC: strings.ToUpper(mac), Iface: f["on-interface"]})
+	}
+	return hosts
+}
+
+// parseLeaseTerse reads `/ip dhcp-server lease print terse` into a
+// MAC -> IP map, preferring the active address over a static one.
+func parseLeaseTerse(out string) map[string]string {
+	leases := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		f := terseFields(line)
+		mac := f["mac-address"]
+		ip := f["active-address"]
+		if ip == "" {
+			ip = f["address"]
+		}
+		if mac != "" && validIPv4(ip) {
+			leases[strings.ToUpper(mac)] = ip
+		}
+	}
+	return leases
+}
+
+// parseBridgeFDB reads Linux `bridge fdb show`, keeping MACs learned
+// from other hosts. Permanent entries are the box's own ports.
+// Example: "64:d1:54:aa:bb:cc dev eth1 master br0"
+func parseBridgeFDB(out string) []BridgeHost {
+	var hosts []BridgeHost
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || groupMAC(fields[0]) || strings.Count(fields[0], ":") != 5 {
+			continue
+		}
+		h := BridgeHost{MAC: strings.ToUpper(fields[0])}
+		skip := false
+		for i, f := range fields {
+			switch f {
+			case "permanent", "self":
+				skip = true
+			case "dev":
+				if i+1 < len(fields) {
+					h.Iface = fields[i+1]
+				}
+			}
+		}
+		if !skip {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// parseLinuxLeases reads ISC dhcpd (EdgeOS) and dnsmasq lease files into
+// a MAC -> IP map. Both formats may be concatenated in out.
+//
+//	lease 10.0.0.5 { ... hardware ethernet 64:d1:54:aa:bb:cc; ... }
+//	1718000000 64:d1:54:aa:bb:cc 10.0.0.5 cam-lobby 01:64:d1:54:aa:bb:cc
+func parseLinuxLeases(out string) map[string]string {
+	leases := make(map[string]string)
+	var iscIP string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(line), ";"))
+		switch {
+		case len(fields) >= 2 && fields[0] == "lease":
+			iscIP = fields[1]
+		case len(fields) >= 3 && fields[0] == "hardware" && fields[1] == "ethernet":
+			if validIPv4(iscIP) {
+				leases[strings.ToUpper(fields[2])] = iscIP
+			}
+		case len(fields) >= 1 && fields[0] == "}":
+			iscIP = ""
+		case len(fields) >= 4 && strings.Count(fields[1], ":") == 5 && validIPv4(fields[2]):
+			leases[strings.ToUpper(fields[1])] = fields[2]
+		}
+	}
+	return leases
+}
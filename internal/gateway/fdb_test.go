@@ -0,0 +1,199 @@
+package gateway
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// routerOSBridgeHosts is `/interface bridge host print terse` from a
+// hAP with a camera and a NAS on the bridge.
+const routerOSBridgeHosts = ` 0  L   mac-address=48:8F:5A:01:02:03 on-interface=bridge bridge=bridge
+ 1 D    mac-address=64:D1:54:AA:BB:CC on-interface=ether3 bridge=bridge
+ 2 D    mac-address=00:11:32:0A:0B:0C on-interface=ether4 bridge=bridge
+ 3      mac-address=48:8F:5A:01:02:04 on-interface=ether2 bridge=bridge local=yes
+ 4 D    mac-address=01:00:5E:00:00:FB on-interface=ether3 bridge=bridge
+`
+
+// routerOSLeases is `/ip dhcp-server lease print terse` for the same
+// LAN: the camera is bound, the NAS holds a static lease.
+const routerOSLeases = ` 0   address=192.168.88.20 mac-address=64:D1:54:AA:BB:CC server=defconf active-address=192.168.88.21 status=bound
+ 1   address=192.168.88.30 mac-address=00:11:32:0A:0B:0C server=defconf status=waiting
+ 2   address=192.168.88.40 mac-address=DC:A6:32:00:00:01 server=defconf status=waiting
+`
+
+// linuxFDB is `bridge fdb show` from an EdgeRouter: the box's own ports
+// are permanent or self, and multicast groups are listed too.
+const linuxFDB = `33:33:00:00:00:01 dev eth1 self permanent
+01:00:5e:00:00:01 dev eth1 self permanent
+e0:63:da:11:22:33 dev eth1 vlan 1 master switch0 permanent
+64:d1:54:aa:bb:cc dev eth1 master switch0
+00:11:32:0a:0b:0c dev eth2 master switch0
+b8:27:eb:44:55:66 dev eth2 vlan 1 self
+`
+
+// linuxLeases is an ISC dhcpd lease file followed by a dnsmasq one.
+const linuxLeases = `lease 192.168.1.21 {
+  starts 4 2024/06/13 09:12:01;
+  hardware ethernet 64:d1:54:aa:bb:cc;
+  client-hostname "cam-lobby";
+}
+lease 192.168.1.99 {
+  hardware ethernet de:ad:be:ef:00:01;
+}
+1718000000 00:11:32:0a:0b:0c 192.168.1.30 nas 01:00:11:32:0a:0b:0c
+`
+
+func TestParseBridgeHostTerse(t *testing.T) {
+	want := []BridgeHost{
+		{MAC: "64:D1:54:AA:BB:CC", Iface: "ether3"},
+		{MAC: "00:11:32:0A:0B:0C", Iface: "ether4"},
+	}
+	if got := parseBridgeHostTerse(routerOSBridgeHosts); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseBridgeHostTerse =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseBridgeFDB(t *testing.T) {
+	want := []BridgeHost{
+		{MAC: "64:D1:54:AA:BB:CC", Iface: "eth1"},
+		{MAC: "00:11:32:0A:0B:0C", Iface: "eth2"},
+	}
+	if got := parseBridgeFDB(linuxFDB); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseBridgeFDB =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseLeases(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) map[string]string
+		out   string
+		want  map[string]string
+	}{
+		{
+			name:  "routeros active over static",
+			parse: parseLeaseTerse,
+			out:   routerOSLeases,
+			want: map[string]string{
+				"64:D1:54:AA:BB:CC": "192.168.88.21",
+				"00:11:32:0A:0B:0C": "192.168.88.30",
+				"DC:A6:32:00:00:01": "192.168.88.40",
+			},
+		},
+		{
+			name:  "isc dhcpd and dnsmasq",
+			parse: parseLinuxLeases,
+			out:   linuxLeases,
+			want: map[string]string{
+				"64:D1:54:AA:BB:CC": "192.168.1.21",
+				"DE:AD:BE:EF:00:01": "192.168.1.99",
+				"00:11:32:0A:0B:0C": "192.168.1.30",
+			},
+		},
+		{
+			name:  "dnsmasq without a hostname",
+			parse: parseLinuxLeases,
+			out:   "1718000000 00:11:32:0a:0b:0c 192.168.1.30 * *\n",
+			want:  map[string]string{"00:11:32:0A:0B:0C": "192.168.1.30"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.parse(tt.out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("leases = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func mikroTikBridge(run CommandRunner) BridgeHostReader { return newMikroTik(run) }
+
+// ubiquitiBridge returns a constructor for a Ubiquiti gateway running
+// firmware.
+func ubiquitiBridge(firmware string) func(CommandRunner) BridgeHostReader {
+	return func(run CommandRunner) BridgeHostReader { return newUbiquiti(run, SelectProfile(firmware)) }
+}
+
+func TestBridgeHosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		gw      func(CommandRunner) BridgeHostReader
+		replies map[string]string
+		want    []BridgeHost
+	}{
+		{
+			name:    "routeros",
+			gw:      mikroTikBridge,
+			replies: map[string]string{"/interface bridge host": routerOSBridgeHosts, "/ip dhcp-server lease": routerOSLeases},
+			want: []BridgeHost{
+				{MAC: "64:D1:54:AA:BB:CC", Iface: "ether3", IP: "192.168.88.21"},
+				{MAC: "00:11:32:0A:0B:0C", Iface: "ether4", IP: "192.168.88.30"},
+			},
+		},
+		{
+			name:    "routeros leases unreadable",
+			gw:      mikroTikBridge,
+			replies: map[string]string{"/interface bridge host": routerOSBridgeHosts},
+			want: []BridgeHost{
+				{MAC: "64:D1:54:AA:BB:CC", Iface: "ether3"},
+				{MAC: "00:11:32:0A:0B:0C", Iface: "ether4"},
+			},
+		},
+		{
+			name:    "edgeos",
+			gw:      ubiquitiBridge("EdgeRouter.ER-e50.v2.0.9-hotfix.7.5622731.230615.0857"),
+			replies: map[string]string{"bridge fdb show": linuxFDB, "cat /var/run/dhcpd.leases": linuxLeases},
+			want: []BridgeHost{
+				{MAC: "64:D1:54:AA:BB:CC", Iface: "eth1", IP: "192.168.1.21"},
+				{MAC: "00:11:32:0A:0B:0C", Iface: "eth2", IP: "192.168.1.30"},
+			},
+		},
+		{
+			name:    "no bridge",
+			gw:      ubiquitiBridge(""),
+			replies: map[string]string{"bridge fdb show": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := func(_ context.Context, cmd string) (string, error) {
+				for prefix, out := range tt.replies {
+					if strings.HasPrefix(cmd, prefix) {
+						return out, nil
+					}
+				}
+				return "", exitError(1)
+			}
+			got, err := tt.gw(run).BridgeHosts(context.Background())
+			if tt.want == nil {
+				if len(got) != 0 {
+					t.Errorf("BridgeHosts = %+v, %v; want none", got, err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BridgeHosts =\n%+v, %v\nwant\n%+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUbiquitiBridgeHostsMissingLeaseFile(t *testing.T) {
+	// cat exits 1 when any of the lease files is missing but still prints
+	// the ones it found.
+	g := newUbiquiti(func(_ context.Context, cmd string) (string, error) {
+		if strings.HasPrefix(cmd, "bridge fdb show") {
+			return linuxFDB, nil
+		}
+		return "1718000000 00:11:32:0a:0b:0c 192.168.1.30 nas *\n", exitError(1)
+	}, SelectProfile(""))
+	hosts, err := g.BridgeHosts(context.Background())
+	if err != nil || len(hosts) != 2 {
+		t.Fatalf("BridgeHosts = %+v, %v", hosts, err)
+	}
+	if hosts[0].IP != "" || hosts[1].IP != "192.168.1.30" {
+		t.Errorf("resolved IPs %q, %q; want none and 192.168.1.30", hosts[0].IP, hosts[1].IP)
+	}
+}
@@ -0,0 +1,155 @@
+package gateway
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// Ubiquiti command steps. Each names one way of reading state off the
+// gateway; a firmware profile orders them and leaves out the ones that
+// firmware can't run.
+const (
+	stepSystemCfg = "cat /tmp/system.cfg"
+	stepIPOneLine = "ip -o addr show"
+	stepIPAddr    = "ip addr show"
+	stepIfconfig  = "ifconfig"
+	stepNeigh     = "ip neigh show"
+	stepARP       = "arp -a"
+)
+
+// FirmwareProfile is the command set used for one family of Ubiquiti
+// firmware. Old airOS has no iproute2 and a BusyBox ping without -W, so
+// trying every strategy there wastes round trips and, worse, lets a half
+// working command pick the wrong LAN interface.
+type FirmwareProfile struct {
+	Name    string // e.g. "airos-6"; "generic" when the version is unknown
+	Version string // raw version string read from the gateway, if any
+
+	wan       []string // WAN strategies in order
+	lan       []string // LAN strategies in order
+	arp       []string // ARP table sources in order
+	pingFlags string   // flags for each sweep ping
+}
+
+// Skipped returns the steps the generic profile would try that this
+// profile leaves out.
+func (p *FirmwareProfile) Skipped() []string {
+	used := make(map[string]bool)
+	for _, list := range [][]string{p.wan, p.lan, p.arp} {
+		for _, s := range list {
+			used[s] = true
+		}
+	}
+	var skipped []string
+	for _, s := range allSteps {
+		if !used[s] {
+			skipped = append(skipped, s)
+		}
+	}
+	return skipped
+}
+
+// allSteps lists every step in the order the generic profile first uses it.
+var allSteps = []string{stepSystemCfg, stepIPOneLine, stepIfconfig, stepIPAddr, stepNeigh, stepARP}
+
+// genericProfile is the try-everything behaviour used when the firmware
+// version is unknown.
+var genericProfile = FirmwareProfile{
+	Name:      "generic",
+	wan:       []string{stepSystemCfg, stepIPAddr, stepIfconfig},
+	lan:       []string{stepSystemCfg, stepIPOneLine, stepIfconfig, stepIPAddr},
+	arp:       []string{stepNeigh, stepARP},
+	pingFlags: "-c1 -W1",
+}
+
+// firmwareProfiles maps a firmware family and major version to its
+// profile. Families without an entry for their major version use
+// genericProfile.
+var firmwareProfiles = map[string]FirmwareProfile{
+	// airOS 6: BusyBox only. No iproute2, and ping has no -W, so -w
+	// (overall deadline) bounds each probe instead.
+	"airos-6": {
+		wan:       []string{stepSystemCfg, stepIfconfig},
+		lan:       []string{stepSystemCfg, stepIfconfig},
+		arp:       []string{stepARP},
+		pingFlags: "-c1 -w1",
+	},
+	// airOS 8 ships a cut-down ip that lacks -o.
+	"airos-8": {
+		wan:       []string{stepSystemCfg, stepIfconfig, stepIPAddr},
+		lan:       []string{stepSystemCfg, stepIfconfig, stepIPAddr},
+		arp:       []string{stepNeigh, stepARP},
+		pingFlags: "-c1 -W1",
+	},
+	// EdgeOS has full iproute2 and never has /tmp/system.cfg.
+	"edgeos-1": {
+		wan:       []string{stepIPAddr, stepIfconfig},
+		lan:       []string{stepIPOneLine, stepIPAddr, stepIfconfig},
+		arp:       []string{stepNeigh, stepARP},
+		pingFlags: "-c1 -W1",
+	},
+	"edgeos-2": {
+		wan:       []string{stepIPAddr},
+		lan:       []string{stepIPOneLine, stepIPAddr},
+		arp:       []string{stepNeigh},
+		pingFlags: "-c1 -W1",
+	},
+}
+
+// firmwareVersionRe pulls the major version out of strings such as
+// "XW.ar934x.v6.3.6.33330.210818.1800" (airOS) or
+// "EdgeRouter.ER-e50.v2.0.9-hotfix.7.5622731.230615.0857" (EdgeOS).
+var firmwareVersionRe = regexp.MustCompile(`(?:^|\.)v(\d+)\.\d+`)
+
+// SelectProfile picks the command profile for a raw firmware version
+// string. Unrecognised versions get the generic profile.
+func SelectProfile(version string) FirmwareProfile {
+	version = strings.TrimSpace(version)
+	p := genericProfile
+
+	m := firmwareVersionRe.FindStringSubmatch(version)
+	if m != nil {
+		family := "airos"
+		if strings.HasPrefix(strings.ToLower(version), "edgerouter") ||
+			strings.Contains(strings.ToLower(version), "edgeos") {
+			family = "edgeos"
+		}
+		name := family + "-" + m[1]
+		if known, ok := firmwareProfiles[name]; ok {
+			p = known
+			p.Name = name
+		}
+	}
+
+	p.Version = version
+	return p
+}
+
+// readFirmwareVersion returns the first non-empty version string the
+// gateway reports, or "" if none of the usual files exist.
+func readFirmwareVersion(ctx context.Context, run CommandRunner) string {
+	for _, cmd := range []string{
+		"cat /etc/version 2>/dev/null",
+		"cat /usr/lib/version 2>/dev/null",
+	} {
+		out, err := run(ctx, cmd)
+		if err != nil {
+			continue
+		}
+		if v := strings.TrimSpace(out); v != "" && firmwareVersionRe.MatchString(v) {
+			return v
+		}
+	}
+	return ""
+}
+
+// ProfileOf returns the firmware profile a gateway was set up with.
+// Only Ubiquiti gateways have one.
+func ProfileOf(gw Gateway) (FirmwareProfile, bool) {
+	u, ok := gw.(*ubiquitiGateway)
+	if !ok {
+		return FirmwareProfile{}, false
+	}
+	return u.profile, true
+}
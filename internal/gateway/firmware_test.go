@@ -0,0 +1,130 @@
+package gateway
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectProfile(t *testing.T) {
+	tests := []struct {
+		version   string
+		want      string
+		pingFlags string
+	}{
+		{"XW.ar934x.v6.3.6.33330.210818.1800", "airos-6", "-c1 -w1"},
+		{"WA.ipq40xx.v8.7.11.46972.220614.0420", "airos-8", "-c1 -W1"},
+		{"EdgeRouter.ER-e100.v1.10.11.5274249.191030.1124", "edgeos-1", "-c1 -W1"},
+		{"EdgeRouter.ER-e50.v2.0.9-hotfix.7.5622731.230615.0857", "edgeos-2", "-c1 -W1"},
+		{"  XW.ar934x.v6.3.6.33330.210818.1800\n", "airos-6", "-c1 -w1"},
+		{"XC.qca955x.v4.0.4.31589.170307.1300", "generic", "-c1 -W1"},
+		{"EdgeRouter.ER-e50.v3.0.0", "generic", "-c1 -W1"},
+		{"", "generic", "-c1 -W1"},
+		{"not a version", "generic", "-c1 -W1"},
+	}
+	for _, tt := range tests {
+		p := SelectProfile(tt.version)
+		if p.Name != tt.want || p.pingFlags != tt.pingFlags {
+			t.Errorf("SelectProfile(%q) = %s %q, want %s %q", tt.version, p.Name, p.pingFlags, tt.want, tt.pingFlags)
+		}
+		if p.Version != strings.TrimSpace(tt.version) {
+			t.Errorf("SelectProfile(%q).Version = %q", tt.version, p.Version)
+		}
+	}
+}
+
+func TestProfileCommandSequences(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		lan     []string
+		arp     []string
+		ping    string
+		skipped []string
+	}{
+		{
+			name:    "airos-6",
+			version: "XW.ar934x.v6.3.6.33330.210818.1800",
+			lan: []string{
+				"cat /tmp/system.cfg 2>/dev/null",
+				"ifconfig eth0 2>/dev/null", "ifconfig br0 2>/dev/null", "ifconfig eth1 2>/dev/null", "ifconfig switch0 2>/dev/null",
+			},
+			arp:     []string{"arp -a 2>/dev/null"},
+			ping:    "ping -c1 -w1 192.168.1.$i",
+			skipped: []string{stepIPOneLine, stepIPAddr, stepNeigh},
+		},
+		{
+			name:    "edgeos-2",
+			version: "EdgeRouter.ER-e50.v2.0.9-hotfix.7.5622731.230615.0857",
+			lan: []string{
+				"ip -o addr show 2>/dev/null",
+				"ip addr show br0 2>/dev/null", "ip addr show eth1 2>/dev/null", "ip addr show switch0 2>/dev/null",
+			},
+			arp:     []string{"ip neigh show 2>/dev/null"},
+			ping:    "ping -c1 -W1 192.168.1.$i",
+			skipped: []string{stepSystemCfg, stepIfconfig, stepARP},
+		},
+		{
+			name:    "generic",
+			version: "",
+			lan: []string{
+				"cat /tmp/system.cfg 2>/dev/null",
+				"ip -o addr show 2>/dev/null",
+				"ifconfig eth0 2>/dev/null", "ifconfig br0 2>/dev/null", "ifconfig eth1 2>/dev/null", "ifconfig switch0 2>/dev/null",
+				"ip addr show br0 2>/dev/null", "ip addr show eth1 2>/dev/null", "ip addr show switch0 2>/dev/null",
+			},
+			arp:  []string{"ip neigh show 2>/dev/null", "arp -a 2>/dev/null"},
+			ping: "ping -c1 -W1 192.168.1.$i",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Only the version file answers, so every strategy in the
+			// profile is tried in turn.
+			var cmds []string
+			run := func(_ context.Context, cmd string) (string, error) {
+				cmds = append(cmds, cmd)
+				if strings.HasPrefix(cmd, "cat /etc/version") && tt.version != "" {
+					return tt.version + "\n", nil
+				}
+				return "", exitError(1)
+			}
+			gw, err := Detect(context.Background(), "SSH-2.0-OpenSSH_7.4", run)
+			if err != nil {
+				t.Fatalf("Detect: %v", err)
+			}
+			p, ok := ProfileOf(gw)
+			if !ok || p.Name != tt.name {
+				t.Fatalf("profile = %s (%v), want %s", p.Name, ok, tt.name)
+			}
+			if got := p.Skipped(); !reflect.DeepEqual(got, tt.skipped) {
+				t.Errorf("Skipped() = %v, want %v", got, tt.skipped)
+			}
+
+			cmds = nil
+			if _, err := gw.LANInfo(context.Background()); err == nil {
+				t.Error("LANInfo succeeded with no answers")
+			}
+			if !reflect.DeepEqual(cmds, tt.lan) {
+				t.Errorf("LANInfo ran\n%q\nwant\n%q", cmds, tt.lan)
+			}
+
+			cmds = nil
+			if _, err := gw.ARPTable(context.Background(), "192.168.1"); err == nil {
+				t.Error("ARPTable succeeded with no answers")
+			}
+			if !reflect.DeepEqual(cmds, tt.arp) {
+				t.Errorf("ARPTable ran %q, want %q", cmds, tt.arp)
+			}
+
+			cmds = nil
+			if err := gw.FloodPing(context.Background(), "192.168.1"); err != nil {
+				t.Errorf("FloodPing: %v", err)
+			}
+			if len(cmds) != 1 || !strings.Contains(cmds[0], tt.ping) {
+				t.Errorf("FloodPing ran %q, want it to use %q", cmds, tt.ping)
+			}
+		})
+	}
+}
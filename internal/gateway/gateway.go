@@ -2,8 +2,11 @@ package gateway
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"regexp"
+	"strings"
 )
 
 // Type identifies the gateway vendor.
@@ -20,6 +23,41 @@ const (
 // gateway does NOT import ssh directly.
 type CommandRunner func(ctx context.Context, cmd string) (string, error)
 
+// ErrNoOutput means a command ran but printed nothing, or only whitespace.
+// Strategy chains treat it like a failed command and move on to the next
+// fallback instead of parsing an empty string into an empty address.
+var ErrNoOutput = errors.New("command returned no output")
+
+// output runs cmd like run itself, but returns ErrNoOutput when the
+// command succeeds with blank output.
+func (run CommandRunner) output(ctx context.Context, cmd string) (string, error) {
+	out, err := run(ctx, cmd)
+	if err != nil {
+		return out, err
+	}
+	if strings.TrimSpace(out) == "" {
+		return "", fmt.Errorf("%s: %w", cmd, ErrNoOutput)
+	}
+	return out, nil
+}
+
+// validIPv4 reports whether s is a dotted-quad IPv4 address.
+func validIPv4(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil && strings.Count(s, ".") == 3
+}
+
+// exitedNonZero reports whether err only means the remote command ran and
+// exited with a non-zero status, as opposed to the session failing. Tools
+// like grep (no match) and ping (some hosts down) do this while their output
+// is still valid, so callers should parse the output rather than bail out.
+// The CommandRunner's error wraps *ssh.ExitError, matched here by its
+// ExitStatus method so gateway doesn't import ssh.
+func exitedNonZero(err error) bool {
+	var exit interface{ ExitStatus() int }
+	return errors.As(err, &exit) && exit.ExitStatus() != 0
+}
+
 // Gateway abstracts vendor-specific operations on a network gateway.
 type Gateway interface {
 	// Type returns the detected gateway vendor.
@@ -34,6 +72,10 @@ type Gateway interface {
 	// LANInfo returns the LAN-side configuration including DHCP range.
 	LANInfo(ctx context.Context) (*LANConfig, error)
 
+	// LANNetworks returns every private LAN network on the gateway, with
+	// LANInfo's pick first. Single-LAN gateways return just that one.
+	LANNetworks(ctx context.Context) ([]LANConfig, error)
+
 	// FloodPing sends a broadcast or sweep ping to populate the ARP table.
 	FloodPing(ctx context.Context, subnet string) error
 
@@ -41,6 +83,13 @@ type Gateway interface {
 	ARPTable(ctx context.Context, subnet string) ([]ARPEntry, error)
 }
 
+// RangePinger is implemented by gateways that can sweep part of a /24,
+// hosts first to last inclusive, so a scan can run the sweep in chunks
+// and report progress between them.
+type RangePinger interface {
+	FloodPingRange(ctx context.Context, subnet string, first, last int) error
+}
+
 // WANConfig holds the WAN-facing interface details.
 type WANConfig struct {
 	PublicIP      string
@@ -58,6 +107,50 @@ type LANConfig struct {
 	InterfaceName string
 }
 
+// ScanWarning returns a note when the LAN is wider than a /24. Scans
+// always cover the /24 around the gateway IP (Subnet), so on a /16 or /22
+// devices outside that segment are not found. Returns "" otherwise.
+func (c *LANConfig) ScanWarning() string {
+	_, ipnet, err := net.ParseCIDR(c.CIDR)
+	if err != nil {
+		return ""
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits != 32 || ones >= 24 {
+		return ""
+	}
+	return fmt.Sprintf("Large subnet (/%d) -- limiting scan to %s.0/24 from gateway IP", ones, c.Subnet)
+}
+
+// PoolSize returns the number of addresses in the DHCP range, or 0 if the
+// range is unknown.
+func (c *LANConfig) PoolSize() int {
+	start, end := net.ParseIP(c.DHCPStart).To4(), net.ParseIP(c.DHCPEnd).To4()
+	if start == nil || end == nil {
+		return 0
+	}
+	a := int(start[0])<<24 | int(start[1])<<16 | int(start[2])<<8 | int(start[3])
+	b := int(end[0])<<24 | int(end[1])<<16 | int(end[2])<<8 | int(end[3])
+	if b < a {
+		return 0
+	}
+	return b - a + 1
+}
+
+// appendNetwork adds n to nets unless a network with the same /24 scan
+// prefix is already there, or n has no usable prefix.
+func appendNetwork(nets []LANConfig, n LANConfig) []LANConfig {
+	if n.Subnet == "" {
+		return nets
+	}
+	for _, have := range nets {
+		if have.Subnet == n.Subnet {
+			return nets
+		}
+	}
+	return append(nets, n)
+}
+
 // ARPEntry represents a single row from the gateway ARP table.
 type ARPEntry struct {
 	IP    string
@@ -85,3 +178,18 @@ func ValidateSubnet(subnet string) error {
 	}
 	return nil
 }
+
+// validHostRange checks a first..last host range inside a /24 before it
+// goes into a sweep command.
+func validHostRange(first, last int) error {
+	if first < 1 || last > 254 || first > last {
+		return fmt.Errorf("invalid host range %d-%d: must be within 1-254", first, last)
+	}
+	return nil
+}
+
+// IsPrivateSubnet reports whether a 3-octet subnet prefix lies in the
+// RFC1918 private ranges.
+func IsPrivateSubnet(subnet string) bool {
+	return isPrivateIPv4(subnet + ".0")
+}
@@ -0,0 +1,94 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Load is one sample of how busy the gateway is. Linux-based firmware
+// reports a load average, RouterOS a CPU percentage.
+type Load struct {
+	Load1 float64 // 1-minute load average; 0 on RouterOS
+	CPU   int     // CPU use in percent; RouterOS only
+	Cores int     // 0 when unknown
+}
+
+// Busy returns the load as a fraction of capacity, where 1.0 is every
+// core fully busy. A load average is divided by the core count.
+func (l Load) Busy() float64 {
+	if l.CPU > 0 || l.Load1 == 0 {
+		return float64(l.CPU) / 100
+	}
+	return l.Load1 / float64(max(l.Cores, 1))
+}
+
+// String returns e.g. "load 1.20 on 2 cores" or "cpu 85%".
+func (l Load) String() string {
+	if l.Load1 == 0 && l.CPU > 0 {
+		return fmt.Sprintf("cpu %d%%", l.CPU)
+	}
+	if l.Cores > 0 {
+		return fmt.Sprintf("load %.2f on %d cores", l.Load1, l.Cores)
+	}
+	return fmt.Sprintf("load %.2f", l.Load1)
+}
+
+// LoadSampler is implemented by gateways that can report their load with
+// one short command, cheap enough to run around scans and during long
+// sessions.
+type LoadSampler interface {
+	SampleLoad(ctx context.Context) (Load, error)
+}
+
+// PingPacer is implemented by gateways whose sweep ping can be slowed:
+// at most batch pings in flight, with delay between batches. A batch of
+// 0 restores the full-speed sweep.
+type PingPacer interface {
+	SetPingPace(batch int, delay time.Duration)
+}
+
+// parseLoadavg reads `cat /proc/loadavg; grep -c ^processor /proc/cpuinfo`.
+// Example: "0.52 0.58 0.59 1/123 4567\n4"
+func parseLoadavg(out string) (Load, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	fields := strings.Fields(lines[0])
+	if len(fields) == 0 {
+		return Load{}, fmt.Errorf("parse loadavg: %w", ErrNoOutput)
+	}
+	load1, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Load{}, fmt.Errorf("parse loadavg: %w", err)
+	}
+	l := Load{Load1: load1}
+	if len(lines) > 1 {
+		l.Cores, _ = strconv.Atoi(strings.TrimSpace(lines[1]))
+	}
+	return l, nil
+}
+
+var (
+	cpuLoadRe  = regexp.MustCompile(`(?m)^\s*cpu-load:\s*(\d+)%`)
+	cpuCountRe = regexp.MustCompile(`(?m)^\s*cpu-count:\s*(\d+)`)
+)
+
+// parseRouterOSResource reads `/system resource print`.
+// Example lines: "cpu-count: 4", "cpu-load: 12%".
+func parseRouterOSResource(out string) (Load, error) {
+	if strings.TrimSpace(out) == "" {
+		return Load{}, fmt.Errorf("parse system resource: %w", ErrNoOutput)
+	}
+	m := cpuLoadRe.FindStringSubmatch(out)
+	if m == nil {
+		return Load{}, fmt.Errorf("parse system resource: no cpu-load")
+	}
+	l := Load{}
+	l.CPU, _ = strconv.Atoi(m[1])
+	if c := cpuCountRe.FindStringSubmatch(out); c != nil {
+		l.Cores, _ = strconv.Atoi(c[1])
+	}
+	return l, nil
+}
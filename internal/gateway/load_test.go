@@ -0,0 +1,81 @@
+package gateway
+
+import "testing"
+
+func TestParseLoadavg(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want Load
+	}{
+		{name: "with core count", out: "0.52 0.58 0.59 1/123 4567\n4\n", want: Load{Load1: 0.52, Cores: 4}},
+		{name: "no cpuinfo", out: "1.20 0.90 0.70 2/88 1234\n", want: Load{Load1: 1.2}},
+		{name: "idle", out: "0.00 0.01 0.05 1/64 321\n1\n", want: Load{Cores: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLoadavg(tt.out)
+			if err != nil {
+				t.Fatalf("parseLoadavg: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseLoadavg = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if _, err := parseLoadavg("sh: cat: not found\n"); err == nil {
+		t.Error("parseLoadavg accepted an error message")
+	}
+}
+
+// routerOSResource is a `/system resource print` from a hEX.
+const routerOSResource = `                   uptime: 3w2d4h11m
+                  version: 7.14.3 (stable)
+               build-time: 2024-04-17 12:47:58
+         factory-software: 6.44.6
+              free-memory: 187.5MiB
+             total-memory: 256.0MiB
+                      cpu: MIPS 1004Kc V2.15
+                cpu-count: 4
+            cpu-frequency: 880MHz
+                 cpu-load: 85%
+           free-hdd-space: 3.3MiB
+          total-hdd-space: 16.0MiB
+        architecture-name: mmips
+               board-name: hEX
+                 platform: MikroTik
+`
+
+func TestParseRouterOSResource(t *testing.T) {
+	got, err := parseRouterOSResource(routerOSResource)
+	if err != nil {
+		t.Fatalf("parseRouterOSResource: %v", err)
+	}
+	if want := (Load{CPU: 85, Cores: 4}); got != want {
+		t.Errorf("parseRouterOSResource = %+v, want %+v", got, want)
+	}
+	if _, err := parseRouterOSResource("bad command name resource (line 1 column 9)\n"); err == nil {
+		t.Error("parseRouterOSResource accepted output without cpu-load")
+	}
+}
+
+func TestLoadBusy(t *testing.T) {
+	tests := []struct {
+		load     Load
+		busy     float64
+		describe string
+	}{
+		{load: Load{CPU: 85, Cores: 4}, busy: 0.85, describe: "cpu 85%"},
+		{load: Load{Load1: 3, Cores: 4}, busy: 0.75, describe: "load 3.00 on 4 cores"},
+		{load: Load{Load1: 1.2}, busy: 1.2, describe: "load 1.20"},
+		{load: Load{}, busy: 0, describe: "load 0.00"},
+	}
+	for _, tt := range tests {
+		if got := tt.load.Busy(); got != tt.busy {
+			t.Errorf("%+v.Busy() = %v, want %v", tt.load, got, tt.busy)
+		}
+		if got := tt.load.String(); got != tt.describe {
+			t.Errorf("%+v.String() = %q, want %q", tt.load, got, tt.describe)
+		}
+	}
+}
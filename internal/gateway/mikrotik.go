@@ -2,13 +2,28 @@ package gateway
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 )
 
+// ErrScriptingDisabled means the gateway refused the ping sweep because the
+// login's policy doesn't allow scripting or test tools -- typically a
+// read-only account, which RouterOS answers with "not enough permissions".
+var ErrScriptingDisabled = errors.New("scripting disabled by policy")
+
 type mikrotikGateway struct {
 	run CommandRunner
+
+	// Sweep pacing set by SetPingPace; a zero batch means no pauses.
+	pingBatch int
+	pingDelay time.Duration
+
+	// RouterOS API session set by UseAPI; nil means SSH commands only.
+	// Queries it fails fall back to the SSH parsers.
+	api *apiClient
 }
 
 func newMikroTik(run CommandRunner) *mikrotikGateway {
@@ -18,6 +33,11 @@ func newMikroTik(run CommandRunner) *mikrotikGateway {
 func (g *mikrotikGateway) Type() Type { return TypeMikroTik }
 
 func (g *mikrotikGateway) Identity(ctx context.Context) (string, error) {
+	if g.api != nil {
+		if name, err := g.api.identity(ctx); err == nil {
+			return name, nil
+		}
+	}
 	out, err := g.run(ctx, "/system identity print")
 	if err != nil {
 		return "", fmt.Errorf("mikrotik identity: %w", err)
@@ -30,16 +50,21 @@ func (g *mikrotikGateway) Identity(ctx context.Context) (string, error) {
 }
 
 func (g *mikrotikGateway) WANInfo(ctx context.Context) (*WANConfig, error) {
+	if g.api != nil {
+		if cfg, err := g.api.wanInfo(ctx); err == nil {
+			return cfg, nil
+		}
+	}
 	cfg := &WANConfig{}
 
 	// Get WAN IP -- try ether1 and pppoe interfaces.
-	out, err := g.run(ctx, `/ip address print terse where interface~"ether1|pppoe"`)
+	out, err := g.run.output(ctx, `/ip address print terse where interface~"ether1|pppoe"`)
 	if err == nil {
 		cfg.PublicIP, cfg.InterfaceName = parseTerseAddress(out)
 	}
 
 	// Get default route gateway.
-	out, err = g.run(ctx, `/ip route print terse where dst-address=0.0.0.0/0`)
+	out, err = g.run.output(ctx, `/ip route print terse where dst-address=0.0.0.0/0`)
 	if err == nil {
 		cfg.Gateway = parseTerseRouteGateway(out)
 	}
@@ -51,10 +76,15 @@ func (g *mikrotikGateway) WANInfo(ctx context.Context) (*WANConfig, error) {
 }
 
 func (g *mikrotikGateway) LANInfo(ctx context.Context) (*LANConfig, error) {
+	if g.api != nil {
+		if cfg, err := g.api.lanInfo(ctx); err == nil {
+			return cfg, nil
+		}
+	}
 	cfg := &LANConfig{}
 
 	// Get LAN address -- try bridge and ether2.
-	out, err := g.run(ctx, `/ip address print terse where interface~"bridge|ether2"`)
+	out, err := g.run.output(ctx, `/ip address print terse where interface~"bridge|ether2"`)
 	if err == nil {
 		ip, iface := parseTerseAddress(out)
 		if ip != "" {
@@ -65,12 +95,12 @@ func (g *mikrotikGateway) LANInfo(ctx context.Context) (*LANConfig, error) {
 		}
 	}
 
-	if cfg.GatewayIP == "" {
+	if cfg.Subnet == "" {
 		return nil, fmt.Errorf("mikrotik LANInfo: could not determine LAN configuration")
 	}
 
 	// Get DHCP pool range.
-	out, err = g.run(ctx, `/ip pool print terse`)
+	out, err = g.run.output(ctx, `/ip pool print terse`)
 	if err == nil {
 		cfg.DHCPStart, cfg.DHCPEnd = parseTersePool(out)
 	}
@@ -78,20 +108,98 @@ func (g *mikrotikGateway) LANInfo(ctx context.Context) (*LANConfig, error) {
 	return cfg, nil
 }
 
+func (g *mikrotikGateway) LANNetworks(ctx context.Context) ([]LANConfig, error) {
+	primary, err := g.LANInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nets := []LANConfig{*primary}
+
+	// Every private address on any interface, not just the first
+	// bridge/ether2 match.
+	out, err := g.run.output(ctx, `/ip address print terse`)
+	if err != nil {
+		return nets, nil
+	}
+	// Pools only fill in the DHCP range. A failed print's output is an
+	// error message, not a pool list.
+	pools, err := g.run(ctx, `/ip pool print terse`)
+	if err != nil {
+		pools = ""
+	}
+	for _, a := range parseTerseAddresses(out) {
+		if !isPrivateIPv4(stripCIDRSuffix(a.addr)) {
+			continue
+		}
+		n := LANConfig{
+			InterfaceName: a.iface,
+			GatewayIP:     stripCIDRSuffix(a.addr),
+			CIDR:          a.addr,
+			Subnet:        subnetFromCIDR(a.addr),
+		}
+		n.DHCPStart, n.DHCPEnd = parseTersePoolFor(pools, n.Subnet)
+		nets = appendNetwork(nets, n)
+	}
+	return nets, nil
+}
+
 func (g *mikrotikGateway) FloodPing(ctx context.Context, subnet string) error {
+	// MikroTik ARP is usually already populated from DHCP leases.
+	// Run a lightweight sweep just in case -- scripted ping of the subnet.
+	err := g.FloodPingRange(ctx, subnet, 1, 254)
+	if !errors.Is(err, ErrScriptingDisabled) {
+		return err
+	}
+
+	// Scripting is forbidden for this login. A single broadcast flood-ping
+	// still makes hosts answer ARP if the "test" policy is allowed.
+	out, err := g.run(ctx, fmt.Sprintf(`/tool flood-ping %s.255 count=1`, subnet))
+	if policyDenied(out, err) {
+		return fmt.Errorf("mikrotik flood ping: %w", ErrScriptingDisabled)
+	}
+	if err != nil && !exitedNonZero(err) {
+		return fmt.Errorf("mikrotik flood ping: %w", err)
+	}
+	return nil
+}
+
+// FloodPingRange pings subnet.first to subnet.last with a script. A login
+// without the script policy gets ErrScriptingDisabled, and FloodPing's
+// broadcast fallback is left to the caller.
+func (g *mikrotikGateway) FloodPingRange(ctx context.Context, subnet string, first, last int) error {
 	if err := ValidateSubnet(subnet); err != nil {
 		return fmt.Errorf("mikrotik flood ping: %w", err)
 	}
-	// MikroTik ARP is usually already populated from DHCP leases.
-	// Run a lightweight sweep just in case -- scripted ping of the subnet.
-	cmd := fmt.Sprintf(`:for i from=1 to=254 do={/ping %s.$i count=1 interval=0.1}`, subnet)
-	_, err := g.run(ctx, cmd)
-	if err != nil {
+	if err := validHostRange(first, last); err != nil {
+		return fmt.Errorf("mikrotik flood ping: %w", err)
+	}
+	cmd := fmt.Sprintf(`:for i from=%d to=%d do={/ping %s.$i count=1 interval=0.1}`, first, last, subnet)
+	if g.pingBatch > 0 {
+		cmd = fmt.Sprintf(`:for i from=%d to=%d do={/ping %s.$i count=1 interval=0.1; :if ($i %% %d = 0) do={:delay %dms}}`,
+			first, last, subnet, g.pingBatch, g.pingDelay.Milliseconds())
+	}
+	// A non-zero exit past the policy check just means some pings went
+	// unanswered.
+	out, err := g.run(ctx, cmd)
+	if policyDenied(out, err) {
+		return fmt.Errorf("mikrotik flood ping: %w", ErrScriptingDisabled)
+	}
+	if err != nil && !exitedNonZero(err) {
 		return fmt.Errorf("mikrotik flood ping: %w", err)
 	}
 	return nil
 }
 
+// policyDenied reports whether RouterOS rejected a command because the
+// user's group policy doesn't permit it.
+func policyDenied(out string, err error) bool {
+	s := strings.ToLower(out)
+	if err != nil {
+		s += " " + strings.ToLower(err.Error())
+	}
+	return strings.Contains(s, "policy") || strings.Contains(s, "not enough permissions")
+}
+
 // arpTerseRe matches terse ARP entries.
 // Example line: " 0 DH 10.0.0.2 AA:BB:CC:DD:EE:FF bridge1"
 // Fields: index, flags, address, mac-address, interface
@@ -105,6 +213,11 @@ func (g *mikrotikGateway) ARPTable(ctx context.Context, subnet string) ([]ARPEnt
 			return nil, fmt.Errorf("mikrotik ARP: %w", err)
 		}
 	}
+	if g.api != nil {
+		if entries, err := g.api.arpTable(ctx, subnet); err == nil {
+			return entries, nil
+		}
+	}
 	out, err := g.run(ctx, `/ip arp print terse where !invalid`)
 	if err != nil {
 		return nil, fmt.Errorf("mikrotik ARP: %w", err)
@@ -148,7 +261,7 @@ func parseTerseAddress(out string) (addr, iface string) {
 			if k, v, ok := strings.Cut(field, "="); ok {
 				switch k {
 				case "address":
-					if addr == "" {
+					if addr == "" && validIPv4(stripCIDRSuffix(v)) {
 						addr = v
 					}
 				case "interface":
@@ -165,6 +278,40 @@ func parseTerseAddress(out string) (addr, iface string) {
 	return "", ""
 }
 
+// terseAddress is one address= / interface= pair from terse output.
+type terseAddress struct {
+	addr  string
+	iface string
+}
+
+// parseTerseAddresses extracts every address= and interface= pair from
+// terse output, one per line, skipping disabled (X) entries.
+func parseTerseAddresses(out string) []terseAddress {
+	var result []terseAddress
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		var a terseAddress
+		for i, field := range fields {
+			if i == 1 && strings.Contains(field, "X") && !strings.Contains(field, "=") {
+				a = terseAddress{}
+				break
+			}
+			if k, v, ok := strings.Cut(field, "="); ok {
+				switch k {
+				case "address":
+					a.addr = v
+				case "interface":
+					a.iface = v
+				}
+			}
+		}
+		if validIPv4(stripCIDRSuffix(a.addr)) {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
 // parseTerseRouteGateway extracts gateway= from terse route output.
 func parseTerseRouteGateway(out string) string {
 	for _, line := range strings.Split(out, "\n") {
@@ -193,6 +340,24 @@ func parseTersePool(out string) (start, end string) {
 	return "", ""
 }
 
+// parseTersePoolFor returns the first pool range inside subnet (a
+// 3-octet prefix such as "10.0.20"), or empty strings if none is.
+func parseTersePoolFor(out, subnet string) (start, end string) {
+	for _, line := range strings.Split(out, "\n") {
+		for _, field := range strings.Fields(line) {
+			k, v, ok := strings.Cut(field, "=")
+			if !ok || k != "ranges" || !strings.HasPrefix(v, subnet+".") {
+				continue
+			}
+			if s, e, ok := strings.Cut(v, "-"); ok {
+				return s, e
+			}
+			return v, ""
+		}
+	}
+	return "", ""
+}
+
 // stripCIDRSuffix removes the /prefix from an address like "10.0.0.1/24".
 func stripCIDRSuffix(addr string) string {
 	ip, _, _ := strings.Cut(addr, "/")
@@ -200,13 +365,18 @@ func stripCIDRSuffix(addr string) string {
 }
 
 // subnetFromCIDR extracts the first 3 octets from "10.0.0.1/24" -> "10.0.0".
+// Blank or malformed input gives "" rather than a fragment that would
+// later be formatted into an address like ".0/24".
 func subnetFromCIDR(cidr string) string {
-	ip := stripCIDRSuffix(cidr)
-	parts := strings.Split(ip, ".")
-	if len(parts) >= 3 {
-		return strings.Join(parts[:3], ".")
+	parts := strings.Split(stripCIDRSuffix(strings.TrimSpace(cidr)), ".")
+	if len(parts) < 3 {
+		return ""
 	}
-	return ip
+	subnet := strings.Join(parts[:3], ".")
+	if ValidateSubnet(subnet) != nil {
+		return ""
+	}
+	return subnet
 }
 
 // Fallback regexes for non-standard terse output.
@@ -239,3 +409,36 @@ func parseTerseARPFallback(out, subnet string) []ARPEntry {
 	}
 	return entries
 }
+
+// SampleLoad reads CPU load and count from the system resource table.
+func (g *mikrotikGateway) SampleLoad(ctx context.Context) (Load, error) {
+	out, err := g.run(ctx, "/system resource print")
+	if err != nil {
+		return Load{}, fmt.Errorf("mikrotik load: %w", err)
+	}
+	return parseRouterOSResource(out)
+}
+
+// BridgeHosts reads the bridge host table and resolves addresses from the
+// DHCP server's leases. A gateway without a bridge returns no hosts.
+func (g *mikrotikGateway) BridgeHosts(ctx context.Context) ([]BridgeHost, error) {
+	out, err := g.run(ctx, "/interface bridge host print terse where !local")
+	if err != nil {
+		return nil, fmt.Errorf("mikrotik bridge hosts: %w", err)
+	}
+	hosts := parseBridgeHostTerse(out)
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+	leases, err := g.run(ctx, "/ip dhcp-server lease print terse")
+	if err != nil {
+		return hosts, nil
+	}
+	return resolveLeases(hosts, parseLeaseTerse(leases)), nil
+}
+
+// SetPingPace slows later FloodPing sweeps.
+func (g *mikrotikGateway) SetPingPace(batch int, delay time.Duration) {
+	g.pingBatch = batch
+	g.pingDelay = delay
+}
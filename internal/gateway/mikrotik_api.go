@@ -0,0 +1,408 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dialer opens a connection as seen from the gateway. This is provided by
+// the ssh package (Client.Dial) -- gateway does NOT import ssh directly.
+type Dialer func(network, addr string) (net.Conn, error)
+
+// The RouterOS API is dialled on the gateway's own loopback through the
+// SSH connection, so the plain port is already inside an encrypted,
+// authenticated channel and the TLS port (8729) adds nothing.
+const (
+	apiAddr    = "127.0.0.1:8728"
+	apiTimeout = 5 * time.Second
+)
+
+// errAPIBroken means an earlier call lost the API connection; every later
+// call falls back to SSH commands.
+var errAPIBroken = errors.New("routeros api: connection lost")
+
+// UseAPI switches a MikroTik gateway to the RouterOS API for identity,
+// LAN, WAN and ARP queries. It returns an error, and the gateway keeps
+// using SSH commands, when the API service is disabled or refuses user
+// and password. Other gateway types are left alone and return false.
+// The API connection runs through dial and closes with it.
+func UseAPI(ctx context.Context, gw Gateway, dial Dialer, user, password string) (bool, error) {
+	g, ok := gw.(*mikrotikGateway)
+	if !ok {
+		return false, nil
+	}
+	conn, err := dial("tcp", apiAddr)
+	if err != nil {
+		return false, fmt.Errorf("routeros api: %w", err)
+	}
+	api := newAPIClient(conn)
+	if err := api.login(ctx, user, password); err != nil {
+		conn.Close()
+		return false, err
+	}
+	g.api = api
+	return true, nil
+}
+
+// KeepAPI moves a RouterOS API session from one MikroTik gateway value to
+// another, so detection can be re-run on a live connection without the
+// password. It does nothing unless both are MikroTik.
+func KeepAPI(from, to Gateway) {
+	f, ok := from.(*mikrotikGateway)
+	t, ok2 := to.(*mikrotikGateway)
+	if ok && ok2 && t.api == nil {
+		t.api = f.api
+	}
+}
+
+// Backend names how a gateway is queried: "api" once UseAPI succeeded,
+// "ssh" otherwise.
+func Backend(gw Gateway) string {
+	if g, ok := gw.(*mikrotikGateway); ok && g.api != nil && !g.api.isBroken() {
+		return "api"
+	}
+	return "ssh"
+}
+
+// apiClient speaks the RouterOS API: sentences of length-prefixed words,
+// each sentence ended by an empty word. Replies are zero or more !re
+// sentences, possibly a !trap, then !done -- or !fatal, after which the
+// router closes the connection. Calls are serialised.
+type apiClient struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	r      *bufio.Reader
+	broken bool
+}
+
+func newAPIClient(conn net.Conn) *apiClient {
+	return &apiClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *apiClient) isBroken() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.broken
+}
+
+// login authenticates with the post-6.43 plain login, then answers the
+// MD5 challenge older RouterOS sends back in =ret= instead of logging in.
+func (c *apiClient) login(ctx context.Context, user, password string) error {
+	done, err := c.call(ctx, "/login", "=name="+user, "=password="+password)
+	if err != nil {
+		return fmt.Errorf("routeros api login: %w", err)
+	}
+	challenge, ok := done["ret"]
+	if !ok {
+		return nil
+	}
+	raw, err := hex.DecodeString(challenge)
+	if err != nil {
+		return fmt.Errorf("routeros api login: bad challenge %q", challenge)
+	}
+	sum := md5.Sum(append(append([]byte{0}, password...), raw...))
+	if _, err := c.call(ctx, "/login", "=name="+user, "=response=00"+hex.EncodeToString(sum[:])); err != nil {
+		return fmt.Errorf("routeros api login: %w", err)
+	}
+	return nil
+}
+
+// call runs one command and returns the attributes of its !done sentence.
+func (c *apiClient) call(ctx context.Context, command string, args ...string) (map[string]string, error) {
+	_, done, err := c.exchange(ctx, command, args...)
+	return done, err
+}
+
+// query runs one print command and returns its rows.
+func (c *apiClient) query(ctx context.Context, command string, args ...string) ([]map[string]string, error) {
+	rows, _, err := c.exchange(ctx, command, args...)
+	return rows, err
+}
+
+// exchange sends one sentence and reads the reply up to !done. A !trap is
+// returned as an error once !done arrives, so the stream stays in step;
+// I/O errors and !fatal mark the client broken.
+func (c *apiClient) exchange(ctx context.Context, command string, args ...string) (rows []map[string]string, done map[string]string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.broken {
+		return nil, nil, errAPIBroken
+	}
+
+	// The connection is an SSH channel, which has no deadlines, so a
+	// router that stops answering is cut off by closing it under the read.
+	ctx, cancel := context.WithTimeout(ctx, apiTimeout)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { c.conn.Close() })
+	defer func() {
+		closed := !stop()
+		if err != nil && ctx.Err() != nil {
+			err = fmt.Errorf("routeros api: %w", ctx.Err())
+		}
+		if closed || err != nil && !errors.Is(err, errAPITrap) {
+			c.broken = true
+			c.conn.Close()
+		}
+	}()
+
+	if err := writeSentence(c.conn, append([]string{command}, args...)); err != nil {
+		return nil, nil, fmt.Errorf("routeros api: %w", err)
+	}
+	var trap error
+	for {
+		words, err := readSentence(c.r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("routeros api: %w", err)
+		}
+		if len(words) == 0 {
+			continue
+		}
+		attrs := sentenceAttrs(words[1:])
+		switch words[0] {
+		case "!re":
+			rows = append(rows, attrs)
+		case "!trap":
+			if trap == nil {
+				trap = fmt.Errorf("%w: %s", errAPITrap, attrs["message"])
+			}
+		case "!done":
+			if trap != nil {
+				return nil, nil, trap
+			}
+			return rows, attrs, nil
+		case "!fatal":
+			msg := strings.Join(words[1:], " ")
+			return nil, nil, fmt.Errorf("routeros api: fatal: %s", msg)
+		}
+	}
+}
+
+// errAPITrap wraps a command the router refused, e.g. a login failure or
+// an unknown path. The connection is still usable afterwards.
+var errAPITrap = errors.New("routeros api: trap")
+
+// sentenceAttrs turns "=key=value" words into a map, ignoring tags and
+// any other words.
+func sentenceAttrs(words []string) map[string]string {
+	attrs := make(map[string]string, len(words))
+	for _, w := range words {
+		if !strings.HasPrefix(w, "=") {
+			continue
+		}
+		if k, v, ok := strings.Cut(w[1:], "="); ok {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+// writeSentence writes words followed by the empty word that ends a
+// sentence.
+func writeSentence(w io.Writer, words []string) error {
+	var buf []byte
+	for _, word := range words {
+		buf = append(buf, encodeLength(len(word))...)
+		buf = append(buf, word...)
+	}
+	buf = append(buf, 0)
+	_, err := w.Write(buf)
+	return err
+}
+
+// readSentence reads words up to the empty word that ends a sentence.
+func readSentence(r *bufio.Reader) ([]string, error) {
+	var words []string
+	for {
+		n, err := readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return words, nil
+		}
+		word := make([]byte, n)
+		if _, err := io.ReadFull(r, word); err != nil {
+			return nil, err
+		}
+		words = append(words, string(word))
+	}
+}
+
+// encodeLength encodes a word length: one byte below 0x80, then two,
+// three and four bytes with 10, 110 and 1110 high-bit markers, and a 0xF0
+// byte followed by four bytes beyond that.
+func encodeLength(n int) []byte {
+	switch {
+	case n < 0x80:
+		return []byte{byte(n)}
+	case n < 0x4000:
+		return []byte{byte(n>>8) | 0x80, byte(n)}
+	case n < 0x200000:
+		return []byte{byte(n>>16) | 0xC0, byte(n >> 8), byte(n)}
+	case n < 0x10000000:
+		return []byte{byte(n>>24) | 0xE0, byte(n >> 16), byte(n >> 8), byte(n)}
+	default:
+		return []byte{0xF0, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+}
+
+// readLength decodes a word length written by encodeLength.
+func readLength(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	var extra int
+	n := int(b)
+	switch {
+	case b&0x80 == 0:
+		return n, nil
+	case b&0xC0 == 0x80:
+		n, extra = n&0x3F, 1
+	case b&0xE0 == 0xC0:
+		n, extra = n&0x1F, 2
+	case b&0xF0 == 0xE0:
+		n, extra = n&0x0F, 3
+	case b == 0xF0:
+		n, extra = 0, 4
+	default:
+		return 0, fmt.Errorf("bad word length byte %#x", b)
+	}
+	for i := 0; i < extra; i++ {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n = n<<8 | int(c)
+	}
+	return n, nil
+}
+
+// ---------------------------------------------------------------------------
+// API-backed queries, used by mikrotikGateway before its SSH parsers
+// ---------------------------------------------------------------------------
+
+// Interface patterns matching the SSH commands' `where interface~"..."`.
+var (
+	apiLANIfaceRe = regexp.MustCompile(`bridge|ether2`)
+	apiWANIfaceRe = regexp.MustCompile(`ether1|pppoe`)
+)
+
+func (c *apiClient) identity(ctx context.Context) (string, error) {
+	rows, err := c.query(ctx, "/system/identity/print")
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 || rows[0]["name"] == "" {
+		return "", fmt.Errorf("routeros api identity: %w", ErrNoOutput)
+	}
+	return rows[0]["name"], nil
+}
+
+// address returns the first enabled address on an interface matching re.
+func (c *apiClient) address(ctx context.Context, re *regexp.Regexp) (addr, iface string, err error) {
+	rows, err := c.query(ctx, "/ip/address/print")
+	if err != nil {
+		return "", "", err
+	}
+	for _, row := range rows {
+		if row["disabled"] == "true" || row["invalid"] == "true" {
+			continue
+		}
+		if re.MatchString(row["interface"]) && validIPv4(stripCIDRSuffix(row["address"])) {
+			return row["address"], row["interface"], nil
+		}
+	}
+	return "", "", fmt.Errorf("routeros api address: %w", ErrNoOutput)
+}
+
+func (c *apiClient) lanInfo(ctx context.Context) (*LANConfig, error) {
+	addr, iface, err := c.address(ctx, apiLANIfaceRe)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &LANConfig{
+		InterfaceName: iface,
+		GatewayIP:     stripCIDRSuffix(addr),
+		CIDR:          addr,
+		Subnet:        subnetFromCIDR(addr),
+	}
+	if cfg.Subnet == "" {
+		return nil, fmt.Errorf("routeros api LAN: bad address %q", addr)
+	}
+	if pools, err := c.query(ctx, "/ip/pool/print"); err == nil && len(pools) > 0 {
+		if s, e, ok := strings.Cut(pools[0]["ranges"], "-"); ok {
+			cfg.DHCPStart, cfg.DHCPEnd = s, e
+		} else {
+			cfg.DHCPStart = pools[0]["ranges"]
+		}
+	}
+	return cfg, nil
+}
+
+func (c *apiClient) wanInfo(ctx context.Context) (*WANConfig, error) {
+	cfg := &WANConfig{}
+	cfg.PublicIP, cfg.InterfaceName, _ = c.address(ctx, apiWANIfaceRe)
+	routes, err := c.query(ctx, "/ip/route/print", "?dst-address=0.0.0.0/0")
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range routes {
+		if r["gateway"] != "" {
+			cfg.Gateway = r["gateway"]
+			break
+		}
+	}
+	if cfg.PublicIP == "" && cfg.Gateway == "" {
+		return nil, fmt.Errorf("routeros api WAN: %w", ErrNoOutput)
+	}
+	return cfg, nil
+}
+
+func (c *apiClient) arpTable(ctx context.Context, subnet string) ([]ARPEntry, error) {
+	rows, err := c.query(ctx, "/ip/arp/print")
+	if err != nil {
+		return nil, err
+	}
+	var entries []ARPEntry
+	for _, row := range rows {
+		ip, mac := row["address"], row["mac-address"]
+		if row["invalid"] == "true" || ip == "" || mac == "" {
+			continue
+		}
+		if subnet != "" && !strings.HasPrefix(ip, subnet+".") {
+			continue
+		}
+		entries = append(entries, ARPEntry{
+			Flags: apiARPFlags(row),
+			IP:    ip,
+			MAC:   strings.ToUpper(mac),
+			Iface: row["interface"],
+		})
+	}
+	return entries, nil
+}
+
+// apiARPFlags rebuilds the flag letters terse output prints, e.g. "DH"
+// for a dynamic entry learned from a DHCP lease.
+func apiARPFlags(row map[string]string) string {
+	var flags string
+	for _, f := range []struct{ key, letter string }{
+		{"dynamic", "D"}, {"dhcp", "H"}, {"complete", "C"}, {"published", "P"},
+	} {
+		if row[f.key] == "true" {
+			flags += f.letter
+		}
+	}
+	return flags
+}
@@ -0,0 +1,177 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// noDeadlineConn fails SetDeadline like an SSH channel does.
+type noDeadlineConn struct {
+	net.Conn
+}
+
+func (noDeadlineConn) SetDeadline(time.Time) error {
+	return errors.New("deadline not supported")
+}
+
+func TestAPIExchange(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	api := newAPIClient(noDeadlineConn{client})
+
+	go func() {
+		r := bufio.NewReader(server)
+		if _, err := readSentence(r); err != nil {
+			return
+		}
+		writeSentence(server, []string{"!re", "=name=gw-lobby"})
+		writeSentence(server, []string{"!done"})
+	}()
+
+	got, err := api.identity(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "gw-lobby" {
+		t.Errorf("identity = %q, want gw-lobby", got)
+	}
+	if api.isBroken() {
+		t.Error("client marked broken after a good exchange")
+	}
+}
+
+func TestAPIExchangeSilentRouter(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	api := newAPIClient(noDeadlineConn{client})
+
+	// Read the request but never answer.
+	go func() {
+		readSentence(bufio.NewReader(server))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := api.query(ctx, "/ip/arp/print")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("query took %s after its context ended", d)
+	}
+	if !api.isBroken() {
+		t.Error("client not marked broken after a stalled exchange")
+	}
+	if _, err := api.query(context.Background(), "/ip/arp/print"); !errors.Is(err, errAPIBroken) {
+		t.Errorf("later query err = %v, want errAPIBroken", err)
+	}
+}
+
+func TestAPIExchangeTrap(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	api := newAPIClient(noDeadlineConn{client})
+
+	go func() {
+		r := bufio.NewReader(server)
+		readSentence(r)
+		writeSentence(server, []string{"!trap", "=message=no such command"})
+		writeSentence(server, []string{"!done"})
+	}()
+
+	_, err := api.query(context.Background(), "/nope")
+	if !errors.Is(err, errAPITrap) {
+		t.Fatalf("err = %v, want trap", err)
+	}
+	if api.isBroken() {
+		t.Error("a trap marked the client broken")
+	}
+}
+
+func TestAPIWordLength(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{0x7F, []byte{0x7F}},
+		{0x80, []byte{0x80, 0x80}},
+		{0x3FFF, []byte{0xBF, 0xFF}},
+		{0x4000, []byte{0xC0, 0x40, 0x00}},
+		{0x1FFFFF, []byte{0xDF, 0xFF, 0xFF}},
+		{0x200000, []byte{0xE0, 0x20, 0x00, 0x00}},
+		{0xFFFFFFF, []byte{0xEF, 0xFF, 0xFF, 0xFF}},
+		{0x10000000, []byte{0xF0, 0x10, 0x00, 0x00, 0x00}},
+	}
+	for _, tt := range tests {
+		got := encodeLength(tt.n)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("encodeLength(%#x) = % x, want % x", tt.n, got, tt.want)
+		}
+		n, err := readLength(bufio.NewReader(bytes.NewReader(got)))
+		if err != nil || n != tt.n {
+			t.Errorf("readLength(% x) = %#x, %v; want %#x", got, n, err, tt.n)
+		}
+	}
+	if _, err := readLength(bufio.NewReader(bytes.NewReader([]byte{0xF8}))); err == nil {
+		t.Error("readLength accepted a reserved control byte")
+	}
+}
+
+func TestAPILogin(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies [][]string
+		want    [][]string
+	}{
+		{
+			name:    "plain login",
+			replies: [][]string{{"!done"}},
+			want:    [][]string{{"/login", "=name=admin", "=password=secret"}},
+		},
+		{
+			// Recorded from RouterOS 6.42, which answers the plain login
+			// with an MD5 challenge.
+			name:    "challenge",
+			replies: [][]string{{"!done", "=ret=ebddd18303a54111e2dea05a92ab46b4"}, {"!done"}},
+			want: [][]string{
+				{"/login", "=name=admin", "=password=secret"},
+				{"/login", "=name=admin", "=response=007319531c22b6b85e160d6ac355c1df2e"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+			api := newAPIClient(noDeadlineConn{client})
+
+			var got [][]string
+			go func() {
+				r := bufio.NewReader(server)
+				for _, reply := range tt.replies {
+					words, err := readSentence(r)
+					if err != nil {
+						return
+					}
+					got = append(got, words)
+					writeSentence(server, reply)
+				}
+			}()
+
+			if err := api.login(context.Background(), "admin", "secret"); err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// exitError mimics ssh.ExitError: the command ran and exited non-zero.
+type exitError int
+
+func (e exitError) Error() string   { return fmt.Sprintf("Process exited with status %d", int(e)) }
+func (e exitError) ExitStatus() int { return int(e) }
+
+func TestMikroTikFloodPingExit(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		err  error
+		want error // nil, ErrScriptingDisabled, or any error (errRunner)
+	}{
+		{name: "all answered", out: "", err: nil},
+		{name: "some unanswered", out: "  SEQ HOST  SIZE TTL TIME  STATUS\n    0 10.0.0.9  timeout\n", err: exitError(1)},
+		{name: "policy in output", out: "failure: not enough permissions (9)", err: exitError(1), want: ErrScriptingDisabled},
+		{name: "session failed", err: errRunner, want: errRunner},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newMikroTik(func(context.Context, string) (string, error) { return tt.out, tt.err })
+			err := g.FloodPingRange(context.Background(), "10.0.0", 1, 254)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("FloodPingRange = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("FloodPingRange = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+var errRunner = errors.New("ssh: session closed")
+
+func TestMikroTikLANNetworksPoolFailure(t *testing.T) {
+	run := func(_ context.Context, cmd string) (string, error) {
+		switch {
+		case strings.HasPrefix(cmd, `/ip address print terse`):
+			return " 0   address=192.168.88.1/24 network=192.168.88.0 interface=bridge\n", nil
+		case strings.HasPrefix(cmd, `/ip pool print terse`):
+			return "ranges=192.168.88.10-192.168.88.254 bad command name pool", exitError(1)
+		}
+		return "", exitError(1)
+	}
+	g := newMikroTik(run)
+	nets, err := g.LANNetworks(context.Background())
+	if err != nil {
+		t.Fatalf("LANNetworks: %v", err)
+	}
+	for _, n := range nets {
+		if n.DHCPStart != "" || n.DHCPEnd != "" {
+			t.Errorf("%s: DHCP range %s-%s parsed from a failed pool print", n.CIDR, n.DHCPStart, n.DHCPEnd)
+		}
+	}
+}
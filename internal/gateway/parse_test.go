@@ -0,0 +1,145 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// blankOutputs are what a command that printed nothing can come back as.
+var blankOutputs = []string{"", " ", "\n", "\r\n\r\n", " \t\n  \n"}
+
+func TestParsersBlankOutput(t *testing.T) {
+	// Each family returns its zero value for blank output: no address,
+	// no entries, nothing a caller could mistake for data.
+	pairs := func(a, b string) []string { return []string{a, b} }
+	parsers := []struct {
+		name  string
+		parse func(out string) any
+	}{
+		// MikroTik terse prints.
+		{"parseTerseAddress", func(out string) any { return pairs(parseTerseAddress(out)) }},
+		{"parseTerseAddresses", func(out string) any { return parseTerseAddresses(out) }},
+		{"parseTerseRouteGateway", func(out string) any { return parseTerseRouteGateway(out) }},
+		{"parseTersePool", func(out string) any { return pairs(parseTersePool(out)) }},
+		{"parseTersePoolFor", func(out string) any { return pairs(parseTersePoolFor(out, "192.168.88")) }},
+		{"parseTerseARPFallback", func(out string) any { return parseTerseARPFallback(out, "") }},
+		{"subnetFromCIDR", func(out string) any { return subnetFromCIDR(out) }},
+
+		// Linux and BusyBox tools.
+		{"parseNeigh", func(out string) any { return parseNeigh(out, "") }},
+		{"parseNeighFallback", func(out string) any { return parseNeighFallback(out, "") }},
+		{"parseBusyBoxARP", func(out string) any { return parseBusyBoxARP(out, "") }},
+		{"parseLinuxInetAddr", func(out string) any { return parseLinuxInetAddr(out) }},
+		{"parseLinuxDefaultGateway", func(out string) any { return parseLinuxDefaultGateway(out) }},
+		{"parseIfconfigInetAddr", func(out string) any { return parseIfconfigInetAddr(out) }},
+		{"parseIfconfigMask", func(out string) any { return parseIfconfigMask(out) }},
+
+		// airOS system.cfg and EdgeOS config.
+		{"parseSystemCfgWAN", func(out string) any { return pairs(parseSystemCfgWAN(out)) }},
+		{"parseSystemCfgLAN", func(out string) any {
+			iface, ip, mask := parseSystemCfgLAN(out)
+			return []string{iface, ip, mask}
+		}},
+		{"parseSystemCfgDHCP", func(out string) any { return pairs(parseSystemCfgDHCP(out)) }},
+		{"parseDnsmasqRange", func(out string) any { return pairs(parseDnsmasqRange(out)) }},
+		{"parseConfigBootDHCP", func(out string) any { return pairs(parseConfigBootDHCP(out, "192.168.1")) }},
+
+		// Bridge hosts and leases.
+		{"parseBridgeHostTerse", func(out string) any { return parseBridgeHostTerse(out) }},
+		{"parseBridgeFDB", func(out string) any { return parseBridgeFDB(out) }},
+		{"parseLeaseTerse", func(out string) any { return parseLeaseTerse(out) }},
+		{"parseLinuxLeases", func(out string) any { return parseLinuxLeases(out) }},
+	}
+	for _, p := range parsers {
+		for _, out := range blankOutputs {
+			if got := p.parse(out); !isZero(got) {
+				t.Errorf("%s(%q) = %#v, want nothing", p.name, out, got)
+			}
+		}
+	}
+}
+
+// isZero reports whether a parser result holds no data.
+func isZero(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return v == ""
+	case []string:
+		return strings.Join(v, "") == ""
+	case []ARPEntry:
+		return len(v) == 0
+	case []terseAddress:
+		return len(v) == 0
+	case []BridgeHost:
+		return len(v) == 0
+	case map[string]string:
+		return len(v) == 0
+	}
+	return false
+}
+
+func TestLoadParsersBlankOutput(t *testing.T) {
+	for _, out := range blankOutputs {
+		if _, err := parseLoadavg(out); !errors.Is(err, ErrNoOutput) {
+			t.Errorf("parseLoadavg(%q) = %v, want ErrNoOutput", out, err)
+		}
+		if _, err := parseRouterOSResource(out); !errors.Is(err, ErrNoOutput) {
+			t.Errorf("parseRouterOSResource(%q) = %v, want ErrNoOutput", out, err)
+		}
+	}
+}
+
+func TestOutputNoData(t *testing.T) {
+	for _, out := range blankOutputs {
+		run := CommandRunner(func(context.Context, string) (string, error) { return out, nil })
+		if _, err := run.output(context.Background(), "ip -o addr show"); !errors.Is(err, ErrNoOutput) {
+			t.Errorf("output(%q) = %v, want ErrNoOutput", out, err)
+		}
+	}
+	run := CommandRunner(func(context.Context, string) (string, error) { return " 0 name: gw\n", nil })
+	if out, err := run.output(context.Background(), "/system identity print"); err != nil || out != " 0 name: gw\n" {
+		t.Errorf("output = %q, %v; want the output unchanged", out, err)
+	}
+}
+
+func TestStrategyChainsSkipBlankOutput(t *testing.T) {
+	// Every command succeeds but prints only whitespace. No strategy may
+	// turn that into a LAN, least of all a ".0/24" one.
+	run := func(context.Context, string) (string, error) { return " \n", nil }
+	gateways := []struct {
+		name string
+		gw   Gateway
+	}{
+		{"mikrotik", newMikroTik(run)},
+		{"ubiquiti generic", newUbiquiti(run, SelectProfile(""))},
+		{"airos-6", newUbiquiti(run, SelectProfile("XW.ar934x.v6.3.6.33330.210818.1800"))},
+		{"edgeos-2", newUbiquiti(run, SelectProfile("EdgeRouter.ER-e50.v2.0.9-hotfix.7.5622731.230615.0857"))},
+	}
+	ctx := context.Background()
+	for _, g := range gateways {
+		t.Run(g.name, func(t *testing.T) {
+			if lan, err := g.gw.LANInfo(ctx); err == nil {
+				t.Errorf("LANInfo = %+v, want an error", lan)
+			}
+			if nets, err := g.gw.LANNetworks(ctx); err == nil {
+				t.Errorf("LANNetworks = %+v, want an error", nets)
+			}
+			if wan, err := g.gw.WANInfo(ctx); err == nil {
+				t.Errorf("WANInfo = %+v, want an error", wan)
+			}
+			if arp, _ := g.gw.ARPTable(ctx, ""); len(arp) != 0 {
+				t.Errorf("ARPTable = %+v, want no entries", arp)
+			}
+		})
+	}
+}
+
+func TestAppendNetworkSkipsBlankSubnet(t *testing.T) {
+	nets := []LANConfig{{Subnet: "192.168.88", CIDR: "192.168.88.1/24"}}
+	nets = appendNetwork(nets, LANConfig{CIDR: " ", Subnet: subnetFromCIDR(" ")})
+	if len(nets) != 1 {
+		t.Errorf("appendNetwork added a network with no subnet: %+v", nets)
+	}
+}
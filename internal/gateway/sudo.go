@@ -0,0 +1,28 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// UseSudo switches a Linux-based gateway to run its commands through
+// sudo, with run doing the elevation, for accounts that need it to read
+// ARP or firewall state. It first checks that sudo exists and accepts
+// run's password; on any error the gateway keeps its plain runner.
+// RouterOS has no sudo or enable mode, so other gateway types are left
+// alone and return false.
+func UseSudo(ctx context.Context, gw Gateway, run CommandRunner) (bool, error) {
+	g, ok := gw.(*ubiquitiGateway)
+	if !ok {
+		return false, nil
+	}
+	if out, err := g.run(ctx, "command -v sudo"); err != nil || strings.TrimSpace(out) == "" {
+		return false, fmt.Errorf("sudo: not installed on the gateway")
+	}
+	if _, err := run(ctx, "true"); err != nil {
+		return false, fmt.Errorf("sudo: %w", err)
+	}
+	g.run = run
+	return true, nil
+}
@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestUseSudo(t *testing.T) {
+	sudoDenied := errors.New("Process exited with status 1: sudo: 1 incorrect password attempt")
+	tests := []struct {
+		name    string
+		mikro   bool
+		hasSudo bool
+		elevErr error
+		want    bool
+		wantErr bool
+	}{
+		{name: "routeros has no sudo", mikro: true, hasSudo: true},
+		{name: "not installed", wantErr: true},
+		{name: "password refused", hasSudo: true, elevErr: sudoDenied, wantErr: true},
+		{name: "elevated", hasSudo: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var plain, elevated []string
+			run := func(_ context.Context, cmd string) (string, error) {
+				plain = append(plain, cmd)
+				if cmd == "command -v sudo" {
+					if !tt.hasSudo {
+						return "", exitError(1)
+					}
+					return "/usr/bin/sudo\n", nil
+				}
+				return "gw-lab\n", nil
+			}
+			sudo := func(_ context.Context, cmd string) (string, error) {
+				elevated = append(elevated, cmd)
+				return "gw-lab\n", tt.elevErr
+			}
+			var gw Gateway = newUbiquiti(run, SelectProfile(""))
+			if tt.mikro {
+				gw = newMikroTik(run)
+			}
+
+			ok, err := UseSudo(context.Background(), gw, sudo)
+			if ok != tt.want || (err != nil) != tt.wantErr {
+				t.Fatalf("UseSudo = %v, %v; want %v, error %v", ok, err, tt.want, tt.wantErr)
+			}
+
+			// Later commands go through whichever runner the gateway kept.
+			plain, elevated = nil, nil
+			if _, err := gw.Identity(context.Background()); err != nil {
+				t.Fatalf("Identity: %v", err)
+			}
+			if got := len(elevated) == 1; got != tt.want || len(plain) == len(elevated) {
+				t.Errorf("after UseSudo: %d plain, %d elevated commands", len(plain), len(elevated))
+			}
+		})
+	}
+}
@@ -5,14 +5,20 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type ubiquitiGateway struct {
-	run CommandRunner
+	run     CommandRunner
+	profile FirmwareProfile
+
+	// Sweep pacing set by SetPingPace; a zero batch means all at once.
+	pingBatch int
+	pingDelay time.Duration
 }
 
-func newUbiquiti(run CommandRunner) *ubiquitiGateway {
-	return &ubiquitiGateway{run: run}
+func newUbiquiti(run CommandRunner, profile FirmwareProfile) *ubiquitiGateway {
+	return &ubiquitiGateway{run: run, profile: profile}
 }
 
 func (g *ubiquitiGateway) Type() Type { return TypeUbiquiti }
@@ -28,50 +34,58 @@ func (g *ubiquitiGateway) Identity(ctx context.Context) (string, error) {
 func (g *ubiquitiGateway) WANInfo(ctx context.Context) (*WANConfig, error) {
 	cfg := &WANConfig{}
 
-	// Strategy 1: airOS system.cfg -- has explicit interface roles.
-	out, err := g.run(ctx, "cat /tmp/system.cfg 2>/dev/null")
-	if err == nil {
-		wanIface, wanIP := parseSystemCfgWAN(out)
-		if wanIP != "" {
-			cfg.PublicIP = wanIP
-			cfg.InterfaceName = wanIface
-		}
-	}
-
-	// Strategy 2: Try PPPoE/WAN interfaces with `ip addr show`.
-	if cfg.PublicIP == "" {
-		for _, iface := range []string{"ppp0", "pppoe0", "eth0"} {
-			out, err := g.run(ctx, fmt.Sprintf("ip addr show %s 2>/dev/null", iface))
-			if err != nil {
-				continue
-			}
-			ip := parseLinuxInetAddr(out)
-			if ip != "" && !isPrivateIPv4(stripCIDRSuffix(ip)) {
-				cfg.PublicIP = ip
-				cfg.InterfaceName = iface
-				break
+	// Strategies run in the firmware profile's order until one finds a
+	// public address.
+	for _, step := range g.profile.wan {
+		if cfg.PublicIP != "" {
+			break
+		}
+		switch step {
+		case stepSystemCfg:
+			// airOS system.cfg -- has explicit interface roles.
+			out, err := g.run.output(ctx, "cat /tmp/system.cfg 2>/dev/null")
+			if err == nil {
+				wanIface, wanIP := parseSystemCfgWAN(out)
+				if wanIP != "" {
+					cfg.PublicIP = wanIP
+					cfg.InterfaceName = wanIface
+				}
 			}
-		}
-	}
 
-	// Strategy 3: ifconfig fallback (airOS BusyBox).
-	if cfg.PublicIP == "" {
-		for _, iface := range []string{"ppp0", "pppoe0", "eth0"} {
-			out, err := g.run(ctx, fmt.Sprintf("ifconfig %s 2>/dev/null", iface))
-			if err != nil {
-				continue
+		case stepIPAddr:
+			// Try PPPoE/WAN interfaces with `ip addr show`.
+			for _, iface := range []string{"ppp0", "pppoe0", "eth0"} {
+				out, err := g.run.output(ctx, fmt.Sprintf("ip addr show %s 2>/dev/null", iface))
+				if err != nil {
+					continue
+				}
+				ip := parseLinuxInetAddr(out)
+				if validIPv4(stripCIDRSuffix(ip)) && !isPrivateIPv4(stripCIDRSuffix(ip)) {
+					cfg.PublicIP = ip
+					cfg.InterfaceName = iface
+					break
+				}
 			}
-			ip := parseIfconfigInetAddr(out)
-			if ip != "" && !isPrivateIPv4(ip) {
-				cfg.PublicIP = ip
-				cfg.InterfaceName = iface
-				break
+
+		case stepIfconfig:
+			// ifconfig fallback (airOS BusyBox).
+			for _, iface := range []string{"ppp0", "pppoe0", "eth0"} {
+				out, err := g.run.output(ctx, fmt.Sprintf("ifconfig %s 2>/dev/null", iface))
+				if err != nil {
+					continue
+				}
+				ip := parseIfconfigInetAddr(out)
+				if validIPv4(ip) && !isPrivateIPv4(ip) {
+					cfg.PublicIP = ip
+					cfg.InterfaceName = iface
+					break
+				}
 			}
 		}
 	}
 
 	// Get default route gateway.
-	out, err = g.run(ctx, "ip route show default 2>/dev/null")
+	out, err := g.run.output(ctx, "ip route show default 2>/dev/null")
 	if err == nil {
 		cfg.Gateway = parseLinuxDefaultGateway(out)
 	}
@@ -85,88 +99,95 @@ func (g *ubiquitiGateway) WANInfo(ctx context.Context) (*WANConfig, error) {
 func (g *ubiquitiGateway) LANInfo(ctx context.Context) (*LANConfig, error) {
 	cfg := &LANConfig{}
 
-	// Strategy 1: airOS system.cfg -- has explicit interface roles and DHCP.
-	out, err := g.run(ctx, "cat /tmp/system.cfg 2>/dev/null")
-	if err == nil {
-		lanIface, lanIP, lanMask := parseSystemCfgLAN(out)
-		if lanIP != "" {
-			cidr := lanIP + cidrFromMask(lanMask)
-			cfg.InterfaceName = lanIface
-			cfg.GatewayIP = lanIP
-			cfg.CIDR = cidr
-			cfg.Subnet = subnetFromCIDR(cidr)
-			// DHCP from system.cfg.
-			cfg.DHCPStart, cfg.DHCPEnd = parseSystemCfgDHCP(out)
-		}
-	}
-
-	// Strategy 2: Dynamic discovery via `ip -o addr show` (EdgeOS).
-	if cfg.GatewayIP == "" {
-		out, err := g.run(ctx, "ip -o addr show 2>/dev/null")
-		if err == nil {
-			// Detect if a PPP/PPPoE interface exists -- if so, eth0 is LAN.
-			hasPPP := strings.Contains(out, "ppp0") || strings.Contains(out, "pppoe0")
-			for _, candidate := range discoverLANInterfaces(out, hasPPP) {
-				cfg.InterfaceName = candidate.iface
-				cfg.GatewayIP = stripCIDRSuffix(candidate.addr)
-				cfg.CIDR = candidate.addr
-				cfg.Subnet = subnetFromCIDR(candidate.addr)
-				break
+	// Strategies run in the firmware profile's order until one finds the
+	// LAN address.
+	for _, step := range g.profile.lan {
+		if cfg.Subnet != "" {
+			break
+		}
+		switch step {
+		case stepSystemCfg:
+			// airOS system.cfg -- has explicit interface roles and DHCP.
+			out, err := g.run.output(ctx, "cat /tmp/system.cfg 2>/dev/null")
+			if err == nil {
+				lanIface, lanIP, lanMask := parseSystemCfgLAN(out)
+				if validIPv4(lanIP) {
+					cidr := lanIP + cidrFromMask(lanMask)
+					cfg.InterfaceName = lanIface
+					cfg.GatewayIP = lanIP
+					cfg.CIDR = cidr
+					cfg.Subnet = subnetFromCIDR(cidr)
+					// DHCP from system.cfg.
+					cfg.DHCPStart, cfg.DHCPEnd = parseSystemCfgDHCP(out)
+				}
 			}
-		}
-	}
 
-	// Strategy 3: ifconfig fallback (airOS BusyBox).
-	if cfg.GatewayIP == "" {
-		for _, iface := range []string{"eth0", "br0", "eth1", "switch0"} {
-			out, err := g.run(ctx, fmt.Sprintf("ifconfig %s 2>/dev/null", iface))
-			if err != nil {
-				continue
-			}
-			ip := parseIfconfigInetAddr(out)
-			mask := parseIfconfigMask(out)
-			if ip != "" && isPrivateIPv4(ip) {
-				cidr := ip + cidrFromMask(mask)
-				cfg.InterfaceName = iface
-				cfg.GatewayIP = ip
-				cfg.CIDR = cidr
-				cfg.Subnet = subnetFromCIDR(cidr)
-				break
+		case stepIPOneLine:
+			// Dynamic discovery via `ip -o addr show` (EdgeOS).
+			out, err := g.run.output(ctx, "ip -o addr show 2>/dev/null")
+			if err == nil {
+				// Detect if a PPP/PPPoE interface exists -- if so, eth0 is LAN.
+				hasPPP := strings.Contains(out, "ppp0") || strings.Contains(out, "pppoe0")
+				for _, candidate := range discoverLANInterfaces(out, hasPPP) {
+					cfg.InterfaceName = candidate.iface
+					cfg.GatewayIP = stripCIDRSuffix(candidate.addr)
+					cfg.CIDR = candidate.addr
+					cfg.Subnet = subnetFromCIDR(candidate.addr)
+					break
+				}
 			}
-		}
-	}
 
-	// Strategy 4: Hardcoded interface names with `ip addr show` (legacy).
-	if cfg.GatewayIP == "" {
-		for _, iface := range []string{"br0", "eth1", "switch0"} {
-			out, err := g.run(ctx, fmt.Sprintf("ip addr show %s 2>/dev/null", iface))
-			if err != nil {
-				continue
+		case stepIfconfig:
+			// ifconfig fallback (airOS BusyBox).
+			for _, iface := range []string{"eth0", "br0", "eth1", "switch0"} {
+				out, err := g.run.output(ctx, fmt.Sprintf("ifconfig %s 2>/dev/null", iface))
+				if err != nil {
+					continue
+				}
+				ip := parseIfconfigInetAddr(out)
+				mask := parseIfconfigMask(out)
+				if validIPv4(ip) && isPrivateIPv4(ip) {
+					cidr := ip + cidrFromMask(mask)
+					cfg.InterfaceName = iface
+					cfg.GatewayIP = ip
+					cfg.CIDR = cidr
+					cfg.Subnet = subnetFromCIDR(cidr)
+					break
+				}
 			}
-			ip := parseLinuxInetAddr(out)
-			if ip != "" {
-				cfg.InterfaceName = iface
-				cfg.GatewayIP = stripCIDRSuffix(ip)
-				cfg.CIDR = ip
-				cfg.Subnet = subnetFromCIDR(ip)
-				break
+
+		case stepIPAddr:
+			// Hardcoded interface names with `ip addr show` (legacy).
+			for _, iface := range []string{"br0", "eth1", "switch0"} {
+				out, err := g.run.output(ctx, fmt.Sprintf("ip addr show %s 2>/dev/null", iface))
+				if err != nil {
+					continue
+				}
+				ip := parseLinuxInetAddr(out)
+				if validIPv4(stripCIDRSuffix(ip)) {
+					cfg.InterfaceName = iface
+					cfg.GatewayIP = stripCIDRSuffix(ip)
+					cfg.CIDR = ip
+					cfg.Subnet = subnetFromCIDR(ip)
+					break
+				}
 			}
 		}
 	}
 
-	if cfg.GatewayIP == "" {
+	if cfg.Subnet == "" {
 		return nil, fmt.Errorf("ubiquiti LANInfo: could not determine LAN configuration")
 	}
 
 	// DHCP: try EdgeOS sources if system.cfg didn't provide it.
 	if cfg.DHCPStart == "" {
-		out, err = g.run(ctx, "cat /etc/dnsmasq.d/dhcpd.conf 2>/dev/null || cat /config/dhcpd.conf 2>/dev/null")
+		out, err := g.run.output(ctx, "cat /etc/dnsmasq.d/dhcpd.conf 2>/dev/null || cat /config/dhcpd.conf 2>/dev/null")
 		if err == nil {
 			cfg.DHCPStart, cfg.DHCPEnd = parseDnsmasqRange(out)
 		}
 	}
 	if cfg.DHCPStart == "" {
-		out, err = g.run(ctx, "cat /config/config.boot 2>/dev/null")
+		out, err := g.run.output(ctx, "cat /config/config.boot 2>/dev/null")
 		if err == nil {
 			cfg.DHCPStart, cfg.DHCPEnd = parseConfigBootDHCP(out, cfg.Subnet)
 		}
@@ -175,17 +196,92 @@ func (g *ubiquitiGateway) LANInfo(ctx context.Context) (*LANConfig, error) {
 	return cfg, nil
 }
 
+func (g *ubiquitiGateway) LANNetworks(ctx context.Context) ([]LANConfig, error) {
+	primary, err := g.LANInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nets := []LANConfig{*primary}
+
+	// Collect every private address the profile's listing commands can
+	// see; LANInfo stopped at the first.
+	for _, step := range g.profile.lan {
+		switch step {
+		case stepIPOneLine:
+			out, err := g.run.output(ctx, "ip -o addr show 2>/dev/null")
+			if err != nil {
+				continue
+			}
+			hasPPP := strings.Contains(out, "ppp0") || strings.Contains(out, "pppoe0")
+			for _, c := range discoverLANInterfaces(out, hasPPP) {
+				nets = appendNetwork(nets, LANConfig{
+					InterfaceName: c.iface,
+					GatewayIP:     stripCIDRSuffix(c.addr),
+					CIDR:          c.addr,
+					Subnet:        subnetFromCIDR(c.addr),
+				})
+			}
+
+		case stepIfconfig:
+			for _, iface := range []string{"eth0", "br0", "eth1", "switch0"} {
+				out, err := g.run.output(ctx, fmt.Sprintf("ifconfig %s 2>/dev/null", iface))
+				if err != nil {
+					continue
+				}
+				ip := parseIfconfigInetAddr(out)
+				if !validIPv4(ip) || !isPrivateIPv4(ip) {
+					continue
+				}
+				cidr := ip + cidrFromMask(parseIfconfigMask(out))
+				nets = appendNetwork(nets, LANConfig{
+					InterfaceName: iface,
+					GatewayIP:     ip,
+					CIDR:          cidr,
+					Subnet:        subnetFromCIDR(cidr),
+				})
+			}
+		}
+	}
+
+	// EdgeOS keeps one DHCP server block per network in config.boot.
+	if len(nets) > 1 {
+		if out, err := g.run.output(ctx, "cat /config/config.boot 2>/dev/null"); err == nil {
+			for i := 1; i < len(nets); i++ {
+				nets[i].DHCPStart, nets[i].DHCPEnd = parseConfigBootDHCP(out, nets[i].Subnet)
+			}
+		}
+	}
+	return nets, nil
+}
+
 func (g *ubiquitiGateway) FloodPing(ctx context.Context, subnet string) error {
+	// Parallel ping sweep of the /24 to populate ARP table.
+	return g.FloodPingRange(ctx, subnet, 1, 254)
+}
+
+// FloodPingRange pings subnet.first to subnet.last in parallel.
+func (g *ubiquitiGateway) FloodPingRange(ctx context.Context, subnet string, first, last int) error {
 	if err := ValidateSubnet(subnet); err != nil {
 		return fmt.Errorf("ubiquiti flood ping: %w", err)
 	}
-	// Parallel ping sweep of the /24 to populate ARP table.
+	if err := validHostRange(first, last); err != nil {
+		return fmt.Errorf("ubiquiti flood ping: %w", err)
+	}
 	cmd := fmt.Sprintf(
-		"for i in $(seq 1 254); do ping -c1 -W1 %s.$i &>/dev/null & done; wait",
-		subnet,
+		"for i in $(seq %d %d); do ping %s %s.$i &>/dev/null & done; wait",
+		first, last, g.profile.pingFlags, subnet,
 	)
+	if g.pingBatch > 0 {
+		// Paced: wait for each batch and pause before the next, so a
+		// loaded gateway isn't handed 254 processes at once.
+		cmd = fmt.Sprintf(
+			"for i in $(seq %d %d); do ping %s %s.$i &>/dev/null & [ $((i %% %d)) -eq 0 ] && { wait; sleep %d; }; done; wait",
+			first, last, g.profile.pingFlags, subnet, g.pingBatch, max(int(g.pingDelay.Seconds()), 1),
+		)
+	}
+	// A non-zero exit just means some pings went unanswered.
 	_, err := g.run(ctx, cmd)
-	if err != nil {
+	if err != nil && !exitedNonZero(err) {
 		return fmt.Errorf("ubiquiti flood ping: %w", err)
 	}
 	return nil
@@ -204,39 +300,50 @@ func (g *ubiquitiGateway) ARPTable(ctx context.Context, subnet string) ([]ARPEnt
 		}
 	}
 
-	// Try `ip neigh show` first (EdgeOS).
-	out, err := g.run(ctx, "ip neigh show 2>/dev/null")
-	if err == nil && strings.TrimSpace(out) != "" {
-		matches := neighRe.FindAllStringSubmatch(out, -1)
-		if len(matches) == 0 {
-			return parseNeighFallback(out, subnet), nil
-		}
-		var entries []ARPEntry
-		for _, m := range matches {
-			ip := m[1]
-			if subnet != "" && !strings.HasPrefix(ip, subnet+".") {
-				continue
+	for _, step := range g.profile.arp {
+		switch step {
+		case stepNeigh:
+			// `ip neigh show` (EdgeOS, airOS 8).
+			out, err := g.run(ctx, "ip neigh show 2>/dev/null")
+			if (err == nil || exitedNonZero(err)) && strings.TrimSpace(out) != "" {
+				return parseNeigh(out, subnet), nil
 			}
-			state := m[4]
-			if strings.EqualFold(state, "FAILED") {
-				continue
+
+		case stepARP:
+			// `arp -a` (airOS BusyBox).
+			out, err := g.run(ctx, "arp -a 2>/dev/null")
+			if err == nil || (exitedNonZero(err) && strings.TrimSpace(out) != "") {
+				return parseBusyBoxARP(out, subnet), nil
 			}
-			entries = append(entries, ARPEntry{
-				IP:    ip,
-				Iface: m[2],
-				MAC:   strings.ToUpper(m[3]),
-				Flags: state,
-			})
 		}
-		return entries, nil
 	}
+	return nil, fmt.Errorf("ubiquiti ARP: none of %s available", strings.Join(g.profile.arp, ", "))
+}
 
-	// Fallback: `arp -a` (airOS BusyBox).
-	out, err = g.run(ctx, "arp -a 2>/dev/null")
-	if err != nil {
-		return nil, fmt.Errorf("ubiquiti ARP: neither ip neigh nor arp available")
+// parseNeigh parses `ip neigh show` output, skipping FAILED entries.
+func parseNeigh(out, subnet string) []ARPEntry {
+	matches := neighRe.FindAllStringSubmatch(out, -1)
+	if len(matches) == 0 {
+		return parseNeighFallback(out, subnet)
+	}
+	var entries []ARPEntry
+	for _, m := range matches {
+		ip := m[1]
+		if subnet != "" && !strings.HasPrefix(ip, subnet+".") {
+			continue
+		}
+		state := m[4]
+		if strings.EqualFold(state, "FAILED") {
+			continue
+		}
+		entries = append(entries, ARPEntry{
+			IP:    ip,
+			Iface: m[2],
+			MAC:   strings.ToUpper(m[3]),
+			Flags: state,
+		})
 	}
-	return parseBusyBoxARP(out, subnet), nil
+	return entries
 }
 
 // ---------------------------------------------------------------------------
@@ -282,7 +389,7 @@ func parseSystemCfgLAN(cfg string) (iface, ip, mask string) {
 		dev := kv[prefix+".devname"]
 		ipAddr := kv[prefix+".ip"]
 		netmask := kv[prefix+".netmask"]
-		if role == "lan" && ipAddr != "" {
+		if role == "lan" && validIPv4(ipAddr) {
 			return dev, ipAddr, netmask
 		}
 	}
@@ -292,7 +399,7 @@ func parseSystemCfgLAN(cfg string) (iface, ip, mask string) {
 		// Find the matching netconf entry for this device.
 		for i := 1; i <= 10; i++ {
 			prefix := fmt.Sprintf("netconf.%d", i)
-			if kv[prefix+".devname"] == dev {
+			if kv[prefix+".devname"] == dev && validIPv4(kv[prefix+".ip"]) {
 				return dev, kv[prefix+".ip"], kv[prefix+".netmask"]
 			}
 		}
@@ -568,3 +675,37 @@ func parseNeighFallback(out, subnet string) []ARPEntry {
 	}
 	return entries
 }
+
+// SampleLoad reads the load average and core count in one command.
+func (g *ubiquitiGateway) SampleLoad(ctx context.Context) (Load, error) {
+	out, err := g.run(ctx, "cat /proc/loadavg; grep -c ^processor /proc/cpuinfo")
+	if err != nil && !exitedNonZero(err) {
+		return Load{}, fmt.Errorf("ubiquiti load: %w", err)
+	}
+	return parseLoadavg(out)
+}
+
+// BridgeHosts reads the Linux bridge forwarding database and resolves
+// addresses from whichever DHCP server's lease file exists: ISC dhcpd on
+// EdgeOS, dnsmasq elsewhere.
+func (g *ubiquitiGateway) BridgeHosts(ctx context.Context) ([]BridgeHost, error) {
+	out, err := g.run.output(ctx, "bridge fdb show 2>/dev/null")
+	if err != nil {
+		return nil, fmt.Errorf("ubiquiti bridge fdb: %w", err)
+	}
+	hosts := parseBridgeFDB(out)
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+	leases, err := g.run(ctx, "cat /var/run/dhcpd.leases /tmp/dhcp.leases /var/lib/misc/dnsmasq.leases 2>/dev/null")
+	if err != nil && !exitedNonZero(err) {
+		return hosts, nil
+	}
+	return resolveLeases(hosts, parseLinuxLeases(leases)), nil
+}
+
+// SetPingPace slows later FloodPing sweeps.
+func (g *ubiquitiGateway) SetPingPace(batch int, delay time.Duration) {
+	g.pingBatch = batch
+	g.pingDelay = delay
+}
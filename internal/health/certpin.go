@@ -0,0 +1,95 @@
+package health
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+)
+
+// CertPin is the leaf certificate fingerprint remembered for one device
+// port. Pins are informational only: a mismatch is reported, never enforced.
+type CertPin struct {
+	Fingerprint string    `json:"fingerprint"`
+	Since       time.Time `json:"since"`
+}
+
+// CertChange reports a device presenting a different certificate than the
+// one pinned for it, usually after a factory reset or a swapped unit.
+type CertChange struct {
+	Key      string // pin key, see PinKey
+	Previous CertPin
+	Current  string // fingerprint presented now
+}
+
+// String renders the change for the dashboard.
+func (c CertChange) String() string {
+	return fmt.Sprintf("certificate changed since %s (was %s, now %s)",
+		c.Previous.Since.Format("2006-01-02"),
+		shortFingerprint(c.Previous.Fingerprint), shortFingerprint(c.Current))
+}
+
+func certPinPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".tunneler", "cache", "certs.json")
+}
+
+// PinKey identifies a device port across sessions. The MAC is preferred;
+// without one the gateway is included since LAN addresses repeat between
+// sites.
+func PinKey(gateway, ip, mac string, port int) string {
+	if mac != "" {
+		return fmt.Sprintf("%s/%d", mac, port)
+	}
+	return fmt.Sprintf("%s/%s/%d", gateway, ip, port)
+}
+
+// Fingerprint returns the SHA256 fingerprint of a certificate in the same
+// "SHA256:base64" form used for SSH host keys.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// CheckPin compares fingerprint against the pin for key. The first
+// fingerprint seen for a key is pinned and reported as unchanged.
+func CheckPin(key, fingerprint string) (CertChange, bool) {
+	pins := make(map[string]CertPin)
+	var change CertChange
+	changed := false
+	_ = store.Update(certPinPath(), &pins, func() error {
+		pin, ok := pins[key]
+		switch {
+		case !ok:
+			pins[key] = CertPin{Fingerprint: fingerprint, Since: time.Now()}
+		case pin.Fingerprint != fingerprint:
+			change = CertChange{Key: key, Previous: pin, Current: fingerprint}
+			changed = true
+		}
+		return nil
+	})
+	return change, changed
+}
+
+// AcceptPin replaces the pin for key with fingerprint.
+func AcceptPin(key, fingerprint string) error {
+	pins := make(map[string]CertPin)
+	return store.Update(certPinPath(), &pins, func() error {
+		pins[key] = CertPin{Fingerprint: fingerprint, Since: time.Now()}
+		return nil
+	})
+}
+
+// shortFingerprint trims a fingerprint to its first few characters.
+func shortFingerprint(fp string) string {
+	const keep = len("SHA256:") + 8
+	if len(fp) <= keep {
+		return fp
+	}
+	return fp[:keep] + "…"
+}
@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// selfSigned makes a throwaway self-signed certificate like a camera's.
+func selfSigned(t *testing.T, name string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// rotatingServer is an HTTPS server whose certificate can be swapped
+// between requests, as a factory reset would.
+type rotatingServer struct {
+	*httptest.Server
+	cert atomic.Pointer[tls.Certificate]
+}
+
+func newRotatingServer(t *testing.T) *rotatingServer {
+	t.Helper()
+	s := &rotatingServer{}
+	s.rotate(t, "camera")
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// StartTLS would add httptest's own certificate, which wins for
+	// clients that send no SNI, so serve TLS on the listener directly.
+	s.Listener = tls.NewListener(s.Listener, &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.cert.Load(), nil
+		},
+	})
+	s.Start()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *rotatingServer) rotate(t *testing.T, name string) {
+	cert := selfSigned(t, name)
+	s.cert.Store(&cert)
+}
+
+// probeFingerprint probes the server over HTTPS and returns the
+// fingerprint it presented.
+func probeFingerprint(t *testing.T, s *rotatingServer) string {
+	t.Helper()
+	addr := s.Listener.Addr().(*net.TCPAddr)
+	r := NewProber(net.Dial, 2*time.Second).Probe(context.Background(), "127.0.0.1", addr.Port, true)
+	if r.Err != nil {
+		t.Fatalf("Probe: %v", r.Err)
+	}
+	if !strings.HasPrefix(r.CertFingerprint, "SHA256:") {
+		t.Fatalf("fingerprint = %q", r.CertFingerprint)
+	}
+	return r.CertFingerprint
+}
+
+func TestCertPinRotation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	srv := newRotatingServer(t)
+	key := PinKey("192.168.1.1", "192.168.1.64", "00:0C:29:11:22:33", 443)
+
+	first := probeFingerprint(t, srv)
+	if _, changed := CheckPin(key, first); changed {
+		t.Fatal("first sighting reported as a change")
+	}
+	if _, changed := CheckPin(key, probeFingerprint(t, srv)); changed {
+		t.Fatal("same certificate reported as a change")
+	}
+
+	srv.rotate(t, "camera-reset")
+	second := probeFingerprint(t, srv)
+	if second == first {
+		t.Fatal("rotated certificate has the same fingerprint")
+	}
+	change, changed := CheckPin(key, second)
+	if !changed {
+		t.Fatal("rotated certificate not reported")
+	}
+	if change.Previous.Fingerprint != first || change.Current != second {
+		t.Errorf("change = %+v, want %s -> %s", change, first, second)
+	}
+	want := "certificate changed since " + time.Now().Format("2006-01-02") +
+		" (was " + shortFingerprint(first) + ", now " + shortFingerprint(second) + ")"
+	if change.String() != want {
+		t.Errorf("String() = %q, want %q", change.String(), want)
+	}
+
+	// Still flagged until accepted, then quiet.
+	if _, changed := CheckPin(key, second); !changed {
+		t.Error("change forgotten before it was accepted")
+	}
+	if err := AcceptPin(key, second); err != nil {
+		t.Fatalf("AcceptPin: %v", err)
+	}
+	if _, changed := CheckPin(key, probeFingerprint(t, srv)); changed {
+		t.Error("accepted certificate still reported as a change")
+	}
+}
+
+func TestPinKey(t *testing.T) {
+	tests := []struct {
+		gateway, ip, mac string
+		port             int
+		want             string
+	}{
+		{"192.168.1.1", "192.168.1.64", "00:0C:29:11:22:33", 443, "00:0C:29:11:22:33/443"},
+		{"192.168.1.1", "192.168.1.64", "", 443, "192.168.1.1/192.168.1.64/443"},
+		{"10.0.0.1", "192.168.1.64", "", 8443, "10.0.0.1/192.168.1.64/8443"},
+	}
+	for _, tt := range tests {
+		if got := PinKey(tt.gateway, tt.ip, tt.mac, tt.port); got != tt.want {
+			t.Errorf("PinKey(%q, %q, %q, %d) = %q, want %q", tt.gateway, tt.ip, tt.mac, tt.port, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package health checks that web-managed devices actually answer HTTP,
+// not just TCP. Some cameras accept the connection and then hang.
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// UserAgent identifies probe requests in device access logs.
+const UserAgent = "lmtm-health/1"
+
+// DialFunc opens a TCP connection through the gateway, e.g. ssh.Client.Dial.
+type DialFunc func(network, addr string) (net.Conn, error)
+
+// Result is the outcome of one probe.
+type Result struct {
+	Status  int // HTTP status code, 0 if no response
+	Latency time.Duration
+	Err     error
+
+	// CertFingerprint is the presented leaf certificate for HTTPS probes.
+	CertFingerprint string
+}
+
+// String renders the result for the dashboard: "200 86ms", "timeout", "error".
+func (r Result) String() string {
+	var ne net.Error
+	switch {
+	case r.Err == nil:
+		return fmt.Sprintf("%d %dms", r.Status, r.Latency.Milliseconds())
+	case errors.Is(r.Err, context.DeadlineExceeded),
+		errors.As(r.Err, &ne) && ne.Timeout():
+		return "timeout"
+	default:
+		return "error"
+	}
+}
+
+// Prober sends GET / to devices. Requests are dialed straight through the
+// SSH connection rather than the local tunnel listener, so probe traffic
+// never mixes with the user's own connections.
+type Prober struct {
+	client *http.Client
+}
+
+// NewProber creates a prober that dials through dial, giving up on each
+// request after timeout.
+func NewProber(dial DialFunc, timeout time.Duration) *Prober {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dial(network, addr)
+		},
+		// Devices use self-signed certificates; the probe only reports
+		// whether the web UI answers, it never sends credentials.
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}
+	return &Prober{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			// Report the redirect itself instead of following it anywhere.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Probe requests GET / from host:port over HTTP or HTTPS.
+func (p *Prober) Probe(ctx context.Context, host string, port int, useTLS bool) Result {
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(host, fmt.Sprint(port)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Result{Err: err}
+	}
+	req.Header.Set("User-Agent", UserAgent)
+
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Result{Err: err, Latency: time.Since(start)}
+	}
+	resp.Body.Close()
+	r := Result{Status: resp.StatusCode, Latency: time.Since(start)}
+	// InsecureSkipVerify still leaves the presented chain on the response.
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		r.CertFingerprint = Fingerprint(resp.TLS.PeerCertificates[0])
+	}
+	return r
+}
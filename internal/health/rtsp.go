@@ -0,0 +1,148 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRTSPBody caps how much of a DESCRIBE body (the SDP) is read.
+const maxRTSPBody = 64 << 10
+
+// RTSPResult is the outcome of an RTSP probe: an OPTIONS, then a DESCRIBE
+// of the stream path, both without credentials.
+type RTSPResult struct {
+	Path    string   // path that was described, without the leading slash
+	Status  int      // DESCRIBE status code, 0 if the server never answered
+	Methods []string // from the OPTIONS reply's Public header
+	Auth    string   // challenge scheme on a 401, e.g. "Digest"
+	Err     error
+}
+
+// AuthRequired reports whether the server answered but wants a login.
+func (r RTSPResult) AuthRequired() bool {
+	return r.Status == 401
+}
+
+// String renders the result for the dashboard. It spells out that the
+// tunnel works whenever the server answered at all, since a 401 or 404 in
+// the player is easily mistaken for a broken tunnel.
+func (r RTSPResult) String() string {
+	path := "/" + r.Path
+	switch {
+	case r.Err != nil:
+		return "RTSP server did not answer: " + r.Err.Error()
+	case r.AuthRequired():
+		auth := "auth required"
+		if r.Auth != "" {
+			auth = r.Auth + " auth required"
+		}
+		return fmt.Sprintf("RTSP reachable, %s for %s -- the tunnel is fine, add the camera's login", auth, path)
+	case r.Status == 404:
+		return fmt.Sprintf("RTSP reachable, no stream at %s -- the tunnel is fine, the path is wrong", path)
+	case r.Status >= 200 && r.Status < 300:
+		return fmt.Sprintf("RTSP reachable, stream at %s answers without a login", path)
+	default:
+		return fmt.Sprintf("RTSP reachable, DESCRIBE %s returned %d", path, r.Status)
+	}
+}
+
+// ProbeRTSP connects to addr through dial and asks for the stream at
+// path, the way a player pasting rtsp://addr/path would, but without
+// credentials. A reply of any status means the server is reachable.
+func ProbeRTSP(ctx context.Context, dial DialFunc, addr, path string, timeout time.Duration) RTSPResult {
+	path = strings.TrimPrefix(path, "/")
+	r := RTSPResult{Path: path}
+
+	conn, err := dial("tcp", addr)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	c := &rtspConn{conn: conn, tp: textproto.NewReader(bufio.NewReader(conn))}
+	base := "rtsp://" + addr + "/"
+
+	status, header, err := c.do("OPTIONS", base, "")
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if status == 200 {
+		for _, m := range strings.Split(header.Get("Public"), ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				r.Methods = append(r.Methods, m)
+			}
+		}
+	}
+
+	status, header, err = c.do("DESCRIBE", base+path, "Accept: application/sdp\r\n")
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Status = status
+	if status == 401 {
+		scheme, _, _ := strings.Cut(header.Get("WWW-Authenticate"), " ")
+		r.Auth = scheme
+	}
+	return r
+}
+
+// rtspConn sends RTSP requests one at a time over a single connection.
+type rtspConn struct {
+	conn net.Conn
+	tp   *textproto.Reader
+	cseq int
+}
+
+// errRTSPReply is returned when the server answers with something that
+// isn't an RTSP status line.
+var errRTSPReply = errors.New("not an RTSP reply")
+
+// do sends one request and reads its reply, discarding any body.
+func (c *rtspConn) do(method, url, extra string) (int, textproto.MIMEHeader, error) {
+	c.cseq++
+	req := fmt.Sprintf("%s %s RTSP/1.0\r\nCSeq: %d\r\nUser-Agent: %s\r\n%s\r\n",
+		method, url, c.cseq, UserAgent, extra)
+	if _, err := io.WriteString(c.conn, req); err != nil {
+		return 0, nil, err
+	}
+
+	line, err := c.tp.ReadLine()
+	if err != nil {
+		return 0, nil, err
+	}
+	proto, rest, _ := strings.Cut(line, " ")
+	code, _, _ := strings.Cut(rest, " ")
+	status, err := strconv.Atoi(code)
+	if !strings.HasPrefix(proto, "RTSP/") || err != nil {
+		return 0, nil, fmt.Errorf("%w: %q", errRTSPReply, line)
+	}
+	header, err := c.tp.ReadMIMEHeader()
+	if err != nil && len(header) == 0 {
+		return 0, nil, err
+	}
+	if n, _ := strconv.Atoi(header.Get("Content-Length")); n > 0 {
+		if n > maxRTSPBody {
+			return status, header, nil
+		}
+		if _, err := io.CopyN(io.Discard, c.tp.R, int64(n)); err != nil {
+			return 0, nil, err
+		}
+	}
+	return status, header, nil
+}
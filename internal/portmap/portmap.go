@@ -15,6 +15,41 @@ type PortMapping struct {
 	RemotePort int
 }
 
+// Service describes a well-known remote port: the local base used by the
+// port formula and the protocol the user should open it with.
+type Service struct {
+	Port     int
+	Base     int
+	Protocol string
+}
+
+// portDirectory lists the well-known remote ports and their local bases.
+var portDirectory = []Service{
+	{Port: 443, Base: 4430, Protocol: "HTTPS"},
+	{Port: 80, Base: 8030, Protocol: "HTTP"},
+	{Port: 22, Base: 2230, Protocol: "SSH"},
+	{Port: 554, Base: 5540, Protocol: "RTSP"},
+	{Port: 8291, Base: 1110, Protocol: "WinBox"},
+}
+
+// PortDirectory returns a copy of the well-known port table.
+func PortDirectory() []Service {
+	result := make([]Service, len(portDirectory))
+	copy(result, portDirectory)
+	return result
+}
+
+// Protocol returns the protocol name for a remote service port
+// ("HTTPS", "HTTP", "SSH", ...), or "TCP" for unrecognized ports.
+func Protocol(remotePort int) string {
+	for _, s := range portDirectory {
+		if s.Port == remotePort {
+			return s.Protocol
+		}
+	}
+	return "TCP"
+}
+
 // PortBase returns the base local port for a given remote service port.
 //
 //	443 -> 4430
@@ -25,20 +60,12 @@ type PortMapping struct {
 // For unrecognized ports, it returns 10000 + remotePort*10 to keep them
 // in a distinct range.
 func PortBase(remotePort int) int {
-	switch remotePort {
-	case 443:
-		return 4430
-	case 80:
-		return 8030
-	case 22:
-		return 2230
-	case 554:
-		return 5540
-	case 8291:
-		return 1110
-	default:
-		return 10000 + remotePort*10
+	for _, s := range portDirectory {
+		if s.Port == remotePort {
+			return s.Base
+		}
 	}
+	return 10000 + remotePort*10
 }
 
 // LocalPort calculates the local port for a given remote IP and service port.
@@ -48,40 +75,100 @@ func LocalPort(remoteIP string, remotePort int) int {
 	return PortBase(remotePort) + lastOctet(remoteIP)
 }
 
+// Strategy selects how a PortAllocator derives local ports.
+type Strategy int
+
+const (
+	// StrategyOctet adds the device's last octet to the service's base
+	// port, so 192.168.1.5:443 lands on 4435.
+	StrategyOctet Strategy = iota
+	// StrategySequential hands out SequentialBase, SequentialBase+1, ...
+	// in allocation order, whatever the device or service.
+	StrategySequential
+)
+
+// SequentialBase is the first local port handed out by StrategySequential.
+const SequentialBase = 20000
+
+// String returns the strategy name.
+func (s Strategy) String() string {
+	if s == StrategySequential {
+		return "sequential"
+	}
+	return "octet"
+}
+
 // PortAllocator tracks allocated local ports and handles collisions.
 type PortAllocator struct {
 	mu        sync.Mutex
 	allocated map[int]PortMapping
+	strategy  Strategy
+	next      int // next sequential port; unused with StrategyOctet
+
+	// busy reports ports held outside this allocator, e.g. by another
+	// lmtm tunneling to a second site; nil checks nothing.
+	busy func(port int) bool
 }
 
-// NewPortAllocator creates a PortAllocator ready for use.
+// NewPortAllocator creates a PortAllocator ready for use. It uses
+// StrategyOctet until SetStrategy says otherwise.
 func NewPortAllocator() *PortAllocator {
 	return &PortAllocator{
 		allocated: make(map[int]PortMapping),
+		next:      SequentialBase,
 	}
 }
 
+// SetStrategy changes how later Allocate calls pick ports. Ports already
+// allocated keep their numbers.
+func (pa *PortAllocator) SetStrategy(s Strategy) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	pa.strategy = s
+}
+
+// SetBusy sets a check for local ports taken by other processes. Allocate
+// steps over them as it does over its own, so two sites whose devices
+// share last octets get distinct ports instead of a failed bind.
+func (pa *PortAllocator) SetBusy(fn func(port int) bool) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	pa.busy = fn
+}
+
 // Allocate assigns a local port for the given remote host and port.
-// It uses the standard formula (PortBase + last octet) and bumps to the
-// next available port if a collision is detected.
+// With StrategyOctet it uses the standard formula (PortBase + last octet);
+// with StrategySequential it takes the next port after the previous
+// allocation, wrapping from 65535 back to SequentialBase. Either way it
+// bumps to the next available port if a collision is detected, with this
+// allocator or, given SetBusy, with another process.
 func (pa *PortAllocator) Allocate(remoteIP string, remotePort int) (int, error) {
 	pa.mu.Lock()
 	defer pa.mu.Unlock()
 
 	port := LocalPort(remoteIP, remotePort)
+	if pa.strategy == StrategySequential {
+		port = pa.next
+	}
 
 	// Try up to 256 consecutive ports to find an open slot.
 	for i := 0; i < 256; i++ {
 		candidate := port + i
 		if candidate > 65535 {
-			break
+			if pa.strategy != StrategySequential {
+				break
+			}
+			candidate -= 65536 - SequentialBase
 		}
-		if _, taken := pa.allocated[candidate]; !taken {
+		if _, taken := pa.allocated[candidate]; !taken && (pa.busy == nil || !pa.busy(candidate)) {
 			pa.allocated[candidate] = PortMapping{
 				LocalPort:  candidate,
 				RemoteHost: remoteIP,
 				RemotePort: remotePort,
 			}
+			if pa.strategy == StrategySequential {
+				pa.next = candidate + 1
+			}
 			return candidate, nil
 		}
 	}
@@ -89,6 +176,50 @@ func (pa *PortAllocator) Allocate(remoteIP string, remotePort int) (int, error)
 	return 0, fmt.Errorf("no available local port for %s:%d", remoteIP, remotePort)
 }
 
+// Reserve claims exactly m.LocalPort for m, for a forward whose local
+// port was typed rather than computed. Unlike Allocate it never bumps; it
+// fails if the port is already taken.
+func (pa *PortAllocator) Reserve(m PortMapping) error {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	if have, taken := pa.allocated[m.LocalPort]; taken {
+		return fmt.Errorf("local port %d already forwards to %s:%d", m.LocalPort, have.RemoteHost, have.RemotePort)
+	}
+	pa.allocated[m.LocalPort] = m
+	return nil
+}
+
+// ParseMapping parses an explicit forward written local:host:remote, such
+// as "18443:10.0.0.5:443". The host must be an IPv4 address and both
+// ports 1-65535.
+func ParseMapping(s string) (PortMapping, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return PortMapping{}, fmt.Errorf("%q: want local:host:remote", s)
+	}
+	local, err := parsePort(parts[0])
+	if err != nil {
+		return PortMapping{}, fmt.Errorf("%q: local %w", s, err)
+	}
+	if ip := net.ParseIP(parts[1]); ip == nil || ip.To4() == nil {
+		return PortMapping{}, fmt.Errorf("%q: %q is not an IPv4 address", s, parts[1])
+	}
+	remote, err := parsePort(parts[2])
+	if err != nil {
+		return PortMapping{}, fmt.Errorf("%q: remote %w", s, err)
+	}
+	return PortMapping{LocalPort: local, RemoteHost: parts[1], RemotePort: remote}, nil
+}
+
+// parsePort parses a port number in 1-65535.
+func parsePort(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("port %q must be 1-65535", s)
+	}
+	return n, nil
+}
+
 // Release frees a previously allocated local port.
 func (pa *PortAllocator) Release(localPort int) {
 	pa.mu.Lock()
@@ -0,0 +1,223 @@
+package portmap
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAllocate(t *testing.T) {
+	type call struct {
+		ip   string
+		port int
+		want int // 0 means an error is expected
+	}
+	tests := []struct {
+		name     string
+		strategy Strategy
+		next     int          // starting sequential port; 0 keeps SequentialBase
+		busy     map[int]bool // ports held by another process
+		calls    []call
+	}{
+		{
+			name: "octet formula",
+			calls: []call{
+				{"192.168.1.5", 443, 4435},
+				{"192.168.1.5", 80, 8035},
+				{"192.168.1.5", 22, 2235},
+				{"192.168.1.5", 554, 5545},
+				{"192.168.1.5", 8291, 1115},
+				{"192.168.1.5", 3000, 40005},
+			},
+		},
+		{
+			name: "octet collision bumps",
+			calls: []call{
+				{"10.0.0.5", 443, 4435},
+				{"10.1.0.5", 443, 4436},
+				{"10.0.0.6", 443, 4437},
+			},
+		},
+		{
+			name:  "octet skips busy ports",
+			busy:  map[int]bool{4435: true, 4436: true},
+			calls: []call{{"10.0.0.5", 443, 4437}},
+		},
+		{
+			name:  "octet gives up past 65535",
+			calls: []call{{"10.0.0.1", 6553, 0}},
+		},
+		{
+			name:     "sequential ignores device and service",
+			strategy: StrategySequential,
+			calls: []call{
+				{"10.0.0.200", 443, SequentialBase},
+				{"10.0.0.3", 80, SequentialBase + 1},
+				{"10.0.0.3", 22, SequentialBase + 2},
+			},
+		},
+		{
+			name:     "sequential skips busy ports",
+			strategy: StrategySequential,
+			busy:     map[int]bool{SequentialBase: true, SequentialBase + 2: true},
+			calls: []call{
+				{"10.0.0.1", 443, SequentialBase + 1},
+				{"10.0.0.2", 443, SequentialBase + 3},
+			},
+		},
+		{
+			name:     "sequential wraps to the base",
+			strategy: StrategySequential,
+			next:     65534,
+			calls: []call{
+				{"10.0.0.1", 443, 65534},
+				{"10.0.0.2", 443, 65535},
+				{"10.0.0.3", 443, SequentialBase},
+			},
+		},
+		{
+			name:     "sequential wrap steps over busy ports",
+			strategy: StrategySequential,
+			next:     65535,
+			busy:     map[int]bool{65535: true, SequentialBase: true},
+			calls:    []call{{"10.0.0.1", 443, SequentialBase + 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pa := NewPortAllocator()
+			pa.SetStrategy(tt.strategy)
+			if tt.next != 0 {
+				pa.next = tt.next
+			}
+			if tt.busy != nil {
+				pa.SetBusy(func(port int) bool { return tt.busy[port] })
+			}
+			for _, c := range tt.calls {
+				got, err := pa.Allocate(c.ip, c.port)
+				if c.want == 0 {
+					if err == nil {
+						t.Errorf("Allocate(%s, %d) = %d, want error", c.ip, c.port, got)
+					}
+					continue
+				}
+				if err != nil || got != c.want {
+					t.Errorf("Allocate(%s, %d) = %d, %v, want %d", c.ip, c.port, got, err, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocateReleased(t *testing.T) {
+	pa := NewPortAllocator()
+	first, _ := pa.Allocate("10.0.0.5", 443)
+	pa.Release(first)
+	if got, _ := pa.Allocate("10.1.0.5", 443); got != first {
+		t.Errorf("Allocate after Release = %d, want %d", got, first)
+	}
+
+	// A sequential allocator moves on rather than reusing a released port.
+	pa.SetStrategy(StrategySequential)
+	a, _ := pa.Allocate("10.0.0.1", 80)
+	pa.Release(a)
+	if b, _ := pa.Allocate("10.0.0.1", 80); b != a+1 {
+		t.Errorf("sequential Allocate after Release = %d, want %d", b, a+1)
+	}
+}
+
+// listening reports whether a local port is already bound, as the
+// busy check the app passes to SetBusy does.
+func listening(port int) bool {
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return true
+	}
+	ln.Close()
+	return false
+}
+
+func TestTwoSitesOverlappingOctets(t *testing.T) {
+	// Two lmtm processes, one per site, each with its own allocator. The
+	// sites number their LANs alike, so the octet formula gives both the
+	// same ports.
+	devices := []struct {
+		port int
+		ip   string
+	}{{443, ".5"}, {80, ".5"}, {554, ".20"}}
+
+	siteA := NewPortAllocator()
+	held := make(map[int]bool)
+	for _, d := range devices {
+		port, err := siteA.Allocate("192.168.1"+d.ip, d.port)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		if err != nil {
+			t.Skipf("port %d unavailable for the first site: %v", port, err)
+		}
+		defer ln.Close()
+		held[port] = true
+	}
+
+	siteB := NewPortAllocator()
+	siteB.SetBusy(listening)
+	for _, d := range devices {
+		want := LocalPort("10.0.0"+d.ip, d.port)
+		port, err := siteB.Allocate("10.0.0"+d.ip, d.port)
+		if err != nil {
+			t.Fatalf("second site Allocate(%s, %d): %v", d.ip, d.port, err)
+		}
+		if held[port] || port <= want {
+			t.Errorf("second site got %d for %s:%d, want a free port above %d", port, d.ip, d.port, want)
+		}
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    PortMapping
+		wantErr string
+	}{
+		{in: "18443:10.0.0.5:443", want: PortMapping{LocalPort: 18443, RemoteHost: "10.0.0.5", RemotePort: 443}},
+		{in: "  8080:192.168.1.1:80 ", want: PortMapping{LocalPort: 8080, RemoteHost: "192.168.1.1", RemotePort: 80}},
+		{in: "1:10.0.0.1:65535", want: PortMapping{LocalPort: 1, RemoteHost: "10.0.0.1", RemotePort: 65535}},
+		{in: "", wantErr: "want local:host:remote"},
+		{in: "8080:10.0.0.5", wantErr: "want local:host:remote"},
+		{in: "8080:fe80::1:80", wantErr: "want local:host:remote"},
+		{in: "0:10.0.0.5:443", wantErr: "local port"},
+		{in: "x:10.0.0.5:443", wantErr: "local port"},
+		{in: "8080:10.0.0.5:65536", wantErr: "remote port"},
+		{in: "8080:camera.lan:80", wantErr: "not an IPv4 address"},
+		{in: "8080:10.0.0.256:80", wantErr: "not an IPv4 address"},
+	}
+	for _, tt := range tests {
+		got, err := ParseMapping(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseMapping(%q) error = %v, want containing %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMapping(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	pa := NewPortAllocator()
+	m := PortMapping{LocalPort: 4435, RemoteHost: "10.0.0.9", RemotePort: 443}
+	if err := pa.Reserve(m); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := pa.Reserve(m); err == nil {
+		t.Error("Reserve of a taken port succeeded")
+	}
+	if got, _ := pa.Allocate("10.0.0.5", 443); got != 4436 {
+		t.Errorf("Allocate over a reserved port = %d, want 4436", got)
+	}
+}
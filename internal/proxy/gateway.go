@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
+)
+
+// DefaultGatewayPort is where the gateway proxy listens, the usual SOCKS
+// port, so browsers and curl --socks5 find it without being told.
+const DefaultGatewayPort = 1080
+
+// GatewayProxy is a SOCKS5 proxy whose connections exit from the gateway,
+// over the session's own SSH connection. Any address the gateway can
+// reach is reachable through it, without a tunnel per device and port.
+type GatewayProxy struct {
+	ListenPort int
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{} // relayed clients, closed by Stop
+	stopped bool
+}
+
+// NewGatewayProxy creates an unstarted proxy.
+func NewGatewayProxy() *GatewayProxy {
+	return &GatewayProxy{conns: make(map[net.Conn]struct{})}
+}
+
+// Start serves SOCKS5 on 127.0.0.1:listenPort, dialing each CONNECT
+// through client.
+func (p *GatewayProxy) Start(client *ssh.Client, listenPort int) error {
+	listenAddr := fmt.Sprintf("127.0.0.1:%d", listenPort)
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return fmt.Errorf("proxy: listen on %s: %w", listenAddr, err)
+	}
+	p.ListenPort = listenPort
+	p.listener = ln
+
+	p.wg.Add(1)
+	go p.acceptLoop(client.Dial)
+	return nil
+}
+
+// acceptLoop serves SOCKS5 clients until the listener is closed.
+func (p *GatewayProxy) acceptLoop(dial dialFunc) {
+	defer p.wg.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		if p.stopped {
+			p.mu.Unlock()
+			conn.Close()
+			return
+		}
+		p.conns[conn] = struct{}{}
+		p.mu.Unlock()
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			serveSOCKS5(conn, dial)
+			p.mu.Lock()
+			delete(p.conns, conn)
+			p.mu.Unlock()
+		}()
+	}
+}
+
+// Stop closes the listener and every relayed connection. The gateway's
+// SSH connection is the session's and stays up.
+func (p *GatewayProxy) Stop() error {
+	if p.listener == nil {
+		return nil
+	}
+	err := p.listener.Close()
+	p.mu.Lock()
+	p.stopped = true
+	for conn := range p.conns {
+		conn.Close()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	return err
+}
@@ -0,0 +1,91 @@
+// Package proxy provides SOCKS5 proxies that exit from the gateway, or
+// through a device reached over an lmtm tunnel for pivoting into networks
+// behind that device.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
+)
+
+// NestedProxy is a SOCKS5 proxy whose connections exit from a device's own
+// SSH server. The device is reached through an existing tunnel to its port
+// 22, so the proxy nests one SSH session inside the gateway's.
+type NestedProxy struct {
+	ListenPort int
+
+	client   *ssh.Client
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+// NewNestedProxy creates an unstarted proxy.
+func NewNestedProxy() *NestedProxy {
+	return &NestedProxy{}
+}
+
+// Start logs into the device through the tunnel listening on
+// 127.0.0.1:sshViaLocalPort and serves SOCKS5 on 127.0.0.1:listenPort.
+// Like the gateway login, it retries with ssh-rsa when the default host
+// key algorithms fail the handshake.
+func (p *NestedProxy) Start(sshViaLocalPort int, user, pass string, listenPort int) error {
+	port := strconv.Itoa(sshViaLocalPort)
+	client := ssh.NewClient()
+	err := client.Connect("127.0.0.1", port, user, pass, nil)
+	if err != nil && !ssh.IsUnreachable(err) {
+		client = ssh.NewClient()
+		if err2 := client.Connect("127.0.0.1", port, user, pass, []string{"ssh-rsa"}); err2 == nil {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("proxy: login via :%d: %w", sshViaLocalPort, err)
+	}
+
+	listenAddr := fmt.Sprintf("127.0.0.1:%d", listenPort)
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		client.Close()
+		return fmt.Errorf("proxy: listen on %s: %w", listenAddr, err)
+	}
+
+	p.ListenPort = listenPort
+	p.client = client
+	p.listener = ln
+
+	p.wg.Add(1)
+	go p.acceptLoop()
+	return nil
+}
+
+// acceptLoop serves SOCKS5 clients until the listener is closed.
+func (p *NestedProxy) acceptLoop() {
+	defer p.wg.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			serveSOCKS5(conn, p.client.Dial)
+		}()
+	}
+}
+
+// Stop closes the listener and the nested SSH session. Closing the session
+// ends every relayed connection, so Stop waits for them to unwind.
+func (p *NestedProxy) Stop() error {
+	if p.listener == nil {
+		return nil
+	}
+	p.listener.Close()
+	err := p.client.Close()
+	p.wg.Wait()
+	return err
+}
@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// SOCKS5 protocol constants (RFC 1928). Only the no-auth method and the
+// CONNECT command are supported; the listener is loopback-only.
+const (
+	socksVersion = 0x05
+
+	methodNoAuth       = 0x00
+	methodNoAcceptable = 0xFF
+
+	cmdConnect = 0x01
+
+	atypIPv4   = 0x01
+	atypDomain = 0x03
+	atypIPv6   = 0x04
+
+	replySucceeded          = 0x00
+	replyGeneralFailure     = 0x01
+	replyCommandUnsupported = 0x07
+	replyAddrUnsupported    = 0x08
+)
+
+// dialFunc opens a TCP connection to addr on the far side of the proxy.
+type dialFunc func(network, addr string) (net.Conn, error)
+
+// serveSOCKS5 handles one client connection: negotiates no-auth, reads a
+// CONNECT request, dials the target and relays until either side closes.
+func serveSOCKS5(conn net.Conn, dial dialFunc) error {
+	defer conn.Close()
+
+	if err := negotiate(conn); err != nil {
+		return err
+	}
+
+	target, err := readRequest(conn)
+	if err != nil {
+		return err
+	}
+
+	remote, err := dial("tcp", target)
+	if err != nil {
+		writeReply(conn, replyGeneralFailure)
+		return fmt.Errorf("socks5: dial %s: %w", target, err)
+	}
+	defer remote.Close()
+
+	if err := writeReply(conn, replySucceeded); err != nil {
+		return err
+	}
+
+	// Relay until either direction finishes. Buffer of 2 so the second
+	// copier doesn't block after we return and close both ends.
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, remote)
+		done <- struct{}{}
+	}()
+	<-done
+	return nil
+}
+
+// negotiate reads the client greeting and selects the no-auth method.
+func negotiate(conn net.Conn) error {
+	var hdr [2]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return fmt.Errorf("socks5: read greeting: %w", err)
+	}
+	if hdr[0] != socksVersion {
+		return fmt.Errorf("socks5: unsupported version %d", hdr[0])
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return fmt.Errorf("socks5: read methods: %w", err)
+	}
+	for _, m := range methods {
+		if m == methodNoAuth {
+			_, err := conn.Write([]byte{socksVersion, methodNoAuth})
+			return err
+		}
+	}
+	conn.Write([]byte{socksVersion, methodNoAcceptable})
+	return errors.New("socks5: client offered no supported auth method")
+}
+
+// readRequest reads a CONNECT request and returns the target as host:port.
+func readRequest(conn net.Conn) (string, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return "", fmt.Errorf("socks5: read request: %w", err)
+	}
+	if hdr[1] != cmdConnect {
+		writeReply(conn, replyCommandUnsupported)
+		return "", fmt.Errorf("socks5: unsupported command %d", hdr[1])
+	}
+
+	var host string
+	switch hdr[3] {
+	case atypIPv4, atypIPv6:
+		size := net.IPv4len
+		if hdr[3] == atypIPv6 {
+			size = net.IPv6len
+		}
+		ip := make(net.IP, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", fmt.Errorf("socks5: read address: %w", err)
+		}
+		host = ip.String()
+	case atypDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return "", fmt.Errorf("socks5: read address: %w", err)
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", fmt.Errorf("socks5: read address: %w", err)
+		}
+		host = string(name)
+	default:
+		writeReply(conn, replyAddrUnsupported)
+		return "", fmt.Errorf("socks5: unsupported address type %d", hdr[3])
+	}
+
+	var port [2]byte
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return "", fmt.Errorf("socks5: read port: %w", err)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), nil
+}
+
+// writeReply sends a reply with a zero IPv4 bind address; clients only
+// look at the reply code for CONNECT.
+func writeReply(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{socksVersion, code, 0x00, atypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
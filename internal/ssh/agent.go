@@ -0,0 +1,60 @@
+package ssh
+
+import (
+	"net"
+	"os"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AgentAvailable reports whether an ssh-agent is advertised through
+// SSH_AUTH_SOCK. Starting lmtm with SSH_AUTH_SOCK empty keeps the agent
+// out of it.
+func AgentAvailable() bool {
+	return os.Getenv("SSH_AUTH_SOCK") != ""
+}
+
+// SetAgent sets whether Connect offers the keys held by ssh-agent, after
+// a SetIdentity key and before the password. On by default; it only
+// matters when AgentAvailable.
+func (c *Client) SetAgent(on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.noAgent = !on
+}
+
+// openAgent connects to the ssh-agent for this connection's logins. The
+// agent signs during the handshake and again for each ExecRedial dial,
+// so the socket stays open until Close. Nil when there is no agent or it
+// can't be reached. Must be called with c.mu held.
+func (c *Client) openAgent() agent.ExtendedAgent {
+	if c.noAgent || !AgentAvailable() {
+		return nil
+	}
+	conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+	if err != nil {
+		tunnelLog().Printf("client: ssh-agent: %v", err)
+		return nil
+	}
+	c.agentConn = conn
+	return agent.NewClient(conn)
+}
+
+// closeAgent drops the agent connection. Must be called with c.mu held.
+func (c *Client) closeAgent() {
+	if c.agentConn != nil {
+		c.agentConn.Close()
+		c.agentConn = nil
+	}
+}
+
+// agentSigners returns the agent's keys, none if it can't list them.
+func agentSigners(a agent.ExtendedAgent) []gossh.Signer {
+	signers, err := a.Signers()
+	if err != nil {
+		tunnelLog().Printf("client: ssh-agent: %v", err)
+		return nil
+	}
+	return signers
+}
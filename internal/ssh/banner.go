@@ -0,0 +1,31 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// BannerHash returns the hex SHA-256 of a login banner, ignoring
+// surrounding whitespace so trailing newlines don't count as a change.
+func BannerHash(banner string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(banner)))
+	return hex.EncodeToString(sum[:])
+}
+
+// BannerAcknowledged reports whether this exact banner was already
+// acknowledged for host in a previous session.
+func BannerAcknowledged(host, banner string) bool {
+	return loadGatewayCache()[host].BannerHash == BannerHash(banner)
+}
+
+// AcknowledgeBanner records that the user acknowledged host's login banner:
+// an audit line with the banner hash goes to the tunnel log, and the hash is
+// cached so the banner is only shown again if its text changes.
+func AcknowledgeBanner(host, banner string) {
+	hash := BannerHash(banner)
+	tunnelLog().Printf("AUDIT: login banner acknowledged for %s (sha256 %s)", host, hash)
+	_ = updateGatewayEntry(host, func(e *gatewayCacheEntry) {
+		e.BannerHash = hash
+	})
+}
@@ -0,0 +1,53 @@
+package ssh
+
+import (
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// ChallengeFunc answers one round of keyboard-interactive prompts, such
+// as an OTP code, with one answer per question. echo says whether each
+// answer may be shown as it is typed.
+type ChallengeFunc func(name, instruction string, questions []string, echo []bool) ([]string, error)
+
+// SetChallenge makes Connect offer keyboard-interactive auth after the
+// password, with fn answering the gateway's prompts. A lone hidden
+// "Password:" prompt is answered with the login password without asking.
+// Nil leaves keyboard-interactive out.
+func (c *Client) SetChallenge(fn ChallengeFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.challenge = fn
+}
+
+// withoutChallenge returns config minus its keyboard-interactive method,
+// for the dials ExecRedial makes after Connect. Those run with nobody to
+// answer prompts, and fn may only be good for the first login.
+func withoutChallenge(config *gossh.ClientConfig) *gossh.ClientConfig {
+	cfg := *config
+	cfg.Auth = nil
+	for _, m := range config.Auth {
+		if _, ok := m.(gossh.KeyboardInteractiveChallenge); !ok {
+			cfg.Auth = append(cfg.Auth, m)
+		}
+	}
+	return &cfg
+}
+
+// keyboardInteractive wraps fn for the handshake, noting in tried when
+// the gateway uses it.
+func keyboardInteractive(fn ChallengeFunc, password string, tried *authAttempts) gossh.AuthMethod {
+	return gossh.KeyboardInteractive(func(name, instruction string, questions []string, echo []bool) ([]string, error) {
+		// Servers send an empty round to finish; it needs no answer.
+		if len(questions) == 0 {
+			return nil, nil
+		}
+		tried.challenge = true
+		if password != "" && len(questions) == 1 && !echo[0] &&
+			strings.Contains(strings.ToLower(questions[0]), "password") {
+			return []string{password}, nil
+		}
+		return fn(name, instruction, questions, echo)
+	})
+}
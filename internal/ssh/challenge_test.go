@@ -0,0 +1,66 @@
+package ssh
+
+import (
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestWithoutChallenge(t *testing.T) {
+	var tried authAttempts
+	ki := keyboardInteractive(func(string, string, []string, []bool) ([]string, error) {
+		return nil, nil
+	}, "pw", &tried)
+	config := &gossh.ClientConfig{Auth: []gossh.AuthMethod{gossh.Password("pw"), ki}}
+
+	got := withoutChallenge(config)
+	if len(got.Auth) != 1 {
+		t.Fatalf("withoutChallenge kept %d methods, want 1", len(got.Auth))
+	}
+	if _, ok := got.Auth[0].(gossh.KeyboardInteractiveChallenge); ok {
+		t.Error("keyboard-interactive method kept")
+	}
+	if len(config.Auth) != 2 {
+		t.Error("withoutChallenge modified the original config")
+	}
+}
+
+func TestKeyboardInteractiveAnswers(t *testing.T) {
+	asked := 0
+	fn := func(name, instruction string, questions []string, echo []bool) ([]string, error) {
+		asked++
+		return []string{"123456"}, nil
+	}
+
+	tests := []struct {
+		name      string
+		password  string
+		questions []string
+		echo      []bool
+		want      []string
+		asked     int
+	}{
+		{"empty round", "pw", nil, nil, nil, 0},
+		{"password prompt", "pw", []string{"Password: "}, []bool{false}, []string{"pw"}, 0},
+		{"no password", "", []string{"Password: "}, []bool{false}, []string{"123456"}, 1},
+		{"otp", "pw", []string{"Verification code: "}, []bool{false}, []string{"123456"}, 1},
+	}
+	for _, tt := range tests {
+		asked = 0
+		var tried authAttempts
+		cb := keyboardInteractive(fn, tt.password, &tried).(gossh.KeyboardInteractiveChallenge)
+		got, err := cb("", "", tt.questions, tt.echo)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+			t.Errorf("%s: answers %q, want %q", tt.name, got, tt.want)
+		}
+		if asked != tt.asked {
+			t.Errorf("%s: asked %d times, want %d", tt.name, asked, tt.asked)
+		}
+		if tried.challenge != (len(tt.questions) > 0) {
+			t.Errorf("%s: tried.challenge = %v", tt.name, tried.challenge)
+		}
+	}
+}
@@ -3,16 +3,18 @@ package ssh
 import (
 	"context"
 	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
+	"syscall"
 	"time"
 
 	gossh "golang.org/x/crypto/ssh"
 )
 
 // Client manages an SSH connection to a gateway device.
-// It handles password authentication, host key verification,
+// It handles password and private key authentication, host key verification,
 // keepalive, and provides tunnel dialing.
 type Client struct {
 	conn       *gossh.Client
@@ -22,7 +24,26 @@ type Client struct {
 	ctx        context.Context
 	cancel     context.CancelFunc
 	password   []byte
+	signer     gossh.Signer  // private key offered before the password; see SetIdentity
+	agentConn  net.Conn      // ssh-agent socket while connected; see agent.go
+	noAgent    bool          // SetAgent(false)
+	challenge  ChallengeFunc // keyboard-interactive prompts; see challenge.go
 	knownHosts map[string]gossh.PublicKey
+	banner     string // pre-auth banner (legal notice/MOTD), if the server sent one
+	hostKey    string // "type SHA256:..." of the key accepted on first use
+
+	config       *gossh.ClientConfig // kept for ExecRedial's per-command dials
+	execMode     ExecMode
+	sessionFails int // consecutive session-open failures on conn
+
+	tcp  net.Conn // conn's underlying TCP connection, for SetDSCP
+	dscp int
+
+	sudo bool // ExecSudo elevates with the login password; see sudo.go
+
+	jump *Client // bastion the gateway is dialed through; see jump.go
+
+	recent execRing // last commands and their output; see recent.go
 }
 
 // NewClient creates a new SSH client with an empty known hosts store.
@@ -32,8 +53,9 @@ func NewClient() *Client {
 	}
 }
 
-// Connect establishes an SSH connection using password authentication.
-// If hostKeyAlgos is non-nil, it restricts the host key algorithms
+// Connect establishes an SSH connection using password authentication,
+// preceded by public key auth when SetIdentity gave it a key. The password
+// may then be empty. If hostKeyAlgos is non-nil, it restricts the host key algorithms
 // (needed for Ubiquiti devices that require ssh-rsa).
 //
 // The underlying TCP connection has OS-level keepalive enabled to maintain
@@ -48,30 +70,72 @@ func (c *Client) Connect(host, port, user, password string, hostKeyAlgos []strin
 	}
 
 	addr := net.JoinHostPort(host, port)
+	auth, tried, err := c.authMethods(password)
+	if err != nil {
+		return err
+	}
 
 	// Store password as bytes for later zeroing.
 	c.password = []byte(password)
 
 	config := &gossh.ClientConfig{
-		User: user,
-		Auth: []gossh.AuthMethod{
-			gossh.Password(password),
-		},
+		User:            user,
+		Auth:            auth,
 		HostKeyCallback: c.hostKeyCallback(host),
-		Timeout:         10 * time.Second,
+		BannerCallback: func(message string) error {
+			c.banner = message
+			return nil
+		},
+		Timeout: 10 * time.Second,
 	}
 
 	if len(hostKeyAlgos) > 0 {
 		config.HostKeyAlgorithms = hostKeyAlgos
 	}
 
-	// Dial TCP manually so we can enable OS-level keepalive.
-	// This keeps the connection alive through NAT without sending SSH
-	// global requests that can destabilize embedded SSH servers.
-	tcpConn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	conn, tcp, err := dialSSH(c.jump, addr, config)
 	if err != nil {
 		c.zeroPassword()
-		return fmt.Errorf("ssh: connect to %s: %w", addr, err)
+		err = c.authError(err, tried, password)
+		c.closeAgent()
+		return err
+	}
+	if c.dscp != DSCPNone {
+		if err := setDSCP(tcp, c.dscp); err != nil {
+			tunnelLog().Printf("client: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.conn = conn
+	c.tcp = tcp
+	c.config = withoutChallenge(config)
+	c.gateway = addr
+	c.connected = true
+	c.ctx = ctx
+	c.cancel = cancel
+
+	return nil
+}
+
+// dialSSH opens a TCP connection to addr and runs the SSH handshake.
+// With a non-nil jump the connection is a channel through the bastion
+// instead, and no TCP connection is returned.
+//
+// TCP is dialed manually so we can enable OS-level keepalive. This keeps
+// the connection alive through NAT without sending SSH global requests
+// that can destabilize embedded SSH servers.
+func dialSSH(jump *Client, addr string, config *gossh.ClientConfig) (*gossh.Client, net.Conn, error) {
+	var tcpConn, tcp net.Conn
+	var err error
+	if jump != nil {
+		tcpConn, err = jump.Dial("tcp", addr)
+	} else {
+		tcpConn, err = net.DialTimeout("tcp", addr, 10*time.Second)
+		tcp = tcpConn
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("ssh: connect to %s: %w", addr, err)
 	}
 
 	if tc, ok := tcpConn.(*net.TCPConn); ok {
@@ -83,20 +147,82 @@ func (c *Client) Connect(host, port, user, password string, hostKeyAlgos []strin
 	sshConn, chans, reqs, err := gossh.NewClientConn(tcpConn, addr, config)
 	if err != nil {
 		tcpConn.Close()
-		c.zeroPassword()
-		return fmt.Errorf("ssh: connect to %s: %w", addr, err)
+		return nil, nil, fmt.Errorf("ssh: connect to %s: %w", addr, err)
 	}
+	return gossh.NewClient(sshConn, chans, reqs), tcp, nil
+}
 
-	conn := gossh.NewClient(sshConn, chans, reqs)
+// SetDSCP marks the gateway connection's packets with a DSCP code point,
+// so QoS on the local network can prioritise (or demote) tunnel traffic.
+// It applies at once if connected and to later connections otherwise;
+// DSCPNone clears the marking.
+func (c *Client) SetDSCP(dscp int) error {
+	if dscp < 0 || dscp > 63 {
+		return fmt.Errorf("ssh: DSCP %d out of range 0-63", dscp)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tcp != nil {
+		if err := setDSCP(c.tcp, dscp); err != nil {
+			return err
+		}
+	}
+	c.dscp = dscp
+	return nil
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	c.conn = conn
-	c.gateway = addr
-	c.connected = true
-	c.ctx = ctx
-	c.cancel = cancel
+// DSCP returns the code point set by SetDSCP.
+func (c *Client) DSCP() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dscp
+}
 
-	return nil
+// ConnectWithFallback tries Connect on each port in order, moving on only
+// when a port is unreachable (refused or timed out). Auth and handshake
+// failures are returned immediately since another port won't fix them.
+// It returns the port that was last attempted; on success that port is
+// cached so future sessions try it first.
+func (c *Client) ConnectWithFallback(host string, ports []string, user, password string, hostKeyAlgos []string) (string, error) {
+	if len(ports) == 0 {
+		ports = []string{"22"}
+	}
+
+	log := tunnelLog()
+	var err error
+	for i, port := range ports {
+		if i > 0 {
+			log.Printf("INFO: SSH port %s blocked, trying %s...", ports[i-1], port)
+		}
+		err = c.Connect(host, port, user, password, hostKeyAlgos)
+		if err == nil {
+			RememberPort(host, port)
+			return port, nil
+		}
+		if !IsUnreachable(err) {
+			return port, err
+		}
+	}
+	return ports[len(ports)-1], err
+}
+
+// IsUnreachable reports whether err means the SSH port could not be
+// reached at all (connection refused or timeout), as opposed to the
+// server rejecting the handshake or credentials.
+func IsUnreachable(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// IsProhibited reports whether err is the gateway refusing a forward by
+// policy ("administratively prohibited"), e.g. permitopen= restrictions in
+// authorized_keys or AllowTcpForwarding settings.
+func IsProhibited(err error) bool {
+	var oce *gossh.OpenChannelError
+	return errors.As(err, &oce) && oce.Reason == gossh.Prohibited
 }
 
 // hostKeyCallback returns a callback that verifies host keys against
@@ -107,15 +233,10 @@ func (c *Client) hostKeyCallback(host string) gossh.HostKeyCallback {
 		stored, seen := c.knownHosts[host]
 		if !seen {
 			// First connection: trust on first use, store the key.
+			// The fingerprint is kept for the TUI to show; printing it
+			// here would corrupt the alt-screen display.
 			c.knownHosts[host] = key
-			fp := gossh.FingerprintSHA256(key)
-			fmt.Fprintf(
-				// Print to stderr so it doesn't interfere with TUI stdout.
-				// In practice the TUI will capture this via a message.
-				newStderrWriter(),
-				"Host key for %s (%s):\n  %s\n",
-				host, key.Type(), fp,
-			)
+			c.hostKey = key.Type() + " " + gossh.FingerprintSHA256(key)
 			return nil
 		}
 
@@ -152,17 +273,43 @@ func (c *Client) ServerVersion() string {
 	return string(c.conn.ServerVersion())
 }
 
+// Banner returns the pre-authentication banner the server presented,
+// or an empty string if there was none.
+func (c *Client) Banner() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.banner
+}
+
+// HostKeyFingerprint returns the type and SHA256 fingerprint of the host
+// key accepted on first use, e.g. "ssh-ed25519 SHA256:...", or "" if none.
+func (c *Client) HostKeyFingerprint() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hostKey
+}
+
 // Close shuts down the SSH connection and zeroes the stored password.
+// A bastion given to ConnectVia is closed after the gateway.
 func (c *Client) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.jump != nil {
+		defer c.jump.Close()
+		c.jump = nil
+	}
+
 	if c.cancel != nil {
 		c.cancel()
 	}
 
 	c.zeroPassword()
+	c.signer = nil
+	c.closeAgent()
 	c.connected = false
+	c.config = nil
+	c.tcp = nil
 
 	if c.conn != nil {
 		err := c.conn.Close()
@@ -255,8 +402,6 @@ func (c *Client) Dial(network, addr string) (net.Conn, error) {
 // zeroPassword overwrites the password bytes with zeros.
 // Must be called with c.mu held.
 func (c *Client) zeroPassword() {
-	for i := range c.password {
-		c.password[i] = 0
-	}
+	zeroBytes(c.password)
 	c.password = nil
 }
@@ -0,0 +1,61 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// DSCP code points offered for the gateway connection. Every tunnel rides
+// the one SSH connection (decision 008), so the marking applies to all of
+// them at once; the loopback side never reaches the office network.
+const (
+	DSCPNone        = 0
+	DSCPInteractive = 34 // AF41: interactive video, web and WinBox UIs
+	DSCPBulk        = 8  // CS1: low-priority bulk, e.g. firmware downloads
+)
+
+// ErrDSCPUnsupported means this platform doesn't let lmtm mark its own
+// packets.
+var ErrDSCPUnsupported = errors.New("DSCP marking not supported on this platform")
+
+// DSCPName returns a short label for a code point, e.g. "AF41".
+func DSCPName(dscp int) string {
+	switch dscp {
+	case DSCPNone:
+		return "off"
+	case DSCPInteractive:
+		return "AF41"
+	case DSCPBulk:
+		return "CS1"
+	default:
+		return fmt.Sprintf("DSCP %d", dscp)
+	}
+}
+
+// setDSCP marks the packets conn sends with dscp (0-63) through the IP
+// TOS byte, or the traffic class for IPv6.
+func setDSCP(conn net.Conn, dscp int) error {
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	raw, err := tc.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("ssh: set DSCP: %w", err)
+	}
+	ipv6 := false
+	if addr, ok := tc.RemoteAddr().(*net.TCPAddr); ok {
+		ipv6 = addr.IP.To4() == nil
+	}
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		serr = setTOS(fd, ipv6, dscp<<2)
+	}); err != nil {
+		return fmt.Errorf("ssh: set DSCP: %w", err)
+	}
+	if serr != nil {
+		return fmt.Errorf("ssh: set DSCP: %w", serr)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package ssh
+
+import "testing"
+
+func TestDSCPName(t *testing.T) {
+	tests := []struct {
+		dscp int
+		want string
+	}{
+		{DSCPNone, "off"},
+		{DSCPInteractive, "AF41"},
+		{DSCPBulk, "CS1"},
+		{46, "DSCP 46"},
+	}
+	for _, tt := range tests {
+		if got := DSCPName(tt.dscp); got != tt.want {
+			t.Errorf("DSCPName(%d) = %q, want %q", tt.dscp, got, tt.want)
+		}
+	}
+}
+
+func TestSetDSCPRange(t *testing.T) {
+	c := NewClient()
+	for _, dscp := range []int{-1, 64} {
+		if err := c.SetDSCP(dscp); err == nil {
+			t.Errorf("SetDSCP(%d) accepted an out-of-range code point", dscp)
+		}
+	}
+	if err := c.SetDSCP(DSCPBulk); err != nil {
+		t.Fatalf("SetDSCP: %v", err)
+	}
+	if got := c.DSCP(); got != DSCPBulk {
+		t.Errorf("DSCP = %d, want %d", got, DSCPBulk)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package ssh
+
+import "syscall"
+
+// setTOS sets the TOS byte (IPv4) or traffic class (IPv6) on a socket.
+func setTOS(fd uintptr, ipv6 bool, tos int) error {
+	if ipv6 {
+		return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+	}
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+}
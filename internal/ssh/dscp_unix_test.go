@@ -0,0 +1,61 @@
+//go:build !windows
+
+package ssh
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+// socketTOS reads the TOS byte of the client's gateway connection.
+func socketTOS(t *testing.T, c *Client) int {
+	t.Helper()
+	raw, err := c.tcp.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tos int
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		tos, serr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	return tos
+}
+
+func TestSetDSCPMarksConnection(t *testing.T) {
+	c := connectFake(t, newFakeServer(t, "SSH-2.0-OpenSSH_9.6", nil))
+	if tos := socketTOS(t, c); tos != 0 {
+		t.Fatalf("TOS before marking = %#x, want 0", tos)
+	}
+	for _, dscp := range []int{DSCPInteractive, DSCPBulk, DSCPNone} {
+		if err := c.SetDSCP(dscp); err != nil {
+			t.Fatalf("SetDSCP(%s): %v", DSCPName(dscp), err)
+		}
+		if tos := socketTOS(t, c); tos != dscp<<2 {
+			t.Errorf("TOS after SetDSCP(%s) = %#x, want %#x", DSCPName(dscp), tos, dscp<<2)
+		}
+	}
+}
+
+func TestSetDSCPBeforeConnect(t *testing.T) {
+	srv := newFakeServer(t, "SSH-2.0-OpenSSH_9.6", nil)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SSH_AUTH_SOCK", "")
+	c := NewClient()
+	if err := c.SetDSCP(DSCPInteractive); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Connect("127.0.0.1", srv.port(), "admin", "secret", nil); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer c.Close()
+	if tos := socketTOS(t, c); tos != DSCPInteractive<<2 {
+		t.Errorf("TOS = %#x, want %#x", tos, DSCPInteractive<<2)
+	}
+}
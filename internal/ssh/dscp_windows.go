@@ -0,0 +1,7 @@
+package ssh
+
+// setTOS is a no-op on Windows, which ignores IP_TOS from applications
+// and marks packets only through QoS policy.
+func setTOS(fd uintptr, ipv6 bool, tos int) error {
+	return ErrDSCPUnsupported
+}
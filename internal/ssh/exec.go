@@ -1,30 +1,180 @@
 package ssh
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// ExecMode selects how Exec reaches the gateway.
+type ExecMode int
+
+const (
+	// ExecShared opens a session per command on the client's one
+	// connection. This is the normal mode.
+	ExecShared ExecMode = iota
+	// ExecRedial dials a fresh connection for every command, for locked
+	// down gateways that tear the connection down after one session.
+	ExecRedial
 )
 
+// String returns the mode name.
+func (m ExecMode) String() string {
+	if m == ExecRedial {
+		return "redial"
+	}
+	return "shared"
+}
+
+// sessionFailLimit is how many session-open failures in a row switch a
+// client from ExecShared to ExecRedial.
+const sessionFailLimit = 2
+
+// ExecMode returns how Exec currently runs commands. A client starts in
+// ExecShared and moves to ExecRedial by itself if sessions keep failing.
+func (c *Client) ExecMode() ExecMode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.execMode
+}
+
+// SetExecMode forces a mode, e.g. for a gateway already known to drop
+// sessions.
+func (c *Client) SetExecMode(mode ExecMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.execMode = mode
+	c.sessionFails = 0
+}
+
 // Exec runs a command on the remote gateway and returns the combined
-// stdout+stderr output. It creates a new SSH session per call, which
-// is cheap on a multiplexed SSH connection. The context controls
-// cancellation and timeout.
-func (c *Client) Exec(ctx context.Context, cmd string) (string, error) {
+// stdout+stderr output. On a non-zero exit the output is still returned
+// alongside an error wrapping *gossh.ExitError, so callers can decide
+// whether the output is usable (grep with no match, partial ping
+// sweeps). It creates a new SSH session per call, which is cheap on a
+// multiplexed SSH connection. The context controls cancellation and
+// timeout.
+//
+// A command whose session can't be opened is retried on a fresh
+// connection, and after sessionFailLimit such failures in a row the
+// client switches to ExecRedial, so a multi-command survey still
+// completes on gateways that drop the connection after each command.
+func (c *Client) Exec(ctx context.Context, cmd string) (out string, err error) {
+	if label, ok := ctx.Value(execTraceKey{}).(string); ok {
+		defer func() { traceExec(label, cmd, out, err) }()
+	}
+	defer func() { c.recent.add(ExecRecord{At: time.Now(), Cmd: cmd, Output: out, Err: err}) }()
+	return c.exec(ctx, cmd, nil)
+}
+
+// exec runs cmd with stdin fed to it, for Exec and ExecSudo.
+func (c *Client) exec(ctx context.Context, cmd string, stdin []byte) (string, error) {
 	c.mu.RLock()
 	conn := c.conn
 	connected := c.connected
+	mode := c.execMode
 	c.mu.RUnlock()
 
 	if !connected || conn == nil {
 		return "", fmt.Errorf("ssh: not connected, cannot exec %q", cmd)
 	}
+	if mode == ExecRedial {
+		return c.execRedial(ctx, cmd, stdin)
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		if c.noteSessionFailure() {
+			tunnelLog().Printf("exec: %d session opens failed on %s, re-dialing per command", sessionFailLimit, c.gateway)
+		}
+		// Retry once on a fresh connection so this command isn't lost.
+		if out, rerr := c.execRedial(ctx, cmd, stdin); rerr == nil {
+			return out, nil
+		}
+		return "", fmt.Errorf("ssh: new session for %q: %w", cmd, err)
+	}
+	c.mu.Lock()
+	c.sessionFails = 0
+	c.mu.Unlock()
+	return runSession(ctx, session, cmd, stdin)
+}
+
+// execTraceKey marks a context whose Exec calls are logged in full.
+type execTraceKey struct{}
+
+// WithExecTrace returns a context under which Exec writes each command
+// and its output to the tunnel log under label, so a phase re-run on
+// request shows exactly what the gateway was asked and what it said.
+func WithExecTrace(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, execTraceKey{}, label)
+}
+
+// traceExec logs one traced command with its output indented below it.
+func traceExec(label, cmd, out string, err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: $ %s", label, cmd)
+	for _, line := range strings.Split(strings.TrimRight(out, "\r\n"), "\n") {
+		if line != "" {
+			b.WriteString("\n    " + line)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(&b, "\n    (error: %v)", err)
+	}
+	tunnelLog().Print(b.String())
+}
+
+// noteSessionFailure counts a failed session open and reports whether
+// that tipped the client into ExecRedial.
+func (c *Client) noteSessionFailure() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessionFails++
+	if c.sessionFails < sessionFailLimit || c.execMode == ExecRedial {
+		return false
+	}
+	c.execMode = ExecRedial
+	return true
+}
+
+// execRedial runs cmd on a connection of its own and closes it after.
+func (c *Client) execRedial(ctx context.Context, cmd string, stdin []byte) (string, error) {
+	c.mu.RLock()
+	addr := c.gateway
+	config := c.config
+	jump := c.jump
+	c.mu.RUnlock()
+	if config == nil {
+		return "", fmt.Errorf("ssh: not connected, cannot exec %q", cmd)
+	}
+
+	// The banner was already captured by Connect; don't race it.
+	cfg := *config
+	cfg.BannerCallback = nil
+	conn, _, err := dialSSH(jump, addr, &cfg)
+	if err != nil {
+		return "", fmt.Errorf("ssh: exec %q: %w", cmd, err)
+	}
+	defer conn.Close()
 
 	session, err := conn.NewSession()
 	if err != nil {
 		return "", fmt.Errorf("ssh: new session for %q: %w", cmd, err)
 	}
+	return runSession(ctx, session, cmd, stdin)
+}
+
+// runSession runs cmd on session, closing it when done or when ctx ends.
+// A non-nil stdin is the command's whole standard input.
+func runSession(ctx context.Context, session *gossh.Session, cmd string, stdin []byte) (string, error) {
 	defer session.Close()
+	if stdin != nil {
+		session.Stdin = bytes.NewReader(stdin)
+	}
 
 	// Run the command in a goroutine so we can respect context cancellation.
 	type result struct {
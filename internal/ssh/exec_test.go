@@ -0,0 +1,272 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+)
+
+// fakeServer is an SSH server on loopback that accepts any password,
+// answers exec requests from replies (by command prefix, exit status 1
+// for anything else) and forwards direct-tcpip channels for real.
+type fakeServer struct {
+	ln      net.Listener
+	config  *gossh.ServerConfig
+	replies map[string]string
+
+	// oneSession closes the connection after its first session, like
+	// locked-down gateways that allow one command per login.
+	oneSession bool
+
+	mu    sync.Mutex
+	conns int
+	cmds  []string
+}
+
+func newFakeServer(t *testing.T, version string, replies map[string]string) *fakeServer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &gossh.ServerConfig{
+		PasswordCallback: func(gossh.ConnMetadata, []byte) (*gossh.Permissions, error) { return nil, nil },
+		ServerVersion:    version,
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, config: config, replies: replies}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(nc)
+		}
+	}()
+	return s
+}
+
+// port returns the port the server listens on.
+func (s *fakeServer) port() string {
+	return strings.TrimPrefix(s.ln.Addr().String(), "127.0.0.1:")
+}
+
+// connections returns how many SSH connections the server has accepted.
+func (s *fakeServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *fakeServer) handle(nc net.Conn) {
+	conn, chans, reqs, err := gossh.NewServerConn(nc, s.config)
+	if err != nil {
+		nc.Close()
+		return
+	}
+	defer conn.Close()
+	s.mu.Lock()
+	s.conns++
+	s.mu.Unlock()
+	go gossh.DiscardRequests(reqs)
+
+	for nch := range chans {
+		switch nch.ChannelType() {
+		case "session":
+			ch, chReqs, err := nch.Accept()
+			if err != nil {
+				return
+			}
+			s.session(ch, chReqs)
+			if s.oneSession {
+				return
+			}
+		case "direct-tcpip":
+			go s.forward(nch)
+		default:
+			nch.Reject(gossh.UnknownChannelType, "unsupported")
+		}
+	}
+}
+
+// session answers one exec request and closes the channel.
+func (s *fakeServer) session(ch gossh.Channel, reqs <-chan *gossh.Request) {
+	defer ch.Close()
+	for req := range reqs {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		var exec struct{ Command string }
+		if err := gossh.Unmarshal(req.Payload, &exec); err != nil {
+			req.Reply(false, nil)
+			return
+		}
+		req.Reply(true, nil)
+		s.mu.Lock()
+		s.cmds = append(s.cmds, exec.Command)
+		s.mu.Unlock()
+
+		status := uint32(1)
+		for prefix, out := range s.replies {
+			if strings.HasPrefix(exec.Command, prefix) {
+				io.WriteString(ch, out)
+				status = 0
+				break
+			}
+		}
+		ch.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+// forward connects a direct-tcpip channel to the address it names.
+func (s *fakeServer) forward(nch gossh.NewChannel) {
+	var target struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	if err := gossh.Unmarshal(nch.ExtraData(), &target); err != nil {
+		nch.Reject(gossh.ConnectionFailed, err.Error())
+		return
+	}
+	dst, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+	if err != nil {
+		nch.Reject(gossh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := nch.Accept()
+	if err != nil {
+		dst.Close()
+		return
+	}
+	go gossh.DiscardRequests(reqs)
+	go func() {
+		io.Copy(dst, ch)
+		dst.Close()
+	}()
+	io.Copy(ch, dst)
+	ch.Close()
+}
+
+// connectFake connects a new client to srv.
+func connectFake(t *testing.T, srv *fakeServer) *Client {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SSH_AUTH_SOCK", "")
+	c := NewClient()
+	if err := c.Connect("127.0.0.1", srv.port(), "admin", "secret", nil); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// mikrotikSurvey answers the commands a RouterOS survey runs.
+var mikrotikSurvey = map[string]string{
+	"/system identity print":                          "  name: core-rtr\n",
+	`/ip address print terse where interface~"bridge`: " 0   address=192.168.88.1/24 network=192.168.88.0 interface=bridge\n",
+	`/ip address print terse where interface~"ether1`: " 0   address=203.0.113.7/24 network=203.0.113.0 interface=ether1\n",
+	"/ip pool print terse":                            " 0 name=dhcp ranges=192.168.88.10-192.168.88.254\n",
+	"/ip route print terse":                           " 0  As dst-address=0.0.0.0/0 gateway=203.0.113.1\n",
+	"/ip arp print terse":                             " 0 DC 192.168.88.10 00:0C:29:11:22:33 bridge\n",
+}
+
+// survey runs a RouterOS survey through c the way the wizard does.
+func survey(t *testing.T, c *Client) {
+	t.Helper()
+	ctx := context.Background()
+	gw, err := gateway.Detect(ctx, c.ServerVersion(), c.Exec)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if gw.Type() != gateway.TypeMikroTik {
+		t.Fatalf("detected %s, want mikrotik", gw.Type())
+	}
+	if name, err := gw.Identity(ctx); err != nil || name != "core-rtr" {
+		t.Errorf("Identity = %q, %v", name, err)
+	}
+	if lan, err := gw.LANInfo(ctx); err != nil || lan.Subnet != "192.168.88" {
+		t.Errorf("LANInfo = %+v, %v", lan, err)
+	}
+	if wan, err := gw.WANInfo(ctx); err != nil || wan.PublicIP != "203.0.113.7/24" || wan.Gateway != "203.0.113.1" {
+		t.Errorf("WANInfo = %+v, %v", wan, err)
+	}
+	if arp, err := gw.ARPTable(ctx, "192.168.88"); err != nil || len(arp) != 1 {
+		t.Errorf("ARPTable = %+v, %v", arp, err)
+	}
+}
+
+func TestSurveyOnGatewayClosingAfterEachSession(t *testing.T) {
+	srv := newFakeServer(t, "SSH-2.0-ROSSSH", mikrotikSurvey)
+	srv.oneSession = true
+	c := connectFake(t, srv)
+
+	survey(t, c)
+
+	if c.ExecMode() != ExecRedial {
+		t.Errorf("ExecMode = %v, want redial after sessions kept failing", c.ExecMode())
+	}
+	// The login ran the first command; every later one needed its own.
+	srv.mu.Lock()
+	cmds := len(srv.cmds)
+	srv.mu.Unlock()
+	if got := srv.connections(); got != cmds {
+		t.Errorf("server saw %d connections for %d commands, want one each", got, cmds)
+	}
+}
+
+func TestSurveyOnPersistentGateway(t *testing.T) {
+	srv := newFakeServer(t, "SSH-2.0-ROSSSH", mikrotikSurvey)
+	c := connectFake(t, srv)
+
+	survey(t, c)
+
+	if c.ExecMode() != ExecShared {
+		t.Errorf("ExecMode = %v, want shared", c.ExecMode())
+	}
+	if got := srv.connections(); got != 1 {
+		t.Errorf("server saw %d connections, want 1", got)
+	}
+}
+
+func TestExecModeForced(t *testing.T) {
+	srv := newFakeServer(t, "SSH-2.0-ROSSSH", mikrotikSurvey)
+	c := connectFake(t, srv)
+	c.SetExecMode(ExecRedial)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Exec(context.Background(), "/system identity print"); err != nil {
+			t.Fatalf("Exec: %v", err)
+		}
+	}
+	if got := srv.connections(); got != 3 {
+		t.Errorf("server saw %d connections, want the login plus one per command", got)
+	}
+	if ExecRedial.String() != "redial" || ExecShared.String() != "shared" {
+		t.Errorf("mode names = %q, %q", ExecRedial, ExecShared)
+	}
+}
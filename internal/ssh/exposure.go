@@ -0,0 +1,70 @@
+package ssh
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// Exposure checks. Tunnels bind to 127.0.0.1 only, so the same port on
+// the machine's LAN address should refuse connections. If it accepts, a
+// stale "ssh -g", another tool or a firewall redirect is exposing the
+// port to the network.
+const (
+	exposureTimeout         = 500 * time.Millisecond
+	exposureChecksPerSecond = 5
+	// exposureWindow is how long after a check an accepted connection
+	// from the LAN address is taken to be the check itself, redirected to
+	// our loopback listener, rather than real traffic.
+	exposureWindow = 2 * time.Second
+)
+
+// primaryLANIP returns the address the OS would use to reach the
+// internet, or nil if there is none or it is loopback. Dialing UDP sends
+// no packets; it only asks the routing table.
+func primaryLANIP() net.IP {
+	conn, err := net.Dial("udp", "192.0.2.1:9")
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok || addr.IP.IsLoopback() || addr.IP.IsUnspecified() {
+		return nil
+	}
+	return addr.IP
+}
+
+// checkExposure dials the tunnel's port on the LAN address and reports
+// whether anything answered.
+func (t *Tunnel) checkExposure() bool {
+	if t.checkIP == "" {
+		return false
+	}
+	t.checkUntil.Store(time.Now().Add(exposureWindow).UnixNano())
+	addr := net.JoinHostPort(t.checkIP, strconv.Itoa(t.LocalPort))
+	conn, err := net.DialTimeout("tcp", addr, exposureTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	t.exposed.Store(true)
+	return true
+}
+
+// isExposureCheck reports whether an accepted connection is our own
+// exposure check arriving through a redirect, so it isn't forwarded or
+// counted as traffic.
+func (t *Tunnel) isExposureCheck(conn net.Conn) bool {
+	if t.checkIP == "" || time.Now().UnixNano() > t.checkUntil.Load() {
+		return false
+	}
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	return err == nil && host == t.checkIP
+}
+
+// Exposed reports whether the exposure check found the tunnel's port
+// reachable on the LAN address.
+func (t *Tunnel) Exposed() bool {
+	return t.exposed.Load()
+}
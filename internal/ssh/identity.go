@@ -0,0 +1,168 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// ErrNoAuth is returned by Connect when it has no password, private key
+// or ssh-agent to offer.
+var ErrNoAuth = errors.New("ssh: no password, private key or ssh-agent to log in with")
+
+// LoadIdentity reads an OpenSSH, PEM or PKCS#8 private key from path for
+// public key auth. An encrypted key is unlocked with passphrase; a
+// leading ~/ in path is the home directory.
+func LoadIdentity(path string, passphrase []byte) (gossh.Signer, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("ssh: key %s: %w", path, err)
+		}
+		path = filepath.Join(home, rest)
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: key: %w", err)
+	}
+	defer zeroBytes(pem)
+
+	signer, err := gossh.ParsePrivateKey(pem)
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("ssh: key %s is encrypted and no passphrase was given", path)
+		}
+		signer, err = gossh.ParsePrivateKeyWithPassphrase(pem, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ssh: key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// SetIdentity makes Connect offer the private key at path before the
+// password, which is then only tried if the gateway refuses the key. An
+// empty path goes back to password auth alone. The passphrase is only
+// needed while the key is loaded and is not kept.
+func (c *Client) SetIdentity(path, passphrase string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if path == "" {
+		c.signer = nil
+		return nil
+	}
+	pass := []byte(passphrase)
+	defer zeroBytes(pass)
+	signer, err := LoadIdentity(path, pass)
+	if err != nil {
+		return err
+	}
+	c.signer = signer
+	return nil
+}
+
+// AuthError is returned by Connect when the gateway accepted none of the
+// logins offered. A bare "unable to authenticate" doesn't tell a refused
+// key from a wrong password, so it records which ones were tried.
+type AuthError struct {
+	KeyOffered      bool // a private key was set with SetIdentity or held by ssh-agent
+	KeyTried        bool // the gateway was asked to accept it
+	PasswordOffered bool // Connect was given a password
+	PasswordTried   bool // the gateway was sent it
+	ChallengeTried  bool // keyboard-interactive prompts were answered
+	Err             error
+}
+
+// Error names the methods the gateway refused.
+func (e *AuthError) Error() string {
+	var msg string
+	switch {
+	case e.KeyTried && e.PasswordTried:
+		msg = "gateway refused both the private key and the password"
+	case e.KeyTried && e.PasswordOffered:
+		msg = "gateway refused the private key and does not allow password login"
+	case e.KeyTried:
+		msg = "gateway refused the private key"
+	case e.PasswordTried && e.KeyOffered:
+		msg = "gateway does not allow key login and refused the password"
+	case e.PasswordTried:
+		msg = "gateway refused the password"
+	case e.ChallengeTried:
+		msg = "gateway refused the answers to its login prompts"
+	default:
+		msg = "gateway allows none of the offered login methods"
+	}
+	return "ssh: " + msg
+}
+
+// Unwrap returns the handshake error.
+func (e *AuthError) Unwrap() error { return e.Err }
+
+// authAttempts records which auth methods the handshake got to.
+type authAttempts struct {
+	key, password, challenge bool
+}
+
+// authMethods lists what Connect offers, key file first, then the keys in
+// ssh-agent, then the password, then keyboard-interactive, each noting in the returned attempts when
+// the handshake uses it. Must be called with c.mu held.
+func (c *Client) authMethods(password string) ([]gossh.AuthMethod, *authAttempts, error) {
+	tried := &authAttempts{}
+	signer := c.signer
+	ag := c.openAgent()
+	var methods []gossh.AuthMethod
+	if signer != nil || ag != nil {
+		methods = append(methods, gossh.PublicKeysCallback(func() ([]gossh.Signer, error) {
+			var signers []gossh.Signer
+			if signer != nil {
+				signers = append(signers, signer)
+			}
+			if ag != nil {
+				signers = append(signers, agentSigners(ag)...)
+			}
+			tried.key = tried.key || len(signers) > 0
+			return signers, nil
+		}))
+	}
+	if password != "" {
+		methods = append(methods, gossh.PasswordCallback(func() (string, error) {
+			tried.password = true
+			return password, nil
+		}))
+	}
+	if c.challenge != nil {
+		methods = append(methods, keyboardInteractive(c.challenge, password, tried))
+	}
+	if len(methods) == 0 {
+		return nil, nil, ErrNoAuth
+	}
+	return methods, tried, nil
+}
+
+// authError turns a handshake that ran out of auth methods into an
+// AuthError; other errors are returned as they are.
+func (c *Client) authError(err error, tried *authAttempts, password string) error {
+	if err == nil || !strings.Contains(err.Error(), "unable to authenticate") {
+		return err
+	}
+	return &AuthError{
+		KeyOffered:      c.signer != nil || c.agentConn != nil,
+		KeyTried:        tried.key,
+		PasswordOffered: password != "",
+		PasswordTried:   tried.password,
+		ChallengeTried:  tried.challenge,
+		Err:             err,
+	}
+}
+
+// zeroBytes overwrites b with zeros.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,41 @@
+package ssh
+
+import "fmt"
+
+// ConnectVia is Connect for a gateway only reachable from a bastion: the
+// gateway's SSH port is dialed through jump, an already connected client,
+// and everything after the handshake (Exec, Dial and so the tunnels) runs
+// over that chained connection. On success c owns jump and Close closes
+// both; on failure jump is left open for the caller to retry or close.
+//
+// DSCP marking is the bastion's to set, since only its connection has a
+// socket of its own.
+func (c *Client) ConnectVia(jump *Client, host, port, user, password string, hostKeyAlgos []string) error {
+	if !jump.IsConnected() {
+		return fmt.Errorf("ssh: jump host not connected, cannot reach %s", host)
+	}
+	c.mu.Lock()
+	c.jump = jump
+	c.mu.Unlock()
+
+	err := c.Connect(host, port, user, password, hostKeyAlgos)
+	if err != nil {
+		c.mu.Lock()
+		c.jump = nil
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// JumpHost returns the host:port of the bastion the gateway is reached
+// through, or "" for a direct connection.
+func (c *Client) JumpHost() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.jump == nil {
+		return ""
+	}
+	c.jump.mu.RLock()
+	defer c.jump.mu.RUnlock()
+	return c.jump.gateway
+}
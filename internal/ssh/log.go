@@ -17,14 +17,9 @@ var (
 // The log file is created on first use and kept open for the process lifetime.
 func tunnelLog() *log.Logger {
 	logOnce.Do(func() {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			home = "/tmp"
-		}
-		dir := filepath.Join(home, ".lmtm")
-		os.MkdirAll(dir, 0700)
+		path := LogPath()
+		os.MkdirAll(filepath.Dir(path), 0700)
 
-		path := filepath.Join(dir, "tunnel.log")
 		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
 		if err != nil {
 			// Fallback: discard logs silently.
@@ -36,3 +31,18 @@ func tunnelLog() *log.Logger {
 	})
 	return tunnelLogger
 }
+
+// Logf writes a line to the tunnel log. It is for packages that have no
+// logger of their own but whose choices matter when debugging a session.
+func Logf(format string, args ...any) {
+	tunnelLog().Printf(format, args...)
+}
+
+// LogPath returns the path of the tunnel log, whether or not it exists yet.
+func LogPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "/tmp"
+	}
+	return filepath.Join(home, ".lmtm", "tunnel.log")
+}
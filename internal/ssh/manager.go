@@ -1,8 +1,9 @@
 package ssh
 
 import (
-	"context"
+	"errors"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 )
@@ -15,6 +16,11 @@ const (
 	EventActive
 	EventFailed
 	EventClosed
+	EventExposed    // the tunnel's port also answers on the LAN address
+	EventVerified   // the first forwarded connection reached the device
+	EventPruned     // closed by PruneDead: the device never answered
+	EventRestricted // closed after going active: the gateway forbids the destination
+	EventBuildDone  // StartBuild finished, probes included; carries no tunnel
 )
 
 // String returns a human-readable event type.
@@ -28,6 +34,16 @@ func (e EventType) String() string {
 		return "failed"
 	case EventClosed:
 		return "closed"
+	case EventExposed:
+		return "exposed"
+	case EventVerified:
+		return "verified"
+	case EventPruned:
+		return "pruned"
+	case EventRestricted:
+		return "restricted"
+	case EventBuildDone:
+		return "build done"
 	default:
 		return "unknown"
 	}
@@ -38,6 +54,14 @@ func (e EventType) String() string {
 type TunnelEvent struct {
 	Tunnel *Tunnel
 	Type   EventType
+
+	// SpecPort is the local port the TunnelSpec asked for, which the TUI
+	// keys its rows by. LocalPort is the port bound when the event was
+	// sent; it differs after a privileged or taken port was swapped. Err
+	// is the tunnel's error at that point.
+	SpecPort  int
+	LocalPort int
+	Err       error
 }
 
 // TunnelSpec describes a single port forward to build.
@@ -45,43 +69,95 @@ type TunnelSpec struct {
 	RemoteHost string
 	RemotePort int
 	LocalPort  int
+	Class      string // device class label from discovery, e.g. "Camera"; display only
+	Vendor     string // device vendor from discovery; display only
 }
 
 // Manager coordinates multiple tunnels on a single SSH connection.
 // It provides an event channel that the TUI can consume to animate
 // tunnel construction.
 type Manager struct {
-	client   *Client
-	tunnels  []*Tunnel
-	mu       sync.RWMutex
-	eventCh  chan TunnelEvent
-	closed   bool     // guards eventCh against send-after-close panic
-	closeMu  sync.Mutex
-	cancelFn context.CancelFunc // cancels BuildTunnels goroutine
-	buildCtx context.Context
+	client  *Client
+	tunnels []*Tunnel
+	mu      sync.RWMutex
+	eventCh chan TunnelEvent
+	closed  bool // guards eventCh against send-after-close panic
+	closeMu sync.Mutex
+	tracker *goroutineTracker // build, accept and forward goroutines
+	limiter *dialLimiter      // shared across tunnels; nil means unlimited
+	groupMu sync.Mutex        // serialises CloseGroup and RebuildGroup
+
+	// fallbackPort picks a replacement local port when a privileged one
+	// can't be bound; nil means such tunnels just fail.
+	fallbackPort func(remoteHost string, remotePort int) (int, error)
+	releasePort  func(localPort int) // frees a port fallbackPort handed out
+	privileged   bool                // CanBindPrivileged, checked once at creation
+
+	lanIP    net.IP       // for exposure checks; nil skips them
+	exposure *dialLimiter // paces exposure checks
+
+	degradedRatio float64 // see SetDegradedRatio
 }
 
+// DefaultDegradedRatio is the HealthRatio below which a session counts as
+// degraded: more than half its tunnels have failed.
+const DefaultDegradedRatio = 0.5
+
 // NewManager creates a tunnel manager for the given SSH client.
 // eventChSize controls the buffer size of the event channel.
 func NewManager(client *Client, eventChSize int) *Manager {
-	ctx, cancel := context.WithCancel(context.Background())
 	return &Manager{
-		client:   client,
-		eventCh:  make(chan TunnelEvent, eventChSize),
-		cancelFn: cancel,
-		buildCtx: ctx,
+		client:     client,
+		eventCh:    make(chan TunnelEvent, eventChSize),
+		tracker:    newGoroutineTracker(),
+		privileged: CanBindPrivileged(),
+		lanIP:      primaryLANIP(),
+		exposure:   newDialLimiter(exposureChecksPerSecond),
+
+		degradedRatio: DefaultDegradedRatio,
 	}
 }
 
+// SetPortFallback sets how the manager replaces a local port below 1024
+// that this user can't bind. Without it those tunnels fail with
+// ErrPrivilegedPort.
+func (m *Manager) SetPortFallback(fn func(remoteHost string, remotePort int) (int, error)) {
+	m.fallbackPort = fn
+}
+
+// SetPortRelease sets how the manager hands back a local port it gave up
+// on after losing it to another process; see bindRetries.
+func (m *Manager) SetPortRelease(fn func(localPort int)) {
+	m.releasePort = fn
+}
+
 // Events returns a read-only channel of tunnel lifecycle events.
 func (m *Manager) Events() <-chan TunnelEvent {
 	return m.eventCh
 }
 
+// SetDialRate limits how many new forwarded connections per second all
+// tunnels combined may open through the gateway. Zero or negative disables
+// the limit (the default). Must be called before BuildTunnels.
+func (m *Manager) SetDialRate(perSecond float64) {
+	m.limiter = newDialLimiter(perSecond)
+}
+
+// StartBuild runs BuildTunnels in a tracked background goroutine so that
+// CloseAll can wait for it to finish, then emits EventBuildDone.
+func (m *Manager) StartBuild(specs []TunnelSpec) {
+	m.tracker.Go(func() {
+		_ = m.BuildTunnels(specs)
+		m.emit(TunnelEvent{Type: EventBuildDone})
+	})
+}
+
 // BuildTunnels creates and starts tunnels for each spec sequentially.
 // It emits EventStarted before each tunnel starts, then EventActive
 // or EventFailed depending on the outcome. A small delay between
 // tunnels gives the TUI animation time to render each pipe.
+// It returns once the forwarding probes of the tunnels it started have
+// finished, so any EventRestricted comes before it returns.
 // The build loop is cancelled if CloseAll is called concurrently.
 func (m *Manager) BuildTunnels(specs []TunnelSpec) error {
 	if len(specs) == 0 {
@@ -89,30 +165,46 @@ func (m *Manager) BuildTunnels(specs []TunnelSpec) error {
 	}
 
 	var firstErr error
+	var probes sync.WaitGroup
+	defer probes.Wait()
 
 	for _, spec := range specs {
-		// Check if we've been cancelled (CloseAll called during build).
-		select {
-		case <-m.buildCtx.Done():
-			return fmt.Errorf("tunnel: build cancelled")
-		default:
-		}
-
 		tun := NewTunnel(m.client, spec.LocalPort, spec.RemoteHost, spec.RemotePort)
+		// Pre-flight: don't even try a privileged port we know will fail.
+		if spec.LocalPort < 1024 && !m.privileged {
+			tun.setPort(m.substitutePort(spec.LocalPort, spec.RemoteHost, spec.RemotePort))
+		}
+		tun.Class = spec.Class
+		tun.Vendor = spec.Vendor
+		if m.lanIP != nil {
+			tun.checkIP = m.lanIP.String()
+		}
+		tun.limiter = m.limiter
+		tun.tracker = m.tracker
+		tun.onVerified = m.verified
 
+		// Check if we've been cancelled (CloseAll called during build).
+		// The cancel check and append happen under the lock so CloseAll's
+		// snapshot either includes this tunnel or the build stops here.
 		m.mu.Lock()
+		if m.tracker.ctx.Err() != nil {
+			m.mu.Unlock()
+			return fmt.Errorf("tunnel: build cancelled")
+		}
 		m.tunnels = append(m.tunnels, tun)
 		m.mu.Unlock()
 
-		m.emit(TunnelEvent{Tunnel: tun, Type: EventStarted})
+		m.emit(tun.event(EventStarted))
+
+		if err := m.launch(tun, &probes); err != nil && firstErr == nil {
+			firstErr = err
+		}
 
-		if err := tun.Start(); err != nil {
-			m.emit(TunnelEvent{Tunnel: tun, Type: EventFailed})
-			if firstErr == nil {
-				firstErr = err
-			}
-		} else {
-			m.emit(TunnelEvent{Tunnel: tun, Type: EventActive})
+		// CloseAll may have stopped this tunnel before Start bound the
+		// listener; stop it again so its accept loop doesn't outlive us.
+		if m.tracker.ctx.Err() != nil {
+			tun.Stop()
+			return fmt.Errorf("tunnel: build cancelled")
 		}
 
 		// Small delay between tunnels for TUI animation pacing.
@@ -122,6 +214,178 @@ func (m *Manager) BuildTunnels(specs []TunnelSpec) error {
 	return firstErr
 }
 
+// launch starts a tunnel, emitting EventActive or EventFailed with the
+// outcome, then probes it in the background. A dead device costs the
+// probe its whole timeout, so the next tunnel doesn't wait for it; a
+// tunnel the gateway turns out to forbid is closed later with
+// EventRestricted. The probe is added to probes, if given.
+func (m *Manager) launch(tun *Tunnel, probes *sync.WaitGroup) error {
+	err := tun.Start()
+	if errors.Is(err, ErrPrivilegedPort) {
+		// The capability check can be wrong (a container, a sandbox); the
+		// bind is what counts.
+		if port := m.substitutePort(tun.LocalPort, tun.RemoteHost, tun.RemotePort); port != tun.LocalPort {
+			tun.setPort(port)
+			err = tun.Start()
+		}
+	}
+	// Another process can take the port between allocation and bind;
+	// move to a fresh one a few times before giving up.
+	for i := 0; i < bindRetries && errors.Is(err, ErrPortInUse) && m.fallbackPort != nil; i++ {
+		port, ferr := m.fallbackPort(tun.RemoteHost, tun.RemotePort)
+		if ferr != nil {
+			break
+		}
+		Logf("tunnel: %s:%d: local port %d was taken by another process, using %d instead",
+			tun.RemoteHost, tun.RemotePort, tun.LocalPort, port)
+		if m.releasePort != nil {
+			m.releasePort(tun.LocalPort)
+		}
+		tun.setPort(port)
+		err = tun.Start()
+	}
+	if err != nil {
+		m.emit(tun.event(EventFailed))
+		return err
+	}
+	m.emit(tun.event(EventActive))
+	if probes != nil {
+		probes.Add(1)
+	}
+	m.tracker.Go(func() {
+		if probes != nil {
+			defer probes.Done()
+		}
+		m.checkRestricted(tun)
+	})
+	m.tracker.Go(func() { m.checkExposure(tun) })
+	return nil
+}
+
+// checkRestricted probes a tunnel that just went active. If the gateway
+// refuses the destination by policy, the listener is closed so the
+// browser fails fast instead of hanging, and it isn't retried.
+func (m *Manager) checkRestricted(tun *Tunnel) {
+	err := tun.probe()
+	if err == nil || m.tracker.ctx.Err() != nil {
+		return
+	}
+	if status, _ := tun.State(); status != StatusActive {
+		return
+	}
+	tun.Stop()
+	err = fmt.Errorf("tunnel: gateway forbids forwarding to %s:%d: %w",
+		tun.RemoteHost, tun.RemotePort, err)
+	tun.setState(StatusFailed, err)
+	Logf("%v", err)
+	m.emit(tun.event(EventRestricted))
+}
+
+// bindRetries is how many fresh local ports launch tries when the
+// allocated one turns out to be in use by another process.
+const bindRetries = 3
+
+// checkExposure runs the tunnel's exposure check at the manager's pace
+// and reports a hit to the log and the event channel.
+func (m *Manager) checkExposure(tun *Tunnel) {
+	if tun.checkIP == "" || m.exposure.Wait(m.tracker.ctx) != nil {
+		return
+	}
+	if !tun.checkExposure() {
+		return
+	}
+	tunnelLog().Printf("WARN: exposure: 127.0.0.1:%d is also reachable at %s:%d -- another process or a firewall redirect is listening on the external interface",
+		tun.LocalPort, tun.checkIP, tun.LocalPort)
+	m.emit(tun.event(EventExposed))
+}
+
+// verified reports a tunnel's first successful forward.
+func (m *Manager) verified(tun *Tunnel) {
+	Logf("tunnel: 127.0.0.1:%d -> %s:%d verified by its first connection",
+		tun.LocalPort, tun.RemoteHost, tun.RemotePort)
+	m.emit(tun.event(EventVerified))
+}
+
+// substitutePort returns the fallback port for a privileged local port,
+// logging the swap, or the original port if there's no fallback.
+func (m *Manager) substitutePort(localPort int, remoteHost string, remotePort int) int {
+	if m.fallbackPort == nil {
+		return localPort
+	}
+	port, err := m.fallbackPort(remoteHost, remotePort)
+	if err != nil {
+		return localPort
+	}
+	Logf("tunnel: %s:%d: local port %d needs privileges, using %d instead",
+		remoteHost, remotePort, localPort, port)
+	return port
+}
+
+// CloseGroup stops the tunnels listening on the given local ports and
+// emits EventClosed for each. Ports without a tunnel are ignored.
+func (m *Manager) CloseGroup(localPorts []int) error {
+	m.groupMu.Lock()
+	defer m.groupMu.Unlock()
+
+	var firstErr error
+	for _, tun := range m.tunnelsOn(localPorts) {
+		if err := tun.Stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		m.emit(tun.event(EventClosed))
+	}
+	return firstErr
+}
+
+// RebuildGroup restarts every tunnel on the given local ports that isn't
+// active -- failed ones and ones closed by CloseGroup. Each emits
+// EventStarted and then EventActive or EventFailed, as during the build.
+// Tunnels the gateway forbids are left alone; retrying can't help them.
+func (m *Manager) RebuildGroup(localPorts []int) error {
+	m.groupMu.Lock()
+	defer m.groupMu.Unlock()
+
+	var firstErr error
+	for _, tun := range m.tunnelsOn(localPorts) {
+		if status, err := tun.State(); status == StatusActive || IsProhibited(err) {
+			continue
+		}
+		if m.tracker.ctx.Err() != nil {
+			return fmt.Errorf("tunnel: rebuild cancelled")
+		}
+		tun.reset()
+		m.emit(tun.event(EventStarted))
+		if err := m.launch(tun, nil); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		// Same race as in BuildTunnels: CloseAll may have run mid-launch.
+		if m.tracker.ctx.Err() != nil {
+			tun.Stop()
+			return fmt.Errorf("tunnel: rebuild cancelled")
+		}
+	}
+	return firstErr
+}
+
+// tunnelsOn returns the managed tunnels listening on the given local
+// ports, in build order.
+func (m *Manager) tunnelsOn(localPorts []int) []*Tunnel {
+	want := make(map[int]bool, len(localPorts))
+	for _, p := range localPorts {
+		want[p] = true
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []*Tunnel
+	for _, tun := range m.tunnels {
+		if want[tun.Port()] {
+			result = append(result, tun)
+		}
+	}
+	return result
+}
+
 // Tunnels returns a snapshot of all managed tunnels.
 func (m *Manager) Tunnels() []*Tunnel {
 	m.mu.RLock()
@@ -131,12 +395,67 @@ func (m *Manager) Tunnels() []*Tunnel {
 	return result
 }
 
+// StopAccepting closes every tunnel's listener while leaving open
+// forwarded connections running, so a disconnect can let uploads and
+// other transfers finish before CloseAll cuts them. Watch
+// ActiveConnections to see them drain.
+func (m *Manager) StopAccepting() {
+	for _, tun := range m.Tunnels() {
+		tun.StopAccepting()
+	}
+}
+
+// ActiveConnections returns the number of forwarded connections open
+// across all tunnels.
+func (m *Manager) ActiveConnections() int64 {
+	var n int64
+	for _, tun := range m.Tunnels() {
+		n += tun.ActiveConnections()
+	}
+	return n
+}
+
+// HealthRatio returns the fraction of tunnels that are active, from 0 to
+// 1. Only active and failed tunnels count: ones still connecting or
+// closed on purpose say nothing about the session. With none of either it
+// returns 1.
+func (m *Manager) HealthRatio() float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var active, failed int
+	for _, tun := range m.tunnels {
+		switch status, _ := tun.State(); status {
+		case StatusActive:
+			active++
+		case StatusFailed:
+			failed++
+		}
+	}
+	if active+failed == 0 {
+		return 1
+	}
+	return float64(active) / float64(active+failed)
+}
+
+// SetDegradedRatio sets the HealthRatio below which Degraded reports true.
+// Zero never reports degraded; the default is DefaultDegradedRatio.
+func (m *Manager) SetDegradedRatio(ratio float64) {
+	m.degradedRatio = ratio
+}
+
+// Degraded reports whether too many tunnels have failed for the session
+// to be called healthy, even though the SSH connection itself is up.
+func (m *Manager) Degraded() bool {
+	return m.HealthRatio() < m.degradedRatio
+}
+
 // CloseAll stops all tunnels, emits EventClosed for each, closes
-// the event channel, and closes the underlying SSH client.
+// the event channel, and closes the underlying SSH client. It then waits
+// for the build, accept and forward goroutines to exit.
 // Safe to call while BuildTunnels is running in a goroutine.
 func (m *Manager) CloseAll() error {
 	// Cancel any in-progress BuildTunnels goroutine first.
-	m.cancelFn()
+	m.tracker.cancel()
 
 	m.mu.Lock()
 	tunnels := make([]*Tunnel, len(m.tunnels))
@@ -148,7 +467,7 @@ func (m *Manager) CloseAll() error {
 		if err := tun.Stop(); err != nil && firstErr == nil {
 			firstErr = err
 		}
-		m.emit(TunnelEvent{Tunnel: tun, Type: EventClosed})
+		m.emit(tun.event(EventClosed))
 	}
 
 	// Mark closed before closing the channel to prevent send-after-close panic.
@@ -161,6 +480,11 @@ func (m *Manager) CloseAll() error {
 		firstErr = err
 	}
 
+	// Closing the client unblocks any forwards still copying data.
+	if !m.tracker.Wait(time.Second) {
+		tunnelLog().Printf("manager: background goroutines still running 1s after close")
+	}
+
 	return firstErr
 }
 
@@ -0,0 +1,360 @@
+package ssh
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// freePort returns a loopback port nothing is listening on.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+// nextEvent waits for the manager's next event.
+func nextEvent(t *testing.T, m *Manager) TunnelEvent {
+	t.Helper()
+	select {
+	case ev := <-m.Events():
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event within 2s")
+		return TunnelEvent{}
+	}
+}
+
+func TestBuildTunnelsPortTakenDuringBuild(t *testing.T) {
+	// Another process holds the allocated port by the time the tunnel binds.
+	held, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	specPort := held.Addr().(*net.TCPAddr).Port
+	fallback := freePort(t)
+
+	m := NewManager(NewClient(), 16)
+	defer m.CloseAll()
+	var released []int
+	m.SetPortFallback(func(string, int) (int, error) { return fallback, nil })
+	m.SetPortRelease(func(port int) { released = append(released, port) })
+
+	if err := m.BuildTunnels([]TunnelSpec{{RemoteHost: "192.0.2.10", RemotePort: 80, LocalPort: specPort}}); err != nil {
+		t.Fatalf("BuildTunnels: %v", err)
+	}
+
+	tests := []struct {
+		typ       EventType
+		localPort int
+	}{
+		{EventStarted, specPort},
+		{EventActive, fallback},
+	}
+	for _, tt := range tests {
+		ev := nextEvent(t, m)
+		if ev.Type != tt.typ || ev.SpecPort != specPort || ev.LocalPort != tt.localPort {
+			t.Errorf("event = %v spec %d local %d, want %v spec %d local %d",
+				ev.Type, ev.SpecPort, ev.LocalPort, tt.typ, specPort, tt.localPort)
+		}
+	}
+
+	tun := m.Tunnels()[0]
+	if tun.Port() != fallback || tun.SpecPort() != specPort {
+		t.Errorf("tunnel on %d for spec %d, want %d for spec %d", tun.Port(), tun.SpecPort(), fallback, specPort)
+	}
+	if len(released) != 1 || released[0] != specPort {
+		t.Errorf("released %v, want [%d]", released, specPort)
+	}
+}
+
+func TestBuildTunnelsSubstitutesPrivilegedPort(t *testing.T) {
+	fallback := freePort(t)
+	m := NewManager(NewClient(), 16)
+	defer m.CloseAll()
+	m.privileged = false
+	m.SetPortFallback(func(string, int) (int, error) { return fallback, nil })
+
+	if err := m.BuildTunnels([]TunnelSpec{{RemoteHost: "192.0.2.10", RemotePort: 443, LocalPort: 443}}); err != nil {
+		t.Fatalf("BuildTunnels: %v", err)
+	}
+
+	// The swap happens before the tunnel is announced, so both events
+	// already carry the substitute alongside the spec's port.
+	for _, want := range []EventType{EventStarted, EventActive} {
+		ev := nextEvent(t, m)
+		if ev.Type != want || ev.SpecPort != 443 || ev.LocalPort != fallback {
+			t.Errorf("event = %v spec %d local %d, want %v spec 443 local %d",
+				ev.Type, ev.SpecPort, ev.LocalPort, want, fallback)
+		}
+	}
+}
+
+func TestRebuildGroupSkipsProhibited(t *testing.T) {
+	m := NewManager(NewClient(), 16)
+	defer m.CloseAll()
+
+	port := freePort(t)
+	tun := NewTunnel(m.client, port, "192.0.2.10", 80)
+	refusal := &gossh.OpenChannelError{Reason: gossh.Prohibited, Message: "administratively prohibited"}
+	tun.setState(StatusFailed, refusal)
+	m.tunnels = append(m.tunnels, tun)
+
+	if err := m.RebuildGroup([]int{port}); err != nil {
+		t.Fatalf("RebuildGroup: %v", err)
+	}
+	select {
+	case ev := <-m.Events():
+		t.Errorf("RebuildGroup retried a forbidden tunnel: got %v", ev.Type)
+	default:
+	}
+	if status, err := tun.State(); status != StatusFailed || !IsProhibited(err) {
+		t.Errorf("tunnel state = %v, %v; want failed with the refusal", status, err)
+	}
+}
+
+func TestHealthRatio(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []TunnelStatus
+		want     float64
+		degraded bool
+	}{
+		{name: "no tunnels", want: 1},
+		{name: "all active", statuses: []TunnelStatus{StatusActive, StatusActive}, want: 1},
+		{name: "still connecting", statuses: []TunnelStatus{StatusConnecting, StatusDisconnected}, want: 1},
+		{name: "one of four failed", statuses: []TunnelStatus{StatusActive, StatusActive, StatusActive, StatusFailed}, want: 0.75},
+		{name: "exactly half", statuses: []TunnelStatus{StatusActive, StatusFailed}, want: 0.5},
+		{name: "most failed", statuses: []TunnelStatus{StatusActive, StatusFailed, StatusFailed}, want: 1.0 / 3, degraded: true},
+		{name: "connecting ignored", statuses: []TunnelStatus{StatusFailed, StatusConnecting, StatusConnecting}, want: 0, degraded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(NewClient(), 16)
+			for i, status := range tt.statuses {
+				m.tunnels = append(m.tunnels, builtTunnel("192.0.2.10", 80+i, 10080+i, status, nil))
+			}
+			if got := m.HealthRatio(); got != tt.want {
+				t.Errorf("HealthRatio = %v, want %v", got, tt.want)
+			}
+			if got := m.Degraded(); got != tt.degraded {
+				t.Errorf("Degraded = %v, want %v", got, tt.degraded)
+			}
+		})
+	}
+}
+
+func TestDegradedThreshold(t *testing.T) {
+	m := NewManager(NewClient(), 16)
+	for i := 0; i < 4; i++ {
+		m.tunnels = append(m.tunnels, builtTunnel("192.0.2.10", 80+i, 10080+i, StatusActive, nil))
+	}
+
+	// Fail tunnels one by one: the session turns degraded once fewer
+	// than half are still up.
+	for i, want := range []bool{false, false, true, true} {
+		m.tunnels[i].setState(StatusFailed, nil)
+		if got := m.Degraded(); got != want {
+			t.Errorf("%d of 4 failed: Degraded = %v, want %v", i+1, got, want)
+		}
+	}
+
+	// And recovers when enough come back.
+	m.tunnels[0].setState(StatusActive, nil)
+	m.tunnels[1].setState(StatusActive, nil)
+	if m.Degraded() {
+		t.Error("still degraded with half the tunnels back")
+	}
+
+	m.SetDegradedRatio(0.8)
+	if !m.Degraded() {
+		t.Error("half active not degraded at a 0.8 threshold")
+	}
+	m.SetDegradedRatio(0)
+	for _, tun := range m.tunnels {
+		tun.setState(StatusFailed, nil)
+	}
+	if m.Degraded() {
+		t.Error("threshold 0 reported degraded")
+	}
+}
+
+// echoServer starts a loopback TCP echo server standing in for a device
+// behind the gateway and returns its port.
+func echoServer(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestStopAcceptingKeepsOpenConnections(t *testing.T) {
+	c := connectFake(t, newFakeServer(t, "SSH-2.0-OpenSSH_9.6", nil))
+	m := NewManager(c, 16)
+	defer m.CloseAll()
+	port := freePort(t)
+	if err := m.BuildTunnels([]TunnelSpec{{RemoteHost: "127.0.0.1", RemotePort: echoServer(t), LocalPort: port}}); err != nil {
+		t.Fatalf("BuildTunnels: %v", err)
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	roundTrip := func(msg string) {
+		t.Helper()
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		if _, err := io.WriteString(conn, msg); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != msg {
+			t.Fatalf("echo = %q, %v; want %q", buf, err, msg)
+		}
+	}
+	roundTrip("before")
+	if n := m.ActiveConnections(); n != 1 {
+		t.Fatalf("ActiveConnections = %d, want 1", n)
+	}
+
+	m.StopAccepting()
+	if extra, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		extra.Close()
+		t.Error("tunnel accepted a connection after StopAccepting")
+	}
+	roundTrip("after")
+
+	conn.Close()
+	for deadline := time.Now().Add(2 * time.Second); m.ActiveConnections() != 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("ActiveConnections = %d after the connection closed", m.ActiveConnections())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFirstConnectVerifies(t *testing.T) {
+	c := connectFake(t, newFakeServer(t, "SSH-2.0-OpenSSH_9.6", nil))
+	m := NewManager(c, 16)
+	defer m.CloseAll()
+	live, dead := freePort(t), freePort(t)
+	specs := []TunnelSpec{
+		{RemoteHost: "127.0.0.1", RemotePort: echoServer(t), LocalPort: live},
+		{RemoteHost: "127.0.0.1", RemotePort: freePort(t), LocalPort: dead}, // nothing listening
+	}
+	if err := m.BuildTunnels(specs); err != nil {
+		t.Fatalf("BuildTunnels: %v", err)
+	}
+	for range 4 {
+		if ev := nextEvent(t, m); ev.Type != EventStarted && ev.Type != EventActive {
+			t.Fatalf("build event = %v", ev.Type)
+		}
+	}
+	tunnels := m.Tunnels()
+	for _, tun := range tunnels {
+		if tun.FirstConnectOK() {
+			t.Fatalf("tunnel to :%d verified by its build probe alone", tun.RemotePort)
+		}
+	}
+
+	// open dials through the tunnel on port and waits for it to close or
+	// echo a byte back.
+	open := func(port int) {
+		t.Helper()
+		conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		io.WriteString(conn, "x")
+		conn.Read(make([]byte, 1))
+	}
+	open(dead)
+	open(live)
+	open(live)
+
+	ev := nextEvent(t, m)
+	if ev.Type != EventVerified || ev.LocalPort != live {
+		t.Errorf("event = %v on :%d, want verified on :%d", ev.Type, ev.LocalPort, live)
+	}
+	select {
+	case ev := <-m.Events():
+		t.Errorf("extra event %v on :%d; want one verification only", ev.Type, ev.LocalPort)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if !tunnels[0].FirstConnectOK() || tunnels[1].FirstConnectOK() {
+		t.Errorf("FirstConnectOK = %v live, %v dead; want true, false", tunnels[0].FirstConnectOK(), tunnels[1].FirstConnectOK())
+	}
+}
+
+func TestPruneDead(t *testing.T) {
+	c := connectFake(t, newFakeServer(t, "SSH-2.0-OpenSSH_9.6", nil))
+	m := NewManager(c, 16)
+	defer m.CloseAll()
+	var released []int
+	m.SetPortRelease(func(port int) { released = append(released, port) })
+	live, dead := freePort(t), freePort(t)
+	specs := []TunnelSpec{
+		{RemoteHost: "127.0.0.1", RemotePort: echoServer(t), LocalPort: live},
+		{RemoteHost: "127.0.0.1", RemotePort: freePort(t), LocalPort: dead}, // nothing listening
+	}
+	if err := m.BuildTunnels(specs); err != nil {
+		t.Fatalf("BuildTunnels: %v", err)
+	}
+	for range 4 {
+		nextEvent(t, m)
+	}
+	tunnels := m.Tunnels()
+
+	// A zero grace gives each tunnel a single attempt.
+	m.PruneDead(0)
+	got := map[int]EventType{}
+	for range 2 {
+		ev := nextEvent(t, m)
+		got[ev.LocalPort] = ev.Type
+	}
+	if got[live] != EventVerified || got[dead] != EventPruned {
+		t.Errorf("events = %v, want verified on :%d and pruned on :%d", got, live, dead)
+	}
+
+	if left := m.Tunnels(); len(left) != 1 || left[0] != tunnels[0] {
+		t.Fatalf("Tunnels after prune = %d, want only the live one", len(left))
+	}
+	if status, err := tunnels[1].State(); status != StatusDisconnected || err == nil {
+		t.Errorf("pruned tunnel state = %v, %v; want disconnected with an error", status, err)
+	}
+	if len(released) != 1 || released[0] != dead {
+		t.Errorf("released = %v, want [%d]", released, dead)
+	}
+	if conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(dead)), time.Second); err == nil {
+		conn.Close()
+		t.Error("pruned tunnel still accepts connections")
+	}
+}
@@ -0,0 +1,30 @@
+package ssh
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MappingTable returns the active tunnels as a mapping sheet for a
+// ticket: a header row, then one row per tunnel ordered by local port
+// with the local port, remote address, device class and vendor.
+func (m *Manager) MappingTable() [][]string {
+	var active []*Tunnel
+	for _, t := range m.Tunnels() {
+		if status, _ := t.State(); status == StatusActive {
+			active = append(active, t)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Port() < active[j].Port() })
+
+	rows := [][]string{{"Local port", "Remote", "Device", "Vendor"}}
+	for _, t := range active {
+		rows = append(rows, []string{
+			fmt.Sprint(t.Port()),
+			fmt.Sprintf("%s:%d", t.RemoteHost, t.RemotePort),
+			t.Class,
+			t.Vendor,
+		})
+	}
+	return rows
+}
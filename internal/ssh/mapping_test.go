@@ -0,0 +1,48 @@
+package ssh
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMappingTable(t *testing.T) {
+	m := NewManager(NewClient(), 16)
+	defer m.CloseAll()
+
+	cam := builtTunnel("192.168.88.20", 554, 5546, StatusActive, nil)
+	cam.Class, cam.Vendor = "Camera", "Hikvision"
+	web := builtTunnel("192.168.88.20", 80, 4435, StatusActive, nil)
+	web.Class, web.Vendor = "Camera", "Hikvision"
+	router := builtTunnel("192.168.88.1", 8291, 1111, StatusActive, nil)
+	router.Class, router.Vendor = "Network Device", "Routerboard.com"
+	failed := builtTunnel("192.168.88.30", 443, 4436, StatusFailed, errors.New("tunnel: listen: address in use"))
+	stopped := builtTunnel("192.168.88.31", 80, 8031, StatusDisconnected, nil)
+	m.tunnels = append(m.tunnels, cam, failed, web, stopped, router)
+
+	want := [][]string{
+		{"Local port", "Remote", "Device", "Vendor"},
+		{"1111", "192.168.88.1:8291", "Network Device", "Routerboard.com"},
+		{"4435", "192.168.88.20:80", "Camera", "Hikvision"},
+		{"5546", "192.168.88.20:554", "Camera", "Hikvision"},
+	}
+	if got := m.MappingTable(); !reflect.DeepEqual(got, want) {
+		t.Errorf("MappingTable() =\n%v\nwant\n%v", got, want)
+	}
+
+	// A substitute port is listed, not the one the spec asked for.
+	web.setPort(14435)
+	if got := m.MappingTable(); got[3][0] != "14435" || got[3][1] != "192.168.88.20:80" {
+		t.Errorf("last row after the port change = %v, want 14435 for 192.168.88.20:80", got[3])
+	}
+}
+
+func TestMappingTableNoActiveTunnels(t *testing.T) {
+	m := NewManager(NewClient(), 16)
+	defer m.CloseAll()
+	m.tunnels = append(m.tunnels, builtTunnel("192.168.88.30", 443, 4436, StatusFailed, errors.New("refused")))
+
+	if got := m.MappingTable(); len(got) != 1 {
+		t.Errorf("MappingTable() = %v, want only the header", got)
+	}
+}
@@ -0,0 +1,81 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// OpenSSHOptions describes a session to reproduce with the stock ssh client.
+type OpenSSHOptions struct {
+	User         string
+	Host         string
+	Port         string   // omitted from the command when "" or "22"
+	HostKeyAlgos []string // algorithms the session had to restrict to, if any
+	Forwards     []TunnelSpec
+}
+
+// OpenSSHCommand returns an ssh(1) one-liner that sets up the same local
+// forwards as the session, for handing off to someone without lmtm:
+//
+//	ssh -N -L 4435:10.0.0.5:443 -o HostKeyAlgorithms=+ssh-rsa -p 2222 admin@gw
+//
+// Forwards bind to 127.0.0.1 explicitly to match decision 009. Arguments
+// are single-quoted only where the shell would otherwise interpret them.
+func OpenSSHCommand(o OpenSSHOptions) string {
+	args := []string{"ssh", "-N"}
+
+	for _, f := range o.Forwards {
+		args = append(args, "-L", fmt.Sprintf("127.0.0.1:%d:%s:%d",
+			f.LocalPort, bracketIPv6(f.RemoteHost), f.RemotePort))
+	}
+
+	// Legacy algorithms are appended to OpenSSH's defaults with "+" so the
+	// command keeps working if the gateway is later upgraded.
+	if len(o.HostKeyAlgos) > 0 {
+		args = append(args, "-o", "HostKeyAlgorithms=+"+strings.Join(o.HostKeyAlgos, ","))
+	}
+
+	if o.Port != "" && o.Port != "22" {
+		args = append(args, "-p", o.Port)
+	}
+
+	target := o.Host
+	if o.User != "" {
+		target = o.User + "@" + o.Host
+	}
+	args = append(args, target)
+
+	for i, a := range args {
+		args[i] = shellQuote(a)
+	}
+	return strings.Join(args, " ")
+}
+
+// bracketIPv6 wraps IPv6 literals in brackets as -L requires.
+func bracketIPv6(host string) string {
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// shellQuote single-quotes s for POSIX shells if it contains anything
+// beyond a conservative set of safe characters.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			strings.ContainsRune("-_.,:/@=+[]%", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,82 @@
+package ssh
+
+import "testing"
+
+func TestOpenSSHCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		opts OpenSSHOptions
+		want string
+	}{
+		{
+			name: "bare",
+			opts: OpenSSHOptions{User: "admin", Host: "10.0.0.1"},
+			want: "ssh -N admin@10.0.0.1",
+		},
+		{
+			name: "default port omitted",
+			opts: OpenSSHOptions{User: "admin", Host: "gw", Port: "22"},
+			want: "ssh -N admin@gw",
+		},
+		{
+			name: "no user",
+			opts: OpenSSHOptions{Host: "gw", Port: "2222"},
+			want: "ssh -N -p 2222 gw",
+		},
+		{
+			name: "forwards, legacy algos and port",
+			opts: OpenSSHOptions{
+				User:         "admin",
+				Host:         "gw",
+				Port:         "2222",
+				HostKeyAlgos: []string{"ssh-rsa", "ssh-dss"},
+				Forwards: []TunnelSpec{
+					{RemoteHost: "10.0.0.5", RemotePort: 443, LocalPort: 4435},
+					{RemoteHost: "10.0.0.5", RemotePort: 80, LocalPort: 8035},
+				},
+			},
+			want: "ssh -N -L 127.0.0.1:4435:10.0.0.5:443 -L 127.0.0.1:8035:10.0.0.5:80 " +
+				"-o HostKeyAlgorithms=+ssh-rsa,ssh-dss -p 2222 admin@gw",
+		},
+		{
+			name: "IPv6 forward bracketed",
+			opts: OpenSSHOptions{User: "admin", Host: "gw", Forwards: []TunnelSpec{
+				{RemoteHost: "fe80::1", RemotePort: 22, LocalPort: 2231},
+			}},
+			want: "ssh -N -L 127.0.0.1:2231:[fe80::1]:22 admin@gw",
+		},
+		{
+			name: "unsafe user quoted",
+			opts: OpenSSHOptions{User: "o'brien smith", Host: "gw"},
+			want: `ssh -N 'o'\''brien smith@gw'`,
+		},
+		{
+			name: "shell metacharacters quoted",
+			opts: OpenSSHOptions{User: "admin", Host: "gw;rm"},
+			want: "ssh -N 'admin@gw;rm'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OpenSSHCommand(tt.opts); got != tt.want {
+				t.Errorf("OpenSSHCommand() =\n  %s\nwant\n  %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", "''"},
+		{"plain-arg_1.2", "plain-arg_1.2"},
+		{"a b", "'a b'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+		{"HostKeyAlgorithms=+ssh-rsa", "HostKeyAlgorithms=+ssh-rsa"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+)
+
+// DefaultPortFallbacks are tried in order when the primary SSH port is
+// refused or times out. Some sites firewall 22 but leave these open.
+var DefaultPortFallbacks = []string{"8022", "443"}
+
+// gatewayCacheEntry holds what we remember about a gateway between sessions.
+type gatewayCacheEntry struct {
+	SSHPort    string `json:"ssh_port,omitempty"`
+	BannerHash string `json:"banner_hash,omitempty"` // last acknowledged login banner
+}
+
+func gatewayCachePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".tunneler", "cache", "gateways.json")
+}
+
+// loadGatewayCache reads the gateway cache. Returns an empty map if the
+// file doesn't exist or can't be parsed.
+func loadGatewayCache() map[string]gatewayCacheEntry {
+	cache := make(map[string]gatewayCacheEntry)
+	if err := store.Load(gatewayCachePath(), &cache); err != nil {
+		return make(map[string]gatewayCacheEntry)
+	}
+	return cache
+}
+
+// updateGatewayEntry applies fn to host's cache entry under the store lock,
+// so concurrent instances don't drop each other's gateways.
+func updateGatewayEntry(host string, fn func(*gatewayCacheEntry)) error {
+	cache := make(map[string]gatewayCacheEntry)
+	return store.Update(gatewayCachePath(), &cache, func() error {
+		entry := cache[host]
+		fn(&entry)
+		cache[host] = entry
+		return nil
+	})
+}
+
+// CachedPort returns the SSH port that last worked for host, or "" if unknown.
+func CachedPort(host string) string {
+	return loadGatewayCache()[host].SSHPort
+}
+
+// RememberPort records the SSH port that worked for host so the next
+// session tries it first. Best effort -- errors are ignored.
+func RememberPort(host, port string) {
+	if CachedPort(host) == port {
+		return
+	}
+	_ = updateGatewayEntry(host, func(e *gatewayCacheEntry) {
+		e.SSHPort = port
+	})
+}
+
+// CandidatePorts returns the SSH ports to try for host, in order: the
+// cached port from a previous session, the default 22, then the fallbacks.
+func CandidatePorts(host string) []string {
+	ports := make([]string, 0, len(DefaultPortFallbacks)+2)
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if p != "" && !seen[p] {
+			seen[p] = true
+			ports = append(ports, p)
+		}
+	}
+	add(CachedPort(host))
+	add("22")
+	for _, p := range DefaultPortFallbacks {
+		add(p)
+	}
+	return ports
+}
@@ -0,0 +1,91 @@
+package ssh
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrPrivilegedPort is returned by Tunnel.Start when the OS refuses to
+// bind a local port below 1024 for this user.
+var ErrPrivilegedPort = errors.New("binding ports below 1024 requires elevated privileges or CAP_NET_BIND_SERVICE")
+
+// ErrPortInUse is returned by Tunnel.Start when another process already
+// listens on the local port.
+var ErrPortInUse = errors.New("local port already in use by another process")
+
+// wsaeaddrinuse is Windows' "address already in use"; syscall.EADDRINUSE
+// there is a made-up value that no socket call returns.
+const wsaeaddrinuse = 10048
+
+// isAddrInUse reports whether a listen failed because the port is taken.
+func isAddrInUse(err error) bool {
+	var errno syscall.Errno
+	return errors.As(err, &errno) && (errno == syscall.EADDRINUSE || errno == wsaeaddrinuse)
+}
+
+// LocalPortInUse reports whether another process already listens on
+// 127.0.0.1:port. A port this user may not bind isn't in use; the
+// privileged port fallback deals with it.
+func LocalPortInUse(port int) bool {
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return isAddrInUse(err)
+	}
+	ln.Close()
+	return false
+}
+
+// capNetBindService is the bit for CAP_NET_BIND_SERVICE in the Linux
+// capability sets.
+const capNetBindService = 10
+
+// CanBindPrivileged reports whether this process can listen on ports
+// below 1024: it runs as root, the binary was given CAP_NET_BIND_SERVICE
+// with setcap, the kernel lowered ip_unprivileged_port_start, or the OS
+// has no privileged ports at all. When unsure it says no, which only
+// costs a fallback port.
+func CanBindPrivileged() bool {
+	switch runtime.GOOS {
+	case "windows":
+		return true
+	case "darwin":
+		// macOS 10.14 dropped the restriction for loopback binds.
+		return true
+	}
+	if os.Geteuid() == 0 {
+		return true
+	}
+	if runtime.GOOS != "linux" {
+		return false
+	}
+
+	if b, err := os.ReadFile("/proc/sys/net/ipv4/ip_unprivileged_port_start"); err == nil {
+		if start, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && start <= 1 {
+			return true
+		}
+	}
+	status, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		hex, ok := strings.CutPrefix(line, "CapEff:")
+		if !ok {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(hex), 16, 64)
+		return err == nil && caps&(1<<capNetBindService) != 0
+	}
+	return false
+}
+
+// isPermissionDenied reports whether a listen error is the OS refusing a
+// privileged port, as opposed to the port being in use.
+func isPermissionDenied(err error) bool {
+	return errors.Is(err, os.ErrPermission)
+}
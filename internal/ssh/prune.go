@@ -0,0 +1,69 @@
+package ssh
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultPruneGrace is how long PruneDead keeps trying a device before it
+// closes the tunnel to it.
+const DefaultPruneGrace = 10 * time.Second
+
+// pruneRetry is the pause between PruneDead's attempts at one device.
+const pruneRetry = 2 * time.Second
+
+// PruneDead checks every active tunnel that hasn't been verified yet by
+// connecting to its device through the gateway, retrying until grace has
+// passed. A tunnel that gets through is verified as if by its first
+// connection; one that never does is stopped, dropped from the manager
+// and reported with EventPruned. The checks run in the background, and a
+// tunnel already being checked is left to that check.
+func (m *Manager) PruneDead(grace time.Duration) {
+	for _, tun := range m.Tunnels() {
+		if status, _ := tun.State(); status != StatusActive || tun.FirstConnectOK() || !tun.pruneCheck.CompareAndSwap(false, true) {
+			continue
+		}
+		m.tracker.Go(func() {
+			defer tun.pruneCheck.Store(false)
+			m.pruneIfDead(tun, grace)
+		})
+	}
+}
+
+// pruneIfDead runs PruneDead's check of one tunnel.
+func (m *Manager) pruneIfDead(tun *Tunnel, grace time.Duration) {
+	deadline := time.Now().Add(grace)
+	for {
+		if tun.FirstConnectOK() {
+			return
+		}
+		if tun.reach() == nil {
+			tun.markVerified()
+			return
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		select {
+		case <-time.After(pruneRetry):
+		case <-m.tracker.ctx.Done():
+			return
+		}
+	}
+
+	m.mu.Lock()
+	for i, t := range m.tunnels {
+		if t == tun {
+			m.tunnels = append(m.tunnels[:i], m.tunnels[i+1:]...)
+			break
+		}
+	}
+	m.mu.Unlock()
+	tun.Stop()
+	tun.setState(StatusDisconnected, fmt.Errorf("tunnel: %s:%d did not answer within %s", tun.RemoteHost, tun.RemotePort, grace))
+	Logf("tunnel: pruned 127.0.0.1:%d -> %s:%d, no answer within %s", tun.Port(), tun.RemoteHost, tun.RemotePort, grace)
+	if m.releasePort != nil {
+		m.releasePort(tun.Port())
+	}
+	m.emit(tun.event(EventPruned))
+}
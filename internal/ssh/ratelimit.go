@@ -0,0 +1,60 @@
+package ssh
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// dialLimiter is a token bucket shared by all tunnels of a Manager. It
+// caps how fast new SSH channels are opened so a burst of browser tabs
+// doesn't flood a fragile gateway with simultaneous direct-tcpip requests.
+// A nil limiter never blocks.
+type dialLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64 // bucket capacity
+	tokens float64
+	last   time.Time
+}
+
+// newDialLimiter returns a limiter allowing perSecond dials per second
+// with a burst of the same size (at least 1). Returns nil if perSecond <= 0.
+func newDialLimiter(perSecond float64) *dialLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	burst := max(perSecond, 1)
+	return &dialLimiter{
+		rate:   perSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or ctx is cancelled.
+func (l *dialLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
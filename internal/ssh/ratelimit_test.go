@@ -0,0 +1,102 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewDialLimiter(t *testing.T) {
+	tests := []struct {
+		perSecond float64
+		wantNil   bool
+		wantBurst float64
+	}{
+		{perSecond: 0, wantNil: true},
+		{perSecond: -5, wantNil: true},
+		{perSecond: 0.5, wantBurst: 1},
+		{perSecond: 1, wantBurst: 1},
+		{perSecond: 10, wantBurst: 10},
+	}
+	for _, tt := range tests {
+		l := newDialLimiter(tt.perSecond)
+		if tt.wantNil {
+			if l != nil {
+				t.Errorf("newDialLimiter(%v) = %+v, want nil", tt.perSecond, l)
+			}
+			continue
+		}
+		if l == nil {
+			t.Fatalf("newDialLimiter(%v) = nil", tt.perSecond)
+		}
+		if l.burst != tt.wantBurst || l.tokens != tt.wantBurst || l.rate != tt.perSecond {
+			t.Errorf("newDialLimiter(%v) = rate %v burst %v tokens %v, want rate %v burst %v full",
+				tt.perSecond, l.rate, l.burst, l.tokens, tt.perSecond, tt.wantBurst)
+		}
+	}
+}
+
+func TestDialLimiterWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    float64
+		tokens  float64
+		calls   int
+		minWait time.Duration
+		maxWait time.Duration
+	}{
+		{name: "burst is immediate", rate: 5, tokens: 5, calls: 5, maxWait: 50 * time.Millisecond},
+		{name: "empty bucket waits one interval", rate: 20, tokens: 0, calls: 1, minWait: 40 * time.Millisecond, maxWait: 500 * time.Millisecond},
+		{name: "past the burst waits", rate: 20, tokens: 2, calls: 4, minWait: 80 * time.Millisecond, maxWait: 500 * time.Millisecond},
+		{name: "partial token waits the remainder", rate: 10, tokens: 0.5, calls: 1, minWait: 40 * time.Millisecond, maxWait: 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &dialLimiter{rate: tt.rate, burst: max(tt.rate, 1), tokens: tt.tokens, last: time.Now()}
+			start := time.Now()
+			for i := 0; i < tt.calls; i++ {
+				if err := l.Wait(context.Background()); err != nil {
+					t.Fatalf("Wait %d: %v", i, err)
+				}
+			}
+			if got := time.Since(start); got < tt.minWait || got > tt.maxWait {
+				t.Errorf("%d calls took %v, want between %v and %v", tt.calls, got, tt.minWait, tt.maxWait)
+			}
+		})
+	}
+}
+
+func TestDialLimiterRefillCapped(t *testing.T) {
+	l := &dialLimiter{rate: 2, burst: 2, tokens: 0, last: time.Now().Add(-time.Hour)}
+	for i := 0; i < 2; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait %d: %v", i, err)
+		}
+	}
+	if l.tokens >= 1 {
+		t.Errorf("tokens = %v after draining the burst, want < 1", l.tokens)
+	}
+}
+
+func TestDialLimiterCancel(t *testing.T) {
+	l := &dialLimiter{rate: 0.1, burst: 1, tokens: 0, last: time.Now()}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := l.Wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want DeadlineExceeded", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Wait ignored the cancelled context")
+	}
+}
+
+func TestDialLimiterNil(t *testing.T) {
+	var l *dialLimiter
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("nil limiter Wait = %v, want nil", err)
+	}
+}
@@ -0,0 +1,55 @@
+package ssh
+
+import (
+	"sync"
+	"time"
+)
+
+// RecentExecs is how many commands a Client keeps for RecentOutput.
+const RecentExecs = 10
+
+// ExecRecord is one command run on the gateway and what it printed,
+// verbatim, for the raw output debug screen.
+type ExecRecord struct {
+	At     time.Time
+	Cmd    string
+	Output string
+	Err    error
+}
+
+// execRing holds the last RecentExecs records, oldest overwritten first.
+type execRing struct {
+	mu      sync.Mutex
+	records [RecentExecs]ExecRecord
+	next    int
+	full    bool
+}
+
+// add records one command.
+func (r *execRing) add(rec ExecRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[r.next] = rec
+	r.next = (r.next + 1) % RecentExecs
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the records oldest first.
+func (r *execRing) list() []ExecRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]ExecRecord(nil), r.records[:r.next]...)
+	}
+	out := make([]ExecRecord, 0, RecentExecs)
+	out = append(out, r.records[r.next:]...)
+	return append(out, r.records[:r.next]...)
+}
+
+// RecentOutput returns the last RecentExecs commands run through Exec or
+// ExecSudo, oldest first, with their raw output.
+func (c *Client) RecentOutput() []ExecRecord {
+	return c.recent.list()
+}
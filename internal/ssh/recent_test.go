@@ -0,0 +1,69 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestExecRing(t *testing.T) {
+	tests := []struct {
+		name  string
+		added int
+		first int // command number of the oldest record kept
+	}{
+		{name: "empty", added: 0},
+		{name: "partly filled", added: 3, first: 1},
+		{name: "exactly full", added: RecentExecs, first: 1},
+		{name: "wrapped once", added: RecentExecs + 4, first: 5},
+		{name: "wrapped twice", added: 3*RecentExecs - 1, first: 2 * RecentExecs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r execRing
+			for i := 1; i <= tt.added; i++ {
+				r.add(ExecRecord{Cmd: fmt.Sprintf("cmd %d", i)})
+			}
+			got := r.list()
+			if want := min(tt.added, RecentExecs); len(got) != want {
+				t.Fatalf("list has %d records, want %d", len(got), want)
+			}
+			for i, rec := range got {
+				if want := fmt.Sprintf("cmd %d", tt.first+i); rec.Cmd != want {
+					t.Errorf("record %d = %q, want %q", i, rec.Cmd, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExecRingListIsACopy(t *testing.T) {
+	var r execRing
+	r.add(ExecRecord{Cmd: "first"})
+	got := r.list()
+	got[0].Cmd = "changed"
+	if r.list()[0].Cmd != "first" {
+		t.Error("changing the returned list changed the ring")
+	}
+}
+
+func TestRecentOutputRecordsExecs(t *testing.T) {
+	srv := newFakeServer(t, "SSH-2.0-OpenSSH_9.6", map[string]string{"hostname": "gw-lab\n"})
+	c := connectFake(t, srv)
+
+	c.Exec(context.Background(), "hostname")
+	c.Exec(context.Background(), "nmap --version")
+	recs := c.RecentOutput()
+	if len(recs) != 2 {
+		t.Fatalf("RecentOutput has %d records, want 2", len(recs))
+	}
+	if recs[0].Cmd != "hostname" || recs[0].Output != "gw-lab" || recs[0].Err != nil {
+		t.Errorf("first record = %+v, want hostname and its output", recs[0])
+	}
+	if recs[1].Cmd != "nmap --version" || recs[1].Err == nil {
+		t.Errorf("second record = %+v, want the failed command with its error", recs[1])
+	}
+	if recs[1].At.Before(recs[0].At) {
+		t.Error("records out of order")
+	}
+}
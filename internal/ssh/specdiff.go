@@ -0,0 +1,100 @@
+package ssh
+
+import "fmt"
+
+// SpecChangeKind describes how a built tunnel differs from what was
+// reviewed.
+type SpecChangeKind int
+
+const (
+	// SpecRemapped means the tunnel was built on another local port,
+	// usually because the expected one collided.
+	SpecRemapped SpecChangeKind = iota
+	// SpecDropped means a reviewed forward has no working tunnel: it was
+	// never built or it failed.
+	SpecDropped
+	// SpecAdded means a tunnel was built that wasn't reviewed, such as the
+	// automatic WinBox forward on MikroTik.
+	SpecAdded
+)
+
+// SpecChange is one divergence between the reviewed specs and the built
+// tunnels. Reviewed is zero for SpecAdded; Built is zero for a spec that
+// was never built.
+type SpecChange struct {
+	Kind     SpecChangeKind
+	Reviewed TunnelSpec
+	Built    TunnelSpec
+	Reason   string
+}
+
+// String returns a one-line description, e.g.
+// "10.0.0.5:443 moved from :4435 to :4436".
+func (c SpecChange) String() string {
+	switch c.Kind {
+	case SpecRemapped:
+		return fmt.Sprintf("%s:%d moved from :%d to :%d",
+			c.Reviewed.RemoteHost, c.Reviewed.RemotePort, c.Reviewed.LocalPort, c.Built.LocalPort)
+	case SpecDropped:
+		return fmt.Sprintf("%s:%d dropped: %s",
+			c.Reviewed.RemoteHost, c.Reviewed.RemotePort, c.Reason)
+	default:
+		return fmt.Sprintf("%s:%d added on :%d",
+			c.Built.RemoteHost, c.Built.RemotePort, c.Built.LocalPort)
+	}
+}
+
+// DiffSpecs compares the reviewed specs with the tunnels a Manager built
+// and returns every divergence, in reviewed order followed by additions.
+// Forwards are matched by remote host and port. A reviewed LocalPort of
+// zero means no particular port was promised, so it is never reported as
+// remapped.
+func DiffSpecs(reviewed []TunnelSpec, built []*Tunnel) []SpecChange {
+	type target struct {
+		host string
+		port int
+	}
+	byTarget := make(map[target]*Tunnel, len(built))
+	for _, tun := range built {
+		byTarget[target{tun.RemoteHost, tun.RemotePort}] = tun
+	}
+
+	var changes []SpecChange
+	seen := make(map[target]bool, len(reviewed))
+	for _, spec := range reviewed {
+		key := target{spec.RemoteHost, spec.RemotePort}
+		seen[key] = true
+		tun, ok := byTarget[key]
+		if !ok {
+			changes = append(changes, SpecChange{
+				Kind:     SpecDropped,
+				Reviewed: spec,
+				Reason:   "not built",
+			})
+			continue
+		}
+		got := TunnelSpec{RemoteHost: tun.RemoteHost, RemotePort: tun.RemotePort, LocalPort: tun.Port()}
+		if status, err := tun.State(); status == StatusFailed {
+			reason := "failed"
+			if err != nil {
+				reason = err.Error()
+			}
+			changes = append(changes, SpecChange{Kind: SpecDropped, Reviewed: spec, Built: got, Reason: reason})
+			continue
+		}
+		if spec.LocalPort != 0 && spec.LocalPort != got.LocalPort {
+			changes = append(changes, SpecChange{Kind: SpecRemapped, Reviewed: spec, Built: got})
+		}
+	}
+
+	for _, tun := range built {
+		if seen[target{tun.RemoteHost, tun.RemotePort}] {
+			continue
+		}
+		changes = append(changes, SpecChange{
+			Kind:  SpecAdded,
+			Built: TunnelSpec{RemoteHost: tun.RemoteHost, RemotePort: tun.RemotePort, LocalPort: tun.Port()},
+		})
+	}
+	return changes
+}
@@ -0,0 +1,124 @@
+package ssh
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// builtTunnel returns a tunnel in the given state, as the manager would
+// leave it after a build.
+func builtTunnel(host string, remotePort, localPort int, status TunnelStatus, err error) *Tunnel {
+	tun := NewTunnel(nil, localPort, host, remotePort)
+	tun.setState(status, err)
+	return tun
+}
+
+func TestDiffSpecs(t *testing.T) {
+	web := TunnelSpec{RemoteHost: "10.0.0.5", RemotePort: 443, LocalPort: 4435}
+	cam := TunnelSpec{RemoteHost: "10.0.0.6", RemotePort: 554, LocalPort: 5546}
+	winbox := TunnelSpec{RemoteHost: "10.0.0.1", RemotePort: 8291, LocalPort: 1111}
+	refused := errors.New("tunnel: gateway forbids forwarding to 10.0.0.6:554")
+
+	tests := []struct {
+		name     string
+		reviewed []TunnelSpec
+		built    []*Tunnel
+		want     []SpecChange
+	}{
+		{
+			name:     "unchanged",
+			reviewed: []TunnelSpec{web, cam},
+			built: []*Tunnel{
+				builtTunnel("10.0.0.5", 443, 4435, StatusActive, nil),
+				builtTunnel("10.0.0.6", 554, 5546, StatusActive, nil),
+			},
+		},
+		{
+			name:     "remapped after a collision or retry",
+			reviewed: []TunnelSpec{web},
+			built:    []*Tunnel{builtTunnel("10.0.0.5", 443, 4436, StatusActive, nil)},
+			want: []SpecChange{{
+				Kind:     SpecRemapped,
+				Reviewed: web,
+				Built:    TunnelSpec{RemoteHost: "10.0.0.5", RemotePort: 443, LocalPort: 4436},
+			}},
+		},
+		{
+			name:     "no port promised",
+			reviewed: []TunnelSpec{{RemoteHost: "10.0.0.5", RemotePort: 443}},
+			built:    []*Tunnel{builtTunnel("10.0.0.5", 443, 4436, StatusActive, nil)},
+		},
+		{
+			name:     "never built",
+			reviewed: []TunnelSpec{web, cam},
+			built:    []*Tunnel{builtTunnel("10.0.0.5", 443, 4435, StatusActive, nil)},
+			want:     []SpecChange{{Kind: SpecDropped, Reviewed: cam, Reason: "not built"}},
+		},
+		{
+			name:     "rejected by policy",
+			reviewed: []TunnelSpec{cam},
+			built:    []*Tunnel{builtTunnel("10.0.0.6", 554, 5546, StatusFailed, refused)},
+			want: []SpecChange{{
+				Kind:     SpecDropped,
+				Reviewed: cam,
+				Built:    cam,
+				Reason:   refused.Error(),
+			}},
+		},
+		{
+			name:     "failed without an error",
+			reviewed: []TunnelSpec{cam},
+			built:    []*Tunnel{builtTunnel("10.0.0.6", 554, 5546, StatusFailed, nil)},
+			want:     []SpecChange{{Kind: SpecDropped, Reviewed: cam, Built: cam, Reason: "failed"}},
+		},
+		{
+			name:     "added",
+			reviewed: []TunnelSpec{web},
+			built: []*Tunnel{
+				builtTunnel("10.0.0.5", 443, 4435, StatusActive, nil),
+				builtTunnel("10.0.0.1", 8291, 1111, StatusActive, nil),
+			},
+			want: []SpecChange{{Kind: SpecAdded, Built: winbox}},
+		},
+		{
+			name:     "reviewed order then additions",
+			reviewed: []TunnelSpec{cam, web},
+			built: []*Tunnel{
+				builtTunnel("10.0.0.1", 8291, 1111, StatusActive, nil),
+				builtTunnel("10.0.0.5", 443, 4436, StatusActive, nil),
+			},
+			want: []SpecChange{
+				{Kind: SpecDropped, Reviewed: cam, Reason: "not built"},
+				{Kind: SpecRemapped, Reviewed: web, Built: TunnelSpec{RemoteHost: "10.0.0.5", RemotePort: 443, LocalPort: 4436}},
+				{Kind: SpecAdded, Built: winbox},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffSpecs(tt.reviewed, tt.built)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffSpecs =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecChangeString(t *testing.T) {
+	web := TunnelSpec{RemoteHost: "10.0.0.5", RemotePort: 443, LocalPort: 4435}
+	tests := []struct {
+		change SpecChange
+		want   string
+	}{
+		{SpecChange{Kind: SpecRemapped, Reviewed: web, Built: TunnelSpec{RemoteHost: "10.0.0.5", RemotePort: 443, LocalPort: 4436}},
+			"10.0.0.5:443 moved from :4435 to :4436"},
+		{SpecChange{Kind: SpecDropped, Reviewed: web, Reason: "not built"}, "10.0.0.5:443 dropped: not built"},
+		{SpecChange{Kind: SpecAdded, Built: web}, "10.0.0.5:443 added on :4435"},
+	}
+	for _, tt := range tests {
+		if got := tt.change.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
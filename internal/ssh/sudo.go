@@ -0,0 +1,56 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+)
+
+// SetSudo sets whether ExecSudo elevates its commands. sudo is given the
+// login password, which the client already holds until Close.
+func (c *Client) SetSudo(on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sudo = on
+}
+
+// ExecSudo runs cmd under sudo with the login password on stdin, or just
+// as Exec does when sudo is off. The password never appears in the
+// command line, and is redacted from the output and the trace log.
+func (c *Client) ExecSudo(ctx context.Context, cmd string) (out string, err error) {
+	c.mu.RLock()
+	on := c.sudo
+	pass := bytes.Clone(c.password)
+	c.mu.RUnlock()
+	if !on {
+		return c.Exec(ctx, cmd)
+	}
+	defer clear(pass)
+	stdin := append(pass, '\n')
+	defer clear(stdin)
+
+	wrapped := sudoCommand(cmd)
+	if label, ok := ctx.Value(execTraceKey{}).(string); ok {
+		defer func() { traceExec(label, wrapped, out, err) }()
+	}
+	defer func() { c.recent.add(ExecRecord{At: time.Now(), Cmd: wrapped, Output: out, Err: err}) }()
+	out, err = c.exec(ctx, wrapped, stdin)
+	return redactSecret(out, pass), err
+}
+
+// sudoCommand wraps cmd to run under sudo, which reads the password from
+// stdin. An empty prompt keeps sudo's prompt out of the output, and sh -c
+// keeps the command's own pipes and redirections under sudo too.
+func sudoCommand(cmd string) string {
+	return "sudo -S -p '' sh -c " + shellQuote(cmd)
+}
+
+// redactSecret masks every occurrence of secret in s, in case the remote
+// side echoed it back.
+func redactSecret(s string, secret []byte) string {
+	if len(secret) == 0 {
+		return s
+	}
+	return strings.ReplaceAll(s, string(secret), "[redacted]")
+}
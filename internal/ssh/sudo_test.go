@@ -0,0 +1,73 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSudoCommand(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"true", "sudo -S -p '' sh -c true"},
+		{"ip neigh show", `sudo -S -p '' sh -c 'ip neigh show'`},
+		{"cat /var/run/dhcpd.leases 2>/dev/null | grep -v '^#'", `sudo -S -p '' sh -c 'cat /var/run/dhcpd.leases 2>/dev/null | grep -v '\''^#'\'''`},
+	}
+	for _, tt := range tests {
+		if got := sudoCommand(tt.cmd); got != tt.want {
+			t.Errorf("sudoCommand(%q) = %s, want %s", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestRedactSecret(t *testing.T) {
+	tests := []struct {
+		s      string
+		secret string
+		want   string
+	}{
+		{"hunter2\nroot\n", "hunter2", "[redacted]\nroot\n"},
+		{"pw hunter2 again hunter2", "hunter2", "pw [redacted] again [redacted]"},
+		{"no password here", "hunter2", "no password here"},
+		{"left alone", "", "left alone"},
+	}
+	for _, tt := range tests {
+		if got := redactSecret(tt.s, []byte(tt.secret)); got != tt.want {
+			t.Errorf("redactSecret(%q, %q) = %q, want %q", tt.s, tt.secret, got, tt.want)
+		}
+	}
+}
+
+func TestExecSudo(t *testing.T) {
+	// The gateway echoes the password back, as a misconfigured sudo can.
+	srv := newFakeServer(t, "SSH-2.0-OpenSSH_7.4", map[string]string{
+		"sudo -S": "secret\nroot\n",
+		"whoami":  "admin\n",
+	})
+	c := connectFake(t, srv)
+
+	out, err := c.ExecSudo(context.Background(), "whoami")
+	if err != nil || out != "admin" {
+		t.Errorf("ExecSudo with sudo off = %q, %v; want the plain command's output", out, err)
+	}
+
+	c.SetSudo(true)
+	out, err = c.ExecSudo(context.Background(), "whoami")
+	if err != nil || out != "[redacted]\nroot" {
+		t.Errorf("ExecSudo = %q, %v; want the output with the password redacted", out, err)
+	}
+	if got := srv.cmds[len(srv.cmds)-1]; got != "sudo -S -p '' sh -c whoami" {
+		t.Errorf("gateway ran %q", got)
+	}
+	for _, cmd := range srv.cmds {
+		if strings.Contains(cmd, "secret") {
+			t.Errorf("password on the command line: %q", cmd)
+		}
+	}
+	recent := c.RecentOutput()
+	if last := recent[len(recent)-1]; strings.Contains(last.Output, "secret") || !strings.HasPrefix(last.Cmd, "sudo ") {
+		t.Errorf("recent output kept %+v", last)
+	}
+}
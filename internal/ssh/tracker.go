@@ -0,0 +1,52 @@
+package ssh
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// goroutineTracker ties background goroutines to a cancellable context and
+// a WaitGroup so their owner can stop them and confirm they have exited,
+// rather than leaking them until the process ends.
+type goroutineTracker struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newGoroutineTracker creates a tracker with a fresh cancellable context.
+func newGoroutineTracker() *goroutineTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &goroutineTracker{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a tracked goroutine. A nil tracker runs it untracked, so
+// tunnels created outside a Manager keep working.
+func (t *goroutineTracker) Go(fn func()) {
+	if t == nil {
+		go fn()
+		return
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until every tracked goroutine has returned or timeout
+// elapses. It reports whether all goroutines exited in time.
+func (t *goroutineTracker) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,107 @@
+package ssh
+
+import (
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGoroutineTrackerWait(t *testing.T) {
+	tr := newGoroutineTracker()
+	release := make(chan struct{})
+	tr.Go(func() { <-release })
+
+	if tr.Wait(20 * time.Millisecond) {
+		t.Fatal("Wait reported done while a goroutine was blocked")
+	}
+	close(release)
+	if !tr.Wait(time.Second) {
+		t.Fatal("Wait timed out after the goroutine returned")
+	}
+}
+
+func TestGoroutineTrackerNil(t *testing.T) {
+	var tr *goroutineTracker
+	ran := make(chan struct{})
+	tr.Go(func() { close(ran) })
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("nil tracker did not run fn")
+	}
+}
+
+func TestCloseAllWaitsForGoroutines(t *testing.T) {
+	m := NewManager(NewClient(), 1)
+
+	// Goroutines that block on the tracker's context and keep emitting
+	// into a full event channel, as build and forward goroutines do.
+	const workers = 8
+	var exited atomic.Int32
+	for i := 0; i < workers; i++ {
+		m.tracker.Go(func() {
+			defer exited.Add(1)
+			for {
+				m.emit(TunnelEvent{Type: EventActive})
+				select {
+				case <-m.tracker.ctx.Done():
+					time.Sleep(10 * time.Millisecond) // still running when cancelled
+					m.emit(TunnelEvent{Type: EventClosed})
+					return
+				case <-time.After(time.Millisecond):
+				}
+			}
+		})
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- m.CloseAll() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("CloseAll: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("CloseAll deadlocked")
+	}
+	if got := exited.Load(); got != workers {
+		t.Errorf("CloseAll returned with %d of %d goroutines exited", got, workers)
+	}
+
+	// The event channel is closed, and later emits are dropped.
+	for range m.Events() {
+	}
+	m.emit(TunnelEvent{Type: EventClosed})
+}
+
+func TestCancelUnblocksAccept(t *testing.T) {
+	port := freePort(t)
+	tun := NewTunnel(NewClient(), port, "192.0.2.10", 80)
+	tun.tracker = newGoroutineTracker()
+	if err := tun.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if tun.tracker.Wait(20 * time.Millisecond) {
+		t.Fatal("accept loop exited before the tunnel was cancelled")
+	}
+
+	// Only the context is cancelled; nothing else closes the listener.
+	start := time.Now()
+	tun.cancel()
+	if !tun.tracker.Wait(time.Second) {
+		t.Fatal("accept loop still blocked 1s after cancel")
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("accept loop took %v to exit after cancel", d)
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("port still bound after cancel: %v", err)
+	}
+	ln.Close()
+	if status, _ := tun.State(); status == StatusFailed {
+		t.Error("cancelling the tunnel marked it failed")
+	}
+}
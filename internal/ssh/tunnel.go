@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -44,12 +45,35 @@ type Tunnel struct {
 	RemotePort int
 	Status     TunnelStatus
 	Error      error
+	Class      string // device class label, copied from the TunnelSpec
+	Vendor     string // device vendor, copied from the TunnelSpec
+
+	// mu guards LocalPort, Status and Error, which launch and the
+	// background probes change while the TUI may be reading them; read
+	// them with Port and State.
+	mu       sync.Mutex
+	specPort int // LocalPort as the TunnelSpec asked for it
 
 	listener  net.Listener
 	client    *Client
 	ctx       context.Context
 	cancel    context.CancelFunc
-	connCount int64 // atomic: number of active forwarded connections
+	connCount int64             // atomic: number of active forwarded connections
+	limiter   *dialLimiter      // shared with other tunnels; nil means unlimited
+	tracker   *goroutineTracker // owning Manager's tracker; nil if unmanaged
+
+	// Exposure check state; checkIP is empty when checks are skipped.
+	checkIP    string
+	checkUntil atomic.Int64 // unix nanos; see isExposureCheck
+	exposed    atomic.Bool
+
+	draining atomic.Bool // listener closed by StopAccepting; forwards still run
+
+	// Set by the first forwarded connection that reached the device, and
+	// kept for the session; onVerified is the owning Manager's hook.
+	verified   atomic.Bool
+	onVerified func(*Tunnel)
+	pruneCheck atomic.Bool // PruneDead is checking this tunnel
 }
 
 // NewTunnel creates a tunnel that will forward from localhost:localPort
@@ -61,64 +85,133 @@ func NewTunnel(client *Client, localPort int, remoteHost string, remotePort int)
 		RemoteHost: remoteHost,
 		RemotePort: remotePort,
 		Status:     StatusDisconnected,
+		specPort:   localPort,
 		client:     client,
 		ctx:        ctx,
 		cancel:     cancel,
 	}
 }
 
+// Port returns the local port the tunnel listens on. It can differ from
+// SpecPort when the requested port was privileged or taken.
+func (t *Tunnel) Port() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.LocalPort
+}
+
+// SpecPort returns the local port the tunnel's TunnelSpec asked for. It
+// stays fixed when the tunnel moves to another port, so the TUI can find
+// the row it drew for the spec.
+func (t *Tunnel) SpecPort() int {
+	return t.specPort
+}
+
+// setPort moves the tunnel to another local port before a retry.
+func (t *Tunnel) setPort(port int) {
+	t.mu.Lock()
+	t.LocalPort = port
+	t.mu.Unlock()
+}
+
+// State returns the tunnel's status and the error that last failed it.
+func (t *Tunnel) State() (TunnelStatus, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Status, t.Error
+}
+
+// setState sets the tunnel's status and error together.
+func (t *Tunnel) setState(status TunnelStatus, err error) {
+	t.mu.Lock()
+	t.Status = status
+	t.Error = err
+	t.mu.Unlock()
+}
+
+// setStatus sets the tunnel's status, keeping its error.
+func (t *Tunnel) setStatus(status TunnelStatus) {
+	t.mu.Lock()
+	t.Status = status
+	t.mu.Unlock()
+}
+
+// event returns a TunnelEvent of the given type for this tunnel.
+func (t *Tunnel) event(typ EventType) TunnelEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return TunnelEvent{Tunnel: t, Type: typ, SpecPort: t.specPort, LocalPort: t.LocalPort, Err: t.Error}
+}
+
 // Start begins listening on 127.0.0.1:LocalPort and forwarding connections.
 // It binds exclusively to loopback to prevent external access.
 func (t *Tunnel) Start() error {
-	t.Status = StatusConnecting
+	t.setState(StatusConnecting, nil)
 
 	listenAddr := fmt.Sprintf("127.0.0.1:%d", t.LocalPort)
 	ln, err := net.Listen("tcp", listenAddr)
 	if err != nil {
-		t.Status = StatusFailed
-		t.Error = fmt.Errorf("tunnel: listen on %s: %w", listenAddr, err)
-		return t.Error
+		if t.LocalPort < 1024 && isPermissionDenied(err) {
+			err = ErrPrivilegedPort
+		} else if isAddrInUse(err) {
+			err = ErrPortInUse
+		}
+		err = fmt.Errorf("tunnel: listen on %s: %w", listenAddr, err)
+		t.setState(StatusFailed, err)
+		return err
 	}
 	t.listener = ln
-	t.Status = StatusActive
+	t.setState(StatusActive, nil)
 
-	// Accept loop runs in background.
-	go t.acceptLoop()
+	// Accept loop runs in background. It captures this run's listener and
+	// context so a later reset can't hand it the next run's.
+	ctx := t.ctx
+	t.tracker.Go(func() { t.acceptLoop(ln, ctx) })
 
 	return nil
 }
 
 // acceptLoop accepts incoming connections on the local listener and
 // forwards each one through the SSH tunnel.
-func (t *Tunnel) acceptLoop() {
+func (t *Tunnel) acceptLoop(ln net.Listener, ctx context.Context) {
+	// Cancelling the run closes its listener, so a blocked Accept returns
+	// at once without any polling, whoever cancelled.
+	defer context.AfterFunc(ctx, func() { ln.Close() })()
+
 	consecutiveErrors := 0
 	for {
-		conn, err := t.listener.Accept()
+		conn, err := ln.Accept()
 		if err != nil {
-			// Listener closed (via Stop) -- exit cleanly.
+			// Listener closed (via Stop or StopAccepting) -- exit cleanly.
 			select {
-			case <-t.ctx.Done():
+			case <-ctx.Done():
 				return
 			default:
 			}
+			if t.draining.Load() {
+				return
+			}
 			// Backoff on persistent accept errors to avoid tight spin.
 			consecutiveErrors++
 			if consecutiveErrors >= 10 {
-				t.Status = StatusFailed
-				t.Error = fmt.Errorf("tunnel: too many accept errors on port %d: %w", t.LocalPort, err)
+				t.setState(StatusFailed, fmt.Errorf("tunnel: too many accept errors on port %d: %w", t.LocalPort, err))
 				return
 			}
 			time.Sleep(time.Duration(consecutiveErrors) * 50 * time.Millisecond)
 			continue
 		}
 		consecutiveErrors = 0
-		go t.forward(conn)
+		if t.isExposureCheck(conn) {
+			conn.Close()
+			continue
+		}
+		t.tracker.Go(func() { t.forward(ctx, conn) })
 	}
 }
 
 // forward connects the local connection to the remote host through the
 // SSH tunnel and copies data bidirectionally.
-func (t *Tunnel) forward(local net.Conn) {
+func (t *Tunnel) forward(ctx context.Context, local net.Conn) {
 	atomic.AddInt64(&t.connCount, 1)
 	defer atomic.AddInt64(&t.connCount, -1)
 	defer local.Close()
@@ -127,6 +220,11 @@ func (t *Tunnel) forward(local net.Conn) {
 	log := tunnelLog()
 	log.Printf("fwd: accept on :%d -> dial %s", t.LocalPort, remoteAddr)
 
+	if err := t.limiter.Wait(ctx); err != nil {
+		log.Printf("fwd: rate limit wait aborted :%d -> %s: %v", t.LocalPort, remoteAddr, err)
+		return
+	}
+
 	remote, err := t.client.Dial("tcp", remoteAddr)
 	if err != nil {
 		log.Printf("fwd: DIAL FAILED :%d -> %s: %v", t.LocalPort, remoteAddr, err)
@@ -135,6 +233,7 @@ func (t *Tunnel) forward(local net.Conn) {
 	defer remote.Close()
 
 	log.Printf("fwd: connected :%d -> %s", t.LocalPort, remoteAddr)
+	t.markVerified()
 
 	// Bidirectional copy: two goroutines, done when either direction finishes.
 	// Buffer of 2 so neither goroutine blocks on send after the function returns.
@@ -157,11 +256,67 @@ func (t *Tunnel) forward(local net.Conn) {
 	select {
 	case <-done:
 		log.Printf("fwd: one direction done :%d <-> %s, closing", t.LocalPort, remoteAddr)
-	case <-t.ctx.Done():
+	case <-ctx.Done():
 		log.Printf("fwd: context cancelled :%d <-> %s", t.LocalPort, remoteAddr)
 	}
 }
 
+// markVerified records that a connection through the tunnel reached its
+// device, telling the manager the first time.
+func (t *Tunnel) markVerified() {
+	if t.verified.CompareAndSwap(false, true) && t.onVerified != nil {
+		t.onVerified(t)
+	}
+}
+
+// reach opens and closes one connection to the device through the
+// gateway, within probeTimeout.
+func (t *Tunnel) reach() error {
+	if err := t.limiter.Wait(t.ctx); err != nil {
+		return err
+	}
+	remoteAddr := fmt.Sprintf("%s:%d", t.RemoteHost, t.RemotePort)
+	result := make(chan error, 1)
+	go func() {
+		conn, err := t.client.Dial("tcp", remoteAddr)
+		if err == nil {
+			conn.Close()
+		}
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(probeTimeout):
+		return fmt.Errorf("tunnel: %s: no answer within %s", remoteAddr, probeTimeout)
+	}
+}
+
+// probeTimeout bounds the build-time probe. Policy refusals come back
+// immediately; a slow answer means the device is just slow or down.
+const probeTimeout = 2 * time.Second
+
+// probe opens and closes one forwarded connection so a gateway that
+// forbids this destination is caught at build time rather than on the
+// user's first click. Only a policy refusal is returned -- an unreachable
+// device may well come up later, so that is not treated as failure.
+func (t *Tunnel) probe() error {
+	if err := t.reach(); IsProhibited(err) {
+		return err
+	}
+	return nil
+}
+
+// StopAccepting closes the listener so no new connections arrive, but
+// leaves forwarded connections already open running until Stop. It is the
+// first half of a graceful close.
+func (t *Tunnel) StopAccepting() {
+	t.draining.Store(true)
+	if t.listener != nil {
+		t.listener.Close()
+	}
+}
+
 // Stop cancels the tunnel, closes the listener, and waits up to 5 seconds
 // for active forwarded connections to drain.
 func (t *Tunnel) Stop() error {
@@ -183,7 +338,7 @@ func (t *Tunnel) Stop() error {
 		select {
 		case <-deadline:
 			// Timed out waiting for connections to drain.
-			t.Status = StatusDisconnected
+			t.setStatus(StatusDisconnected)
 			return fmt.Errorf("tunnel: %d connections still active after 5s drain timeout on port %d",
 				atomic.LoadInt64(&t.connCount), t.LocalPort)
 		case <-ticker.C:
@@ -191,10 +346,31 @@ func (t *Tunnel) Stop() error {
 		}
 	}
 
-	t.Status = StatusDisconnected
+	t.setStatus(StatusDisconnected)
 	return nil
 }
 
+// reset returns a stopped or failed tunnel to its initial state so it can
+// be started again on the same local port.
+func (t *Tunnel) reset() {
+	t.cancel()
+	if t.listener != nil {
+		t.listener.Close()
+		t.listener = nil
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.setState(StatusDisconnected, nil)
+	t.exposed.Store(false)
+	t.draining.Store(false)
+}
+
+// FirstConnectOK reports whether a forwarded connection has reached the
+// device through this tunnel. An active tunnel only proves the local
+// listener is bound; this proves the path behind it works.
+func (t *Tunnel) FirstConnectOK() bool {
+	return t.verified.Load()
+}
+
 // ActiveConnections returns the number of currently active forwarded connections.
 func (t *Tunnel) ActiveConnections() int64 {
 	return atomic.LoadInt64(&t.connCount)
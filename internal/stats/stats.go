@@ -1,9 +1,10 @@
 package stats
 
 import (
-	"encoding/json"
 	"os"
 	"path/filepath"
+
+	"github.com/406-mot-acceptable/lmtm/internal/store"
 )
 
 // Stats tracks persistent usage data across sessions.
@@ -29,37 +30,28 @@ func statsPath() string {
 
 // Load reads the stats file. Returns zero stats if the file doesn't exist.
 func Load() Stats {
-	data, err := os.ReadFile(statsPath())
-	if err != nil {
-		return Stats{}
-	}
 	var s Stats
-	if err := json.Unmarshal(data, &s); err != nil {
+	if err := store.Load(statsPath(), &s); err != nil {
 		return Stats{}
 	}
 	return s
 }
 
-// save writes stats to disk, creating the directory if needed.
-func save(s Stats) error {
-	p := statsPath()
-	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
-		return err
-	}
-	data, err := json.Marshal(s)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(p, data, 0o644)
-}
-
 // AddTunnels increments the tunnel counter and saves. Returns a milestone
 // message if a threshold was just crossed, or empty string otherwise.
+// The update is locked so concurrent instances don't lose counts.
 func AddTunnels(count int) string {
-	s := Load()
-	prev := s.TunnelsBuilt
-	s.TunnelsBuilt += count
-	_ = save(s) // best-effort, don't break the app if this fails
+	var s Stats
+	prev := 0
+	// Best-effort, don't break the app if this fails.
+	err := store.Update(statsPath(), &s, func() error {
+		prev = s.TunnelsBuilt
+		s.TunnelsBuilt += count
+		return nil
+	})
+	if err != nil {
+		return ""
+	}
 
 	// Check if we crossed a milestone.
 	for _, threshold := range milestoneThresholds {
@@ -0,0 +1,311 @@
+// Package store provides locked read-modify-write access to the small JSON
+// state files kept under ~/.tunneler, so two lmtm windows open against
+// different sites don't lose each other's updates.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// lockTimeout is how long Update waits for another instance to finish.
+	lockTimeout = 2 * time.Second
+
+	// staleLockAge is when a lock file is assumed to belong to a crashed
+	// instance and is removed. Updates take milliseconds, so this is generous.
+	staleLockAge = 30 * time.Second
+
+	// instanceRefresh is how often a held instance lock is touched so it
+	// never looks stale while its holder is alive.
+	instanceRefresh = staleLockAge / 3
+)
+
+// ErrLocked is returned when the lock could not be acquired in time.
+var ErrLocked = errors.New("store: locked by another lmtm instance")
+
+// Load reads the JSON file at path into v. A missing file is not an error
+// and leaves v untouched.
+func Load(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("store: read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("store: parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// Update locks path, loads its current contents into v, calls fn to modify
+// v, and writes v back atomically. Because the file is re-read under the
+// lock, concurrent instances never overwrite each other's changes.
+//
+// A file that can't be parsed is treated as empty, matching the best-effort
+// behaviour of the stores built on top of this.
+func Update(path string, v any, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("store: create dir for %s: %w", path, err)
+	}
+
+	unlock, err := lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_ = Load(path, v)
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("store: encode %s: %w", path, err)
+	}
+	return writeAtomic(path, data)
+}
+
+// Save writes v to path atomically without locking, for files with a
+// single writer where last-writer-wins is the intent.
+func Save(path string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("store: create dir for %s: %w", path, err)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("store: encode %s: %w", path, err)
+	}
+	return writeAtomic(path, data)
+}
+
+// lock creates path.lock exclusively, retrying until lockTimeout. Lock
+// files older than staleLockAge are broken first. A plain O_EXCL file is
+// used instead of flock so the same code works on Windows.
+func lock(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		token, err := acquire(lockPath)
+		if err == nil {
+			return func() { releaseLock(lockPath, token) }, nil
+		}
+		if !errors.Is(err, errHeld) {
+			return nil, fmt.Errorf("store: lock %s: %w", path, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s (waited %s; remove %s if no other instance is running)",
+				ErrLocked, path, lockTimeout, lockPath)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// errHeld means a lock file exists and isn't stale.
+var errHeld = errors.New("lock held")
+
+// acquire tries once to create lockPath, breaking it first if it is
+// stale. The file holds a token unique to this holder, so releaseLock never
+// removes a lock that has since passed to someone else.
+func acquire(lockPath string) (string, error) {
+	token := fmt.Sprintf("%d %d", os.Getpid(), time.Now().UnixNano())
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_, err = fmt.Fprintln(f, token)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return "", err
+			}
+			return token, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+		if !breakStale(lockPath) {
+			return "", errHeld
+		}
+	}
+	return "", errHeld
+}
+
+// breakStale removes lockPath if it is older than staleLockAge, and
+// reports whether creating it is worth another try. The file is renamed
+// aside and checked to still be the one judged stale before it is
+// removed: two waiters can both see the same stale lock, and without the
+// check the slower one would delete the fresh lock the faster one just
+// created. A fresh lock moved aside by mistake is put back.
+func breakStale(lockPath string) bool {
+	info, err := os.Stat(lockPath)
+	if err != nil {
+		return errors.Is(err, os.ErrNotExist)
+	}
+	if time.Since(info.ModTime()) <= staleLockAge {
+		return false
+	}
+	aside := fmt.Sprintf("%s.stale-%d-%d", lockPath, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(lockPath, aside); err != nil {
+		// Someone else broke it first.
+		return true
+	}
+	if moved, err := os.Stat(aside); err == nil && !os.SameFile(info, moved) {
+		_ = os.Link(aside, lockPath)
+	}
+	os.Remove(aside)
+	return true
+}
+
+// releaseLock removes lockPath if it still holds token.
+func releaseLock(lockPath, token string) {
+	data, err := os.ReadFile(lockPath)
+	if err == nil && strings.TrimSpace(string(data)) == token {
+		os.Remove(lockPath)
+	}
+}
+
+// holderPID returns the PID recorded in a lock file, or 0 if unknown.
+func holderPID(lockPath string) int {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0
+	}
+	pid, _ := strconv.Atoi(fields[0])
+	return pid
+}
+
+// LockInstance claims path for as long as this process holds it, such as
+// one lock file per gateway, so a second lmtm connected to the same
+// gateway finds out about the first instead of silently sharing it. It
+// doesn't wait: a lock held elsewhere returns ErrLocked at once, naming
+// the holder's PID. The file is touched every instanceRefresh while held,
+// so one left by a crash goes stale and is taken over. Call release to
+// give it up.
+func LockInstance(path string) (release func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("store: create dir for %s: %w", path, err)
+	}
+	token, err := acquire(path)
+	if errors.Is(err, errHeld) {
+		if pid := holderPID(path); pid != 0 {
+			return nil, fmt.Errorf("%w: %s is held by process %d", ErrLocked, path, pid)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrLocked, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: lock %s: %w", path, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(instanceRefresh)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-t.C:
+				_ = os.Chtimes(path, now, now)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			releaseLock(path, token)
+		})
+	}, nil
+}
+
+// Version identifies what a file held when it was last read or written,
+// so a later write can tell whether another instance changed it in the
+// meantime. The zero Version stands for a missing file.
+type Version struct {
+	modTime time.Time
+	size    int64
+}
+
+// Stat returns the current Version of path.
+func Stat(path string) Version {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Version{}
+	}
+	return Version{modTime: info.ModTime(), size: info.Size()}
+}
+
+// Replace writes v to path under the lock, for files where the last
+// writer should win. conflict reports that the file was no longer at
+// since, meaning another instance wrote it after this one last saw it,
+// so the caller can say whose data it replaced. next is the version just
+// written, to pass as since next time.
+func Replace(path string, v any, since Version) (next Version, conflict bool, err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Version{}, false, fmt.Errorf("store: create dir for %s: %w", path, err)
+	}
+	unlock, err := lock(path)
+	if err != nil {
+		return Version{}, false, err
+	}
+	defer unlock()
+
+	cur := Stat(path)
+	conflict = !cur.modTime.Equal(since.modTime) || cur.size != since.size
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return Version{}, false, fmt.Errorf("store: encode %s: %w", path, err)
+	}
+	if err := writeAtomic(path, data); err != nil {
+		return Version{}, false, err
+	}
+	return Stat(path), conflict, nil
+}
+
+// writeAtomic writes data to a temp file next to path and renames it into
+// place so readers never see a half-written file.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("store: write %s: %w", path, err)
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("store: write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("store: write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmpName, 0o644); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("store: write %s: %w", path, err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("store: write %s: %w", path, err)
+	}
+	return nil
+}
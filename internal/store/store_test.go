@@ -0,0 +1,284 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type counter struct {
+	N       int            `json:"n"`
+	Writers map[string]int `json:"writers"`
+}
+
+// increment adds one to the counter at path under Update, checking that no
+// other writer is inside the critical section at the same time.
+func increment(path, writer string, inside *atomic.Int32) error {
+	var c counter
+	return Update(path, &c, func() error {
+		if inside != nil {
+			if n := inside.Add(1); n != 1 {
+				return fmt.Errorf("%d writers inside Update at once", n)
+			}
+			defer inside.Add(-1)
+		}
+		c.N++
+		if c.Writers == nil {
+			c.Writers = make(map[string]int)
+		}
+		c.Writers[writer]++
+		return nil
+	})
+}
+
+func TestUpdateConcurrentWriters(t *testing.T) {
+	tests := []struct {
+		name       string
+		staleFirst bool // start with a lock left behind by a crash
+	}{
+		{name: "clean"},
+		{name: "stale lock broken by racing waiters", staleFirst: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "stats.json")
+			if tt.staleFirst {
+				lockPath := path + ".lock"
+				if err := os.WriteFile(lockPath, []byte("1 1\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				old := time.Now().Add(-2 * staleLockAge)
+				if err := os.Chtimes(lockPath, old, old); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			const writers, each = 8, 25
+			var inside atomic.Int32
+			var wg sync.WaitGroup
+			errs := make(chan error, writers*each)
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < each; i++ {
+						if err := increment(path, strconv.Itoa(w), &inside); err != nil {
+							errs <- err
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+
+			var c counter
+			if err := Load(path, &c); err != nil {
+				t.Fatal(err)
+			}
+			if c.N != writers*each {
+				t.Errorf("counter = %d, want %d: updates were lost", c.N, writers*each)
+			}
+			if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+				t.Errorf("lock file left behind: %v", err)
+			}
+		})
+	}
+}
+
+// TestHelperProcess is run as a separate process by
+// TestUpdateAcrossProcesses; it is not a real test.
+func TestHelperProcess(t *testing.T) {
+	path := os.Getenv("STORE_HELPER_PATH")
+	if path == "" {
+		t.Skip("helper process only")
+	}
+	n, _ := strconv.Atoi(os.Getenv("STORE_HELPER_COUNT"))
+	for i := 0; i < n; i++ {
+		if err := increment(path, strconv.Itoa(os.Getpid()), nil); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func TestUpdateAcrossProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns processes")
+	}
+	path := filepath.Join(t.TempDir(), "stats.json")
+	const procs, each = 4, 20
+
+	var wg sync.WaitGroup
+	errs := make([]error, procs)
+	out := make([][]byte, procs)
+	for p := 0; p < procs; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+			cmd.Env = append(os.Environ(), "STORE_HELPER_PATH="+path, "STORE_HELPER_COUNT="+strconv.Itoa(each))
+			out[p], errs[p] = cmd.CombinedOutput()
+		}(p)
+	}
+	wg.Wait()
+	for p, err := range errs {
+		if err != nil {
+			t.Fatalf("helper %d: %v\n%s", p, err, out[p])
+		}
+	}
+
+	var c counter
+	if err := Load(path, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.N != procs*each {
+		t.Errorf("counter = %d, want %d: updates were lost", c.N, procs*each)
+	}
+	if len(c.Writers) != procs {
+		t.Errorf("%d processes recorded, want %d", len(c.Writers), procs)
+	}
+}
+
+func TestUpdateTimesOutOnHeldLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	unlock, err := lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	start := time.Now()
+	err = increment(path, "late", nil)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("Update = %v, want ErrLocked", err)
+	}
+	if !strings.Contains(err.Error(), path+".lock") {
+		t.Errorf("error %q doesn't name the lock file", err)
+	}
+	if d := time.Since(start); d < lockTimeout {
+		t.Errorf("gave up after %v, want %v", d, lockTimeout)
+	}
+}
+
+func TestBreakStaleKeepsFreshLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "x.lock")
+	if err := os.WriteFile(lockPath, []byte("1 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if breakStale(lockPath) {
+		t.Fatal("breakStale broke a fresh lock")
+	}
+
+	old := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if !breakStale(lockPath) {
+		t.Fatal("breakStale kept a stale lock")
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("stale lock still present: %v", err)
+	}
+	if matches, _ := filepath.Glob(lockPath + ".stale-*"); len(matches) != 0 {
+		t.Errorf("aside files left behind: %v", matches)
+	}
+}
+
+func TestReleaseLeavesOthersLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "x.lock")
+	token, err := acquire(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The lock was broken and re-taken by someone else meanwhile.
+	if err := os.WriteFile(lockPath, []byte("999 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	releaseLock(lockPath, token)
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Error("releaseLock removed another holder's lock")
+	}
+}
+
+func TestReplaceConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "last.json")
+
+	v1, conflict, err := Replace(path, map[string]string{"by": "a"}, Stat(path))
+	if err != nil || conflict {
+		t.Fatalf("first Replace = %v, conflict %v", err, conflict)
+	}
+	v2, conflict, err := Replace(path, map[string]string{"by": "a", "again": "yes"}, v1)
+	if err != nil || conflict {
+		t.Fatalf("Replace from own version = %v, conflict %v", err, conflict)
+	}
+
+	// Another instance writes in between.
+	if err := Save(path, map[string]string{"by": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	os.Chtimes(path, later, later)
+
+	if _, conflict, err = Replace(path, map[string]string{"by": "a"}, v2); err != nil || !conflict {
+		t.Fatalf("Replace over another writer = %v, conflict %v, want conflict", err, conflict)
+	}
+	var got map[string]string
+	if err := Load(path, &got); err != nil || got["by"] != "a" {
+		t.Errorf("file = %v, %v, want the last writer's data", got, err)
+	}
+
+	if _, conflict, _ := Replace(path, nil, Version{}); !conflict {
+		t.Error("Replace of an existing file from the missing-file version reported no conflict")
+	}
+}
+
+func TestLockInstance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "10.0.0.1.lock")
+
+	release, err := LockInstance(path)
+	if err != nil {
+		t.Fatalf("LockInstance: %v", err)
+	}
+	_, err = LockInstance(path)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("second LockInstance = %v, want ErrLocked", err)
+	}
+	if !strings.Contains(err.Error(), strconv.Itoa(os.Getpid())) {
+		t.Errorf("error %q doesn't name the holder's PID", err)
+	}
+
+	release()
+	release() // a second release is harmless
+	again, err := LockInstance(path)
+	if err != nil {
+		t.Fatalf("LockInstance after release: %v", err)
+	}
+	again()
+}
+
+func TestLockInstanceTakesOverStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gw.lock")
+	if err := os.WriteFile(path, []byte("424242 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	release, err := LockInstance(path)
+	if err != nil {
+		t.Fatalf("LockInstance over a crashed holder: %v", err)
+	}
+	release()
+}
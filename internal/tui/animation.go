@@ -2,6 +2,8 @@ package tui
 
 import (
 	"fmt"
+	"net"
+	"sort"
 	"strings"
 	"time"
 
@@ -17,10 +19,10 @@ type animTickMsg time.Time
 type pipeState int
 
 const (
-	pipePending  pipeState = iota // Waiting to build
-	pipeDrawing                   // Currently animating
-	pipeActive                    // Built successfully
-	pipeFailed                    // Build failed
+	pipePending pipeState = iota // Waiting to build
+	pipeDrawing                  // Currently animating
+	pipeActive                   // Built successfully
+	pipeFailed                   // Build failed
 )
 
 // animPipe represents one tunnel's visual pipe in the animation.
@@ -30,6 +32,34 @@ type animPipe struct {
 	RemotePort int
 	State      pipeState
 	Frame      int // Animation frame counter (0-4)
+	seq        int // position in the spec list, for orderSpec
+}
+
+// pipeOrder is the order the animation lists its pipes in. Events find
+// their pipe by local port, so the order is display only.
+type pipeOrder int
+
+const (
+	orderSpec      pipeOrder = iota // build order, device by device
+	orderLocalPort                  // ascending local port
+	orderRemote                     // remote IP, then remote port
+)
+
+// String names the order for the building screen's status bar.
+func (o pipeOrder) String() string {
+	switch o {
+	case orderLocalPort:
+		return "local port"
+	case orderRemote:
+		return "remote IP"
+	default:
+		return "build order"
+	}
+}
+
+// next returns the order 'o' switches to.
+func (o pipeOrder) next() pipeOrder {
+	return (o + 1) % 3
 }
 
 // AnimationModel renders the ASCII pipe construction animation.
@@ -37,6 +67,7 @@ type AnimationModel struct {
 	pipes      []animPipe
 	gatewayTag string
 	active     int // Index of currently-drawing pipe (-1 if none)
+	order      pipeOrder
 }
 
 // NewAnimationModel creates an animation for the given tunnel specs.
@@ -48,6 +79,7 @@ func NewAnimationModel(specs []ssh.TunnelSpec, gatewayTag string) AnimationModel
 			RemoteHost: s.RemoteHost,
 			RemotePort: s.RemotePort,
 			State:      pipePending,
+			seq:        i,
 		}
 	}
 	if gatewayTag == "" {
@@ -60,6 +92,45 @@ func NewAnimationModel(specs []ssh.TunnelSpec, gatewayTag string) AnimationModel
 	}
 }
 
+// SetOrder re-sorts the pipes, keeping the drawing one current.
+func (m *AnimationModel) SetOrder(o pipeOrder) {
+	activePort := 0
+	if m.active >= 0 && m.active < len(m.pipes) {
+		activePort = m.pipes[m.active].LocalPort
+	}
+	m.order = o
+	sort.SliceStable(m.pipes, func(i, j int) bool {
+		return o.less(m.pipes[i], m.pipes[j])
+	})
+	if activePort != 0 {
+		for i := range m.pipes {
+			if m.pipes[i].LocalPort == activePort {
+				m.active = i
+			}
+		}
+	}
+}
+
+// less orders two pipes. Remote IPs compare numerically, so .9 comes
+// before .10.
+func (o pipeOrder) less(a, b animPipe) bool {
+	switch o {
+	case orderLocalPort:
+		return a.LocalPort < b.LocalPort
+	case orderRemote:
+		if a.RemoteHost != b.RemoteHost {
+			ai, bi := net.ParseIP(a.RemoteHost).To4(), net.ParseIP(b.RemoteHost).To4()
+			if ai != nil && bi != nil {
+				return string(ai) < string(bi)
+			}
+			return a.RemoteHost < b.RemoteHost
+		}
+		return a.RemotePort < b.RemotePort
+	default:
+		return a.seq < b.seq
+	}
+}
+
 // Init starts the frame ticker.
 func (m AnimationModel) Init() tea.Cmd {
 	return m.tickCmd()
@@ -134,12 +205,11 @@ func (m AnimationModel) AllDone() bool {
 //
 // Layout per tunnel:
 //
-//   localhost:4435 ====[ GW ]==== 192.168.1.5:443   [ OK ]
+//	localhost:4435 ====[ GW ]==== 192.168.1.5:443   [ OK ]
 //
 // During animation, the pipe builds progressively with dots becoming equals:
 //
-//   localhost:4435 ==..[ GW ]..== 192.168.1.5:443   [....]
-//
+//	localhost:4435 ==..[ GW ]..== 192.168.1.5:443   [....]
 func (m AnimationModel) View() string {
 	if len(m.pipes) == 0 {
 		return ""
@@ -2,25 +2,37 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/406-mot-acceptable/lmtm/internal/browser"
+	"github.com/406-mot-acceptable/lmtm/internal/bugreport"
+	"github.com/406-mot-acceptable/lmtm/internal/config"
 	"github.com/406-mot-acceptable/lmtm/internal/discovery"
 	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+	"github.com/406-mot-acceptable/lmtm/internal/health"
 	"github.com/406-mot-acceptable/lmtm/internal/portmap"
+	"github.com/406-mot-acceptable/lmtm/internal/proxy"
 	"github.com/406-mot-acceptable/lmtm/internal/ssh"
 	"github.com/406-mot-acceptable/lmtm/internal/stats"
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+	"github.com/406-mot-acceptable/lmtm/internal/webdash"
 )
 
 // SurveyDataMsg carries WAN/LAN info from the async survey command.
 type SurveyDataMsg struct {
 	WAN      *gateway.WANConfig
 	LAN      *gateway.LANConfig
+	Networks []gateway.LANConfig // every LAN, LAN first; nil if only LANInfo worked
 	Hostname string
 	Err      error
 }
@@ -29,7 +41,7 @@ type SurveyDataMsg struct {
 type wizardState int
 
 const (
-	stateConnect   wizardState = iota
+	stateConnect wizardState = iota
 	stateDetecting
 	stateSurvey
 	stateScanning
@@ -39,6 +51,34 @@ const (
 	stateError
 )
 
+// healthProbeInterval is how often health probes run once enabled
+// with 'h' on the dashboard. healthProbeTimeout bounds each request.
+const (
+	healthProbeInterval = 30 * time.Second
+	healthProbeTimeout  = 5 * time.Second
+)
+
+// socksServicePort keys nested proxy listeners in the port allocator;
+// the formula puts them at 20800 + last octet.
+const socksServicePort = 1080
+
+// webDashboardPort is where 'w' serves the read-only web dashboard. It
+// sits below every base in the port formula.
+const webDashboardPort = 4400
+
+// sessionResume is what survives a dropped connection mid-wizard. The
+// password is deliberately not kept (decision 002); the user re-enters it.
+type sessionResume struct {
+	gateway  string
+	username string
+	entries  []deviceEntry
+
+	// rebuild skips the device list and builds the selection straight
+	// away; set when resuming the last session from a previous launch.
+	rebuild  bool
+	strategy portmap.Strategy
+}
+
 // errMsg wraps a generic error for state transitions.
 type errMsg struct {
 	err error
@@ -60,32 +100,101 @@ type AppModel struct {
 	tunnels  TunnelsModel
 
 	// Backend state.
-	sshClient   *ssh.Client
-	gw          gateway.Gateway
-	manager     *ssh.Manager
-	scanner     *discovery.Scanner
-	allocator   *portmap.PortAllocator
-	lanSubnet   string
-	gatewayAddr string
-	gatewayType string
-	hostname    string
+	sshClient  *ssh.Client
+	gw         gateway.Gateway
+	manager    *ssh.Manager
+	scanner    *discovery.Scanner
+	allocator  *portmap.PortAllocator
+	reviewed   []ssh.TunnelSpec    // forwards as selected, before allocation
+	webdash    *webdash.Server     // read-only web dashboard, nil when off
+	socks      *proxy.GatewayProxy // SOCKS5 proxy through the gateway, nil when off
+	lanSubnet  string
+	lanSubnets []string      // every LAN checked on the survey; nil means just lanSubnet
+	scanHosts  int           // addresses the next scan covers, for its timeout
+	gatewayRTT time.Duration // one command round trip, measured at connect
+	peakLoad   *gateway.Load // highest gateway load seen this session, nil if unsampled
+
+	// Public subnet guard: scans of non-RFC1918 subnets wait for 'y' on
+	// the scan screen, which allows them until disconnect. scanNmap says
+	// which scan to run again once confirmed.
+	allowPublic bool
+	scanNmap    bool
+
+	// Disconnect drain: listeners close at once, but open forwards get
+	// up to drainTimeout to finish. drainUntil is zero unless draining.
+	drainTimeout time.Duration
+	drainUntil   time.Time
+	gatewayAddr  string
+	username     string
+	sudo         bool     // gateway commands run through sudo; see useSudo
+	sshPort      string   // port that accepted the SSH connection
+	hostKeyAlgs  []string // restricted host key algorithms, if the gateway needed them
+	gatewayType  string
+	hostname     string
+	loginBanner  string // shown on the survey screen until acknowledged
+
+	// Nested SOCKS5 proxies: requested at device selection, started once
+	// the tunnels to the devices' SSH ports are up. Keyed by device IP.
+	pendingProxies []SelectedDevice
+	proxies        map[string]*proxy.NestedProxy
 
 	// Rescan merge state.
 	previousEntries []deviceEntry
 
+	// Set when the session fails after devices were listed, so a
+	// reconnect to the same gateway can skip straight back to them.
+	resume *sessionResume
+
+	// The last-session file as this instance last saw it, so replacing
+	// one saved meanwhile by another instance is noticed.
+	lastSaved store.Version
+
+	// Per-gateway instance lock, held while connected; nil when not
+	// held. instanceWarning says who holds it instead.
+	releaseInstance func()
+	instanceWarning string
+
 	// Error state.
-	lastErr error
+	lastErr   error
+	bugReport string // path of the report written from the error screen
 
 	// Terminal size.
 	width, height int
+
+	// Guided tour hints, on for a first run until dismissed with Ctrl+T.
+	tour bool
+
+	// Phase being re-run from the survey screen, "" when none is.
+	rerunning rerunPhase
+
+	// Pipe order on the building screen, kept for later builds.
+	buildOrder pipeOrder
+
+	// Close tunnels whose device doesn't answer, toggled with 'P' on the
+	// dashboard and applied to later builds in the session.
+	pruneDead bool
+
+	// Raw gateway output debug screen, toggled with Ctrl+L.
+	rawOutput bool
+	rawNotice string // result of the last copy from it
 }
 
 // NewAppModel creates the initial application model.
 func NewAppModel() AppModel {
-	return AppModel{
+	m := AppModel{
 		state:   stateConnect,
 		connect: NewConnectModel(),
+
+		drainTimeout: defaultDrainTimeout,
+		tour:         !tourDismissed(),
+		lastSaved:    store.Stat(lastSessionPath()),
 	}
+	if last := loadLastSession(); last != nil {
+		m.resume = last.resume()
+		m.connect.Prefill(last.Gateway, last.Username)
+		m.connect.SetResumeNote(last.summary() + " -- connect to rebuild it")
+	}
+	return m
 }
 
 // Init starts the connect screen.
@@ -93,18 +202,51 @@ func (m AppModel) Init() tea.Cmd {
 	return m.connect.Init()
 }
 
-// Update dispatches messages to the current state's handler.
+// Update dispatches messages to the current state's handler and keeps
+// the status file in step with state changes and tunnel events.
 func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	if nm, ok := next.(AppModel); ok {
+		_, tunnelEvent := msg.(TunnelBuildMsg)
+		if nm.state != m.state || tunnelEvent {
+			nm.writeStatus()
+		}
+		if nm.webdash != nil {
+			nm.webdash.Publish(nm.dashboardSnapshot())
+		}
+	}
+	return next, cmd
+}
+
+func (m AppModel) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// Handle global keys first.
 	if kmsg, ok := msg.(tea.KeyMsg); ok {
 		// Ctrl+C always force-quits.
 		if kmsg.String() == "ctrl+c" {
 			return m, m.cleanup()
 		}
+		// Ctrl+L opens the raw gateway output, which takes every key
+		// until it is closed.
+		if key.Matches(kmsg, DefaultGlobalKeys.RawOutput) {
+			return m.toggleRawOutput()
+		}
+		if m.rawOutput {
+			return m.updateRawOutput(kmsg)
+		}
 		// Esc goes back or disconnects depending on state.
 		if key.Matches(kmsg, DefaultGlobalKeys.Back) {
 			return m.handleBack()
 		}
+		// Ctrl+T ends the tour for good, or brings it back for this session.
+		if key.Matches(kmsg, DefaultGlobalKeys.Tour) {
+			m.tour = !m.tour
+			if !m.tour {
+				if err := dismissTour(); err != nil {
+					ssh.Logf("tour: %v", err)
+				}
+			}
+			return m, nil
+		}
 	}
 
 	// Handle window size.
@@ -113,6 +255,10 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		return m, nil
 	}
+	if msg, ok := msg.(rawOutputCopiedMsg); ok {
+		m.rawNotice = string(msg)
+		return m, nil
+	}
 
 	switch m.state {
 	case stateConnect:
@@ -136,8 +282,20 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-// View renders the current state's view.
+// View renders the current state's view, with its tour hint while the
+// tour is on.
 func (m AppModel) View() string {
+	if m.rawOutput {
+		return m.rawOutputView()
+	}
+	view := m.stateView()
+	if m.tour {
+		view = withTourHint(view, m.state, m.width, m.height)
+	}
+	return view
+}
+
+func (m AppModel) stateView() string {
 	switch m.state {
 	case stateConnect:
 		return m.connect.View()
@@ -164,14 +322,43 @@ func (m AppModel) View() string {
 
 func (m AppModel) updateConnect(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg.(type) {
+	case ForgetSessionMsg:
+		m.resume = nil
+		forgetLastSession()
+		m.lastSaved = store.Version{}
+		m.connect.SetResumeNote("")
+		return m, nil
+
+	case challengeAnsweredMsg:
+		m.state = stateDetecting
+		return m, msg.(challengeAnsweredMsg).next
+
+	case sshConnectedMsg, DetectDoneMsg:
+		// The login ended, e.g. timed out, while its prompts were shown.
+		if m.connect.Challenging() {
+			m.connect.CancelChallenge()
+			m.state = stateDetecting
+			return m.updateDetecting(msg)
+		}
+		return m, nil
+
 	case ConnectMsg:
 		cm := msg.(ConnectMsg)
 		m.gatewayAddr = cm.Gateway
-		m.detect = NewDetectModel(cm.Gateway)
+		m.username = cm.Username
+		m.sudo = cm.Sudo
+		if m.resume != nil && m.resume.gateway != cm.Gateway {
+			m.resume = nil
+		}
+		target := cm.Gateway
+		if cm.Jump != "" {
+			target += " via " + cm.Jump
+		}
+		m.detect = NewDetectModel(target)
 		m.state = stateDetecting
 		return m, tea.Batch(
 			m.detect.Init(),
-			m.connectCmd(cm.Gateway, cm.Username, cm.Password),
+			m.connectCmd(cm.Gateway, cm.Jump, cm.Username, cm.Password, cm.KeyFile, cm.Sudo),
 		)
 	}
 
@@ -182,16 +369,28 @@ func (m AppModel) updateConnect(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m AppModel) updateDetecting(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case AuthChallengeMsg:
+		m.state = stateConnect
+		return m, m.connect.SetChallenge(msg)
+
 	case sshConnectedMsg:
 		// Store backend state from the connection.
 		m.sshClient = msg.client
 		m.gw = msg.gw
 		m.hostname = msg.hostname
 		m.gatewayType = msg.gwType
+		m.loginBanner = msg.loginBanner
+		_, m.sshPort, _ = net.SplitHostPort(msg.addr)
+		m.hostKeyAlgs = msg.hostKeyAlgs
+		m.gatewayRTT = msg.rtt
+		m.lockInstance()
 		// Forward to detect sub-model as DetectDoneMsg.
 		doneMsg := DetectDoneMsg{
 			GatewayType: msg.gwType,
 			Hostname:    msg.hostname,
+			Addr:        msg.addr,
+			HostKey:     msg.hostKey,
+			Backend:     msg.backend,
 		}
 		m.detect, _ = m.detect.Update(doneMsg)
 		// Start async survey.
@@ -207,31 +406,7 @@ func (m AppModel) updateDetecting(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, m.surveyCmd()
 
 	case SurveyDataMsg:
-		if msg.Err != nil {
-			return m.toError(msg.Err)
-		}
-		var wan *WANConfig
-		if msg.WAN != nil {
-			wan = &WANConfig{
-				Interface: msg.WAN.InterfaceName,
-				PublicIP:  msg.WAN.PublicIP,
-				Gateway:   msg.WAN.Gateway,
-			}
-		}
-		var lan *LANConfig
-		if msg.LAN != nil {
-			lan = &LANConfig{
-				Interface: msg.LAN.InterfaceName,
-				Subnet:    msg.LAN.CIDR,
-				Gateway:   msg.LAN.GatewayIP,
-				DHCPStart: msg.LAN.DHCPStart,
-				DHCPEnd:   msg.LAN.DHCPEnd,
-			}
-			m.lanSubnet = msg.LAN.Subnet
-		}
-		m.survey = NewSurveyModel(m.gatewayAddr, m.gatewayType, m.hostname, wan, lan)
-		m.state = stateSurvey
-		return m, m.survey.Init()
+		return m.applySurvey(msg)
 	}
 
 	var cmd tea.Cmd
@@ -239,9 +414,105 @@ func (m AppModel) updateDetecting(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// applySurvey shows survey results, from the first survey or a re-run.
+// A re-run that finds a different LAN drops what was learned about the
+// old one.
+func (m AppModel) applySurvey(msg SurveyDataMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m.toError(msg.Err)
+	}
+	rerun := m.rerunning
+	if rerun != "" {
+		m.rerunning = ""
+		if msg.LAN != nil && m.lanSubnet != "" && msg.LAN.Subnet != m.lanSubnet {
+			ssh.Logf("rerun: LAN changed from %s.0/24 to %s.0/24, dropping the previous scan", m.lanSubnet, msg.LAN.Subnet)
+			m.previousEntries = nil
+			m.resume = nil
+		}
+	}
+	var wan *WANConfig
+	if msg.WAN != nil {
+		wan = &WANConfig{
+			Interface: msg.WAN.InterfaceName,
+			PublicIP:  msg.WAN.PublicIP,
+			Gateway:   msg.WAN.Gateway,
+		}
+	}
+	var lan *LANConfig
+	if msg.LAN != nil {
+		lan = surveyLAN(msg.LAN)
+		m.lanSubnet = msg.LAN.Subnet
+		m.scanHosts = discovery.ScanHostCount(msg.LAN.CIDR)
+	}
+	m.lanSubnets = nil
+	m.survey = NewSurveyModel(m.gatewayAddr, m.gatewayType, m.hostname, wan, lan)
+	var nets []LANConfig
+	for i := range msg.Networks {
+		nets = append(nets, *surveyLAN(&msg.Networks[i]))
+	}
+	m.survey.SetNetworks(nets)
+	if rerun != "" {
+		m.survey.SetRerun(fmt.Sprintf("Re-ran %s -- commands and output are in %s", rerun, ssh.LogPath()))
+	}
+	if m.loginBanner != "" {
+		m.survey.ShowNotice(m.loginBanner)
+	}
+	if m.instanceWarning != "" {
+		m.survey.SetWarning(m.instanceWarning)
+	}
+
+	// Reconnected to the gateway we dropped from: go back to the
+	// device list with the previous selection instead of rescanning.
+	if r := m.resume; r != nil && r.gateway == m.gatewayAddr && m.loginBanner == "" {
+		m.resume = nil
+		m.devices = NewDevicesModelFromEntries(r.entries)
+		m.devices.ApplyFavorites(m.gatewayAddr)
+		m.devices.notes = newDeviceNotes(m.gatewayAddr)
+		m.state = stateDevices
+		if r.rebuild {
+			m.devices.portStrategy = r.strategy
+			sel := DeviceSelectMsg{Devices: m.devices.SelectedDevices(), PortStrategy: r.strategy}
+			return m, func() tea.Msg { return sel }
+		}
+		return m, m.devices.Init()
+	}
+
+	m.state = stateSurvey
+	return m, m.survey.Init()
+}
+
 func (m AppModel) updateSurvey(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg.(type) {
+	case RerunPhaseMsg:
+		return m.rerunPhase(msg.(RerunPhaseMsg).Phase)
+	case phaseDetectedMsg:
+		return m.applyRedetect(msg.(phaseDetectedMsg))
+	case SurveyDataMsg:
+		return m.applySurvey(msg.(SurveyDataMsg))
+
+	case NoticeAckMsg:
+		host, banner := m.gatewayAddr, m.loginBanner
+		m.loginBanner = ""
+		return m, func() tea.Msg {
+			ssh.AcknowledgeBanner(host, banner)
+			return nil
+		}
+
 	case ScanRequestMsg:
+		// Scanning now would race the re-run's survey results.
+		if m.rerunning != "" {
+			return m, nil
+		}
+		if nets := msg.(ScanRequestMsg).Networks; len(nets) > 0 {
+			m.lanSubnets = nil
+			m.scanHosts = 0
+			for _, n := range nets {
+				m.lanSubnets = append(m.lanSubnets, n.Prefix)
+				m.scanHosts += discovery.ScanHostCount(n.Subnet)
+			}
+			m.lanSubnet = m.lanSubnets[0]
+		}
+		m.scanNmap = false
 		m.scan = NewScanModel()
 		m.state = stateScanning
 		return m, tea.Batch(
@@ -261,6 +532,10 @@ func (m AppModel) updateScanning(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Scan finished successfully with devices.
 		doneMsg := ScanDoneMsg{DevicesFound: len(msg.devices)}
 		m.scan, _ = m.scan.Update(doneMsg)
+		for _, s := range msg.loads {
+			m.notePeakLoad(s.Load)
+		}
+		strategy := m.devices.portStrategy
 		if m.previousEntries != nil {
 			merged := mergeEntries(m.previousEntries, msg.devices)
 			m.devices = NewDevicesModelFromEntries(merged)
@@ -268,15 +543,40 @@ func (m AppModel) updateScanning(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.devices = NewDevicesModel(msg.devices)
 		}
+		m.devices.notice = msg.notice
+		m.devices.portStrategy = strategy
+		m.devices.ApplyFavorites(m.gatewayAddr)
+		m.devices.notes = newDeviceNotes(m.gatewayAddr)
 		m.state = stateDevices
 		return m, m.devices.Init()
 
+	case ScanProgressMsg:
+		m.scan, _ = m.scan.Update(msg)
+		return m, msg.next
+
 	case ScanDoneMsg:
 		m.scan, _ = m.scan.Update(msg)
+		if errors.Is(msg.Err, discovery.ErrPublicSubnet) {
+			ssh.Logf("scan: refused: %v", msg.Err)
+			return m, nil // the scan screen asks for confirmation
+		}
 		if msg.Err != nil {
 			return m.toError(msg.Err)
 		}
 		return m, nil
+
+	case tea.KeyMsg:
+		if m.scan.AwaitingPublic() && msg.String() == "y" {
+			ssh.Logf("scan: public subnet confirmed for this session")
+			m.allowPublic = true
+			m.scan = NewScanModel()
+			cmd := m.scanCmd()
+			if m.scanNmap {
+				cmd = m.nmapScanCmd()
+			}
+			return m, tea.Batch(m.scan.Init(), cmd)
+		}
+		return m, nil
 	}
 
 	var cmd tea.Cmd
@@ -289,6 +589,9 @@ func (m AppModel) updateDevices(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case SubnetScanRequestMsg:
 		m.previousEntries = m.devices.Entries()
 		m.lanSubnet = msg.Subnet
+		m.lanSubnets = nil
+		m.scanHosts = 254 // typed subnets are always a /24
+		m.scanNmap = false
 		m.scan = NewScanModel()
 		m.state = stateScanning
 		return m, tea.Batch(
@@ -296,9 +599,21 @@ func (m AppModel) updateDevices(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.scanCmd(),
 		)
 
+	case NmapScanRequestMsg:
+		m.previousEntries = m.devices.Entries()
+		m.scanNmap = true
+		m.scan = NewScanModel()
+		m.state = stateScanning
+		return m, tea.Batch(
+			m.scan.Init(),
+			m.nmapScanCmd(),
+		)
+
 	case DeviceSelectMsg:
 		// Allocate ports and build tunnel specs.
 		m.allocator = portmap.NewPortAllocator()
+		m.allocator.SetStrategy(msg.PortStrategy)
+		m.allocator.SetBusy(ssh.LocalPortInUse)
 		var specs []ssh.TunnelSpec
 
 		// Auto-forward WinBox (8291) on MikroTik gateways.
@@ -312,33 +627,68 @@ func (m AppModel) updateDevices(msg tea.Msg) (tea.Model, tea.Cmd) {
 					RemoteHost: host,
 					RemotePort: 8291,
 					LocalPort:  lp,
+					Class:      discovery.ClassRouter.String(),
+					Vendor:     "MikroTik",
 				})
 			}
 		}
 
+		m.pendingProxies = nil
+		m.reviewed = nil
 		for _, d := range msg.Devices {
+			if d.DeviceProxyMode {
+				m.pendingProxies = append(m.pendingProxies, d)
+			}
 			for _, port := range d.Ports {
-				localPort, err := m.allocator.Allocate(d.IP, port)
-				if err != nil {
-					continue
+				// Only the octet formula promises a port up front.
+				expected := 0
+				if msg.PortStrategy == portmap.StrategyOctet {
+					expected = portmap.LocalPort(d.IP, port)
+				}
+				pinned, isPinned := d.LocalPorts[port]
+				if isPinned {
+					expected = pinned
+				}
+				m.reviewed = append(m.reviewed, ssh.TunnelSpec{
+					RemoteHost: d.IP,
+					RemotePort: port,
+					LocalPort:  expected,
+				})
+				// A typed port that's already taken falls back to the
+				// strategy; the spec diff then shows the move.
+				localPort := pinned
+				if !isPinned || m.allocator.Reserve(portmap.PortMapping{LocalPort: pinned, RemoteHost: d.IP, RemotePort: port}) != nil {
+					var err error
+					if localPort, err = m.allocator.Allocate(d.IP, port); err != nil {
+						continue
+					}
 				}
 				specs = append(specs, ssh.TunnelSpec{
 					RemoteHost: d.IP,
 					RemotePort: port,
 					LocalPort:  localPort,
+					Class:      d.Class.String(),
+					Vendor:     d.Vendor,
 				})
 			}
 		}
 		if len(specs) == 0 {
 			return m.toError(fmt.Errorf("no tunnels could be allocated"))
 		}
+		// The logins now live only in pendingProxies until the proxies start.
+		m.devices.proxyLogins = nil
 
 		m.manager = ssh.NewManager(m.sshClient, len(specs)*2)
+		m.manager.SetDialRate(config.LoadPrefs().MaxDialsPerSecond)
+		m.manager.SetPortFallback(m.allocator.Allocate)
+		m.manager.SetPortRelease(m.allocator.Release)
 		gwTag := m.hostname
 		if gwTag == "" {
 			gwTag = m.gatewayAddr
 		}
 		m.building = NewBuildingModel(specs, gwTag)
+		m.building.favorites = m.devices.favoriteIPs()
+		m.building.SetOrder(m.buildOrder)
 		m.state = stateBuilding
 		return m, tea.Batch(
 			m.building.Init(),
@@ -353,6 +703,11 @@ func (m AppModel) updateDevices(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m AppModel) updateBuilding(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg.(type) {
+	case tea.KeyMsg:
+		m.building, _ = m.building.Update(msg)
+		m.buildOrder = m.building.Order()
+		return m, nil
+
 	case TunnelBuildMsg:
 		var cmd tea.Cmd
 		m.building, cmd = m.building.Update(msg)
@@ -361,6 +716,7 @@ func (m AppModel) updateBuilding(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case BuildDoneMsg:
 		m.building, _ = m.building.Update(msg)
+		m.building.diff = ssh.DiffSpecs(m.reviewed, m.manager.Tunnels())
 		// Record tunnel stats and check for milestones.
 		active := msg.(BuildDoneMsg).Active
 		milestone := ""
@@ -375,10 +731,36 @@ func (m AppModel) updateBuilding(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case transitionToTunnelsMsg:
 		tunnels := m.manager.Tunnels()
 		tmsg := msg.(transitionToTunnelsMsg)
+		session := m.tunnels.sessionNotes
+		macs := make(map[string]string)
+		for _, e := range m.devices.Entries() {
+			macs[e.Device.IP] = e.Device.MAC
+		}
 		m.tunnels = NewTunnelsModel(tunnels)
+		if m.socks != nil {
+			m.tunnels.socksPort = m.socks.ListenPort
+		}
 		m.tunnels.milestone = tmsg.milestone
+		m.tunnels.SetNotes(session, m.devices.notes, macs)
+		m.tunnels.SetSpecDiff(m.building.diff)
+		m.tunnels.gatewayLoad = m.peakLoadHint()
+		m.tunnels.autoOpen = autoOpenEnabled()
+		m.tunnels.compact = config.LoadPrefs().Compact
+		m.checkDegraded()
 		m.state = stateTunnels
-		return m, m.tunnels.Init()
+		proxyCmd := m.startProxies(tunnels)
+		var loadCmd tea.Cmd
+		if _, ok := m.gw.(gateway.LoadSampler); ok && len(tunnels) >= largeSessionTunnels {
+			loadCmd = gatewayLoadTick(m.gw)
+		}
+		if m.pruneDead {
+			m.manager.PruneDead(ssh.DefaultPruneGrace)
+		}
+		var openCmd tea.Cmd
+		if m.tunnels.autoOpen && len(m.tunnels.webURLs()) > 0 {
+			m.tunnels, openCmd = m.tunnels.openAll()
+		}
+		return m, tea.Batch(m.tunnels.Init(), proxyCmd, loadCmd, openCmd)
 	}
 
 	var cmd tea.Cmd
@@ -387,11 +769,160 @@ func (m AppModel) updateBuilding(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m AppModel) updateTunnels(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if !m.drainUntil.IsZero() {
+		if kmsg, ok := msg.(tea.KeyMsg); ok {
+			if kmsg.String() == "f" {
+				ssh.Logf("session: drain force-closed with %d connections open", m.manager.ActiveConnections())
+				return m.disconnect()
+			}
+			return m, nil
+		}
+	}
 	switch msg.(type) {
 	case DisconnectMsg:
-		return m.disconnect()
+		return m.beginDisconnect()
+	case drainTickMsg:
+		return m.checkDrain()
+	case TunnelBuildMsg:
+		// Group operations report through the same event channel the
+		// build used; keep reading it.
+		var cmd tea.Cmd
+		m.tunnels, cmd = m.tunnels.Update(TunnelUpdateMsg{Event: msg.(TunnelBuildMsg).Event})
+		m.checkDegraded()
+		return m, tea.Batch(cmd, m.nextEventCmd())
+	case proxyStartedMsg:
+		pm := msg.(proxyStartedMsg)
+		if pm.err != nil {
+			m.tunnels.SetProxyStatus(pm.host, ssh.StatusFailed, pm.err)
+			return m, nil
+		}
+		if m.proxies == nil {
+			m.proxies = make(map[string]*proxy.NestedProxy)
+		}
+		m.proxies[pm.host] = pm.proxy
+		m.tunnels.SetProxyStatus(pm.host, ssh.StatusActive, nil)
+		return m, nil
+	case CloseGroupMsg:
+		if p, ok := m.proxies[msg.(CloseGroupMsg).Host]; ok {
+			p.Stop()
+			delete(m.proxies, msg.(CloseGroupMsg).Host)
+			m.tunnels.SetProxyStatus(msg.(CloseGroupMsg).Host, ssh.StatusDisconnected, nil)
+		}
+		mgr := m.manager
+		ports := msg.(CloseGroupMsg).Ports
+		return m, func() tea.Msg {
+			if err := mgr.CloseGroup(ports); err != nil {
+				return tunnelNoticeMsg(err.Error())
+			}
+			return nil
+		}
 	case ReconnectMsg:
-		// TODO: reconnect failed tunnels
+		// Only failed tunnels: ones closed with x stay closed.
+		var ports []int
+		for _, g := range m.tunnels.groups {
+			for _, t := range g.Tunnels {
+				if t.Status == ssh.StatusFailed {
+					ports = append(ports, t.LocalPort)
+				}
+			}
+		}
+		if len(ports) == 0 {
+			return m, nil
+		}
+		mgr := m.manager
+		return m, func() tea.Msg {
+			_ = mgr.RebuildGroup(ports) // per-tunnel failures arrive as events
+			return nil
+		}
+	case RebuildGroupMsg:
+		mgr := m.manager
+		ports := msg.(RebuildGroupMsg).Ports
+		return m, func() tea.Msg {
+			_ = mgr.RebuildGroup(ports) // per-tunnel failures arrive as events
+			return nil
+		}
+	case gatewayLoadTickMsg:
+		if msg.(gatewayLoadTickMsg).gw != m.gw {
+			return m, nil
+		}
+		return m, m.sampleLoadCmd()
+	case gatewayLoadMsg:
+		lm := msg.(gatewayLoadMsg)
+		if lm.gw != m.gw {
+			return m, nil
+		}
+		if lm.err == nil {
+			ssh.Logf("gateway load (%d devices): %s", len(m.tunnels.groups), lm.load)
+			m.notePeakLoad(lm.load)
+			m.tunnels.gatewayLoad = m.peakLoadHint()
+		}
+		return m, gatewayLoadTick(m.gw)
+	case CopySSHCommandMsg:
+		return m, m.copySSHCommandCmd()
+	case CopyPortTableMsg:
+		return m, m.copyPortTableCmd()
+	case CycleQoSMsg:
+		return m.cycleQoS()
+	case PruneDeadMsg:
+		m.pruneDead = !m.pruneDead
+		if !m.pruneDead {
+			m.tunnels.notice = "Tunnels to devices that don't answer are kept from now on"
+			return m, nil
+		}
+		m.manager.PruneDead(ssh.DefaultPruneGrace)
+		m.tunnels.notice = fmt.Sprintf("Closing tunnels whose device doesn't answer within %s, now and after each build",
+			ssh.DefaultPruneGrace)
+		return m, nil
+	case RTSPPlaylistMsg:
+		return m, m.rtspPlaylistCmd()
+	case WebDashboardMsg:
+		if m.webdash != nil {
+			m.webdash.Stop()
+			m.webdash = nil
+			m.tunnels.notice = "Web dashboard stopped"
+			return m, nil
+		}
+		srv := webdash.New()
+		if err := srv.Start(webDashboardPort); err != nil {
+			m.tunnels.notice = err.Error()
+			return m, nil
+		}
+		m.webdash = srv
+		m.tunnels.notice = "Read-only dashboard at " + srv.URL() + " (w: stop)"
+		return m, nil
+	case GatewaySOCKSMsg:
+		if m.socks != nil {
+			m.stopGatewaySOCKS()
+			m.tunnels.notice = "SOCKS5 proxy stopped"
+			return m, nil
+		}
+		p := proxy.NewGatewayProxy()
+		if err := p.Start(m.sshClient, proxy.DefaultGatewayPort); err != nil {
+			m.tunnels.notice = err.Error()
+			return m, nil
+		}
+		m.socks = p
+		m.tunnels.socksPort = p.ListenPort
+		m.tunnels.notice = fmt.Sprintf("Point the browser at SOCKS5 127.0.0.1:%d to reach any LAN address (s: stop)", p.ListenPort)
+		return m, nil
+	case AcceptCertsMsg:
+		changes := msg.(AcceptCertsMsg).Changes
+		return m, func() tea.Msg {
+			for _, c := range changes {
+				if err := health.AcceptPin(c.Key, c.Current); err != nil {
+					return tunnelNoticeMsg("accept certificate: " + err.Error())
+				}
+			}
+			return nil
+		}
+	case HealthProbeMsg:
+		gen := msg.(HealthProbeMsg).Gen
+		if !m.tunnels.probing || gen != m.tunnels.probeGen {
+			return m, nil // probing was toggled off since this was scheduled
+		}
+		return m, m.healthProbeCmd(gen)
+	case sshCommandMsg:
+		m.tunnels.ShowSSHCommand(msg.(sshCommandMsg).command, msg.(sshCommandMsg).copied)
 		return m, nil
 	}
 
@@ -408,16 +939,67 @@ func (m AppModel) updateError(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.disconnect()
 		case "q":
 			return m, m.cleanup()
+		case "b":
+			return m, m.bugReportCmd()
+		}
+	}
+	if msg, ok := msg.(bugReportMsg); ok {
+		if msg.err != nil {
+			m.bugReport = "Bug report failed: " + msg.err.Error()
+		} else {
+			m.bugReport = "Bug report written to " + msg.path
 		}
 	}
 	return m, nil
 }
 
+// bugReportMsg carries the outcome of writing a bug report.
+type bugReportMsg struct {
+	path string
+	err  error
+}
+
+// bugReportCmd writes an anonymized diagnostics file for the current
+// session, including the tail of the tunnel log.
+func (m AppModel) bugReportCmd() tea.Cmd {
+	info := bugreport.Info{
+		State:       stateLabel(m.prevState),
+		GatewayType: m.gatewayType,
+		SSHPort:     m.sshPort,
+		HostKeyAlgs: m.hostKeyAlgs,
+	}
+	if m.sshClient != nil {
+		info.ServerVersion = m.sshClient.ServerVersion()
+	}
+	if m.manager != nil {
+		for _, t := range m.manager.Tunnels() {
+			info.Tunnels++
+			switch status, _ := t.State(); status {
+			case ssh.StatusActive:
+				info.Active++
+			case ssh.StatusFailed:
+				info.Failed++
+			}
+		}
+	}
+	if m.lastErr != nil {
+		info.LastError = m.lastErr.Error()
+	}
+	return func() tea.Msg {
+		path, err := bugreport.Write(info, ssh.LogPath())
+		return bugReportMsg{path: path, err: err}
+	}
+}
+
 // --- Navigation ---
 
 func (m AppModel) handleBack() (tea.Model, tea.Cmd) {
 	switch m.state {
 	case stateConnect:
+		if m.connect.Challenging() {
+			m.state = stateDetecting
+			return m, m.connect.CancelChallenge()
+		}
 		return m, m.cleanup()
 	case stateSurvey:
 		return m.disconnect()
@@ -429,11 +1011,34 @@ func (m AppModel) handleBack() (tea.Model, tea.Cmd) {
 			m.devices.subnetInput.Blur()
 			m.devices.ipInput.Blur()
 			m.devices.portInput.Blur()
+			m.devices.cancelBatch()
+			m.devices.cancelProxy()
 			return m, nil
 		}
 		// Go back to survey.
 		m.state = stateSurvey
 		return m, nil
+	case stateTunnels:
+		// The note editor discards its edit on Esc.
+		if m.tunnels.editing {
+			var cmd tea.Cmd
+			m.tunnels, cmd = m.tunnels.Update(tea.KeyMsg{Type: tea.KeyEsc})
+			return m, cmd
+		}
+		m.tunnels.sshCommand = ""
+		return m, nil
+	case stateScanning:
+		// Only a refused public subnet stops the scan screen for input.
+		if !m.scan.AwaitingPublic() {
+			return m, nil
+		}
+		if m.previousEntries != nil {
+			m.previousEntries = nil
+			m.state = stateDevices
+			return m, nil
+		}
+		m.state = stateSurvey
+		return m, nil
 	case stateError:
 		return m.disconnect()
 	default:
@@ -441,20 +1046,112 @@ func (m AppModel) handleBack() (tea.Model, tea.Cmd) {
 	}
 }
 
+// surveyLAN converts a gateway LAN for display on the survey screen.
+func surveyLAN(lan *gateway.LANConfig) *LANConfig {
+	return &LANConfig{
+		Interface: lan.InterfaceName,
+		Subnet:    lan.CIDR,
+		Gateway:   lan.GatewayIP,
+		DHCPStart: lan.DHCPStart,
+		DHCPEnd:   lan.DHCPEnd,
+		Warning:   lan.ScanWarning(),
+		Prefix:    lan.Subnet,
+		PoolSize:  lan.PoolSize(),
+	}
+}
+
 // --- Async commands ---
 
-func (m AppModel) connectCmd(host, user, pass string) tea.Cmd {
-	return func() tea.Msg {
-		client := ssh.NewClient()
+// useSudo switches gw to running its commands through sudo, logging the
+// outcome. A gateway that refuses keeps running them unelevated.
+func useSudo(ctx context.Context, client *ssh.Client, gw gateway.Gateway) {
+	client.SetSudo(true)
+	ok, err := gateway.UseSudo(ctx, gw, client.ExecSudo)
+	switch {
+	case ok:
+		ssh.Logf("gateway: running commands through sudo")
+	case err != nil:
+		ssh.Logf("gateway: sudo unavailable, running commands unelevated: %v", err)
+	default:
+		ssh.Logf("gateway: %s has no sudo, running commands as the login user", gwDisplayName(gw.Type()))
+	}
+}
 
-		// Try connecting. If it fails with default algos, retry with ssh-rsa for Ubiquiti.
-		err := client.Connect(host, "22", user, pass, nil)
+func (m AppModel) connectCmd(host, jump, user, pass, keyFile string, sudo bool) tea.Cmd {
+	challenges := make(chan AuthChallengeMsg)
+	done := make(chan struct{})
+	run := func() tea.Msg {
+		defer close(done)
+		// An encrypted key is unlocked with the password field. A key that
+		// can't be read or unlocked is logged and skipped, so password and
+		// agent auth still get their turn. Any keyboard-interactive prompts
+		// go to the connect screen.
+		newClient := func() *ssh.Client {
+			client := ssh.NewClient()
+			client.SetChallenge(promptChallenge(challenges, done))
+			if err := client.SetIdentity(keyFile, pass); err != nil {
+				ssh.Logf("auth: skipping identity %s: %v", keyFile, err)
+			}
+			return client
+		}
+		client := newClient()
+
+		// A bastion is logged in to first, with the same credentials, and
+		// the gateway dialed through it. The gateway's client closes it.
+		var bastion *ssh.Client
+		if jump != "" {
+			jumpUser, jumpHost, jumpPort := splitJump(jump, user)
+			bastion = newClient()
+			ports := ssh.CandidatePorts(jumpHost)
+			if jumpPort != "" {
+				ports = []string{jumpPort}
+			}
+			if _, err := bastion.ConnectWithFallback(jumpHost, ports, jumpUser, pass, nil); err != nil {
+				return DetectDoneMsg{Err: fmt.Errorf("connection failed: bastion %s: %w", jump, err)}
+			}
+			ssh.Logf("client: reaching %s through bastion %s", host, jump)
+		}
+		connect := func(port string, algs []string) error {
+			if bastion != nil {
+				return client.ConnectVia(bastion, host, port, user, pass, algs)
+			}
+			return client.Connect(host, port, user, pass, algs)
+		}
+		fail := func(err error) tea.Msg {
+			if bastion != nil {
+				bastion.Close()
+			}
+			return DetectDoneMsg{Err: fmt.Errorf("connection failed: %w", err)}
+		}
+
+		// Try the cached port, then 22, then the fallbacks. If the handshake
+		// fails with default algos, retry that port with ssh-rsa for Ubiquiti.
+		// Through a bastion only the first port is tried.
+		var hostKeyAlgs []string
+		var port string
+		var err error
+		if bastion != nil {
+			port = ssh.CandidatePorts(host)[0]
+			if err = connect(port, nil); err == nil {
+				ssh.RememberPort(host, port)
+			}
+		} else {
+			port, err = client.ConnectWithFallback(host, ssh.CandidatePorts(host), user, pass, nil)
+		}
 		if err != nil {
+			// A refused login got past the host key exchange, so the
+			// ssh-rsa retry below can't help it.
+			var authErr *ssh.AuthError
+			if ssh.IsUnreachable(err) || errors.As(err, &authErr) {
+				return fail(err)
+			}
 			// Retry with ssh-rsa host key algorithm for Ubiquiti devices.
-			client = ssh.NewClient()
-			if err2 := client.Connect(host, "22", user, pass, []string{"ssh-rsa"}); err2 != nil {
-				return DetectDoneMsg{Err: fmt.Errorf("connection failed: %w", err)}
+			client = newClient()
+			hostKeyAlgs = []string{"ssh-rsa"}
+			if err2 := connect(port, hostKeyAlgs); err2 != nil {
+				return fail(err)
 			}
+			ssh.RememberPort(host, port)
 		}
 
 		// NOTE: No SSH-level keepalive. OS-level TCP keepalive is enabled
@@ -474,32 +1171,92 @@ func (m AppModel) connectCmd(host, user, pass string) tea.Cmd {
 			return DetectDoneMsg{Err: fmt.Errorf("detection failed: %w", err)}
 		}
 
-		// Get identity.
+		if p, ok := gateway.ProfileOf(gw); ok {
+			ssh.Logf("gateway: firmware %q -> profile %s, skipping [%s]", p.Version, p.Name, strings.Join(p.Skipped(), ", "))
+		}
+
+		// MikroTik answers structured queries faster over its API, when
+		// the service is enabled; otherwise everything stays on SSH.
+		if ok, err := gateway.UseAPI(ctx, gw, client.Dial, user, pass); ok {
+			ssh.Logf("gateway: using RouterOS API")
+		} else if err != nil {
+			ssh.Logf("gateway: RouterOS API unavailable, using SSH commands: %v", err)
+		}
+		if sudo {
+			useSudo(ctx, client, gw)
+		}
+
+		// Get identity. Its round trip stands in for the gateway's RTT
+		// when sizing the scan timeout.
+		start := time.Now()
 		hostname, _ := gw.Identity(ctx)
+		rtt := time.Since(start)
+
+		// Only surface the login banner if this text hasn't been
+		// acknowledged for this gateway before.
+		loginBanner := client.Banner()
+		if strings.TrimSpace(loginBanner) == "" || ssh.BannerAcknowledged(host, loginBanner) {
+			loginBanner = ""
+		}
 
 		// Store client and gateway on the model via a closure trick:
 		// We can't modify m directly, so we send the data via the msg.
 		// The AppModel will store these in updateDetecting via sshConnectedMsg.
 		return sshConnectedMsg{
-			client:   client,
-			gw:       gw,
-			hostname: hostname,
-			gwType:   gwDisplayName(gw.Type()),
+			client:      client,
+			gw:          gw,
+			hostname:    hostname,
+			gwType:      gwDisplayName(gw.Type()),
+			addr:        net.JoinHostPort(host, port),
+			loginBanner: loginBanner,
+			hostKey:     client.HostKeyFingerprint(),
+			hostKeyAlgs: hostKeyAlgs,
+			rtt:         rtt,
+			backend:     gateway.Backend(gw),
 		}
 	}
+	return tea.Batch(run, challengeCmd(challenges, done))
 }
 
 // sshConnectedMsg carries the SSH client and gateway after successful connection.
 type sshConnectedMsg struct {
-	client   *ssh.Client
-	gw       gateway.Gateway
-	hostname string
-	gwType   string
+	client      *ssh.Client
+	gw          gateway.Gateway
+	hostname    string
+	gwType      string
+	addr        string   // host:port that accepted the connection
+	loginBanner string   // unacknowledged login banner, empty if none
+	hostKey     string   // fingerprint accepted on first use
+	hostKeyAlgs []string // non-nil if the ssh-rsa retry was needed
+	rtt         time.Duration
+	backend     string // "api" or "ssh", see gateway.Backend
 }
 
 // scanDevicesMsg carries discovered devices from the scan.
 type scanDevicesMsg struct {
 	devices []discovery.DiscoveredDevice
+	notice  string // scanner guidance, e.g. ARP-only for read-only accounts
+	loads   []discovery.LoadSample
+}
+
+// Gateway load is sampled during sessions with at least
+// largeSessionTunnels tunnels, once per loadSampleInterval.
+const (
+	largeSessionTunnels = 16
+	loadSampleInterval  = time.Minute
+)
+
+// gatewayLoadTickMsg asks for the next gateway load sample. gw ties it to
+// the session that scheduled it, so a tick outliving a disconnect is dropped.
+type gatewayLoadTickMsg struct {
+	gw gateway.Gateway
+}
+
+// gatewayLoadMsg carries one gateway load sample taken on the dashboard.
+type gatewayLoadMsg struct {
+	gw   gateway.Gateway
+	load gateway.Load
+	err  error
 }
 
 // transitionToTunnelsMsg triggers the transition from building to tunnels view.
@@ -511,15 +1268,24 @@ func (m AppModel) surveyCmd() tea.Cmd {
 	return func() tea.Msg {
 		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
+		return m.surveyData(ctx)
+	}
+}
 
-		wan, _ := m.gw.WANInfo(ctx)
-		lan, _ := m.gw.LANInfo(ctx)
+// surveyData reads the WAN and LAN configuration from the gateway.
+func (m AppModel) surveyData(ctx context.Context) SurveyDataMsg {
+	wan, _ := m.gw.WANInfo(ctx)
+	var lan *gateway.LANConfig
+	nets, err := m.gw.LANNetworks(ctx)
+	if err == nil && len(nets) > 0 {
+		lan = &nets[0]
+	}
 
-		return SurveyDataMsg{
-			WAN:      wan,
-			LAN:      lan,
-			Hostname: m.hostname,
-		}
+	return SurveyDataMsg{
+		WAN:      wan,
+		LAN:      lan,
+		Networks: nets,
+		Hostname: m.hostname,
 	}
 }
 
@@ -528,27 +1294,301 @@ func (m AppModel) scanCmd() tea.Cmd {
 	// back to m.scanner inside the closure -- m is a value receiver copy
 	// and the assignment would be silently lost.
 	gw := m.gw
+	client := m.sshClient
+	subnets := m.lanSubnets
+	if len(subnets) == 0 {
+		subnets = []string{m.lanSubnet}
+	}
+	timeout := discovery.EstimateScanTimeout(m.scanHosts, m.gatewayRTT)
+	allowPublic := m.allowPublic
+
+	// The sweep reports between chunks; an update the screen hasn't
+	// taken yet is dropped rather than stalling the scan.
+	progress := make(chan ScanProgressMsg, 4)
+	sweepStatus := func(subnet string) discovery.SweepProgressFunc {
+		return func(pinged, total int) {
+			select {
+			case progress <- ScanProgressMsg{Status: fmt.Sprintf("Pinging %s.0/24: %d of %d addresses", subnet, pinged, total)}:
+			default:
+			}
+		}
+	}
+	run := func() tea.Msg {
+		defer close(progress)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		scanner := discovery.NewScanner(gw)
+		scanner.SetDialer(client.Dial)
+		scanner.SetExclusions(discovery.LoadExclusions())
+		scanner.SetBridgeFDB(true)
+		scanner.SetAllowPublic(allowPublic)
+		scanner.SetNmapRunner(client.Exec)
+		if len(subnets) == 1 {
+			scanner.SetSweepProgress(sweepStatus(subnets[0]))
+			devices, err := scanner.Scan(ctx, subnets[0], nil)
+			if err != nil {
+				return ScanDoneMsg{Err: err}
+			}
+			logScanMethod(subnets[0], scanner)
+			loads := logScanLoad(subnets[0], scanner.LoadSamples())
+			return scanDevicesMsg{devices: devices, notice: scanner.Notice(), loads: loads}
+		}
+
+		// Several LANs: one failing doesn't lose the others' devices.
+		var all []discovery.DiscoveredDevice
+		var notices []string
+		var loads []discovery.LoadSample
+		var lastErr error
+		for _, subnet := range subnets {
+			scanner.SetSweepProgress(sweepStatus(subnet))
+			devices, err := scanner.Scan(ctx, subnet, nil)
+			if err != nil {
+				lastErr = err
+				notices = append(notices, fmt.Sprintf("Scan of %s.0/24 failed: %v", subnet, err))
+				continue
+			}
+			all = append(all, devices...)
+			logScanMethod(subnet, scanner)
+			loads = append(loads, logScanLoad(subnet, scanner.LoadSamples())...)
+			if n := scanner.Notice(); n != "" && !slices.Contains(notices, n) {
+				notices = append(notices, n)
+			}
+		}
+		if len(all) == 0 && lastErr != nil {
+			return ScanDoneMsg{Err: lastErr}
+		}
+		return scanDevicesMsg{devices: all, notice: strings.Join(notices, "\n"), loads: loads}
+	}
+	return tea.Batch(run, scanProgressCmd(progress))
+}
+
+// logScanMethod writes each step down the scan's method ladder, and the
+// method it landed on, to the session log.
+func logScanMethod(subnet string, scanner *discovery.Scanner) {
+	for _, f := range scanner.Fallbacks() {
+		ssh.Logf("scan of %s.0/24: falling back: %s", subnet, f)
+	}
+	ssh.Logf("scan of %s.0/24: using %s", subnet, scanner.Method())
+}
+
+// logScanLoad writes a scan's gateway load samples to the session log and
+// returns them.
+func logScanLoad(subnet string, samples []discovery.LoadSample) []discovery.LoadSample {
+	for _, s := range samples {
+		ssh.Logf("gateway load %s scan of %s.0/24: %s", s.Phase, subnet, s.Load)
+	}
+	return samples
+}
+
+// nmapScanCmd runs the gateway-side nmap escalation. A gateway without
+// nmap isn't an error: the list comes back unchanged with a notice.
+func (m AppModel) nmapScanCmd() tea.Cmd {
+	gw := m.gw
+	client := m.sshClient
 	subnet := m.lanSubnet
+	allowPublic := m.allowPublic
 	return func() tea.Msg {
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		if err := discovery.CheckSubnet(subnet, allowPublic); err != nil {
+			return ScanDoneMsg{Err: err}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 		defer cancel()
 
-		scanner := discovery.NewScanner(gw)
-		devices, err := scanner.Scan(ctx, subnet, nil)
+		devices, err := discovery.NmapScan(ctx, gw, client.Exec, subnet)
+		if errors.Is(err, discovery.ErrNmapUnavailable) {
+			return scanDevicesMsg{notice: "nmap is not installed on the gateway -- list unchanged."}
+		}
 		if err != nil {
 			return ScanDoneMsg{Err: err}
 		}
+		devices = discovery.LoadExclusions().Filter(devices)
+		return scanDevicesMsg{
+			devices: devices,
+			notice:  fmt.Sprintf("nmap found %d live hosts on %s.0/24.", len(devices), subnet),
+		}
+	}
+}
+
+// rtspPlaylistCmd writes the active RTSP tunnels to a playlist, using
+// each camera's vendor for its stream path, and opens it.
+func (m AppModel) rtspPlaylistCmd() tea.Cmd {
+	vendors := make(map[string]string)
+	for _, e := range m.devices.Entries() {
+		vendors[e.Device.IP] = e.Device.Vendor
+	}
+	var streams []browser.RTSPStream
+	for _, t := range m.manager.Tunnels() {
+		if status, _ := t.State(); status != ssh.StatusActive || portmap.Protocol(t.RemotePort) != "RTSP" {
+			continue
+		}
+		name := t.RemoteHost
+		if v := vendors[t.RemoteHost]; v != "" {
+			name += " " + v
+		}
+		streams = append(streams, browser.RTSPStream{
+			Name:      name,
+			LocalPort: t.Port(),
+			Path:      browser.RTSPPath(vendors[t.RemoteHost]),
+		})
+	}
+	return func() tea.Msg {
+		if len(streams) == 0 {
+			return tunnelNoticeMsg("No active RTSP tunnels")
+		}
+		path, err := browser.SaveRTSPPlaylist(streams)
+		if err != nil {
+			return tunnelNoticeMsg(err.Error())
+		}
+		if err := browser.Open(path); err != nil {
+			return tunnelNoticeMsg(fmt.Sprintf("Playlist saved to %s, but opening it failed: %v", path, err))
+		}
+		return tunnelNoticeMsg(fmt.Sprintf("Opened %d streams from %s", len(streams), path))
+	}
+}
+
+// healthProbeCmd probes every active HTTP/HTTPS and RTSP tunnel
+// concurrently.
+func (m AppModel) healthProbeCmd(gen int) tea.Cmd {
+	client := m.sshClient
+	var targets, cameras []*ssh.Tunnel
+	for _, t := range m.manager.Tunnels() {
+		if status, _ := t.State(); status != ssh.StatusActive {
+			continue
+		}
+		switch portmap.Protocol(t.RemotePort) {
+		case "HTTP", "HTTPS":
+			targets = append(targets, t)
+		case "RTSP":
+			cameras = append(cameras, t)
+		}
+	}
+	// Certificates are pinned per device, by MAC where the scan found one.
+	gatewayAddr := m.gatewayAddr
+	macs := make(map[string]string)
+	vendors := make(map[string]string)
+	for _, e := range m.devices.Entries() {
+		macs[e.Device.IP] = e.Device.MAC
+		vendors[e.Device.IP] = e.Device.Vendor
+	}
+	return func() tea.Msg {
+		prober := health.NewProber(client.Dial, healthProbeTimeout)
+		results := make(map[int]health.Result, len(targets))
+		changes := make(map[int]health.CertChange)
+		rtsp := make(map[int]rtspCheck, len(cameras))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		// RTSP goes through the local listener, the same way the tech's
+		// player will, so a reply also proves the tunnel end to end.
+		dialLocal := func(network, addr string) (net.Conn, error) {
+			return net.DialTimeout(network, addr, healthProbeTimeout)
+		}
+		for _, t := range cameras {
+			wg.Add(1)
+			go func(t *ssh.Tunnel) {
+				defer wg.Done()
+				c := rtspCheck{vendor: vendors[t.RemoteHost]}
+				path := browser.RTSPPath(c.vendor)
+				if path == "" {
+					c.vendor = ""
+				}
+				addr := net.JoinHostPort("127.0.0.1", fmt.Sprint(t.Port()))
+				c.RTSPResult = health.ProbeRTSP(context.Background(), dialLocal, addr, path, healthProbeTimeout)
+				mu.Lock()
+				rtsp[t.Port()] = c
+				mu.Unlock()
+			}(t)
+		}
+		for _, t := range targets {
+			wg.Add(1)
+			go func(t *ssh.Tunnel) {
+				defer wg.Done()
+				useTLS := portmap.Protocol(t.RemotePort) == "HTTPS"
+				r := prober.Probe(context.Background(), t.RemoteHost, t.RemotePort, useTLS)
+				var change health.CertChange
+				changed := false
+				if r.CertFingerprint != "" {
+					key := health.PinKey(gatewayAddr, t.RemoteHost, macs[t.RemoteHost], t.RemotePort)
+					change, changed = health.CheckPin(key, r.CertFingerprint)
+				}
+				mu.Lock()
+				results[t.Port()] = r
+				if changed {
+					changes[t.Port()] = change
+				}
+				mu.Unlock()
+			}(t)
+		}
+		wg.Wait()
+		return HealthResultMsg{Gen: gen, Results: results, CertChanges: changes, RTSP: rtsp}
+	}
+}
 
-		return scanDevicesMsg{devices: devices}
+// sshCommandMsg carries the generated OpenSSH one-liner to the dashboard.
+type sshCommandMsg struct {
+	command string
+	copied  bool // false if the clipboard was unavailable
+}
+
+// copySSHCommandCmd generates the ssh(1) command equivalent to the
+// session's active tunnels and tries to put it on the clipboard.
+func (m AppModel) copySSHCommandCmd() tea.Cmd {
+	opts := ssh.OpenSSHOptions{
+		User:         m.username,
+		Host:         m.gatewayAddr,
+		Port:         m.sshPort,
+		HostKeyAlgos: m.hostKeyAlgs,
+	}
+	for _, t := range m.manager.Tunnels() {
+		if status, _ := t.State(); status == ssh.StatusActive {
+			opts.Forwards = append(opts.Forwards, ssh.TunnelSpec{
+				RemoteHost: t.RemoteHost,
+				RemotePort: t.RemotePort,
+				LocalPort:  t.Port(),
+			})
+		}
+	}
+	return func() tea.Msg {
+		command := ssh.OpenSSHCommand(opts)
+		err := clipboard.WriteAll(command)
+		return sshCommandMsg{command: command, copied: err == nil}
+	}
+}
+
+// copyPortTableCmd puts the session's port mapping on the clipboard as a
+// markdown table, ready to paste into a ticket.
+func (m AppModel) copyPortTableCmd() tea.Cmd {
+	rows := m.manager.MappingTable()
+	return func() tea.Msg {
+		if len(rows) < 2 {
+			return tunnelNoticeMsg("No active tunnels to copy")
+		}
+		if err := clipboard.WriteAll(markdownTable(rows)); err != nil {
+			return tunnelNoticeMsg("Clipboard unavailable: " + err.Error())
+		}
+		return tunnelNoticeMsg(fmt.Sprintf("Copied the port table for %d tunnels", len(rows)-1))
 	}
 }
 
+// markdownTable renders rows as a markdown table, the first row as its
+// header.
+func markdownTable(rows [][]string) string {
+	var b strings.Builder
+	for i, row := range rows {
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString(strings.Repeat("|---", len(row)) + "|\n")
+		}
+	}
+	return b.String()
+}
+
 func (m AppModel) buildCmd(specs []ssh.TunnelSpec) tea.Cmd {
 	// Capture manager before the closure to avoid value-copy issues.
 	mgr := m.manager
 	eventCh := mgr.Events()
 	return func() tea.Msg {
-		go mgr.BuildTunnels(specs)
+		mgr.StartBuild(specs)
 		// Read the first event; subsequent reads are chained via nextEventCmd.
 		ev, ok := <-eventCh
 		if !ok {
@@ -576,9 +1616,223 @@ func (m AppModel) nextEventCmd() tea.Cmd {
 	}
 }
 
+// proxyStartedMsg reports the outcome of starting one nested proxy.
+type proxyStartedMsg struct {
+	host  string
+	proxy *proxy.NestedProxy
+	err   error
+}
+
+// startProxies starts the nested proxies requested at device selection,
+// each through its device's SSH tunnel, and adds them to the dashboard.
+// The pending logins are dropped once the commands have captured them.
+func (m *AppModel) startProxies(tunnels []*ssh.Tunnel) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, d := range m.pendingProxies {
+		var via *ssh.Tunnel
+		for _, t := range tunnels {
+			if t.RemoteHost == d.IP && t.RemotePort == 22 {
+				via = t
+				break
+			}
+		}
+		listenPort, err := m.allocator.Allocate(d.IP, socksServicePort)
+		if err != nil {
+			continue
+		}
+		m.tunnels.AddProxy(d.IP, listenPort)
+		viaStatus := ssh.StatusDisconnected
+		if via != nil {
+			viaStatus, _ = via.State()
+		}
+		if viaStatus != ssh.StatusActive {
+			m.tunnels.SetProxyStatus(d.IP, ssh.StatusFailed,
+				fmt.Errorf("no active SSH tunnel to %s", d.IP))
+			continue
+		}
+
+		host, viaPort, user, pass := d.IP, via.Port(), d.ProxyUser, d.ProxyPassword
+		cmds = append(cmds, func() tea.Msg {
+			p := proxy.NewNestedProxy()
+			if err := p.Start(viaPort, user, pass, listenPort); err != nil {
+				return proxyStartedMsg{host: host, err: err}
+			}
+			return proxyStartedMsg{host: host, proxy: p}
+		})
+	}
+	m.pendingProxies = nil
+	return tea.Batch(cmds...)
+}
+
+// stopProxies stops every running nested proxy. They ride on tunnels, so
+// this runs before the manager closes them.
+func (m AppModel) stopProxies() {
+	for _, p := range m.proxies {
+		p.Stop()
+	}
+}
+
+// stopGatewaySOCKS stops the SOCKS5 proxy through the gateway if it is
+// running. It rides on the gateway connection, so this runs before the
+// manager closes it.
+func (m *AppModel) stopGatewaySOCKS() {
+	if m.socks != nil {
+		m.socks.Stop()
+		m.socks = nil
+		m.tunnels.socksPort = 0
+	}
+}
+
+// stopWebDashboard stops the read-only web dashboard if it is running.
+func (m *AppModel) stopWebDashboard() {
+	if m.webdash != nil {
+		m.webdash.Stop()
+		m.webdash = nil
+	}
+}
+
 // --- Cleanup ---
 
+// qosCycle is the order Q steps through DSCP markings.
+var qosCycle = []int{ssh.DSCPNone, ssh.DSCPInteractive, ssh.DSCPBulk}
+
+// cycleQoS moves the gateway connection to the next DSCP marking. All
+// tunnels share the connection, so the marking covers every one.
+func (m AppModel) cycleQoS() (tea.Model, tea.Cmd) {
+	if m.sshClient == nil {
+		return m, nil
+	}
+	next := qosCycle[0]
+	for i, d := range qosCycle {
+		if d == m.sshClient.DSCP() {
+			next = qosCycle[(i+1)%len(qosCycle)]
+		}
+	}
+	if err := m.sshClient.SetDSCP(next); err != nil {
+		m.tunnels.notice = err.Error()
+		return m, nil
+	}
+	ssh.Logf("session: QoS marking %s", ssh.DSCPName(next))
+	m.tunnels.qos = ssh.DSCPName(next)
+	return m, nil
+}
+
+// defaultDrainTimeout is how long a disconnect waits for open forwarded
+// connections, such as a firmware upload, before closing them. Zero
+// disconnects at once.
+const defaultDrainTimeout = 15 * time.Second
+
+// drainTickMsg re-checks a draining disconnect.
+type drainTickMsg struct{}
+
+func drainTick() tea.Cmd {
+	return tea.Tick(250*time.Millisecond, func(time.Time) tea.Msg { return drainTickMsg{} })
+}
+
+// beginDisconnect stops the tunnels accepting and, if forwarded
+// connections are still open, waits for them before disconnecting.
+func (m AppModel) beginDisconnect() (tea.Model, tea.Cmd) {
+	if m.manager == nil || m.drainTimeout <= 0 || m.manager.ActiveConnections() == 0 {
+		return m.disconnect()
+	}
+	m.manager.StopAccepting()
+	m.drainUntil = time.Now().Add(m.drainTimeout)
+	ssh.Logf("session: draining %d connections before disconnect", m.manager.ActiveConnections())
+	return m.checkDrain()
+}
+
+// checkDrain disconnects once the open connections have finished or the
+// drain timeout has passed, and otherwise updates the overlay.
+func (m AppModel) checkDrain() (tea.Model, tea.Cmd) {
+	if m.drainUntil.IsZero() {
+		return m, nil
+	}
+	n := m.manager.ActiveConnections()
+	left := time.Until(m.drainUntil)
+	if n == 0 || left <= 0 {
+		if n > 0 {
+			ssh.Logf("session: drain timed out with %d connections open", n)
+		}
+		return m.disconnect()
+	}
+	noun := "connections"
+	if n == 1 {
+		noun = "connection"
+	}
+	m.tunnels.draining = fmt.Sprintf("Draining %d active %s... %ds (f: force close)", n, noun, int(left.Seconds())+1)
+	return m, drainTick()
+}
+
+// checkDegraded updates the dashboard's degraded-session warning from the
+// manager's health ratio, logging each time the session crosses the line.
+func (m *AppModel) checkDegraded() {
+	if m.manager == nil {
+		return
+	}
+	ratio := m.manager.HealthRatio()
+	if !m.manager.Degraded() {
+		if m.tunnels.degraded != "" {
+			ssh.Logf("session: recovered, %.0f%% of tunnels active", ratio*100)
+		}
+		m.tunnels.degraded = ""
+		return
+	}
+	warning := fmt.Sprintf("Session degraded: only %.0f%% of tunnels active (SSH connection still up)", ratio*100)
+	if m.tunnels.degraded == "" {
+		ssh.Logf("session: degraded, %.0f%% of tunnels active", ratio*100)
+	}
+	m.tunnels.degraded = warning
+}
+
+// notePeakLoad keeps the highest gateway load seen this session.
+func (m *AppModel) notePeakLoad(l gateway.Load) {
+	if m.peakLoad == nil || l.Busy() > m.peakLoad.Busy() {
+		m.peakLoad = &l
+	}
+}
+
+// peakLoadHint returns the dashboard's peak load hint, or "" if the
+// gateway was never sampled.
+func (m AppModel) peakLoadHint() string {
+	if m.peakLoad == nil {
+		return ""
+	}
+	return "gateway peak: " + m.peakLoad.String()
+}
+
+// gatewayLoadTick schedules the next dashboard load sample.
+func gatewayLoadTick(gw gateway.Gateway) tea.Cmd {
+	return tea.Tick(loadSampleInterval, func(time.Time) tea.Msg { return gatewayLoadTickMsg{gw: gw} })
+}
+
+// sampleLoadCmd takes one gateway load sample.
+func (m AppModel) sampleLoadCmd() tea.Cmd {
+	gw := m.gw
+	sampler, ok := gw.(gateway.LoadSampler)
+	if !ok {
+		return nil
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		l, err := sampler.SampleLoad(ctx)
+		return gatewayLoadMsg{gw: gw, load: l, err: err}
+	}
+}
+
 func (m AppModel) disconnect() (tea.Model, tea.Cmd) {
+	if m.peakLoad != nil {
+		ssh.Logf("session: peak gateway load %s", m.peakLoad)
+	}
+	if m.state == stateTunnels {
+		m.lastSaved, _ = saveLastSession(m.lastSaved, m.gatewayAddr, m.username, m.devices.portStrategy, m.devices.Entries())
+	}
+	m.stopProxies()
+	m.stopGatewaySOCKS()
+	m.stopWebDashboard()
+	m.unlockInstance()
+	m.proxies = nil
+	m.pendingProxies = nil
 	if m.manager != nil {
 		m.manager.CloseAll()
 		m.manager = nil
@@ -590,13 +1844,60 @@ func (m AppModel) disconnect() (tea.Model, tea.Cmd) {
 	m.scanner = nil
 	m.allocator = nil
 	m.lanSubnet = ""
-
+	m.lanSubnets = nil
+	m.scanHosts = 0
+	m.allowPublic = false
+	m.peakLoad = nil
+	m.drainUntil = time.Time{}
+	m.rerunning = ""
+
+	m.devices = DevicesModel{}
 	m.connect = NewConnectModel()
+	if m.resume == nil {
+		if last := loadLastSession(); last != nil {
+			m.resume = last.resume()
+			m.connect.SetResumeNote(last.summary() + " -- connect to rebuild it")
+		}
+	}
+	if m.resume != nil {
+		m.connect.Prefill(m.resume.gateway, m.resume.username)
+	}
 	m.state = stateConnect
 	return m, m.connect.Init()
 }
 
+// lockInstance claims the connected gateway for this instance. Another
+// lmtm already connected to it isn't refused, since it may be a second
+// window on purpose, but its tunnels and state files can collide with
+// this one's, so the survey screen says so.
+func (m *AppModel) lockInstance() {
+	m.unlockInstance()
+	release, err := store.LockInstance(instanceLockPath(m.gatewayAddr))
+	if err != nil {
+		ssh.Logf("session: %v", err)
+		m.instanceWarning = fmt.Sprintf("Another lmtm instance is connected to %s (%v)", m.gatewayAddr, err)
+		return
+	}
+	m.releaseInstance = release
+}
+
+// unlockInstance gives up the gateway's instance lock, if held.
+func (m *AppModel) unlockInstance() {
+	if m.releaseInstance != nil {
+		m.releaseInstance()
+		m.releaseInstance = nil
+	}
+	m.instanceWarning = ""
+}
+
 func (m AppModel) cleanup() tea.Cmd {
+	if m.state == stateTunnels {
+		_, _ = saveLastSession(m.lastSaved, m.gatewayAddr, m.username, m.devices.portStrategy, m.devices.Entries())
+	}
+	m.stopProxies()
+	m.stopGatewaySOCKS()
+	m.stopWebDashboard()
+	m.unlockInstance()
 	if m.manager != nil {
 		m.manager.CloseAll()
 		m.manager = nil
@@ -604,11 +1905,23 @@ func (m AppModel) cleanup() tea.Cmd {
 		m.sshClient.Close()
 		m.sshClient = nil
 	}
+	removeStatus()
 	return tea.Quit
 }
 
 func (m AppModel) toError(err error) (tea.Model, tea.Cmd) {
+	switch m.state {
+	case stateDevices, stateScanning, stateBuilding:
+		if entries := m.devices.Entries(); len(entries) > 0 {
+			m.resume = &sessionResume{
+				gateway:  m.gatewayAddr,
+				username: m.username,
+				entries:  entries,
+			}
+		}
+	}
 	m.lastErr = err
+	m.bugReport = ""
 	m.prevState = m.state
 	m.state = stateError
 	return m, nil
@@ -632,8 +1945,13 @@ func (m AppModel) errorView() string {
 		b.WriteString(ErrorStyle.Render("An unknown error occurred"))
 	}
 
+	if m.bugReport != "" {
+		b.WriteString("\n\n")
+		b.WriteString(DimStyle.Render(m.bugReport))
+	}
+
 	panel := renderPanel("Error", b.String())
-	bar := renderStatusBar("r: retry", "q: quit", "Esc: back")
+	bar := renderStatusBar("r: retry", "b: bug report", "q: quit", "Esc: back")
 
 	return ContentStyle.Render(panel + "\n" + bar)
 }
@@ -0,0 +1,257 @@
+package tui
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/406-mot-acceptable/lmtm/internal/discovery"
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
+)
+
+func TestEscCancelsDashboardNote(t *testing.T) {
+	m := AppModel{state: stateTunnels, tunnels: NewTunnelsModel(nil)}
+	m.tunnels.sessionNotes = "kept"
+	m.tunnels, _ = m.tunnels.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("N")})
+	if !m.tunnels.editing {
+		t.Fatal("N did not open the session note editor")
+	}
+
+	next, _ := m.update(tea.KeyMsg{Type: tea.KeyEsc})
+	got := next.(AppModel)
+	if got.tunnels.editing {
+		t.Error("Esc left the note editor open")
+	}
+	if got.tunnels.sessionNotes != "kept" {
+		t.Errorf("Esc changed the session notes to %q", got.tunnels.sessionNotes)
+	}
+	if got.state != stateTunnels {
+		t.Errorf("Esc left the dashboard for state %d", got.state)
+	}
+}
+
+// dropAtDevices lists three devices with two selected, then fails the
+// session while the device list is showing.
+func dropAtDevices(t *testing.T) AppModel {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	entries := []deviceEntry{
+		{Device: discovery.DiscoveredDevice{IP: "192.168.88.10"}, Selected: true, Preset: PresetWeb},
+		{Device: discovery.DiscoveredDevice{IP: "192.168.88.11"}},
+		{Device: discovery.DiscoveredDevice{IP: "192.168.88.12"}, Selected: true, CustomPorts: []int{554}},
+	}
+	m := AppModel{
+		state:       stateDevices,
+		gatewayAddr: "192.168.88.1",
+		username:    "admin",
+		devices:     NewDevicesModelFromEntries(entries),
+	}
+	next, _ := m.toError(errors.New("ssh: connection lost"))
+	next, _ = next.(AppModel).disconnect()
+	return next.(AppModel)
+}
+
+func TestResumeSelectionAfterDrop(t *testing.T) {
+	m := dropAtDevices(t)
+	if m.state != stateConnect {
+		t.Fatalf("state = %d after the drop, want connect", m.state)
+	}
+	if m.connect.Gateway() != "192.168.88.1" || m.connect.Username() != "admin" {
+		t.Errorf("connect form prefilled with %q / %q", m.connect.Gateway(), m.connect.Username())
+	}
+
+	next, _ := m.updateConnect(ConnectMsg{Gateway: "192.168.88.1", Username: "admin", Password: "x"})
+	m = next.(AppModel)
+	next, _ = m.applySurvey(SurveyDataMsg{LAN: &gateway.LANConfig{Subnet: "192.168.88", CIDR: "192.168.88.0/24"}})
+	m = next.(AppModel)
+
+	if m.state != stateDevices {
+		t.Fatalf("state = %d after reconnecting, want devices", m.state)
+	}
+	if m.resume != nil {
+		t.Error("resume state kept after it was used")
+	}
+	var got []string
+	for _, d := range m.devices.SelectedDevices() {
+		got = append(got, d.IP)
+	}
+	if len(got) != 2 || got[0] != "192.168.88.10" || got[1] != "192.168.88.12" {
+		t.Errorf("selected after resume = %v, want [192.168.88.10 192.168.88.12]", got)
+	}
+	if e := m.devices.Entries()[2]; len(e.CustomPorts) != 1 || e.CustomPorts[0] != 554 {
+		t.Errorf("custom ports after resume = %v, want [554]", e.CustomPorts)
+	}
+}
+
+func TestResumeDroppedForOtherGateway(t *testing.T) {
+	m := dropAtDevices(t)
+
+	next, _ := m.updateConnect(ConnectMsg{Gateway: "10.0.0.1", Username: "admin", Password: "x"})
+	m = next.(AppModel)
+	next, _ = m.applySurvey(SurveyDataMsg{LAN: &gateway.LANConfig{Subnet: "10.0.0", CIDR: "10.0.0.0/24"}})
+	m = next.(AppModel)
+
+	if m.state != stateSurvey {
+		t.Errorf("state = %d on a different gateway, want survey", m.state)
+	}
+	if m.resume != nil {
+		t.Error("resume state kept for a different gateway")
+	}
+}
+
+func TestNmapScanMergesIntoDeviceList(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := AppModel{
+		state:       stateDevices,
+		gatewayAddr: "192.168.1.1",
+		lanSubnet:   "192.168.1",
+		devices: NewDevicesModelFromEntries([]deviceEntry{
+			{Device: discovery.DiscoveredDevice{IP: "192.168.1.10", MAC: "00:0C:29:11:22:33"}, Selected: true, Preset: PresetWeb},
+		}),
+	}
+
+	next, _ := m.updateDevices(NmapScanRequestMsg{})
+	m = next.(AppModel)
+	if m.state != stateScanning || !m.scanNmap {
+		t.Fatalf("state = %d, nmap = %v after N, want an nmap scan", m.state, m.scanNmap)
+	}
+
+	// nmap saw the known host again plus one that never answered ping.
+	next, _ = m.updateScanning(scanDevicesMsg{devices: []discovery.DiscoveredDevice{
+		{IP: "192.168.1.10", MAC: "00:0C:29:11:22:33"},
+		{IP: "192.168.1.30"},
+	}})
+	m = next.(AppModel)
+
+	entries := m.devices.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("merged list has %d entries, want 2", len(entries))
+	}
+	if e := entries[0]; e.Device.IP != "192.168.1.10" || !e.Selected || e.Preset != PresetWeb {
+		t.Errorf("known host after merge = %+v, want its selection kept", e)
+	}
+	if e := entries[1]; e.Device.IP != "192.168.1.30" || e.Device.MAC != "" || e.Selected {
+		t.Errorf("nmap-only host = %+v, want unselected with no MAC", e)
+	}
+}
+
+// drainingSession returns a dashboard with one tunnel holding an open
+// forwarded connection. The dial rate leaves the build's probe the only
+// token, so the connection waits on the limiter for about 1/rate seconds
+// and then fails, since the client never connected. Each test ends the
+// drain, which closes the manager.
+func drainingSession(t *testing.T, rate float64) (AppModel, int) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := free.Addr().(*net.TCPAddr).Port
+	free.Close()
+
+	mgr := ssh.NewManager(ssh.NewClient(), 16)
+	mgr.SetDialRate(rate)
+	if err := mgr.BuildTunnels([]ssh.TunnelSpec{{RemoteHost: "192.0.2.10", RemotePort: 80, LocalPort: port}}); err != nil {
+		t.Fatalf("BuildTunnels: %v", err)
+	}
+	conn, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	for deadline := time.Now().Add(2 * time.Second); mgr.ActiveConnections() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("forwarded connection never became active")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	m := AppModel{
+		state:        stateTunnels,
+		manager:      mgr,
+		tunnels:      NewTunnelsModel(nil),
+		drainTimeout: time.Minute,
+	}
+	next, _ := m.updateTunnels(DisconnectMsg{})
+	m = next.(AppModel)
+	if m.manager == nil || m.drainUntil.IsZero() {
+		t.Fatal("disconnect with an open connection did not start draining")
+	}
+	return m, port
+}
+
+func TestDrainStopsAccepting(t *testing.T) {
+	m, port := drainingSession(t, 0.001)
+	if !strings.HasPrefix(m.tunnels.draining, "Draining 1 active connection...") {
+		t.Errorf("drain overlay = %q", m.tunnels.draining)
+	}
+	if conn, err := net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(port), time.Second); err == nil {
+		conn.Close()
+		t.Error("tunnel accepted a new connection while draining")
+	}
+	next, _ := m.updateTunnels(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	if m = next.(AppModel); m.manager == nil {
+		t.Fatal("a key other than f ended the drain")
+	}
+	m.disconnect()
+}
+
+func TestDrainWaitsForConnections(t *testing.T) {
+	m, _ := drainingSession(t, 1)
+	for deadline := time.Now().Add(5 * time.Second); m.state == stateTunnels; {
+		if time.Now().After(deadline) {
+			t.Fatal("drain did not finish once the connection closed")
+		}
+		time.Sleep(50 * time.Millisecond)
+		next, _ := m.updateTunnels(drainTickMsg{})
+		m = next.(AppModel)
+	}
+	if m.manager != nil || m.state != stateConnect {
+		t.Errorf("after the drain: state %d, manager %v", m.state, m.manager)
+	}
+}
+
+func TestDrainTimeoutAndForce(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  tea.Msg
+		late bool
+	}{
+		{"timeout", drainTickMsg{}, true},
+		{"force", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := drainingSession(t, 0.001)
+			if tt.late {
+				m.drainUntil = time.Now().Add(-time.Second)
+			}
+			next, _ := m.updateTunnels(tt.msg)
+			if m = next.(AppModel); m.manager != nil || m.state != stateConnect {
+				t.Errorf("state %d, manager %v; want disconnected with the connection still open", m.state, m.manager)
+			}
+		})
+	}
+}
+
+func TestMarkdownTable(t *testing.T) {
+	rows := [][]string{
+		{"Local port", "Remote", "Device", "Vendor"},
+		{"1111", "192.168.88.1:8291", "Network Device", "Routerboard.com"},
+		{"4435", "192.168.88.20:80", "Camera", ""},
+	}
+	want := "| Local port | Remote | Device | Vendor |\n" +
+		"|---|---|---|---|\n" +
+		"| 1111 | 192.168.88.1:8291 | Network Device | Routerboard.com |\n" +
+		"| 4435 | 192.168.88.20:80 | Camera |  |\n"
+	if got := markdownTable(rows); got != want {
+		t.Errorf("markdownTable =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/406-mot-acceptable/lmtm/internal/browser"
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+)
+
+// autoOpenState remembers whether web tunnels open by themselves once a
+// build finishes. It is off until turned on with 'B' on the dashboard.
+type autoOpenState struct {
+	AutoOpen bool `json:"auto_open"`
+}
+
+func autoOpenPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".tunneler", "cache", "browser.json")
+}
+
+// autoOpenEnabled reports whether builds should open their web tunnels.
+func autoOpenEnabled() bool {
+	var s autoOpenState
+	if err := store.Load(autoOpenPath(), &s); err != nil {
+		return false
+	}
+	return s.AutoOpen
+}
+
+// saveAutoOpen keeps the setting for later builds and launches.
+func saveAutoOpen(on bool) error {
+	return store.Save(autoOpenPath(), &autoOpenState{AutoOpen: on})
+}
+
+// webURLs returns the web page URL of every device group that has an
+// HTTP or HTTPS tunnel, in dashboard order.
+func (m TunnelsModel) webURLs() []string {
+	var urls []string
+	for _, g := range m.groups {
+		if url := g.webURL(); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// openAllCmd opens urls in batches, so a large build doesn't flood the
+// browser, and reports the outcome as a dashboard notice.
+func openAllCmd(urls []string) tea.Cmd {
+	return func() tea.Msg {
+		if err := browser.OpenAll(urls, browser.DefaultBatchSize, browser.DefaultBatchDelay); err != nil {
+			return tunnelNoticeMsg(err.Error())
+		}
+		return tunnelNoticeMsg(fmt.Sprintf("Opened %d tabs", len(urls)))
+	}
+}
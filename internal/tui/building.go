@@ -2,8 +2,10 @@ package tui
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/406-mot-acceptable/lmtm/internal/ssh"
@@ -16,18 +18,24 @@ type TunnelBuildMsg struct {
 
 // BuildDoneMsg signals all tunnels have been built.
 type BuildDoneMsg struct {
-	Failed int
-	Active int
+	Failed     int
+	Active     int
+	Prohibited int // failures refused by gateway forwarding policy
 }
 
 // BuildingModel tracks tunnel construction and drives the animation.
 type BuildingModel struct {
-	animation AnimationModel
-	specs     []ssh.TunnelSpec
-	pending   int
-	active    int
-	failed    int
-	done      bool
+	animation  AnimationModel
+	specs      []ssh.TunnelSpec
+	active     int
+	failed     int
+	prohibited int // subset of failed refused by gateway policy
+	done       bool
+
+	favorites      map[string]bool // starred device IPs
+	failedFavorite map[string]int  // failed tunnel count per starred IP
+
+	diff []ssh.SpecChange // built tunnels vs the selection, set at BuildDone
 }
 
 // NewBuildingModel creates the tunnel construction screen.
@@ -35,7 +43,6 @@ func NewBuildingModel(specs []ssh.TunnelSpec, gatewayTag string) BuildingModel {
 	return BuildingModel{
 		animation: NewAnimationModel(specs, gatewayTag),
 		specs:     specs,
-		pending:   len(specs),
 	}
 }
 
@@ -44,12 +51,28 @@ func (m BuildingModel) Init() tea.Cmd {
 	return m.animation.Init()
 }
 
-// Update handles tunnel build events and animation ticks.
+// SetOrder sets the order the pipes are listed in.
+func (m *BuildingModel) SetOrder(o pipeOrder) {
+	m.animation.SetOrder(o)
+}
+
+// Order returns the order the pipes are listed in.
+func (m BuildingModel) Order() pipeOrder {
+	return m.animation.order
+}
+
+// Update handles tunnel build events, animation ticks and the order key.
 func (m BuildingModel) Update(msg tea.Msg) (BuildingModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case TunnelBuildMsg:
 		return m.handleEvent(msg.Event)
 
+	case tea.KeyMsg:
+		if key.Matches(msg, DefaultBuildingKeys.Order) {
+			m.SetOrder(m.Order().next())
+		}
+		return m, nil
+
 	case animTickMsg:
 		var cmd tea.Cmd
 		m.animation, cmd = m.animation.Update(msg)
@@ -61,7 +84,9 @@ func (m BuildingModel) Update(msg tea.Msg) (BuildingModel, tea.Cmd) {
 
 // handleEvent processes a single tunnel event.
 func (m BuildingModel) handleEvent(ev ssh.TunnelEvent) (BuildingModel, tea.Cmd) {
-	port := ev.Tunnel.LocalPort
+	// Pipes were drawn for the specs, so a tunnel moved to another
+	// local port still belongs to its spec's pipe.
+	port := ev.SpecPort
 
 	switch ev.Type {
 	case ssh.EventStarted:
@@ -70,25 +95,41 @@ func (m BuildingModel) handleEvent(ev ssh.TunnelEvent) (BuildingModel, tea.Cmd)
 
 	case ssh.EventActive:
 		m.animation.MarkActive(port)
-		m.pending--
 		m.active++
 
-	case ssh.EventFailed:
+	case ssh.EventFailed, ssh.EventRestricted:
+		// A restricted tunnel had already counted as active.
 		m.animation.MarkFailed(port)
-		m.pending--
+		if ev.Type == ssh.EventRestricted {
+			m.active--
+		}
 		m.failed++
+		if ssh.IsProhibited(ev.Err) {
+			m.prohibited++
+		}
+		if host := ev.Tunnel.RemoteHost; m.favorites[host] {
+			if m.failedFavorite == nil {
+				m.failedFavorite = make(map[string]int)
+			}
+			m.failedFavorite[host]++
+		}
 
 	case ssh.EventClosed:
 		// Ignore during build phase.
-	}
 
-	// Check if all tunnels are done.
-	if m.pending <= 0 && !m.done {
-		m.done = true
-		return m, func() tea.Msg {
-			return BuildDoneMsg{
-				Failed: m.failed,
-				Active: m.active,
+	case ssh.EventExposed, ssh.EventVerified, ssh.EventPruned:
+		// Shown on the dashboard.
+
+	case ssh.EventBuildDone:
+		// Sent after the forwarding probes, so the restricted count is final.
+		if !m.done {
+			m.done = true
+			return m, func() tea.Msg {
+				return BuildDoneMsg{
+					Failed:     m.failed,
+					Active:     m.active,
+					Prohibited: m.prohibited,
+				}
 			}
 		}
 	}
@@ -131,9 +172,36 @@ func (m BuildingModel) View() string {
 				formatBuildSummary(m.active, m.failed)))
 		}
 		b.WriteByte('\n')
+		if m.prohibited > 0 {
+			permitted := len(m.specs) - m.prohibited
+			b.WriteString(WarningStyle.Render(fmt.Sprintf(
+				"Gateway restricts forwarding targets -- %d of %d destinations permitted",
+				permitted, len(m.specs))))
+			b.WriteByte('\n')
+			b.WriteString(DimStyle.Render(
+				"The SSH account only allows forwards to specific hosts (e.g. permitopen=)."))
+			b.WriteByte('\n')
+		}
+		if len(m.failedFavorite) > 0 {
+			hosts := make([]string, 0, len(m.failedFavorite))
+			for host, n := range m.failedFavorite {
+				hosts = append(hosts, fmt.Sprintf("%s (%d failed)", host, n))
+			}
+			sort.Strings(hosts)
+			b.WriteString(ErrorStyle.Render("Favorites with failed tunnels: " + strings.Join(hosts, ", ")))
+			b.WriteByte('\n')
+		}
+		if len(m.diff) > 0 {
+			b.WriteString(WarningStyle.Render(fmt.Sprintf(
+				"%d forwards differ from the selection:", len(m.diff))))
+			b.WriteByte('\n')
+			b.WriteString(renderSpecDiff(m.diff))
+		}
 	}
 
-	return ContentStyle.Render(renderPanel("Building Tunnels", b.String()))
+	panel := renderPanel("Building Tunnels", b.String())
+	bar := renderStatusBar("o: order by " + m.Order().next().String() + " (now " + m.Order().String() + ")")
+	return ContentStyle.Render(panel + "\n" + bar)
 }
 
 func formatBuildSummary(active, failed int) string {
@@ -149,3 +217,18 @@ func formatBuildSummary(active, failed int) string {
 	}
 	return s
 }
+
+// renderSpecDiff lists each difference between the selection and the
+// built tunnels, one per line, so stale port numbers don't get copied.
+func renderSpecDiff(diff []ssh.SpecChange) string {
+	var b strings.Builder
+	for _, c := range diff {
+		style := WarningStyle
+		if c.Kind == ssh.SpecDropped {
+			style = ErrorStyle
+		}
+		b.WriteString("  " + style.Render(c.String()))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package tui
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
+)
+
+func TestBuildingPortSwapCompletesPipe(t *testing.T) {
+	specs := []ssh.TunnelSpec{
+		{RemoteHost: "192.168.1.10", RemotePort: 80, LocalPort: 10080},
+		{RemoteHost: "192.168.1.11", RemotePort: 443, LocalPort: 10443},
+	}
+	m := NewBuildingModel(specs, "gw")
+
+	// The first tunnel lost its port to another process and moved.
+	moved := ssh.NewTunnel(nil, 10080, "192.168.1.10", 80)
+	kept := ssh.NewTunnel(nil, 10443, "192.168.1.11", 443)
+	events := []ssh.TunnelEvent{
+		{Tunnel: moved, Type: ssh.EventStarted, SpecPort: 10080, LocalPort: 10080},
+		{Tunnel: moved, Type: ssh.EventActive, SpecPort: 10080, LocalPort: 20080},
+		{Tunnel: kept, Type: ssh.EventStarted, SpecPort: 10443, LocalPort: 10443},
+		{Tunnel: kept, Type: ssh.EventActive, SpecPort: 10443, LocalPort: 10443},
+		{Type: ssh.EventBuildDone},
+	}
+	for _, ev := range events {
+		m, _ = m.handleEvent(ev)
+	}
+
+	if !m.Done() {
+		t.Fatal("build not done")
+	}
+	if m.active != 2 {
+		t.Errorf("active = %d, want 2", m.active)
+	}
+	for _, p := range m.animation.pipes {
+		if p.State != pipeActive {
+			t.Errorf("pipe for :%d in state %v, want active", p.LocalPort, p.State)
+		}
+	}
+}
+
+func TestBuildingWaitsForRestrictedProbes(t *testing.T) {
+	specs := []ssh.TunnelSpec{
+		{RemoteHost: "192.168.1.10", RemotePort: 80, LocalPort: 10080},
+		{RemoteHost: "192.168.1.11", RemotePort: 80, LocalPort: 10081},
+	}
+	m := NewBuildingModel(specs, "gw")
+	open := ssh.NewTunnel(nil, 10080, "192.168.1.10", 80)
+	denied := ssh.NewTunnel(nil, 10081, "192.168.1.11", 80)
+	refusal := &gossh.OpenChannelError{Reason: gossh.Prohibited, Message: "administratively prohibited"}
+
+	events := []ssh.TunnelEvent{
+		{Tunnel: open, Type: ssh.EventStarted, SpecPort: 10080, LocalPort: 10080},
+		{Tunnel: open, Type: ssh.EventActive, SpecPort: 10080, LocalPort: 10080},
+		{Tunnel: denied, Type: ssh.EventStarted, SpecPort: 10081, LocalPort: 10081},
+		{Tunnel: denied, Type: ssh.EventActive, SpecPort: 10081, LocalPort: 10081},
+	}
+	for _, ev := range events {
+		m, _ = m.handleEvent(ev)
+	}
+	if m.Done() {
+		t.Fatal("build done before its probes finished")
+	}
+
+	m, _ = m.handleEvent(ssh.TunnelEvent{Tunnel: denied, Type: ssh.EventRestricted, SpecPort: 10081, LocalPort: 10081, Err: refusal})
+	m, cmd := m.handleEvent(ssh.TunnelEvent{Type: ssh.EventBuildDone})
+	if cmd == nil {
+		t.Fatal("EventBuildDone sent no BuildDoneMsg")
+	}
+	done, ok := cmd().(BuildDoneMsg)
+	if !ok {
+		t.Fatalf("cmd returned %T, want BuildDoneMsg", cmd())
+	}
+	if done.Active != 1 || done.Failed != 1 || done.Prohibited != 1 {
+		t.Errorf("BuildDoneMsg = %+v, want 1 active, 1 failed, 1 prohibited", done)
+	}
+	if view := m.View(); !strings.Contains(view, "Gateway restricts forwarding targets -- 1 of 2") {
+		t.Errorf("summary is missing the restriction line:\n%s", view)
+	}
+}
+
+// pipeLines returns the local port, remote and status of each pipe line
+// in the order the building screen draws them.
+func pipeLines(view string) [][3]string {
+	var lines [][3]string
+	for _, line := range strings.Split(view, "\n") {
+		f := strings.Fields(line)
+		if len(f) < 4 || !strings.HasPrefix(f[0], "localhost:") {
+			continue
+		}
+		for i := 1; i < len(f); i++ {
+			if strings.Count(f[i], ".") == 3 && strings.Contains(f[i], ":") {
+				lines = append(lines, [3]string{strings.TrimPrefix(f[0], "localhost:"), f[i], strings.Join(f[i+1:], " ")})
+				break
+			}
+		}
+	}
+	return lines
+}
+
+func TestBuildingPipeOrder(t *testing.T) {
+	specs := []ssh.TunnelSpec{
+		{RemoteHost: "192.168.1.10", RemotePort: 80, LocalPort: 10080},
+		{RemoteHost: "192.168.1.9", RemotePort: 554, LocalPort: 5546},
+		{RemoteHost: "192.168.1.10", RemotePort: 22, LocalPort: 1022},
+	}
+	web := ssh.NewTunnel(nil, 10080, "192.168.1.10", 80)
+	shell := ssh.NewTunnel(nil, 1022, "192.168.1.10", 22)
+	events := []ssh.TunnelEvent{
+		{Tunnel: web, Type: ssh.EventStarted, SpecPort: 10080, LocalPort: 10080},
+		{Tunnel: web, Type: ssh.EventActive, SpecPort: 10080, LocalPort: 10080},
+		{Tunnel: shell, Type: ssh.EventStarted, SpecPort: 1022, LocalPort: 1022},
+		{Tunnel: shell, Type: ssh.EventFailed, SpecPort: 1022, LocalPort: 1022, Err: errors.New("tunnel: port in use")},
+	}
+	web80 := [3]string{"10080", "192.168.1.10:80", "[ OK ]"}
+	cam := [3]string{"5546", "192.168.1.9:554", "[ ]"}
+	ssh22 := [3]string{"1022", "192.168.1.10:22", "[FAIL]"}
+
+	tests := []struct {
+		order pipeOrder
+		want  [][3]string
+	}{
+		{orderSpec, [][3]string{web80, cam, ssh22}},
+		{orderLocalPort, [][3]string{ssh22, cam, web80}},
+		{orderRemote, [][3]string{cam, ssh22, web80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order.String(), func(t *testing.T) {
+			m := NewBuildingModel(specs, "gw")
+			m.SetOrder(tt.order)
+			for _, ev := range events {
+				m, _ = m.handleEvent(ev)
+			}
+			if got := pipeLines(m.animation.View()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pipes =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildingOrderKeyKeepsDrawingPipe(t *testing.T) {
+	specs := []ssh.TunnelSpec{
+		{RemoteHost: "192.168.1.10", RemotePort: 80, LocalPort: 10080},
+		{RemoteHost: "192.168.1.9", RemotePort: 554, LocalPort: 5546},
+	}
+	m := NewBuildingModel(specs, "gw")
+	cam := ssh.NewTunnel(nil, 5546, "192.168.1.9", 554)
+	m, _ = m.handleEvent(ssh.TunnelEvent{Tunnel: cam, Type: ssh.EventStarted, SpecPort: 5546, LocalPort: 5546})
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")})
+	if m.Order() != orderLocalPort {
+		t.Fatalf("order after o = %v, want local port", m.Order())
+	}
+	if p := m.animation.pipes[m.animation.active]; p.LocalPort != 5546 || p.State != pipeDrawing {
+		t.Errorf("drawing pipe is :%d in state %v after reordering, want :5546", p.LocalPort, p.State)
+	}
+
+	// The reorder moved the camera first; its events still land on it.
+	m, _ = m.handleEvent(ssh.TunnelEvent{Tunnel: cam, Type: ssh.EventActive, SpecPort: 5546, LocalPort: 5546})
+	if got := pipeLines(m.animation.View()); got[0][0] != "5546" || got[0][2] != "[ OK ]" || got[1][2] != "[ ]" {
+		t.Errorf("pipes after the camera came up = %v", got)
+	}
+}
@@ -0,0 +1,196 @@
+package tui
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
+)
+
+// challengeTimeout is how long a login prompt waits for answers before
+// the login gives up, about as long as an OTP app takes to find.
+const challengeTimeout = 2 * time.Minute
+
+// errChallengeCancelled is the login error when the prompts are left.
+var errChallengeCancelled = errors.New("login prompts cancelled")
+
+// AuthChallengeMsg carries one round of keyboard-interactive prompts
+// from the gateway, e.g. a one-time code, to the connect screen. The
+// login waits until the answers are sent on answers.
+type AuthChallengeMsg struct {
+	Name        string
+	Instruction string
+	Questions   []string
+	Echo        []bool // whether each answer may be shown as it's typed
+
+	answers chan<- []string // nil answers cancel the login
+	next    tea.Cmd         // waits for the round after this one; see challengeCmd
+}
+
+// challengeAnsweredMsg hands the login back to the detect screen once the
+// answers are sent.
+type challengeAnsweredMsg struct {
+	next tea.Cmd
+}
+
+// challengeCmd waits for the next round of prompts from a running login.
+// Like scanProgressCmd, each round carries the wait for the one after it,
+// and the chain ends when the login closes done. ch itself is never
+// closed, so a prompt arriving late can't panic its sender.
+func challengeCmd(ch <-chan AuthChallengeMsg, done <-chan struct{}) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case msg := <-ch:
+			msg.next = challengeCmd(ch, done)
+			return msg
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// promptChallenge returns an ssh.ChallengeFunc that puts each round of
+// prompts on ch and waits for the connect screen's answers. Once done is
+// closed nobody is listening and prompts fail at once.
+func promptChallenge(ch chan<- AuthChallengeMsg, done <-chan struct{}) ssh.ChallengeFunc {
+	return func(name, instruction string, questions []string, echo []bool) ([]string, error) {
+		answers := make(chan []string, 1)
+		msg := AuthChallengeMsg{
+			Name:        name,
+			Instruction: instruction,
+			Questions:   questions,
+			Echo:        echo,
+			answers:     answers,
+		}
+		select {
+		case ch <- msg:
+		case <-done:
+			return nil, errChallengeCancelled
+		}
+		select {
+		case a := <-answers:
+			if a == nil {
+				return nil, errChallengeCancelled
+			}
+			return a, nil
+		case <-time.After(challengeTimeout):
+			return nil, errors.New("login prompts timed out")
+		}
+	}
+}
+
+// challengeForm is the connect screen's form for one round of prompts.
+type challengeForm struct {
+	msg    AuthChallengeMsg
+	inputs []textinput.Model
+	focus  int
+}
+
+// SetChallenge replaces the connect form with one input per question.
+func (m *ConnectModel) SetChallenge(msg AuthChallengeMsg) tea.Cmd {
+	f := &challengeForm{msg: msg}
+	for i := range msg.Questions {
+		ti := textinput.New()
+		ti.CharLimit = 128
+		ti.Width = 30
+		if i >= len(msg.Echo) || !msg.Echo[i] {
+			ti.EchoMode = textinput.EchoPassword
+			ti.EchoCharacter = '*'
+		}
+		f.inputs = append(f.inputs, ti)
+	}
+	m.challenge = f
+	return f.inputs[0].Focus()
+}
+
+// Challenging reports whether the screen is showing login prompts.
+func (m ConnectModel) Challenging() bool {
+	return m.challenge != nil
+}
+
+// CancelChallenge drops the prompts and fails the login waiting on them.
+// It returns the wait for the login's result.
+func (m *ConnectModel) CancelChallenge() tea.Cmd {
+	return m.endChallenge(nil)
+}
+
+// endChallenge sends answers to the waiting login and clears the form.
+func (m *ConnectModel) endChallenge(answers []string) tea.Cmd {
+	f := m.challenge
+	if f == nil {
+		return nil
+	}
+	m.challenge = nil
+	f.msg.answers <- answers
+	return f.msg.next
+}
+
+// updateChallenge handles input while prompts are shown. Enter on the
+// last prompt sends the answers.
+func (m ConnectModel) updateChallenge(msg tea.Msg) (ConnectModel, tea.Cmd) {
+	f := m.challenge
+	if kmsg, ok := msg.(tea.KeyMsg); ok {
+		step := 0
+		switch {
+		case key.Matches(kmsg, m.keys.NextField):
+			step = 1
+		case key.Matches(kmsg, m.keys.PrevField):
+			step = len(f.inputs) - 1
+		case key.Matches(kmsg, m.keys.Connect):
+			if f.focus < len(f.inputs)-1 {
+				step = 1
+				break
+			}
+			answers := make([]string, len(f.inputs))
+			for i, in := range f.inputs {
+				answers[i] = in.Value()
+			}
+			next := m.endChallenge(answers)
+			return m, func() tea.Msg { return challengeAnsweredMsg{next: next} }
+		}
+		if step != 0 {
+			f.inputs[f.focus].Blur()
+			f.focus = (f.focus + step) % len(f.inputs)
+			return m, f.inputs[f.focus].Focus()
+		}
+	}
+	var cmd tea.Cmd
+	f.inputs[f.focus], cmd = f.inputs[f.focus].Update(msg)
+	return m, cmd
+}
+
+// challengeView renders the prompts in place of the connect form.
+func (m ConnectModel) challengeView() string {
+	f := m.challenge
+	var form strings.Builder
+	if name := strings.TrimSpace(f.msg.Name); name != "" {
+		form.WriteString(AccentStyle.Render(name) + "\n")
+	}
+	if inst := strings.TrimSpace(f.msg.Instruction); inst != "" {
+		form.WriteString(DimStyle.Render(inst) + "\n")
+	}
+	if form.Len() > 0 {
+		form.WriteByte('\n')
+	}
+	for i, q := range f.msg.Questions {
+		cursor := "  "
+		if i == f.focus {
+			cursor = AccentStyle.Render("> ")
+		}
+		form.WriteString(cursor + LabelStyle.Render(strings.TrimSpace(q)) + " " + f.inputs[i].View() + "\n")
+	}
+	form.WriteString("\n" + DimStyle.Render("The gateway asked for more before letting you in, e.g. a one-time code."))
+
+	var b strings.Builder
+	b.WriteString(Banner())
+	b.WriteString("\n\n")
+	b.WriteString(renderPanel("Login prompt", form.String()))
+	b.WriteByte('\n')
+	b.WriteString(renderStatusBar("Tab/Shift+Tab: navigate", "Enter: answer", "Esc: cancel login"))
+	return ContentStyle.Render(b.String())
+}
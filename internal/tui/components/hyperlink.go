@@ -1,6 +1,9 @@
 package components
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Hyperlink renders an OSC8 clickable hyperlink for terminals that support it.
 // Terminals that do not support OSC8 will display just the text.
@@ -20,3 +23,13 @@ func HTTPLink(port int) string {
 	url := fmt.Sprintf("http://localhost:%d", port)
 	return Hyperlink(url, url)
 }
+
+// RTSPLink generates a clickable rtsp://localhost:PORT hyperlink, with
+// the stream path after it when path isn't empty.
+func RTSPLink(port int, path string) string {
+	url := fmt.Sprintf("rtsp://localhost:%d", port)
+	if path = strings.TrimPrefix(path, "/"); path != "" {
+		url += "/" + path
+	}
+	return Hyperlink(url, url)
+}
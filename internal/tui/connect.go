@@ -1,27 +1,46 @@
 package tui
 
 import (
+	"net"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/406-mot-acceptable/lmtm/internal/config"
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
 )
 
 // ConnectMsg is sent when the user submits the connection form.
 type ConnectMsg struct {
 	Gateway  string
+	Jump     string // "[user@]bastion[:port]" the gateway is reached through; "" for direct
 	Username string
 	Password string
+	KeyFile  string // private key offered before Password; "" for password only
+	Sudo     bool   // run gateway commands through sudo with Password
 }
 
+// connectFields is the number of inputs on the connect form.
+const connectFields = 4
+
+// ForgetSessionMsg asks to drop the saved last session and start fresh.
+type ForgetSessionMsg struct{}
+
 // ConnectModel is the gateway connection input screen.
 type ConnectModel struct {
 	gatewayInput  textinput.Model
 	usernameInput textinput.Model
 	passwordInput textinput.Model
+	keyInput      textinput.Model
 	focusIndex    int
+	inferredUser  string // last inferred username; replaced while untouched
+	inferredKey   string // last inferred key file, likewise
 	err           error
+	resumeNote    string         // describes the saved last session, if any
+	sudo          bool           // toggled with Ctrl+S
+	challenge     *challengeForm // gateway login prompts, shown in place of the form
 	keys          ConnectKeys
 	globals       GlobalKeys
 }
@@ -30,36 +49,74 @@ type ConnectModel struct {
 func NewConnectModel() ConnectModel {
 	gi := textinput.New()
 	gi.Placeholder = "192.168.1.1"
-	gi.CharLimit = 45 // IPv6 max
+	gi.CharLimit = 128 // IPv6 max, twice over with a bastion in front
 	gi.Width = 30
 	gi.Focus()
 
 	ui := textinput.New()
 	ui.Placeholder = "admin"
-	ui.SetValue("dato")
+	inferred := config.DefaultUsername("")
+	ui.SetValue(inferred)
 	ui.CharLimit = 32
 	ui.Width = 30
 
 	pi := textinput.New()
 	pi.Placeholder = "password"
+	if ssh.AgentAvailable() {
+		pi.Placeholder = "password (blank: ssh-agent)"
+	}
 	pi.EchoMode = textinput.EchoPassword
 	pi.EchoCharacter = '*'
 	pi.CharLimit = 128
 	pi.Width = 30
 
+	ki := textinput.New()
+	ki.Placeholder = "~/.ssh/id_ed25519 (optional)"
+	ki.CharLimit = 256
+	ki.Width = 30
+	inferredKey := config.DefaultIdentityFile("")
+	ki.SetValue(inferredKey)
+
 	return ConnectModel{
 		gatewayInput:  gi,
 		usernameInput: ui,
 		passwordInput: pi,
+		keyInput:      ki,
 		focusIndex:    0,
+		inferredUser:  inferred,
+		inferredKey:   inferredKey,
 		keys:          DefaultConnectKeys,
 		globals:       DefaultGlobalKeys,
 	}
 }
 
-// Gateway returns the entered gateway address.
+// Gateway returns the entered gateway address, without any bastion.
 func (m ConnectModel) Gateway() string {
-	return strings.TrimSpace(m.gatewayInput.Value())
+	v := m.gatewayInput.Value()
+	return strings.TrimSpace(v[strings.LastIndex(v, "+")+1:])
+}
+
+// Jump returns the bastion typed before the gateway as
+// "bastion:22+gateway", "" when the gateway is reached directly.
+func (m ConnectModel) Jump() string {
+	v := m.gatewayInput.Value()
+	if i := strings.LastIndex(v, "+"); i >= 0 {
+		return strings.TrimSpace(v[:i])
+	}
+	return ""
+}
+
+// splitJump parses a "[user@]host[:port]" bastion. The user defaults to
+// the gateway's and port is "" when not given.
+func splitJump(jump, user string) (jumpUser, host, port string) {
+	if u, rest, ok := strings.Cut(jump, "@"); ok {
+		user, jump = u, rest
+	}
+	host, port, err := net.SplitHostPort(jump)
+	if err != nil {
+		return user, strings.Trim(jump, "[]"), ""
+	}
+	return user, host, port
 }
 
 // Username returns the entered username.
@@ -72,11 +129,55 @@ func (m ConnectModel) Password() string {
 	return m.passwordInput.Value()
 }
 
+// KeyFile returns the entered private key path, "" when none.
+func (m ConnectModel) KeyFile() string {
+	return strings.TrimSpace(m.keyInput.Value())
+}
+
 // SetError sets an error to display on the connect screen.
 func (m *ConnectModel) SetError(err error) {
 	m.err = err
 }
 
+// Prefill fills in the gateway and username from an interrupted session
+// and moves focus to the password field.
+func (m *ConnectModel) Prefill(gateway, username string) {
+	m.gatewayInput.SetValue(gateway)
+	if username != "" {
+		m.usernameInput.SetValue(username)
+		m.inferredUser = ""
+	}
+	if m.KeyFile() == m.inferredKey {
+		m.inferredKey = config.DefaultIdentityFile(gateway)
+		m.keyInput.SetValue(m.inferredKey)
+	}
+	m.focusIndex = 2
+	m.updateFocus()
+}
+
+// SetResumeNote shows which saved session connecting will rebuild; ""
+// hides it.
+func (m *ConnectModel) SetResumeNote(note string) {
+	m.resumeNote = note
+}
+
+// refreshInferredUser re-infers the username and key file for the
+// entered gateway (ssh config Host blocks can be per-gateway), unless the
+// user has already typed their own.
+func (m *ConnectModel) refreshInferredUser() {
+	if m.focusIndex != 0 {
+		return
+	}
+	if m.Username() == m.inferredUser {
+		m.inferredUser = config.DefaultUsername(m.Gateway())
+		m.usernameInput.SetValue(m.inferredUser)
+	}
+	if m.KeyFile() == m.inferredKey {
+		m.inferredKey = config.DefaultIdentityFile(m.Gateway())
+		m.keyInput.SetValue(m.inferredKey)
+	}
+}
+
 // Init initializes the text input blink.
 func (m ConnectModel) Init() tea.Cmd {
 	return textinput.Blink
@@ -84,28 +185,46 @@ func (m ConnectModel) Init() tea.Cmd {
 
 // Update handles input events for the connect screen.
 func (m ConnectModel) Update(msg tea.Msg) (ConnectModel, tea.Cmd) {
+	if m.challenge != nil {
+		return m.updateChallenge(msg)
+	}
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch {
+		case m.resumeNote != "" && msg.String() == "ctrl+x":
+			m.gatewayInput.SetValue("")
+			m.focusIndex = 0
+			return m, tea.Batch(m.updateFocus(), func() tea.Msg { return ForgetSessionMsg{} })
+
+		case key.Matches(msg, m.keys.Sudo):
+			m.sudo = !m.sudo
+			return m, nil
+
 		case key.Matches(msg, m.keys.NextField):
-			m.focusIndex = (m.focusIndex + 1) % 3
+			m.refreshInferredUser()
+			m.focusIndex = (m.focusIndex + 1) % connectFields
 			return m, m.updateFocus()
 
 		case key.Matches(msg, m.keys.PrevField):
-			m.focusIndex = (m.focusIndex + 2) % 3 // +2 wraps backwards
+			m.refreshInferredUser()
+			m.focusIndex = (m.focusIndex + connectFields - 1) % connectFields
 			return m, m.updateFocus()
 
 		case key.Matches(msg, m.keys.Connect):
-			// Only trigger connect if we have at least gateway and password.
-			if m.Gateway() != "" && m.Password() != "" {
+			// Only trigger connect if we have a gateway and a password,
+			// key or agent to log in with.
+			if m.Gateway() != "" && (m.Password() != "" || m.KeyFile() != "" || ssh.AgentAvailable()) {
 				username := m.Username()
 				if username == "" {
-					username = "dato"
+					username = config.DefaultUsername(m.Gateway())
 				}
 				cmsg := ConnectMsg{
 					Gateway:  m.Gateway(),
+					Jump:     m.Jump(),
 					Username: username,
 					Password: m.Password(),
+					KeyFile:  m.KeyFile(),
+					Sudo:     m.sudo,
 				}
 				// Clear password from the input model immediately after
 				// capturing it, to reduce the window of plaintext retention.
@@ -135,14 +254,16 @@ func (m ConnectModel) Update(msg tea.Msg) (ConnectModel, tea.Cmd) {
 		m.usernameInput, cmd = m.usernameInput.Update(msg)
 	case 2:
 		m.passwordInput, cmd = m.passwordInput.Update(msg)
+	case 3:
+		m.keyInput, cmd = m.keyInput.Update(msg)
 	}
 	return m, cmd
 }
 
 // updateFocus sets focus on the correct input field.
 func (m *ConnectModel) updateFocus() tea.Cmd {
-	cmds := make([]tea.Cmd, 3)
-	inputs := []*textinput.Model{&m.gatewayInput, &m.usernameInput, &m.passwordInput}
+	cmds := make([]tea.Cmd, connectFields)
+	inputs := []*textinput.Model{&m.gatewayInput, &m.usernameInput, &m.passwordInput, &m.keyInput}
 	for i, input := range inputs {
 		if i == m.focusIndex {
 			cmds[i] = input.Focus()
@@ -155,6 +276,9 @@ func (m *ConnectModel) updateFocus() tea.Cmd {
 
 // View renders the connect screen.
 func (m ConnectModel) View() string {
+	if m.challenge != nil {
+		return m.challengeView()
+	}
 	var b strings.Builder
 
 	// LMTM banner.
@@ -170,6 +294,7 @@ func (m ConnectModel) View() string {
 		{"Gateway", m.gatewayInput},
 		{"Username", m.usernameInput},
 		{"Password", m.passwordInput},
+		{"Key file", m.keyInput},
 	}
 
 	for i, f := range fields {
@@ -185,6 +310,30 @@ func (m ConnectModel) View() string {
 		form.WriteByte('\n')
 	}
 
+	if m.Jump() != "" {
+		form.WriteByte('\n')
+		form.WriteString(DimStyle.Render("bastion: " + m.Jump() + " is logged in to first with the same credentials, then the gateway through it"))
+		form.WriteByte('\n')
+	}
+
+	if m.KeyFile() != "" {
+		form.WriteByte('\n')
+		form.WriteString(DimStyle.Render("key: offered first; an encrypted key is unlocked with the password, which is tried if the key is refused"))
+		form.WriteByte('\n')
+	}
+
+	if m.sudo {
+		form.WriteByte('\n')
+		form.WriteString(WarningStyle.Render("sudo: gateway commands run through sudo with this password (Linux gateways only)"))
+		form.WriteByte('\n')
+	}
+
+	if m.resumeNote != "" {
+		form.WriteByte('\n')
+		form.WriteString(AccentStyle.Render(m.resumeNote))
+		form.WriteByte('\n')
+	}
+
 	// Error display.
 	if m.err != nil {
 		form.WriteByte('\n')
@@ -195,11 +344,12 @@ func (m ConnectModel) View() string {
 
 	// Status bar.
 	b.WriteByte('\n')
-	b.WriteString(renderStatusBar(
-		"Tab/Shift+Tab: navigate",
-		"Enter: connect",
-		"Ctrl+C: quit",
-	))
+	hints := []string{"Tab/Shift+Tab: navigate", "Enter: connect", "Ctrl+S: sudo"}
+	if m.resumeNote != "" {
+		hints = append(hints, "Ctrl+X: start fresh")
+	}
+	hints = append(hints, "Ctrl+C: quit")
+	b.WriteString(renderStatusBar(hints...))
 
 	return ContentStyle.Render(b.String())
 }
@@ -18,6 +18,9 @@ type DetectStatusMsg struct {
 type DetectDoneMsg struct {
 	GatewayType string // "MikroTik" or "Ubiquiti"
 	Hostname    string
+	Addr        string // host:port that accepted the connection
+	HostKey     string // key type and SHA256 fingerprint, trusted on first use
+	Backend     string // "api" when MikroTik queries go over the RouterOS API
 	Err         error
 }
 
@@ -28,6 +31,8 @@ type DetectModel struct {
 	status      string
 	gatewayType string
 	hostname    string
+	addr        string
+	hostKey     string
 	done        bool
 	err         error
 }
@@ -62,7 +67,12 @@ func (m DetectModel) Update(msg tea.Msg) (DetectModel, tea.Cmd) {
 		} else {
 			m.gatewayType = msg.GatewayType
 			m.hostname = msg.Hostname
+			m.addr = msg.Addr
+			m.hostKey = msg.HostKey
 			m.status = fmt.Sprintf("Detected %s - %q", msg.GatewayType, msg.Hostname)
+			if msg.Backend == "api" {
+				m.status += " (RouterOS API)"
+			}
 		}
 		return m, nil
 	}
@@ -111,6 +121,19 @@ func (m DetectModel) View() string {
 			b.WriteString(DimStyle.Render(fmt.Sprintf(" - %q", m.hostname)))
 		}
 		b.WriteByte('\n')
+		if m.addr != "" {
+			b.WriteString(DimStyle.Render("  Connected to " + m.addr))
+			b.WriteByte('\n')
+		}
+		if m.hostKey != "" {
+			b.WriteByte('\n')
+			b.WriteString(BoxStyle.Padding(0, 1).Render(
+				AccentStyle.Render("New host key") + "\n" +
+					m.hostKey + "\n" +
+					DimStyle.Render("Compare with the fingerprint on the device label."),
+			))
+			b.WriteByte('\n')
+		}
 	} else {
 		b.WriteString(m.spinner.View())
 	}
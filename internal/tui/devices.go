@@ -13,6 +13,7 @@ import (
 
 	"github.com/406-mot-acceptable/lmtm/internal/discovery"
 	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+	"github.com/406-mot-acceptable/lmtm/internal/portmap"
 )
 
 // devicesMode tracks the current input mode of the devices screen.
@@ -22,6 +23,10 @@ const (
 	modeList   devicesMode = iota // Normal device list browsing
 	modeSubnet                    // Subnet input for rescanning
 	modeManual                    // Manual IP:Port entry
+	modeBatch                     // Per-device port lists for all selected devices
+	modeProxy                     // Device login for a nested SOCKS5 proxy
+	modeNote                      // Editing the note on the cursor device
+	modeByPort                    // Port input for selecting by port
 )
 
 // PortPreset cycles through port assignment modes for a device.
@@ -61,24 +66,57 @@ func (p PortPreset) Ports() []int {
 	}
 }
 
+// maxUndo is how many selection snapshots Ctrl+Z can step back through.
+const maxUndo = 10
+
 // deviceEntry tracks selection and port override state per device.
 type deviceEntry struct {
-	Device   discovery.DiscoveredDevice
-	Selected bool
-	Preset   PortPreset
+	Device      discovery.DiscoveredDevice
+	Selected    bool
+	Preset      PortPreset
+	CustomPorts []int // set in batch edit; overrides Preset when non-nil
+	ProxyMode   bool  // also pivot through the device with a SOCKS5 proxy
+	Favorite    bool  // starred; independent of selection, so a/n leave it alone
+
+	// LocalPorts pins a remote port to a typed local port, from a manual
+	// local:host:remote entry. Other ports use the port strategy.
+	LocalPorts map[int]int
 }
 
-// effectivePorts returns the active port list for this entry.
+// pinLocalPort records a typed local port for a remote port.
+func (e *deviceEntry) pinLocalPort(remote, local int) {
+	if e.LocalPorts == nil {
+		e.LocalPorts = make(map[int]int)
+	}
+	e.LocalPorts[remote] = local
+}
+
+// effectivePorts returns the active port list for this entry. Proxy mode
+// needs the device's SSH port, so 22 is added if the list lacks it.
 func (e deviceEntry) effectivePorts() []int {
-	if ports := e.Preset.Ports(); ports != nil {
-		return ports
+	ports := e.Device.DefaultPorts
+	if e.CustomPorts != nil {
+		ports = e.CustomPorts
+	} else if preset := e.Preset.Ports(); preset != nil {
+		ports = preset
+	}
+	if e.ProxyMode && !hasDupePort(ports, 22) {
+		ports = append([]int{22}, ports...)
 	}
-	return e.Device.DefaultPorts
+	return ports
+}
+
+// proxyLogin is the device login for a nested proxy. It is kept outside
+// deviceEntry so session resume snapshots never carry the password.
+type proxyLogin struct {
+	user     string
+	password string
 }
 
 // DeviceSelectMsg is sent when the user confirms their device selection.
 type DeviceSelectMsg struct {
-	Devices []SelectedDevice
+	Devices      []SelectedDevice
+	PortStrategy portmap.Strategy
 }
 
 // SubnetScanRequestMsg is emitted when the user submits a subnet for scanning.
@@ -86,11 +124,27 @@ type SubnetScanRequestMsg struct {
 	Subnet string
 }
 
+// NmapScanRequestMsg asks for a gateway-side nmap scan of the current
+// subnet, merged into the list.
+type NmapScanRequestMsg struct{}
+
 // SelectedDevice is a device chosen for tunneling with its port list.
 type SelectedDevice struct {
-	IP    string
-	MAC   string
-	Ports []int
+	IP     string
+	MAC    string
+	Vendor string
+	Class  discovery.DeviceClass
+	Ports  []int
+
+	// LocalPorts maps remote ports to the local port the user typed for
+	// them; see deviceEntry.LocalPorts.
+	LocalPorts map[int]int
+
+	// DeviceProxyMode asks for a SOCKS5 proxy exiting from the device,
+	// logged into through the tunnel to its port 22.
+	DeviceProxyMode bool
+	ProxyUser       string
+	ProxyPassword   string
 }
 
 // DevicesModel handles the device selection list.
@@ -100,6 +154,7 @@ type DevicesModel struct {
 	viewStart  int
 	viewHeight int
 	selKeys    SelectionKeys
+	devKeys    DeviceKeys
 	navKeys    NavigationKeys
 	globals    GlobalKeys
 
@@ -108,8 +163,43 @@ type DevicesModel struct {
 	subnetInput textinput.Model
 	ipInput     textinput.Model
 	portInput   textinput.Model
-	manualFocus int    // 0=IP, 1=Port
+	manualFocus int // 0=IP, 1=Port
 	inputErr    string
+
+	// Batch edit state: one port input per selected device.
+	batchRows   []int // entry indices being edited
+	batchInputs []textinput.Model
+	batchFocus  int // index into batchRows
+
+	// Proxy login state, keyed by device IP.
+	proxyUserInput textinput.Model
+	proxyPassInput textinput.Model
+	proxyFocus     int // 0=user, 1=password
+	proxyLogins    map[string]proxyLogin
+
+	// Scanner guidance shown above the list, e.g. ARP-only mode.
+	notice string
+
+	// How local ports are numbered for the build; toggled with 'o'.
+	portStrategy portmap.Strategy
+
+	// Favorites starred at this gateway that the scan didn't find.
+	gatewayAddr string
+	missingFavs []discovery.Favorite
+
+	// Saved device notes and the editor open on one, in modeNote.
+	notes *deviceNotes
+	note  noteEditor
+
+	// Devices with randomized MACs moved out of the list by 'h'.
+	hidden     []deviceEntry
+	hideRandom bool
+
+	showKeys bool // status bar lists every key, toggled with '?'
+
+	// Selection snapshots keyed by IP, newest last. Keyed by IP rather than
+	// index so manual adds that re-sort the list don't scramble an undo.
+	undoStack []map[string]bool
 }
 
 // NewDevicesModel creates the device selection screen from scan results.
@@ -122,11 +212,15 @@ func NewDevicesModel(devices []discovery.DiscoveredDevice) DevicesModel {
 		entries:     entries,
 		viewHeight:  20,
 		selKeys:     DefaultSelectionKeys,
+		devKeys:     DefaultDeviceKeys,
 		navKeys:     DefaultNavigationKeys,
 		globals:     DefaultGlobalKeys,
 		subnetInput: newSubnetInput(),
 		ipInput:     newIPInput(),
 		portInput:   newPortInput(),
+
+		proxyUserInput: newProxyUserInput(),
+		proxyPassInput: newProxyPassInput(),
 	}
 }
 
@@ -137,18 +231,25 @@ func NewDevicesModelFromEntries(entries []deviceEntry) DevicesModel {
 		entries:     entries,
 		viewHeight:  20,
 		selKeys:     DefaultSelectionKeys,
+		devKeys:     DefaultDeviceKeys,
 		navKeys:     DefaultNavigationKeys,
 		globals:     DefaultGlobalKeys,
 		subnetInput: newSubnetInput(),
 		ipInput:     newIPInput(),
 		portInput:   newPortInput(),
+
+		proxyUserInput: newProxyUserInput(),
+		proxyPassInput: newProxyPassInput(),
 	}
 }
 
-// Entries returns a copy of the current device entries.
+// Entries returns a copy of the current device entries, including any
+// hidden by the randomized MAC filter.
 func (m DevicesModel) Entries() []deviceEntry {
-	result := make([]deviceEntry, len(m.entries))
-	copy(result, m.entries)
+	result := make([]deviceEntry, 0, len(m.entries)+len(m.hidden))
+	result = append(result, m.entries...)
+	result = append(result, m.hidden...)
+	sortEntriesByIP(result)
 	return result
 }
 
@@ -157,11 +258,21 @@ func (m DevicesModel) SelectedDevices() []SelectedDevice {
 	var result []SelectedDevice
 	for _, e := range m.entries {
 		if e.Selected {
-			result = append(result, SelectedDevice{
-				IP:    e.Device.IP,
-				MAC:   e.Device.MAC,
-				Ports: e.effectivePorts(),
-			})
+			d := SelectedDevice{
+				IP:     e.Device.IP,
+				MAC:    e.Device.MAC,
+				Vendor: e.Device.Vendor,
+				Class:  e.Device.DeviceType,
+				Ports:  e.effectivePorts(),
+
+				LocalPorts: e.LocalPorts,
+			}
+			if login, ok := m.proxyLogins[e.Device.IP]; ok && e.ProxyMode {
+				d.DeviceProxyMode = true
+				d.ProxyUser = login.user
+				d.ProxyPassword = login.password
+			}
+			result = append(result, d)
 		}
 	}
 	return result
@@ -181,6 +292,14 @@ func (m DevicesModel) Update(msg tea.Msg) (DevicesModel, tea.Cmd) {
 			return m.updateSubnetMode(msg)
 		case modeManual:
 			return m.updateManualMode(msg)
+		case modeBatch:
+			return m.updateBatchMode(msg)
+		case modeProxy:
+			return m.updateProxyMode(msg)
+		case modeNote:
+			return m.updateNoteMode(msg)
+		case modeByPort:
+			return m.updateByPortMode(msg)
 		default:
 			return m.updateListMode(msg)
 		}
@@ -209,38 +328,122 @@ func (m DevicesModel) updateListMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
 
 	case key.Matches(msg, m.selKeys.Toggle):
 		if len(m.entries) > 0 {
+			m.pushUndo()
 			m.entries[m.cursor].Selected = !m.entries[m.cursor].Selected
 		}
 
 	case key.Matches(msg, m.selKeys.All):
+		m.pushUndo()
 		for i := range m.entries {
 			m.entries[i].Selected = true
 		}
 
 	case key.Matches(msg, m.selKeys.None):
+		m.pushUndo()
 		for i := range m.entries {
 			m.entries[i].Selected = false
 		}
 
+	case key.Matches(msg, m.selKeys.Invert):
+		m.pushUndo()
+		for i := range m.entries {
+			m.entries[i].Selected = !m.entries[i].Selected
+		}
+
 	case key.Matches(msg, m.selKeys.FirstN):
+		m.pushUndo()
 		for i := range m.entries {
 			m.entries[i].Selected = i < 10
 		}
 
-	case key.Matches(msg, key.NewBinding(key.WithKeys("p"))):
+	case key.Matches(msg, m.selKeys.Starred):
+		// Exactly the known devices this scan found; the missing ones
+		// are already listed under the table.
+		m.pushUndo()
+		for i := range m.entries {
+			m.entries[i].Selected = m.entries[i].Favorite
+		}
+
+	case key.Matches(msg, m.selKeys.ByPort):
+		m.mode = modeByPort
+		m.inputErr = ""
+		m.portInput.SetValue("")
+		return m, m.portInput.Focus()
+
+	case key.Matches(msg, m.devKeys.Undo):
+		m.popUndo()
+
+	case key.Matches(msg, m.devKeys.Preset):
 		// Cycle port preset on current device.
 		if len(m.entries) > 0 {
 			e := &m.entries[m.cursor]
 			e.Preset = (e.Preset + 1) % 4
+			e.CustomPorts = nil
 		}
 
-	case key.Matches(msg, key.NewBinding(key.WithKeys("s"))):
+	case key.Matches(msg, m.devKeys.EditPorts):
+		return m.startBatchEdit()
+
+	case key.Matches(msg, m.devKeys.Help):
+		m.showKeys = !m.showKeys
+
+	case key.Matches(msg, m.devKeys.RandomMAC):
+		m.toggleRandomFilter()
+
+	case key.Matches(msg, m.devKeys.Favorite):
+		return m.toggleFavorite()
+
+	case key.Matches(msg, m.devKeys.Exclude):
+		return m.excludeDevice()
+
+	case key.Matches(msg, m.devKeys.Note):
+		// 'N' is the nmap scan here, so notes are on 'm'.
+		if len(m.entries) == 0 || m.notes == nil {
+			return m, nil
+		}
+		d := m.entries[m.cursor].Device
+		note, _ := m.notes.get(d.IP, d.MAC)
+		m.note = newNoteEditor("Note -- "+d.IP, note.Text, discovery.MaxNoteLen)
+		m.note.ip, m.note.mac = d.IP, d.MAC
+		m.mode = modeNote
+
+	case key.Matches(msg, m.devKeys.Proxy):
+		// Shift+Enter would be the natural binding, but most terminals
+		// send it as a plain Enter.
+		if len(m.entries) == 0 {
+			return m, nil
+		}
+		e := &m.entries[m.cursor]
+		if e.ProxyMode {
+			e.ProxyMode = false
+			delete(m.proxyLogins, e.Device.IP)
+			return m, nil
+		}
+		m.mode = modeProxy
+		m.proxyFocus = 0
+		m.inputErr = ""
+		m.proxyUserInput.SetValue("")
+		m.proxyPassInput.SetValue("")
+		m.proxyPassInput.Blur()
+		return m, m.proxyUserInput.Focus()
+
+	case key.Matches(msg, m.devKeys.Strategy):
+		if m.portStrategy == portmap.StrategyOctet {
+			m.portStrategy = portmap.StrategySequential
+		} else {
+			m.portStrategy = portmap.StrategyOctet
+		}
+
+	case key.Matches(msg, m.devKeys.Nmap):
+		return m, func() tea.Msg { return NmapScanRequestMsg{} }
+
+	case key.Matches(msg, m.devKeys.Subnet):
 		m.mode = modeSubnet
 		m.inputErr = ""
 		m.subnetInput.SetValue("")
 		return m, m.subnetInput.Focus()
 
-	case key.Matches(msg, key.NewBinding(key.WithKeys("+"))):
+	case key.Matches(msg, m.devKeys.AddDevice):
 		m.mode = modeManual
 		m.manualFocus = 0
 		m.inputErr = ""
@@ -251,8 +454,9 @@ func (m DevicesModel) updateListMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
 	case key.Matches(msg, m.navKeys.Enter):
 		selected := m.SelectedDevices()
 		if len(selected) > 0 {
+			strategy := m.portStrategy
 			return m, func() tea.Msg {
-				return DeviceSelectMsg{Devices: selected}
+				return DeviceSelectMsg{Devices: selected, PortStrategy: strategy}
 			}
 		}
 	}
@@ -260,6 +464,257 @@ func (m DevicesModel) updateListMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
 	return m, nil
 }
 
+// ApplyFavorites marks starred devices, pre-selecting any not already
+// marked (so a device the user deselected stays deselected across a
+// rescan), moves them to the top, and records favorites last seen at this
+// gateway that are missing from the list.
+func (m *DevicesModel) ApplyFavorites(gatewayAddr string) {
+	m.gatewayAddr = gatewayAddr
+	favs := discovery.LoadFavorites()
+
+	var devices []discovery.DiscoveredDevice
+	for _, list := range [][]deviceEntry{m.entries, m.hidden} {
+		for i := range list {
+			e := &list[i]
+			devices = append(devices, e.Device)
+			fav, ok := favs[strings.ToUpper(e.Device.MAC)]
+			if !ok || e.Device.MAC == "" {
+				continue
+			}
+			if !e.Favorite {
+				e.Favorite = true
+				e.Selected = true
+			}
+			if fav.IP != e.Device.IP || fav.Gateway != gatewayAddr {
+				// Keep last-seen current so the missing check follows the device.
+				_ = discovery.SetFavorite(e.Device.MAC, &discovery.Favorite{
+					IP: e.Device.IP, Vendor: e.Device.Vendor, Gateway: gatewayAddr,
+				})
+			}
+		}
+	}
+
+	m.missingFavs = discovery.MissingFavorites(favs, gatewayAddr, devices)
+	sort.Slice(m.missingFavs, func(i, j int) bool {
+		return lastOctet(m.missingFavs[i].IP) < lastOctet(m.missingFavs[j].IP)
+	})
+	sortEntriesByIP(m.entries)
+}
+
+// toggleFavorite stars or unstars the device under the cursor and saves
+// it. Selection is left as is either way.
+func (m DevicesModel) toggleFavorite() (DevicesModel, tea.Cmd) {
+	if len(m.entries) == 0 {
+		return m, nil
+	}
+	e := m.entries[m.cursor]
+	if e.Device.MAC == "" {
+		m.notice = "Favorites are remembered by MAC -- " + e.Device.IP + " has none."
+		return m, nil
+	}
+
+	e.Favorite = !e.Favorite
+	var fav *discovery.Favorite
+	if e.Favorite {
+		fav = &discovery.Favorite{IP: e.Device.IP, Vendor: e.Device.Vendor, Gateway: m.gatewayAddr}
+	}
+	m.entries[m.cursor] = e
+
+	// Re-sort and keep the cursor on the same device.
+	sortEntriesByIP(m.entries)
+	for i := range m.entries {
+		if m.entries[i].Device.IP == e.Device.IP {
+			m.cursor = i
+			break
+		}
+	}
+	if m.cursor < m.viewStart {
+		m.viewStart = m.cursor
+	} else if m.cursor >= m.viewStart+m.viewHeight {
+		m.viewStart = m.cursor - m.viewHeight + 1
+	}
+
+	mac := e.Device.MAC
+	return m, func() tea.Msg {
+		_ = discovery.SetFavorite(mac, fav)
+		return nil
+	}
+}
+
+// updateNoteMode handles keys while the device note editor is open.
+func (m DevicesModel) updateNoteMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
+	var result noteResult
+	m.note, result = m.note.Update(msg)
+	switch result {
+	case noteSaved:
+		m.mode = modeList
+		return m, m.notes.set(m.note.ip, m.note.mac, m.note.Value())
+	case noteCancelled:
+		m.mode = modeList
+	}
+	return m, nil
+}
+
+// excludeDevice drops the device under the cursor from the list and from
+// every later scan.
+func (m DevicesModel) excludeDevice() (DevicesModel, tea.Cmd) {
+	if len(m.entries) == 0 {
+		return m, nil
+	}
+	e := m.entries[m.cursor]
+	m.entries = append(m.entries[:m.cursor], m.entries[m.cursor+1:]...)
+	if m.cursor >= len(m.entries) && m.cursor > 0 {
+		m.cursor--
+	}
+	if m.cursor < m.viewStart {
+		m.viewStart = m.cursor
+	}
+	m.notice = fmt.Sprintf("Excluded %s from scans -- edit %s to undo.",
+		e.Device.IP, discovery.ExclusionsPath())
+
+	ip, mac := e.Device.IP, e.Device.MAC
+	return m, func() tea.Msg {
+		_ = discovery.AddExclusion(ip, mac)
+		return nil
+	}
+}
+
+// favoriteIPs returns the IPs of starred devices.
+func (m DevicesModel) favoriteIPs() map[string]bool {
+	ips := make(map[string]bool)
+	for _, e := range m.entries {
+		if e.Favorite {
+			ips[e.Device.IP] = true
+		}
+	}
+	return ips
+}
+
+// toggleRandomFilter hides or restores devices with randomized MACs.
+// Hidden devices are deselected so they can't be built unseen.
+func (m *DevicesModel) toggleRandomFilter() {
+	m.hideRandom = !m.hideRandom
+	if !m.hideRandom {
+		m.entries = append(m.entries, m.hidden...)
+		m.hidden = nil
+		sortEntriesByIP(m.entries)
+		return
+	}
+
+	kept := m.entries[:0:0]
+	for _, e := range m.entries {
+		if e.Device.RandomMAC && !e.Favorite {
+			e.Selected = false
+			m.hidden = append(m.hidden, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	m.entries = kept
+	m.cursor = min(m.cursor, max(len(m.entries)-1, 0))
+	m.viewStart = min(m.viewStart, m.cursor)
+}
+
+// randomCount returns how many listed devices have randomized MACs.
+func (m DevicesModel) randomCount() int {
+	n := 0
+	for _, e := range m.entries {
+		if e.Device.RandomMAC {
+			n++
+		}
+	}
+	return n
+}
+
+// updateProxyMode handles keys while entering the device login for a
+// nested proxy on the device under the cursor.
+func (m DevicesModel) updateProxyMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
+	switch {
+	case key.Matches(msg, key.NewBinding(key.WithKeys("tab", "shift+tab"))):
+		m.proxyFocus = (m.proxyFocus + 1) % 2
+		if m.proxyFocus == 0 {
+			m.proxyPassInput.Blur()
+			return m, m.proxyUserInput.Focus()
+		}
+		m.proxyUserInput.Blur()
+		return m, m.proxyPassInput.Focus()
+
+	case key.Matches(msg, m.navKeys.Enter):
+		user := strings.TrimSpace(m.proxyUserInput.Value())
+		if user == "" {
+			m.inputErr = "username required"
+			return m, nil
+		}
+		if m.proxyLogins == nil {
+			m.proxyLogins = make(map[string]proxyLogin)
+		}
+		e := &m.entries[m.cursor]
+		m.pushUndo()
+		e.ProxyMode = true
+		e.Selected = true
+		m.proxyLogins[e.Device.IP] = proxyLogin{user: user, password: m.proxyPassInput.Value()}
+		m.cancelProxy()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	if m.proxyFocus == 0 {
+		m.proxyUserInput, cmd = m.proxyUserInput.Update(msg)
+	} else {
+		m.proxyPassInput, cmd = m.proxyPassInput.Update(msg)
+	}
+	return m, cmd
+}
+
+// cancelProxy leaves proxy login mode and clears the inputs.
+func (m *DevicesModel) cancelProxy() {
+	m.mode = modeList
+	m.inputErr = ""
+	m.proxyUserInput.Blur()
+	m.proxyPassInput.Blur()
+	m.proxyUserInput.SetValue("")
+	m.proxyPassInput.SetValue("")
+}
+
+// updateByPortMode handles keys while entering a port to select by.
+func (m DevicesModel) updateByPortMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
+	if key.Matches(msg, m.navKeys.Enter) {
+		port, err := strconv.Atoi(strings.TrimSpace(m.portInput.Value()))
+		if err != nil || port < 1 || port > 65535 {
+			m.inputErr = "port must be 1-65535"
+			return m, nil
+		}
+		m.mode = modeList
+		m.inputErr = ""
+		m.portInput.Blur()
+		m.portInput.SetValue("")
+		m.selectByPort(port)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.portInput, cmd = m.portInput.Update(msg)
+	return m, cmd
+}
+
+// selectByPort adds every device that would get a tunnel to port to the
+// selection, e.g. 554 for all the cameras in a mixed fleet. Devices
+// already selected stay selected.
+func (m *DevicesModel) selectByPort(port int) {
+	m.pushUndo()
+	n := 0
+	for i := range m.entries {
+		if hasDupePort(m.entries[i].effectivePorts(), port) {
+			m.entries[i].Selected = true
+			n++
+		}
+	}
+	m.notice = fmt.Sprintf("Selected %d devices with port %d.", n, port)
+	if n == 0 {
+		m.notice = fmt.Sprintf("No devices have port %d -- selection unchanged.", port)
+	}
+}
+
 // updateSubnetMode handles keys in subnet input mode.
 func (m DevicesModel) updateSubnetMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
 	switch {
@@ -299,6 +754,18 @@ func (m DevicesModel) updateManualMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
 		ip := strings.TrimSpace(m.ipInput.Value())
 		portStr := strings.TrimSpace(m.portInput.Value())
 
+		// local:host:remote pins the local port and ignores the Port field.
+		var local int
+		if strings.Count(ip, ":") == 2 {
+			pm, err := portmap.ParseMapping(ip)
+			if err != nil {
+				m.inputErr = err.Error()
+				return m, nil
+			}
+			ip, local = pm.RemoteHost, pm.LocalPort
+			portStr = strconv.Itoa(pm.RemotePort)
+		}
+
 		// Validate IP.
 		if net.ParseIP(ip) == nil {
 			m.inputErr = "invalid IP address"
@@ -320,6 +787,9 @@ func (m DevicesModel) updateManualMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
 				if !hasDupePort(m.entries[i].Device.DefaultPorts, port) {
 					m.entries[i].Device.DefaultPorts = append(m.entries[i].Device.DefaultPorts, port)
 				}
+				if local != 0 {
+					m.entries[i].pinLocalPort(port, local)
+				}
 				break
 			}
 		}
@@ -335,6 +805,9 @@ func (m DevicesModel) updateManualMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
 				},
 				Selected: true,
 			})
+			if local != 0 {
+				m.entries[len(m.entries)-1].pinLocalPort(port, local)
+			}
 			sortEntriesByIP(m.entries)
 			// Reset cursor to the newly added device.
 			for i, e := range m.entries {
@@ -367,15 +840,143 @@ func (m DevicesModel) updateManualMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
 	return m, cmd
 }
 
+// pushUndo snapshots the current selection before a change, dropping the
+// oldest snapshot once maxUndo is reached.
+func (m *DevicesModel) pushUndo() {
+	snap := make(map[string]bool, len(m.entries))
+	for _, e := range m.entries {
+		snap[e.Device.IP] = e.Selected
+	}
+	if len(m.undoStack) == maxUndo {
+		m.undoStack = m.undoStack[1:]
+	}
+	m.undoStack = append(m.undoStack, snap)
+}
+
+// popUndo restores the most recent selection snapshot. Devices added
+// since the snapshot keep their current selection.
+func (m *DevicesModel) popUndo() {
+	if len(m.undoStack) == 0 {
+		return
+	}
+	snap := m.undoStack[len(m.undoStack)-1]
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+	for i, e := range m.entries {
+		if sel, ok := snap[e.Device.IP]; ok {
+			m.entries[i].Selected = sel
+		}
+	}
+}
+
+// startBatchEdit opens a port input for every selected device, prefilled
+// with its current ports. Does nothing if no devices are selected.
+func (m DevicesModel) startBatchEdit() (DevicesModel, tea.Cmd) {
+	m.batchRows = nil
+	m.batchInputs = nil
+	for i, e := range m.entries {
+		if !e.Selected {
+			continue
+		}
+		ti := newBatchPortInput()
+		ti.SetValue(formatPorts(e.effectivePorts()))
+		m.batchRows = append(m.batchRows, i)
+		m.batchInputs = append(m.batchInputs, ti)
+	}
+	if len(m.batchRows) == 0 {
+		return m, nil
+	}
+	m.mode = modeBatch
+	m.inputErr = ""
+	return m.focusBatch(0)
+}
+
+// focusBatch moves batch focus to row i and scrolls the cursor to it.
+func (m DevicesModel) focusBatch(i int) (DevicesModel, tea.Cmd) {
+	m.batchInputs[m.batchFocus].Blur()
+	m.batchFocus = i
+	m.cursor = m.batchRows[i]
+	if m.cursor < m.viewStart {
+		m.viewStart = m.cursor
+	} else if m.cursor >= m.viewStart+m.viewHeight {
+		m.viewStart = m.cursor - m.viewHeight + 1
+	}
+	return m, m.batchInputs[i].Focus()
+}
+
+// updateBatchMode handles keys in batch port edit mode.
+func (m DevicesModel) updateBatchMode(msg tea.KeyMsg) (DevicesModel, tea.Cmd) {
+	n := len(m.batchRows)
+	switch {
+	case key.Matches(msg, key.NewBinding(key.WithKeys("tab"))):
+		return m.focusBatch((m.batchFocus + 1) % n)
+
+	case key.Matches(msg, key.NewBinding(key.WithKeys("shift+tab"))):
+		return m.focusBatch((m.batchFocus + n - 1) % n)
+
+	case key.Matches(msg, m.navKeys.Enter):
+		// Validate every row before committing any of them.
+		parsed := make([][]int, n)
+		for i, ti := range m.batchInputs {
+			ports, err := parsePortList(ti.Value())
+			if err != nil {
+				m.inputErr = fmt.Sprintf("%s: %v", m.entries[m.batchRows[i]].Device.IP, err)
+				return m.focusBatch(i)
+			}
+			parsed[i] = ports
+		}
+		for i, idx := range m.batchRows {
+			m.entries[idx].CustomPorts = parsed[i]
+		}
+		m.cancelBatch()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.batchInputs[m.batchFocus], cmd = m.batchInputs[m.batchFocus].Update(msg)
+	return m, cmd
+}
+
+// cancelBatch leaves batch edit mode without touching the entries.
+func (m *DevicesModel) cancelBatch() {
+	m.mode = modeList
+	m.inputErr = ""
+	m.batchRows = nil
+	m.batchInputs = nil
+	m.batchFocus = 0
+}
+
 // View renders the device selection list.
 func (m DevicesModel) View() string {
+	if m.mode == modeNote {
+		return m.note.View()
+	}
+
 	var b strings.Builder
 
+	if m.notice != "" {
+		b.WriteString(WarningStyle.Render(m.notice))
+		b.WriteString("\n\n")
+	}
+	if len(m.missingFavs) > 0 {
+		missing := make([]string, len(m.missingFavs))
+		for i, f := range m.missingFavs {
+			missing[i] = f.IP
+			if f.Vendor != "" {
+				missing[i] += " (" + f.Vendor + ")"
+			}
+		}
+		b.WriteString(WarningStyle.Render("Favorites missing from this scan: " + strings.Join(missing, ", ")))
+		b.WriteString("\n\n")
+	}
+
 	if len(m.entries) == 0 {
 		b.WriteString(DimStyle.Render("No devices found."))
 	} else {
+		b.WriteString(m.densityGrid())
+		b.WriteByte('\n')
+
 		// Column header.
-		header := fmt.Sprintf("  %-3s %-16s %-14s %-18s %-10s %s",
+		header := fmt.Sprintf("  %-4s %-16s %-14s %-18s %-10s %s",
 			" ", "IP", "MAC", "Vendor", "Type", "Ports")
 		b.WriteString(TableHeaderStyle.Render(header))
 		b.WriteByte('\n')
@@ -388,6 +989,10 @@ func (m DevicesModel) View() string {
 
 		for i := m.viewStart; i < end; i++ {
 			e := m.entries[i]
+			if e.Device.Collapsed && (i == m.viewStart || !sameBridge(m.entries[i-1], e)) {
+				b.WriteString(bridgeHeading(e.Device))
+				b.WriteByte('\n')
+			}
 			b.WriteString(m.renderRow(i, e))
 			b.WriteByte('\n')
 		}
@@ -398,6 +1003,18 @@ func (m DevicesModel) View() string {
 				"  [%d-%d of %d]", m.viewStart+1, end, len(m.entries))))
 			b.WriteByte('\n')
 		}
+
+		d := m.entries[m.cursor].Device
+		if note, ok := m.notes.get(d.IP, d.MAC); ok {
+			b.WriteString(AccentStyle.Render("  " + noteLine(note)))
+			b.WriteByte('\n')
+		}
+		if d.SharedMAC > 0 {
+			b.WriteString(DimStyle.Render(fmt.Sprintf(
+				"  %s answers with the same MAC as %d other addresses: it sits behind a NAT router or wireless bridge.\n"+
+					"  The tunnel to it works; the vendor and type shown are the bridge's.", d.IP, d.SharedMAC-1)))
+			b.WriteByte('\n')
+		}
 	}
 
 	panel := renderPanel("Select Devices", b.String())
@@ -409,17 +1026,43 @@ func (m DevicesModel) View() string {
 		bar = m.subnetBar()
 	case modeManual:
 		bar = m.manualBar()
+	case modeBatch:
+		bar = m.batchBar()
+	case modeProxy:
+		bar = m.proxyBar()
+	case modeByPort:
+		bar = m.byPortBar()
 	default:
 		selCount, portCount := m.selectionCounts()
 		summary := fmt.Sprintf("%d/%d devices, %d ports",
 			selCount, len(m.entries), portCount)
-		bar = renderStatusBar(summary, "Space: toggle", "a/n: all/none",
-			"p: preset", "s: scan subnet", "+: add device", "Enter: build")
+		hints := []string{summary, "Space: toggle", m.portStrategyHint(), "Enter: build", "?: all keys"}
+		if m.showKeys {
+			hints = append([]string{summary}, keyHints(m.selKeys.FullHelp(), m.devKeys.FullHelp())...)
+		}
+		if m.hideRandom {
+			hints = append(hints, fmt.Sprintf("h: show %d randomized MAC", len(m.hidden)))
+		} else if n := m.randomCount(); n > 0 {
+			hints = append(hints, fmt.Sprintf("h: hide %d randomized MAC", n))
+		}
+		if n := len(m.undoStack); n > 0 {
+			hints = append(hints, fmt.Sprintf("Ctrl+Z: undo (%d available)", n))
+		}
+		bar = renderWrappedStatusBar(hints...)
 	}
 
 	return ContentStyle.Render(panel + "\n" + bar)
 }
 
+// portStrategyHint names the port numbering the build will use and what
+// 'o' switches it to.
+func (m DevicesModel) portStrategyHint() string {
+	if m.portStrategy == portmap.StrategySequential {
+		return fmt.Sprintf("o: ports %d+ (sequential)", portmap.SequentialBase)
+	}
+	return "o: ports by IP octet"
+}
+
 // subnetBar renders the subnet input bar and status hints.
 func (m DevicesModel) subnetBar() string {
 	var b strings.Builder
@@ -433,10 +1076,23 @@ func (m DevicesModel) subnetBar() string {
 	return b.String()
 }
 
+// byPortBar renders the select-by-port input bar and status hints.
+func (m DevicesModel) byPortBar() string {
+	var b strings.Builder
+	label := AccentStyle.Render("Select devices with port")
+	b.WriteString("  " + label + " " + m.portInput.View())
+	if m.inputErr != "" {
+		b.WriteString("  " + ErrorStyle.Render(m.inputErr))
+	}
+	b.WriteByte('\n')
+	b.WriteString(renderStatusBar("Enter: select", "Esc: cancel"))
+	return b.String()
+}
+
 // manualBar renders the manual IP:Port input bar and status hints.
 func (m DevicesModel) manualBar() string {
 	var b strings.Builder
-	ipLabel := AccentStyle.Render("IP")
+	ipLabel := AccentStyle.Render("IP or local:IP:port")
 	portLabel := AccentStyle.Render("Port")
 	b.WriteString("  " + ipLabel + " " + m.ipInput.View())
 	b.WriteString("   " + portLabel + " " + m.portInput.View())
@@ -448,6 +1104,36 @@ func (m DevicesModel) manualBar() string {
 	return b.String()
 }
 
+// proxyBar renders the device login inputs for a nested proxy.
+func (m DevicesModel) proxyBar() string {
+	var b strings.Builder
+	ip := ""
+	if m.cursor < len(m.entries) {
+		ip = m.entries[m.cursor].Device.IP
+	}
+	b.WriteString("  " + DimStyle.Render("SOCKS5 via "+ip+" --"))
+	b.WriteString(" " + AccentStyle.Render("User") + " " + m.proxyUserInput.View())
+	b.WriteString("   " + AccentStyle.Render("Password") + " " + m.proxyPassInput.View())
+	if m.inputErr != "" {
+		b.WriteString("  " + ErrorStyle.Render(m.inputErr))
+	}
+	b.WriteByte('\n')
+	b.WriteString(renderStatusBar("Tab: next field", "Enter: enable proxy", "Esc: cancel"))
+	return b.String()
+}
+
+// batchBar renders the batch edit error line and status hints.
+func (m DevicesModel) batchBar() string {
+	var b strings.Builder
+	if m.inputErr != "" {
+		b.WriteString("  " + ErrorStyle.Render(m.inputErr) + "\n")
+	}
+	b.WriteString(renderStatusBar(
+		fmt.Sprintf("Editing %d/%d", m.batchFocus+1, len(m.batchRows)),
+		"Tab/Shift+Tab: next/prev device", "Enter: apply all", "Esc: cancel"))
+	return b.String()
+}
+
 // renderRow renders a single device row.
 func (m DevicesModel) renderRow(idx int, e deviceEntry) string {
 	check := "[ ]"
@@ -468,9 +1154,27 @@ func (m DevicesModel) renderRow(idx int, e deviceEntry) string {
 	}
 
 	ports := formatPorts(e.effectivePorts())
+	if e.ProxyMode {
+		ports += " +socks"
+	}
+	if _, ok := m.notes.get(e.Device.IP, e.Device.MAC); ok {
+		ports += " +note"
+	}
+	if m.mode == modeBatch {
+		for i, row := range m.batchRows {
+			if row == idx {
+				ports = m.batchInputs[i].View()
+				break
+			}
+		}
+	}
 
-	line := fmt.Sprintf("%s %-16s %-14s %-18s %-10s %s",
-		check, e.Device.IP, mac, vendor, e.Device.DeviceType, ports)
+	star := " "
+	if e.Favorite {
+		star = "*"
+	}
+	line := fmt.Sprintf("%s%s %-16s %-14s %-18s %-10s %s",
+		check, star, e.Device.IP, mac, vendor, e.Device.DeviceType, ports)
 
 	switch {
 	case idx == m.cursor && e.Selected:
@@ -484,6 +1188,47 @@ func (m DevicesModel) renderRow(idx int, e deviceEntry) string {
 	}
 }
 
+// sameBridge reports whether two entries are collapsed under the same
+// bridge heading.
+func sameBridge(a, b deviceEntry) bool {
+	return a.Device.Collapsed && b.Device.Collapsed && strings.EqualFold(a.Device.MAC, b.Device.MAC)
+}
+
+// bridgeHeading introduces the addresses collapsed behind one MAC.
+func bridgeHeading(d discovery.DiscoveredDevice) string {
+	vendor := ""
+	if d.Vendor != "" && d.Vendor != "Unknown" {
+		vendor = " (" + d.Vendor + ")"
+	}
+	return WarningStyle.Render(fmt.Sprintf("  -- %d addresses behind %s%s, likely a NAT router or wireless bridge --",
+		d.SharedMAC, d.MAC, vendor))
+}
+
+// densityGrid renders which host octets (1-254) have a device, 64 per row,
+// so the layout of an unfamiliar site is visible at a glance.
+func (m DevicesModel) densityGrid() string {
+	devices := make([]discovery.DiscoveredDevice, len(m.entries))
+	for i, e := range m.entries {
+		devices[i] = e.Device
+	}
+	occupied := discovery.OctetMap(devices)
+
+	const perRow = 64
+	var b strings.Builder
+	for start := 1; start <= 254; start += perRow {
+		b.WriteString(DimStyle.Render(fmt.Sprintf("  %3d ", start)))
+		for o := start; o < start+perRow && o <= 254; o++ {
+			if occupied[o] {
+				b.WriteString(AccentStyle.Render("█"))
+			} else {
+				b.WriteString(DimStyle.Render("·"))
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
 // selectionCounts returns the number of selected devices and total ports.
 func (m DevicesModel) selectionCounts() (int, int) {
 	var devices, ports int
@@ -537,10 +1282,28 @@ func newSubnetInput() textinput.Model {
 	return ti
 }
 
+func newProxyUserInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "admin"
+	ti.CharLimit = 64
+	ti.Width = 12
+	return ti
+}
+
+func newProxyPassInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "password"
+	ti.EchoMode = textinput.EchoPassword
+	ti.EchoCharacter = '*'
+	ti.CharLimit = 128
+	ti.Width = 16
+	return ti
+}
+
 func newIPInput() textinput.Model {
 	ti := textinput.New()
 	ti.Placeholder = "192.168.1.100"
-	ti.CharLimit = 15
+	ti.CharLimit = 27 // "65535:255.255.255.255:65535"
 	ti.Width = 18
 	return ti
 }
@@ -553,12 +1316,81 @@ func newPortInput() textinput.Model {
 	return ti
 }
 
+func newBatchPortInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "80,443"
+	ti.CharLimit = 64
+	ti.Width = 24
+	ti.Prompt = ""
+	return ti
+}
+
+// parsePortList parses a comma or space separated port list, dropping
+// duplicates. An empty list is an error; deselect the device instead.
+func parsePortList(s string) ([]int, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no ports given")
+	}
+	var ports []int
+	for _, f := range fields {
+		port, err := strconv.Atoi(f)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("port %q must be 1-65535", f)
+		}
+		if !hasDupePort(ports, port) {
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// sortEntriesByIP orders entries by last IP octet, favorites first.
+// Collapsed addresses behind one bridge stay together, placed where the
+// lowest of them would be.
 func sortEntriesByIP(entries []deviceEntry) {
+	bridgeAt := make(map[string]int)
+	for _, e := range entries {
+		if !e.Device.Collapsed {
+			continue
+		}
+		mac := strings.ToUpper(e.Device.MAC)
+		if at, ok := bridgeAt[mac]; !ok || lastOctet(e.Device.IP) < at {
+			bridgeAt[mac] = lastOctet(e.Device.IP)
+		}
+	}
+	position := func(e deviceEntry) int {
+		if e.Device.Collapsed {
+			return bridgeAt[strings.ToUpper(e.Device.MAC)]
+		}
+		return lastOctet(e.Device.IP)
+	}
 	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Favorite != entries[j].Favorite {
+			return entries[i].Favorite
+		}
+		// Devices from several LANs stay grouped by network.
+		if a, b := subnetKey(entries[i].Device.IP), subnetKey(entries[j].Device.IP); a != b {
+			return a < b
+		}
+		if a, b := position(entries[i]), position(entries[j]); a != b {
+			return a < b
+		}
 		return lastOctet(entries[i].Device.IP) < lastOctet(entries[j].Device.IP)
 	})
 }
 
+// subnetKey orders IPv4 addresses by their first three octets.
+func subnetKey(ip string) uint32 {
+	v4 := net.ParseIP(ip).To4()
+	if v4 == nil {
+		return 0
+	}
+	return uint32(v4[0])<<16 | uint32(v4[1])<<8 | uint32(v4[2])
+}
+
 func lastOctet(ip string) int {
 	parts := strings.Split(ip, ".")
 	if len(parts) != 4 {
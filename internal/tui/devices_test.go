@@ -0,0 +1,313 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/406-mot-acceptable/lmtm/internal/discovery"
+)
+
+func TestDensityGrid(t *testing.T) {
+	tests := []struct {
+		name string
+		ips  []string
+		want [4]int // marked cells per row: .1-.64, .65-.128, .129-.192, .193-.254
+	}{
+		{name: "empty", ips: nil},
+		{name: "row edges", ips: []string{"10.0.0.1", "10.0.0.64", "10.0.0.65", "10.0.0.254"}, want: [4]int{2, 1, 0, 1}},
+		{name: "network and broadcast not drawn", ips: []string{"10.0.0.0", "10.0.0.255"}},
+		{name: "one per row", ips: []string{"10.0.0.30", "10.0.0.100", "10.0.0.150", "10.0.0.200"}, want: [4]int{1, 1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices := make([]discovery.DiscoveredDevice, len(tt.ips))
+			for i, ip := range tt.ips {
+				devices[i] = discovery.DiscoveredDevice{IP: ip}
+			}
+			rows := strings.Split(strings.TrimSuffix(NewDevicesModel(devices).densityGrid(), "\n"), "\n")
+			if len(rows) != 4 {
+				t.Fatalf("grid has %d rows, want 4", len(rows))
+			}
+			cells := 0
+			for i, row := range rows {
+				cells += strings.Count(row, "█") + strings.Count(row, "·")
+				if got := strings.Count(row, "█"); got != tt.want[i] {
+					t.Errorf("row %d has %d marked cells, want %d", i, got, tt.want[i])
+				}
+			}
+			if cells != 254 {
+				t.Errorf("grid has %d cells, want 254 (.1-.254)", cells)
+			}
+		})
+	}
+}
+
+// keyPress is a plain key press as bubbletea delivers it.
+func keyPress(k string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+}
+
+func TestPresetCycle(t *testing.T) {
+	m := NewDevicesModel([]discovery.DiscoveredDevice{
+		{IP: "192.168.1.10", DefaultPorts: []int{80, 8000}},
+	})
+	m.entries[0].CustomPorts = []int{8080}
+
+	tests := []struct {
+		preset PortPreset
+		ports  []int
+	}{
+		{PresetCamera, []int{22, 80, 443, 554}},
+		{PresetRouter, []int{22, 80, 443}},
+		{PresetWeb, []int{80, 443}},
+		{PresetDefault, []int{80, 8000}},
+	}
+	for _, tt := range tests {
+		m, _ = m.Update(keyPress("t"))
+		e := m.entries[0]
+		if e.Preset != tt.preset || e.CustomPorts != nil {
+			t.Fatalf("preset %v custom %v, want %v and no custom ports", e.Preset, e.CustomPorts, tt.preset)
+		}
+		if got := formatPorts(e.effectivePorts()); got != formatPorts(tt.ports) {
+			t.Errorf("%v ports = %s, want %s", tt.preset, got, formatPorts(tt.ports))
+		}
+	}
+}
+
+func TestDevicesStatusBarFits(t *testing.T) {
+	m := NewDevicesModel([]discovery.DiscoveredDevice{
+		{IP: "192.168.1.10", MAC: "3c:22:fb:00:00:01", DefaultPorts: []int{80}},
+	})
+	m.undoStack = append(m.undoStack, map[string]bool{})
+
+	for _, showKeys := range []bool{false, true} {
+		m.showKeys = showKeys
+		view := m.View()
+		bar := view[strings.LastIndex(view, "Select Devices"):]
+		for _, line := range strings.Split(bar, "\n") {
+			if strings.Contains(line, "Enter: build") || strings.Contains(line, ": ") {
+				if w := lipgloss.Width(strings.TrimRight(line, " ")); w > 80 {
+					t.Errorf("showKeys=%v: line is %d columns wide:\n%s", showKeys, w, line)
+				}
+			}
+		}
+		if showKeys && !strings.Contains(view, "t: cycle port preset") {
+			t.Error("full key list lacks the preset key")
+		}
+	}
+}
+
+// addManual types ip (and port, if set) into the add-device form and
+// submits it.
+func addManual(m DevicesModel, ip, port string) DevicesModel {
+	m, _ = m.Update(keyPress("+"))
+	m, _ = m.Update(keyPress(ip))
+	if port != "" {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		m, _ = m.Update(keyPress(port))
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	return m
+}
+
+func TestManualMappingEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		ip      string
+		port    string
+		wantErr string
+		want    map[int]int // pinned remote -> local ports on 192.168.1.50
+		ports   []int
+	}{
+		{name: "new device", ip: "18443:192.168.1.50:443", want: map[int]int{443: 18443}, ports: []int{443}},
+		{name: "port field ignored", ip: "18080:192.168.1.50:80", port: "22", want: map[int]int{80: 18080}, ports: []int{80}},
+		{name: "existing device", ip: "15554:192.168.1.10:554", ports: []int{554}},
+		{name: "plain ip and port", ip: "192.168.1.50", port: "8080", ports: []int{8080}},
+		{name: "bad local port", ip: "0:192.168.1.50:443", wantErr: "local port"},
+		{name: "bad remote port", ip: "18443:192.168.1.50:70000", wantErr: "remote port"},
+		{name: "hostname", ip: "18443:camera.lan:443", wantErr: "not an IPv4 address"},
+		{name: "bad host octet", ip: "18443:192.168.1.300:443", wantErr: "not an IPv4 address"},
+		{name: "empty remote", ip: "18443:192.168.1.50:", wantErr: "remote port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewDevicesModel([]discovery.DiscoveredDevice{
+				{IP: "192.168.1.10", DefaultPorts: []int{80}},
+			})
+			m = addManual(m, tt.ip, tt.port)
+
+			if tt.wantErr != "" {
+				if m.mode != modeManual || !strings.Contains(m.inputErr, tt.wantErr) {
+					t.Errorf("mode %v, error %q; want the form kept open with %q", m.mode, m.inputErr, tt.wantErr)
+				}
+				if len(m.entries) != 1 {
+					t.Errorf("%d entries after a rejected entry, want 1", len(m.entries))
+				}
+				return
+			}
+			if m.mode != modeList || m.inputErr != "" {
+				t.Fatalf("mode %v, error %q after a valid entry", m.mode, m.inputErr)
+			}
+
+			ip := "192.168.1.50"
+			if strings.Contains(tt.ip, "192.168.1.10") {
+				ip = "192.168.1.10"
+			}
+			var e *deviceEntry
+			for i := range m.entries {
+				if m.entries[i].Device.IP == ip {
+					e = &m.entries[i]
+				}
+			}
+			if e == nil {
+				t.Fatalf("no entry for %s", ip)
+			}
+			for _, p := range tt.ports {
+				if !hasDupePort(e.Device.DefaultPorts, p) {
+					t.Errorf("ports %v lack %d", e.Device.DefaultPorts, p)
+				}
+			}
+			if ip == "192.168.1.10" {
+				if e.LocalPorts[554] != 15554 || !hasDupePort(e.Device.DefaultPorts, 80) {
+					t.Errorf("existing entry = %+v, want 554 pinned to 15554 and 80 kept", e)
+				}
+				return
+			}
+			if !e.Selected {
+				t.Error("new device not selected")
+			}
+			if !reflect.DeepEqual(e.LocalPorts, tt.want) {
+				t.Errorf("pinned ports = %v, want %v", e.LocalPorts, tt.want)
+			}
+		})
+	}
+}
+
+// selectPort types port into the select-by-port bar and submits it.
+func selectPort(m DevicesModel, port string) DevicesModel {
+	m, _ = m.Update(keyPress("#"))
+	m, _ = m.Update(keyPress(port))
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	return m
+}
+
+func TestSelectByPort(t *testing.T) {
+	devices := []discovery.DiscoveredDevice{
+		{IP: "192.168.1.10", DefaultPorts: []int{80, 554}},     // camera
+		{IP: "192.168.1.11", DefaultPorts: []int{22, 80, 443}}, // router
+		{IP: "192.168.1.12", DefaultPorts: []int{3389}},        // desktop
+		{IP: "192.168.1.13", DefaultPorts: []int{80}},          // camera with a custom port
+	}
+	tests := []struct {
+		name    string
+		port    string
+		want    []bool
+		notice  string
+		wantErr string
+	}{
+		{name: "subset", port: "554", want: []bool{true, false, true, true}, notice: "Selected 2 devices with port 554."},
+		{name: "custom ports replace defaults", port: "80", want: []bool{true, true, true, false}, notice: "Selected 2 devices with port 80."},
+		{name: "no match", port: "9100", want: []bool{false, false, true, false}, notice: "No devices have port 9100 -- selection unchanged."},
+		{name: "out of range", port: "70000", wantErr: "port must be 1-65535"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewDevicesModel(devices)
+			m.entries[2].Selected = true
+			m.entries[3].CustomPorts = []int{554}
+
+			m = selectPort(m, tt.port)
+			if tt.wantErr != "" {
+				if m.mode != modeByPort || m.inputErr != tt.wantErr {
+					t.Errorf("mode %d, error %q; want the port bar open with %q", m.mode, m.inputErr, tt.wantErr)
+				}
+				return
+			}
+			if m.mode != modeList || m.notice != tt.notice {
+				t.Errorf("mode %d, notice %q; want the list with %q", m.mode, m.notice, tt.notice)
+			}
+			for i, e := range m.entries {
+				if e.Selected != tt.want[i] {
+					t.Errorf("%s selected = %v, want %v", e.Device.IP, e.Selected, tt.want[i])
+				}
+			}
+
+			m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlZ})
+			for i, e := range m.entries {
+				if e.Selected != (i == 2) {
+					t.Errorf("after undo %s selected = %v", e.Device.IP, e.Selected)
+				}
+			}
+		})
+	}
+}
+
+func TestInvertSelection(t *testing.T) {
+	m := NewDevicesModel([]discovery.DiscoveredDevice{
+		{IP: "192.168.1.10", DefaultPorts: []int{80, 554}},
+		{IP: "192.168.1.11", DefaultPorts: []int{22, 80, 443}},
+		{IP: "192.168.1.12", DefaultPorts: []int{3389}},
+	})
+	m.entries[0].Selected = true
+
+	m, _ = m.Update(keyPress("i"))
+	for i, e := range m.entries {
+		if e.Selected != (i != 0) {
+			t.Errorf("%s selected = %v after invert", e.Device.IP, e.Selected)
+		}
+	}
+	if !strings.Contains(m.View(), "2/3 devices, 4 ports") {
+		t.Error("status bar does not count the inverted selection")
+	}
+
+	m, _ = m.Update(keyPress("i"))
+	if n, ports := m.selectionCounts(); n != 1 || ports != 2 || !m.entries[0].Selected {
+		t.Errorf("after a second invert: %d devices, %d ports; want the first device back", n, ports)
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if n, _ := m.selectionCounts(); n != 2 || m.entries[0].Selected {
+		t.Errorf("undo left %d selected, first device %v; want the first invert back", n, m.entries[0].Selected)
+	}
+}
+
+func TestSelectStarred(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	const gw = "192.168.1.1"
+	for mac, ip := range map[string]string{
+		"00:0C:29:11:22:33": "192.168.1.11", // found by the scan
+		"B8:27:EB:44:55:66": "192.168.1.40", // starred here but not found
+	} {
+		if err := discovery.SetFavorite(mac, &discovery.Favorite{IP: ip, Gateway: gw}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := NewDevicesModel([]discovery.DiscoveredDevice{
+		{IP: "192.168.1.10", MAC: "00:0C:29:AA:BB:CC", DefaultPorts: []int{80}},
+		{IP: "192.168.1.11", MAC: "00:0c:29:11:22:33", DefaultPorts: []int{80}},
+		{IP: "192.168.1.12", DefaultPorts: []int{80}},
+	})
+	m.ApplyFavorites(gw)
+	for i := range m.entries {
+		m.entries[i].Selected = m.entries[i].Device.IP != "192.168.1.11"
+	}
+
+	m, _ = m.Update(keyPress("F"))
+	for _, e := range m.entries {
+		if want := e.Device.IP == "192.168.1.11"; e.Selected != want || e.Favorite != want {
+			t.Errorf("%s selected = %v, starred = %v; want %v", e.Device.IP, e.Selected, e.Favorite, want)
+		}
+	}
+	if len(m.missingFavs) != 1 || m.missingFavs[0].IP != "192.168.1.40" {
+		t.Errorf("missing favorites = %+v, want 192.168.1.40", m.missingFavs)
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if n, _ := m.selectionCounts(); n != 2 {
+		t.Errorf("undo left %d selected, want the 2 from before F", n)
+	}
+}
@@ -6,6 +6,10 @@ import "github.com/charmbracelet/bubbles/key"
 type GlobalKeys struct {
 	Quit key.Binding
 	Back key.Binding
+	Tour key.Binding
+
+	// RawOutput is the hidden debug screen with the last gateway commands.
+	RawOutput key.Binding
 }
 
 // ShortHelp returns keybindings for the short help view.
@@ -40,7 +44,10 @@ type SelectionKeys struct {
 	Toggle  key.Binding
 	All     key.Binding
 	None    key.Binding
+	Invert  key.Binding
 	FirstN  key.Binding
+	Starred key.Binding
+	ByPort  key.Binding
 }
 
 // ShortHelp returns keybindings for the short help view.
@@ -50,23 +57,83 @@ func (k SelectionKeys) ShortHelp() []key.Binding {
 
 // FullHelp returns keybindings for the full help view.
 func (k SelectionKeys) FullHelp() [][]key.Binding {
-	return [][]key.Binding{{k.Toggle, k.All, k.None, k.FirstN}}
+	return [][]key.Binding{{k.Toggle, k.All, k.None, k.Invert, k.FirstN, k.Starred, k.ByPort}}
+}
+
+// DeviceKeys handles per-device actions in the device list.
+type DeviceKeys struct {
+	Undo      key.Binding
+	Preset    key.Binding
+	EditPorts key.Binding
+	RandomMAC key.Binding
+	Favorite  key.Binding
+	Exclude   key.Binding
+	Note      key.Binding
+	Proxy     key.Binding
+	Strategy  key.Binding
+	Nmap      key.Binding
+	Subnet    key.Binding
+	AddDevice key.Binding
+	Help      key.Binding
+}
+
+// ShortHelp returns keybindings for the short help view.
+func (k DeviceKeys) ShortHelp() []key.Binding {
+	return []key.Binding{k.EditPorts, k.Favorite, k.AddDevice}
+}
+
+// FullHelp returns keybindings for the full help view.
+func (k DeviceKeys) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Undo, k.Preset, k.EditPorts, k.RandomMAC, k.Favorite, k.Exclude, k.Note, k.Proxy, k.Strategy, k.Nmap, k.Subnet, k.AddDevice, k.Help}}
 }
 
 // TunnelKeys handles the active tunnel dashboard.
 type TunnelKeys struct {
-	Reconnect key.Binding
-	EditPorts key.Binding
+	Reconnect    key.Binding
+	RebuildGroup key.Binding
+	CloseGroup   key.Binding
+	OpenWeb      key.Binding
+	OpenAll      key.Binding
+	AutoOpen     key.Binding
+	AcceptCert   key.Binding
+	SpecDiff     key.Binding
+	Playlist     key.Binding
+	WebDash      key.Binding
+	EditPorts    key.Binding
+	Compact      key.Binding
+	CopySSH      key.Binding
+	PortTable    key.Binding
+	Health       key.Binding
+	Notes        key.Binding
+	DeviceNote   key.Binding
+	QoS          key.Binding
+	PruneDead    key.Binding
+	SOCKS        key.Binding
 }
 
 // ShortHelp returns keybindings for the short help view.
 func (k TunnelKeys) ShortHelp() []key.Binding {
-	return []key.Binding{k.Reconnect, k.EditPorts}
+	return []key.Binding{k.Reconnect, k.RebuildGroup, k.CloseGroup, k.OpenWeb, k.Compact, k.CopySSH, k.Health}
 }
 
 // FullHelp returns keybindings for the full help view.
 func (k TunnelKeys) FullHelp() [][]key.Binding {
-	return [][]key.Binding{{k.Reconnect, k.EditPorts}}
+	return [][]key.Binding{{k.Reconnect, k.RebuildGroup, k.CloseGroup, k.OpenWeb, k.AutoOpen, k.SpecDiff, k.Playlist, k.WebDash, k.EditPorts, k.Compact, k.CopySSH, k.PortTable, k.Health, k.Notes, k.DeviceNote, k.QoS, k.PruneDead, k.SOCKS}}
+}
+
+// BuildingKeys handles the tunnel construction screen.
+type BuildingKeys struct {
+	Order key.Binding
+}
+
+// ShortHelp returns keybindings for the short help view.
+func (k BuildingKeys) ShortHelp() []key.Binding {
+	return []key.Binding{k.Order}
+}
+
+// FullHelp returns keybindings for the full help view.
+func (k BuildingKeys) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Order}}
 }
 
 // ConnectKeys handles the connection input screen.
@@ -74,6 +141,7 @@ type ConnectKeys struct {
 	NextField key.Binding
 	PrevField key.Binding
 	Connect   key.Binding
+	Sudo      key.Binding
 }
 
 // ShortHelp returns keybindings for the short help view.
@@ -83,7 +151,7 @@ func (k ConnectKeys) ShortHelp() []key.Binding {
 
 // FullHelp returns keybindings for the full help view.
 func (k ConnectKeys) FullHelp() [][]key.Binding {
-	return [][]key.Binding{{k.NextField, k.PrevField, k.Connect}}
+	return [][]key.Binding{{k.NextField, k.PrevField, k.Connect, k.Sudo}}
 }
 
 // DefaultGlobalKeys returns the default global keybindings.
@@ -96,6 +164,14 @@ var DefaultGlobalKeys = GlobalKeys{
 		key.WithKeys("esc"),
 		key.WithHelp("esc", "back"),
 	),
+	Tour: key.NewBinding(
+		key.WithKeys("ctrl+t"),
+		key.WithHelp("ctrl+t", "tour"),
+	),
+	RawOutput: key.NewBinding(
+		key.WithKeys("ctrl+l"),
+		key.WithHelp("ctrl+l", "raw gateway output"),
+	),
 }
 
 // DefaultNavigationKeys returns the default navigation keybindings.
@@ -128,10 +204,78 @@ var DefaultSelectionKeys = SelectionKeys{
 		key.WithKeys("n"),
 		key.WithHelp("n", "select none"),
 	),
+	Invert: key.NewBinding(
+		key.WithKeys("i"),
+		key.WithHelp("i", "invert selection"),
+	),
 	FirstN: key.NewBinding(
 		key.WithKeys("f"),
 		key.WithHelp("f", "first 10"),
 	),
+	Starred: key.NewBinding(
+		key.WithKeys("F"),
+		key.WithHelp("F", "favorites only"),
+	),
+	ByPort: key.NewBinding(
+		key.WithKeys("#"),
+		key.WithHelp("#", "select by port"),
+	),
+}
+
+// DefaultDeviceKeys returns the default device list keybindings.
+var DefaultDeviceKeys = DeviceKeys{
+	Undo: key.NewBinding(
+		key.WithKeys("ctrl+z"),
+		key.WithHelp("ctrl+z", "undo selection"),
+	),
+	Preset: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "cycle port preset"),
+	),
+	EditPorts: key.NewBinding(
+		key.WithKeys("E"),
+		key.WithHelp("E", "edit ports of selected devices"),
+	),
+	RandomMAC: key.NewBinding(
+		key.WithKeys("h"),
+		key.WithHelp("h", "hide/show randomized MACs"),
+	),
+	Favorite: key.NewBinding(
+		key.WithKeys("*"),
+		key.WithHelp("*", "favorite"),
+	),
+	Exclude: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "exclude from scans"),
+	),
+	Note: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "device note"),
+	),
+	Proxy: key.NewBinding(
+		key.WithKeys("P"),
+		key.WithHelp("P", "SOCKS proxy through device"),
+	),
+	Strategy: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "octet/sequential ports"),
+	),
+	Nmap: key.NewBinding(
+		key.WithKeys("N"),
+		key.WithHelp("N", "nmap scan"),
+	),
+	Subnet: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "scan subnet"),
+	),
+	AddDevice: key.NewBinding(
+		key.WithKeys("+"),
+		key.WithHelp("+", "add device"),
+	),
+	Help: key.NewBinding(
+		key.WithKeys("?"),
+		key.WithHelp("?", "fewer keys"),
+	),
 }
 
 // DefaultTunnelKeys returns the default tunnel dashboard keybindings.
@@ -140,10 +284,90 @@ var DefaultTunnelKeys = TunnelKeys{
 		key.WithKeys("r"),
 		key.WithHelp("r", "reconnect"),
 	),
+	RebuildGroup: key.NewBinding(
+		key.WithKeys("g"),
+		key.WithHelp("g", "rebuild group"),
+	),
+	CloseGroup: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "close group"),
+	),
+	OpenWeb: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "open in browser"),
+	),
+	OpenAll: key.NewBinding(
+		key.WithKeys("O"),
+		key.WithHelp("O", "open all in browser"),
+	),
+	AutoOpen: key.NewBinding(
+		key.WithKeys("B"),
+		key.WithHelp("B", "open web tunnels after builds"),
+	),
+	AcceptCert: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "accept changed certificates"),
+	),
+	SpecDiff: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "show/hide changes since selection"),
+	),
+	Playlist: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("v", "open cameras playlist"),
+	),
+	WebDash: key.NewBinding(
+		key.WithKeys("w"),
+		key.WithHelp("w", "read-only web dashboard"),
+	),
 	EditPorts: key.NewBinding(
 		key.WithKeys("p"),
 		key.WithHelp("p", "edit ports"),
 	),
+	Compact: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "compact view"),
+	),
+	CopySSH: key.NewBinding(
+		key.WithKeys("y"),
+		key.WithHelp("y", "copy ssh command"),
+	),
+	PortTable: key.NewBinding(
+		key.WithKeys("T"),
+		key.WithHelp("T", "copy port table"),
+	),
+	Health: key.NewBinding(
+		key.WithKeys("h"),
+		key.WithHelp("h", "health probes"),
+	),
+	Notes: key.NewBinding(
+		key.WithKeys("N"),
+		key.WithHelp("N", "session notes"),
+	),
+	DeviceNote: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "device note"),
+	),
+	QoS: key.NewBinding(
+		key.WithKeys("Q"),
+		key.WithHelp("Q", "cycle QoS marking"),
+	),
+	PruneDead: key.NewBinding(
+		key.WithKeys("P"),
+		key.WithHelp("P", "close tunnels to dead devices"),
+	),
+	SOCKS: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "SOCKS5 proxy through the gateway"),
+	),
+}
+
+// DefaultBuildingKeys returns the default tunnel construction keybindings.
+var DefaultBuildingKeys = BuildingKeys{
+	Order: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "cycle pipe order"),
+	),
 }
 
 // DefaultConnectKeys returns the default connect screen keybindings.
@@ -160,4 +384,8 @@ var DefaultConnectKeys = ConnectKeys{
 		key.WithKeys("enter"),
 		key.WithHelp("enter", "connect"),
 	),
+	Sudo: key.NewBinding(
+		key.WithKeys("ctrl+s"),
+		key.WithHelp("ctrl+s", "toggle sudo"),
+	),
 }
@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/406-mot-acceptable/lmtm/internal/discovery"
+	"github.com/406-mot-acceptable/lmtm/internal/portmap"
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+)
+
+// lastSession is what a clean disconnect leaves behind so the next
+// launch can rebuild the same tunnels after asking only for the
+// password. Device logins for nested proxies are never saved.
+type lastSession struct {
+	Gateway  string              `json:"gateway"`
+	Username string              `json:"username"`
+	Strategy portmap.Strategy    `json:"port_strategy"`
+	Devices  []lastSessionDevice `json:"devices"`
+	Saved    time.Time           `json:"saved"`
+}
+
+// lastSessionDevice is one selected device and the ports it was built with.
+type lastSessionDevice struct {
+	IP     string                `json:"ip"`
+	MAC    string                `json:"mac,omitempty"`
+	Vendor string                `json:"vendor,omitempty"`
+	Class  discovery.DeviceClass `json:"class"`
+	Ports  []int                 `json:"ports"`
+}
+
+func lastSessionPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".tunneler", "cache", "last-session.json")
+}
+
+// loadLastSession returns the saved session, or nil if there is none.
+func loadLastSession() *lastSession {
+	var s lastSession
+	if err := store.Load(lastSessionPath(), &s); err != nil || s.Gateway == "" || len(s.Devices) == 0 {
+		return nil
+	}
+	return &s
+}
+
+// saveLastSession records the selected devices of the session that is
+// ending, replacing whatever is saved: the last session to end wins.
+// since is the file as this instance last saw it; if another instance
+// saved its own session in between, that is logged. Nothing is saved when
+// nothing was selected. It returns the file's version afterwards.
+func saveLastSession(since store.Version, gateway, username string, strategy portmap.Strategy, entries []deviceEntry) (store.Version, error) {
+	s := lastSession{Gateway: gateway, Username: username, Strategy: strategy, Saved: time.Now()}
+	for _, e := range entries {
+		if !e.Selected {
+			continue
+		}
+		s.Devices = append(s.Devices, lastSessionDevice{
+			IP:     e.Device.IP,
+			MAC:    e.Device.MAC,
+			Vendor: e.Device.Vendor,
+			Class:  e.Device.DeviceType,
+			Ports:  e.effectivePorts(),
+		})
+	}
+	if len(s.Devices) == 0 {
+		return since, nil
+	}
+	next, conflict, err := store.Replace(lastSessionPath(), &s, since)
+	if err != nil {
+		return since, err
+	}
+	if conflict {
+		ssh.Logf("session: replacing a last session saved by another lmtm instance")
+	}
+	return next, nil
+}
+
+// instanceLockPath returns the lock file held while connected to gateway.
+func instanceLockPath(gateway string) string {
+	home, _ := os.UserHomeDir()
+	name := strings.NewReplacer(":", "_", "/", "_", "\\", "_", "[", "", "]", "").Replace(gateway)
+	return filepath.Join(home, ".tunneler", "run", name+".lock")
+}
+
+// forgetLastSession deletes the saved session.
+func forgetLastSession() {
+	_ = os.Remove(lastSessionPath())
+}
+
+// resume turns the saved session into a resume that rebuilds its tunnels
+// as soon as the gateway is reconnected.
+func (s *lastSession) resume() *sessionResume {
+	entries := make([]deviceEntry, len(s.Devices))
+	for i, d := range s.Devices {
+		ports := append([]int(nil), d.Ports...)
+		entries[i] = deviceEntry{
+			Device: discovery.DiscoveredDevice{
+				IP:           d.IP,
+				MAC:          d.MAC,
+				Vendor:       d.Vendor,
+				DeviceType:   d.Class,
+				DefaultPorts: ports,
+				Online:       true,
+			},
+			Selected:    true,
+			CustomPorts: ports,
+		}
+	}
+	return &sessionResume{
+		gateway:  s.Gateway,
+		username: s.Username,
+		entries:  entries,
+		rebuild:  true,
+		strategy: s.Strategy,
+	}
+}
+
+// summary describes the saved session for the connect screen.
+func (s *lastSession) summary() string {
+	ports := 0
+	for _, d := range s.Devices {
+		ports += len(d.Ports)
+	}
+	return fmt.Sprintf("Last session: %s, %d devices, %d tunnels (%s)",
+		s.Gateway, len(s.Devices), ports, s.Saved.Format("2006-01-02 15:04"))
+}
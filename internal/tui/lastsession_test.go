@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/406-mot-acceptable/lmtm/internal/discovery"
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+	"github.com/406-mot-acceptable/lmtm/internal/portmap"
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+)
+
+// sessionEntries is a device list with two of three devices selected.
+func sessionEntries() []deviceEntry {
+	return []deviceEntry{
+		{
+			Device:      discovery.DiscoveredDevice{IP: "192.168.88.64", MAC: "C0:56:E3:11:22:33", Vendor: "Hikvision", DeviceType: discovery.ClassCamera},
+			Selected:    true,
+			CustomPorts: []int{554, 80},
+		},
+		{Device: discovery.DiscoveredDevice{IP: "192.168.88.70", DefaultPorts: []int{80}}},
+		{
+			Device:   discovery.DiscoveredDevice{IP: "192.168.88.2", Vendor: "Synology", DeviceType: discovery.ClassNVR, DefaultPorts: []int{5000}},
+			Selected: true,
+			Preset:   PresetWeb,
+		},
+	}
+}
+
+func TestLastSessionRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if _, err := saveLastSession(store.Version{}, "192.168.88.1", "admin", portmap.StrategySequential, sessionEntries()); err != nil {
+		t.Fatalf("saveLastSession: %v", err)
+	}
+
+	s := loadLastSession()
+	if s == nil {
+		t.Fatal("no session saved")
+	}
+	if s.Gateway != "192.168.88.1" || s.Username != "admin" || s.Strategy != portmap.StrategySequential {
+		t.Errorf("session = %s %s %v", s.Gateway, s.Username, s.Strategy)
+	}
+	want := []lastSessionDevice{
+		{IP: "192.168.88.64", MAC: "C0:56:E3:11:22:33", Vendor: "Hikvision", Class: discovery.ClassCamera, Ports: []int{554, 80}},
+		{IP: "192.168.88.2", Vendor: "Synology", Class: discovery.ClassNVR, Ports: []int{80, 443}},
+	}
+	if !reflect.DeepEqual(s.Devices, want) {
+		t.Errorf("devices =\n%+v\nwant\n%+v", s.Devices, want)
+	}
+	if !strings.HasPrefix(s.summary(), "Last session: 192.168.88.1, 2 devices, 4 tunnels (") {
+		t.Errorf("summary = %q", s.summary())
+	}
+
+	forgetLastSession()
+	if loadLastSession() != nil {
+		t.Error("session still loads after forgetLastSession")
+	}
+}
+
+func TestLastSessionNothingSelected(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	entries := sessionEntries()
+	for i := range entries {
+		entries[i].Selected = false
+	}
+	if _, err := saveLastSession(store.Version{}, "192.168.88.1", "admin", portmap.StrategyOctet, entries); err != nil {
+		t.Fatalf("saveLastSession: %v", err)
+	}
+	if loadLastSession() != nil {
+		t.Error("a session with nothing selected was saved")
+	}
+}
+
+func TestLastSessionResumeRebuilds(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if _, err := saveLastSession(store.Version{}, "192.168.88.1", "admin", portmap.StrategySequential, sessionEntries()); err != nil {
+		t.Fatalf("saveLastSession: %v", err)
+	}
+
+	// A fresh launch lands on the connect screen with the session offered.
+	next, _ := AppModel{}.disconnect()
+	m := next.(AppModel)
+	if m.resume == nil || !m.resume.rebuild {
+		t.Fatal("saved session not offered for rebuild")
+	}
+	if m.connect.Gateway() != "192.168.88.1" || m.connect.Username() != "admin" {
+		t.Errorf("connect form prefilled with %q / %q", m.connect.Gateway(), m.connect.Username())
+	}
+
+	next, _ = m.updateConnect(ConnectMsg{Gateway: "192.168.88.1", Username: "admin", Password: "x"})
+	m = next.(AppModel)
+	next, cmd := m.applySurvey(SurveyDataMsg{LAN: &gateway.LANConfig{Subnet: "192.168.88", CIDR: "192.168.88.0/24"}})
+	m = next.(AppModel)
+	if cmd == nil {
+		t.Fatal("reconnecting sent no command")
+	}
+	sel, ok := cmd().(DeviceSelectMsg)
+	if !ok {
+		t.Fatalf("reconnecting sent %T, want DeviceSelectMsg", cmd())
+	}
+	if sel.PortStrategy != portmap.StrategySequential {
+		t.Errorf("strategy = %v, want sequential", sel.PortStrategy)
+	}
+	got := make(map[string][]int)
+	for _, d := range sel.Devices {
+		got[d.IP] = d.Ports
+	}
+	want := map[string][]int{
+		"192.168.88.64": {554, 80},
+		"192.168.88.2":  {80, 443},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rebuilding %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/406-mot-acceptable/lmtm/internal/discovery"
+)
+
+// maxSessionNoteLen caps the session notes, in characters.
+const maxSessionNoteLen = 2000
+
+// noteSummaryLen is how much of a note fits on one line of a panel.
+const noteSummaryLen = 60
+
+// deviceNotes holds the saved device notes for one gateway. Both the
+// device list and the dashboard share one, so a note added on either
+// shows on the other.
+type deviceNotes struct {
+	gateway string
+	byKey   map[string]discovery.Note
+}
+
+// newDeviceNotes loads the saved notes for use behind gateway.
+func newDeviceNotes(gateway string) *deviceNotes {
+	return &deviceNotes{gateway: gateway, byKey: discovery.LoadNotes()}
+}
+
+// get returns the note for a device, if it has one.
+func (n *deviceNotes) get(ip, mac string) (discovery.Note, bool) {
+	if n == nil {
+		return discovery.Note{}, false
+	}
+	note, ok := n.byKey[discovery.NoteKey(n.gateway, ip, mac)]
+	return note, ok
+}
+
+// set replaces a device's note, or deletes it when text is blank, and
+// returns the command that saves the change.
+func (n *deviceNotes) set(ip, mac, text string) tea.Cmd {
+	k := discovery.NoteKey(n.gateway, ip, mac)
+	var note *discovery.Note
+	if text = strings.TrimSpace(text); text == "" {
+		delete(n.byKey, k)
+	} else {
+		note = &discovery.Note{Text: text, Updated: time.Now(), IP: ip, Gateway: n.gateway}
+		n.byKey[k] = *note
+	}
+	return func() tea.Msg {
+		_ = discovery.SetNote(k, note)
+		return nil
+	}
+}
+
+// noteResult is what a key press did to a note editor.
+type noteResult int
+
+const (
+	noteEditing noteResult = iota
+	noteSaved
+	noteCancelled
+)
+
+var (
+	noteSaveKey = key.NewBinding(key.WithKeys("ctrl+s"))
+	noteQuitKey = key.NewBinding(key.WithKeys("esc"))
+)
+
+// noteEditor is a small multi-line editor for a note. ip and mac name the
+// device being annotated; both are empty for the session notes.
+type noteEditor struct {
+	title string
+	ip    string
+	mac   string
+	area  textarea.Model
+}
+
+// newNoteEditor opens an editor on text, allowing up to limit characters.
+func newNoteEditor(title, text string, limit int) noteEditor {
+	ta := textarea.New()
+	ta.CharLimit = limit
+	ta.SetWidth(64)
+	ta.SetHeight(6)
+	ta.ShowLineNumbers = false
+	ta.Prompt = "  "
+	ta.Cursor.SetMode(cursor.CursorStatic)
+	ta.SetValue(text)
+	ta.Focus()
+	return noteEditor{title: title, area: ta}
+}
+
+// Update handles a key press. Enter adds a line; Ctrl+S saves, Esc
+// discards the edit.
+func (e noteEditor) Update(msg tea.KeyMsg) (noteEditor, noteResult) {
+	switch {
+	case key.Matches(msg, noteSaveKey):
+		return e, noteSaved
+	case key.Matches(msg, noteQuitKey):
+		return e, noteCancelled
+	}
+	e.area, _ = e.area.Update(msg)
+	return e, noteEditing
+}
+
+// Value returns the edited text.
+func (e noteEditor) Value() string {
+	return e.area.Value()
+}
+
+// View renders the editor as a panel with its own status bar.
+func (e noteEditor) View() string {
+	count := DimStyle.Render(fmt.Sprintf("%d/%d characters -- an empty note is deleted",
+		len([]rune(e.area.Value())), e.area.CharLimit))
+	panel := renderPanel(e.title, e.area.View()+"\n"+count)
+	bar := renderStatusBar("Ctrl+S: save", "Enter: new line", "Esc: cancel")
+	return ContentStyle.Render(panel + "\n" + bar)
+}
+
+// noteSummary flattens a note to one line of at most max characters, so
+// multi-line text and stray control characters can't break a panel.
+func noteSummary(text string, max int) string {
+	lines := strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '\r' })
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return ' '
+			}
+			return r
+		}, l))
+	}
+	flat := []rune(strings.Join(lines, " / "))
+	if len(flat) > max {
+		flat = append(flat[:max-3], []rune("...")...)
+	}
+	return string(flat)
+}
+
+// noteLine renders a saved note as "note from 2006-01-02: text".
+func noteLine(note discovery.Note) string {
+	return fmt.Sprintf("note from %s: %s", note.Updated.Format("2006-01-02"), noteSummary(note.Text, noteSummaryLen))
+}
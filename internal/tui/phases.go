@@ -0,0 +1,120 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/406-mot-acceptable/lmtm/internal/gateway"
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
+)
+
+// rerunPhase is a wizard phase the survey screen can run again on the
+// live connection, e.g. after fixing something on the router by hand.
+type rerunPhase string
+
+const (
+	phaseDetect rerunPhase = "detection"
+	phaseSurvey rerunPhase = "survey"
+)
+
+// RerunPhaseMsg asks the app to run a phase again and replace its
+// results. Detection carries on into the survey, since a different
+// gateway type reads the network differently.
+type RerunPhaseMsg struct {
+	Phase rerunPhase
+}
+
+// phaseDetectedMsg carries the result of re-running detection.
+type phaseDetectedMsg struct {
+	gw       gateway.Gateway
+	gwType   string
+	hostname string
+	err      error
+}
+
+// phaseTraceLabel prefixes the commands of a re-run in the tunnel log.
+func phaseTraceLabel(p rerunPhase) string {
+	return "rerun " + string(p)
+}
+
+// rerunPhase starts a phase again. Re-runs only start from the survey,
+// before any scan or build, and one at a time.
+func (m AppModel) rerunPhase(p rerunPhase) (tea.Model, tea.Cmd) {
+	if m.state != stateSurvey || m.rerunning != "" {
+		return m, nil
+	}
+	m.rerunning = p
+	m.survey.SetRerun(fmt.Sprintf("Re-running %s -- commands go to %s", p, ssh.LogPath()))
+	ssh.Logf("%s: started on %s", phaseTraceLabel(p), m.gatewayAddr)
+	if p == phaseDetect {
+		return m, m.redetectCmd()
+	}
+	return m, m.tracedSurveyCmd(p)
+}
+
+// redetectCmd runs gateway detection again on the open connection. A
+// MikroTik keeps its RouterOS API session.
+func (m AppModel) redetectCmd() tea.Cmd {
+	client := m.sshClient
+	old := m.gw
+	sudo := m.sudo
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		ctx = ssh.WithExecTrace(ctx, phaseTraceLabel(phaseDetect))
+
+		gw, err := gateway.Detect(ctx, client.ServerVersion(), client.Exec)
+		if err != nil {
+			return phaseDetectedMsg{err: fmt.Errorf("detection failed: %w", err)}
+		}
+		if p, ok := gateway.ProfileOf(gw); ok {
+			ssh.Logf("gateway: firmware %q -> profile %s, skipping [%s]", p.Version, p.Name, strings.Join(p.Skipped(), ", "))
+		}
+		gateway.KeepAPI(old, gw)
+		if sudo {
+			useSudo(ctx, client, gw)
+		}
+		hostname, _ := gw.Identity(ctx)
+		return phaseDetectedMsg{gw: gw, gwType: gwDisplayName(gw.Type()), hostname: hostname}
+	}
+}
+
+// tracedSurveyCmd is surveyCmd with its commands written to the log.
+func (m AppModel) tracedSurveyCmd(p rerunPhase) tea.Cmd {
+	label := phaseTraceLabel(p)
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		sd := m.surveyData(ssh.WithExecTrace(ctx, label))
+		lan := "none"
+		if sd.LAN != nil {
+			lan = sd.LAN.CIDR
+		}
+		ssh.Logf("%s: LAN %s, %d networks", label, lan, len(sd.Networks))
+		return sd
+	}
+}
+
+// applyRedetect replaces the gateway with the re-detected one and runs
+// the survey again under it.
+func (m AppModel) applyRedetect(msg phaseDetectedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		ssh.Logf("%s: %v", phaseTraceLabel(phaseDetect), msg.err)
+		m.rerunning = ""
+		m.survey.SetRerun("Re-running detection failed, previous results kept: " + msg.err.Error())
+		return m, nil
+	}
+	if msg.gwType != m.gatewayType {
+		ssh.Logf("%s: gateway type %s -> %s", phaseTraceLabel(phaseDetect), m.gatewayType, msg.gwType)
+	}
+	m.gw = msg.gw
+	m.gatewayType = msg.gwType
+	if msg.hostname != "" {
+		m.hostname = msg.hostname
+	}
+	return m, m.tracedSurveyCmd(phaseDetect)
+}
@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
+)
+
+// rawOutputMaxLines caps how much of one command's output the debug
+// screen shows; the clipboard copy always has all of it.
+const rawOutputMaxLines = 12
+
+// rawOutputCopiedMsg reports the result of copying the raw output.
+type rawOutputCopiedMsg string
+
+// toggleRawOutput opens or closes the raw gateway output screen.
+func (m AppModel) toggleRawOutput() (tea.Model, tea.Cmd) {
+	m.rawOutput = !m.rawOutput
+	m.rawNotice = ""
+	return m, nil
+}
+
+// updateRawOutput handles keys while the raw output screen is open. It
+// swallows everything else so the screen underneath doesn't react.
+func (m AppModel) updateRawOutput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, DefaultGlobalKeys.Back):
+		return m.toggleRawOutput()
+	case msg.String() == "c":
+		return m, m.copyRawOutputCmd()
+	}
+	return m, nil
+}
+
+// recentExecs returns the connection's recent commands, nil before one
+// is open.
+func (m AppModel) recentExecs() []ssh.ExecRecord {
+	if m.sshClient == nil {
+		return nil
+	}
+	return m.sshClient.RecentOutput()
+}
+
+// formatExecRecord renders one command as it would look in a shell, for
+// pasting into a bug report. maxLines of 0 keeps every line.
+func formatExecRecord(rec ssh.ExecRecord, maxLines int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s $ %s\n", rec.At.Format("15:04:05"), rec.Cmd)
+	lines := strings.Split(strings.TrimRight(rec.Output, "\n"), "\n")
+	if maxLines > 0 && len(lines) > maxLines {
+		more := len(lines) - maxLines
+		lines = append(lines[:maxLines], fmt.Sprintf("... %d more lines", more))
+	}
+	b.WriteString(strings.Join(lines, "\n"))
+	if rec.Err != nil {
+		fmt.Fprintf(&b, "\n(error: %v)", rec.Err)
+	}
+	return b.String()
+}
+
+// copyRawOutputCmd puts every recent command and its full output on the
+// clipboard.
+func (m AppModel) copyRawOutputCmd() tea.Cmd {
+	recs := m.recentExecs()
+	return func() tea.Msg {
+		if len(recs) == 0 {
+			return rawOutputCopiedMsg("No gateway commands to copy")
+		}
+		parts := make([]string, len(recs))
+		for i, rec := range recs {
+			parts[i] = formatExecRecord(rec, 0)
+		}
+		if err := clipboard.WriteAll(strings.Join(parts, "\n\n") + "\n"); err != nil {
+			return rawOutputCopiedMsg("Clipboard unavailable: " + err.Error())
+		}
+		return rawOutputCopiedMsg(fmt.Sprintf("Copied %d commands", len(recs)))
+	}
+}
+
+// rawOutputView shows the last commands run on the gateway, newest at the
+// bottom, exactly as the gateway printed them.
+func (m AppModel) rawOutputView() string {
+	recs := m.recentExecs()
+	var b strings.Builder
+	if len(recs) == 0 {
+		b.WriteString(DimStyle.Render("No gateway commands run yet."))
+	}
+	for i, rec := range recs {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		head, body, _ := strings.Cut(formatExecRecord(rec, rawOutputMaxLines), "\n")
+		b.WriteString(AccentStyle.Render(head))
+		if body != "" {
+			b.WriteString("\n" + body)
+		}
+	}
+	if m.rawNotice != "" {
+		b.WriteString("\n\n" + DimStyle.Render(m.rawNotice))
+	}
+
+	title := fmt.Sprintf("Raw gateway output (last %d commands)", ssh.RecentExecs)
+	panel := renderPanel(title, b.String())
+	bar := renderStatusBar("c: copy all", DefaultGlobalKeys.RawOutput.Help().Key+"/Esc: close")
+	return ContentStyle.Render(panel + "\n" + bar)
+}
@@ -1,12 +1,14 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/406-mot-acceptable/lmtm/internal/discovery"
 	"github.com/406-mot-acceptable/lmtm/internal/tui/components"
 )
 
@@ -14,6 +16,22 @@ import (
 type ScanProgressMsg struct {
 	DevicesFound int
 	Status       string
+
+	next tea.Cmd // waits for the update after this one; see scanProgressCmd
+}
+
+// scanProgressCmd waits for the next update from a running scan. Each
+// update carries the wait for the one after it, so the chain ends when
+// the scan closes ch or the scan screen stops passing it on.
+func scanProgressCmd(ch <-chan ScanProgressMsg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-ch
+		if !ok {
+			return nil
+		}
+		msg.next = scanProgressCmd(ch)
+		return msg
+	}
 }
 
 // ScanDoneMsg signals the scan is complete.
@@ -34,6 +52,7 @@ type ScanModel struct {
 	status       string
 	done         bool
 	err          error
+	public       bool // err is discovery.ErrPublicSubnet, awaiting 'y'
 }
 
 // NewScanModel creates the scan progress screen.
@@ -68,7 +87,11 @@ func (m ScanModel) Update(msg tea.Msg) (ScanModel, tea.Cmd) {
 		m.done = true
 		m.devicesFound = msg.DevicesFound
 		m.elapsed = time.Since(m.startTime)
-		if msg.Err != nil {
+		if errors.Is(msg.Err, discovery.ErrPublicSubnet) {
+			m.err = msg.Err
+			m.public = true
+			m.status = "Scan not started"
+		} else if msg.Err != nil {
 			m.err = msg.Err
 			m.status = "Scan failed"
 		} else {
@@ -101,6 +124,12 @@ func (m ScanModel) Err() error {
 	return m.err
 }
 
+// AwaitingPublic reports whether the scan was refused for a public
+// subnet and is waiting for the user to confirm or go back.
+func (m ScanModel) AwaitingPublic() bool {
+	return m.public
+}
+
 // DevicesFound returns the number of discovered devices.
 func (m ScanModel) DevicesFound() int {
 	return m.devicesFound
@@ -110,7 +139,13 @@ func (m ScanModel) DevicesFound() int {
 func (m ScanModel) View() string {
 	var b strings.Builder
 
-	if m.err != nil {
+	if m.public {
+		b.WriteString(WarningStyle.Render("Not scanned: " + m.err.Error()))
+		b.WriteByte('\n')
+		b.WriteString(DimStyle.Render("This is usually the WAN side, picked up by mistake. Scanning it pings public addresses."))
+		b.WriteByte('\n')
+		b.WriteString(DimStyle.Render("[y] scan it anyway  [Esc] back"))
+	} else if m.err != nil {
 		b.WriteString(ErrorStyle.Render("Error: " + m.err.Error()))
 		b.WriteByte('\n')
 		b.WriteString(DimStyle.Render("[Esc] back"))
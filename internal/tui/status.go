@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+	"github.com/406-mot-acceptable/lmtm/internal/webdash"
+)
+
+// sessionStatus is written to ~/.lmtm/status.json so status bars and tmux
+// can show what the running session is doing ("LMTM: 12 tunnels up").
+type sessionStatus struct {
+	State         string    `json:"state"`
+	Gateway       string    `json:"gateway,omitempty"`
+	TunnelsActive int       `json:"tunnels_active"`
+	TunnelsFailed int       `json:"tunnels_failed"`
+	Updated       time.Time `json:"updated"`
+}
+
+func statusPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".lmtm", "status.json")
+}
+
+// statusStateName returns the stable machine-readable name for a state.
+func statusStateName(s wizardState) string {
+	switch s {
+	case stateConnect:
+		return "connect"
+	case stateDetecting:
+		return "detecting"
+	case stateSurvey:
+		return "survey"
+	case stateScanning:
+		return "scanning"
+	case stateDevices:
+		return "devices"
+	case stateBuilding:
+		return "building"
+	case stateTunnels:
+		return "tunnels"
+	case stateError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// writeStatus records the session's current state and tunnel counts.
+// Best effort -- a status bar is not worth interrupting the session for.
+func (m AppModel) writeStatus() {
+	st := sessionStatus{
+		State:   statusStateName(m.state),
+		Gateway: m.gatewayAddr,
+		Updated: time.Now(),
+	}
+	if m.manager != nil {
+		for _, t := range m.manager.Tunnels() {
+			switch status, _ := t.State(); status {
+			case ssh.StatusActive:
+				st.TunnelsActive++
+			case ssh.StatusFailed:
+				st.TunnelsFailed++
+			}
+		}
+	}
+	_ = store.Save(statusPath(), st)
+}
+
+// dashboardSnapshot mirrors the tunnel dashboard for the web view. It
+// reads the same groups the TUI renders so the two can't disagree.
+func (m AppModel) dashboardSnapshot() webdash.Snapshot {
+	snap := webdash.Snapshot{
+		Gateway:     m.gatewayAddr,
+		Identity:    m.hostname,
+		GatewayType: m.gatewayType,
+		State:       statusStateName(m.state),
+		Started:     m.tunnels.startTime,
+		Devices:     len(m.devices.Entries()),
+		Notes:       m.tunnels.sessionNotes,
+	}
+	conns := make(map[int]int64)
+	if m.manager != nil {
+		for _, t := range m.manager.Tunnels() {
+			conns[t.Port()] = t.ActiveConnections()
+		}
+	}
+	for _, g := range m.tunnels.groups {
+		wg := webdash.Group{Host: g.RemoteHost}
+		if note, ok := m.tunnels.notes.get(g.RemoteHost, m.tunnels.macs[g.RemoteHost]); ok {
+			wg.Note = note.Text
+		}
+		for _, t := range g.Tunnels {
+			wg.Tunnels = append(wg.Tunnels, webdash.Tunnel{
+				LocalPort:   t.LocalPort,
+				RemotePort:  t.RemotePort,
+				Protocol:    t.Protocol,
+				Status:      t.Status.String(),
+				Connections: conns[t.LocalPort],
+				Error:       t.Error,
+			})
+		}
+		snap.Groups = append(snap.Groups, wg)
+	}
+	return snap
+}
+
+// removeStatus deletes the status file on exit so tools don't report a
+// session that is no longer running.
+func removeStatus() {
+	_ = os.Remove(statusPath())
+}
@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
+)
+
+// readStatus decodes the status file the way a status bar script would.
+func readStatus(t *testing.T) sessionStatus {
+	t.Helper()
+	data, err := os.ReadFile(statusPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st sessionStatus
+	if err := json.Unmarshal(data, &st); err != nil {
+		t.Fatalf("status.json is not valid JSON: %v\n%s", err, data)
+	}
+	return st
+}
+
+func TestWriteStatusTransitions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// One tunnel binds, the other finds its port taken.
+	held, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	freePort := free.Addr().(*net.TCPAddr).Port
+	free.Close()
+
+	mgr := ssh.NewManager(ssh.NewClient(), 16)
+	defer mgr.CloseAll()
+
+	m := AppModel{state: stateConnect}
+	start := time.Now()
+	m.writeStatus()
+	if st := readStatus(t); st.State != "connect" || st.Gateway != "" || st.TunnelsActive != 0 || st.Updated.Before(start.Add(-time.Second)) {
+		t.Errorf("connect status = %+v", st)
+	}
+
+	m.state = stateDevices
+	m.gatewayAddr = "192.168.88.1:22"
+	m.writeStatus()
+	if st := readStatus(t); st.State != "devices" || st.Gateway != "192.168.88.1:22" {
+		t.Errorf("devices status = %+v", st)
+	}
+
+	_ = mgr.BuildTunnels([]ssh.TunnelSpec{
+		{RemoteHost: "192.168.88.10", RemotePort: 80, LocalPort: freePort},
+		{RemoteHost: "192.168.88.11", RemotePort: 80, LocalPort: held.Addr().(*net.TCPAddr).Port},
+	})
+	m.state = stateTunnels
+	m.manager = mgr
+	m.writeStatus()
+	if st := readStatus(t); st.State != "tunnels" || st.TunnelsActive != 1 || st.TunnelsFailed != 1 {
+		t.Errorf("tunnels status = %+v, want 1 active and 1 failed", st)
+	}
+
+	// Each write replaces the file whole; nothing is left beside it.
+	entries, err := os.ReadDir(filepath.Dir(statusPath()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "status.json" {
+			t.Errorf("stray file %s next to status.json", e.Name())
+		}
+	}
+}
+
+func TestWriteStatusReplacesUnreadableFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := os.MkdirAll(filepath.Dir(statusPath()), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(statusPath(), []byte(`{"state": "tunn`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	AppModel{state: stateScanning}.writeStatus()
+	if st := readStatus(t); st.State != "scanning" {
+		t.Errorf("status = %+v, want scanning", st)
+	}
+}
@@ -3,7 +3,10 @@ package tui
 import (
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/lipgloss"
+
+	"github.com/406-mot-acceptable/lmtm/internal/discovery"
 )
 
 // Adaptive colors that work on both light and dark terminals.
@@ -20,6 +23,10 @@ var (
 	colorBorder   = lipgloss.AdaptiveColor{Dark: "#3A3A3A", Light: "#CCCCCC"}
 	colorInputBg  = lipgloss.AdaptiveColor{Dark: "#1C1C1C", Light: "#F0F0F0"}
 	colorStatusBg = lipgloss.AdaptiveColor{Dark: "#262626", Light: "#E8E8E8"}
+	colorCyan     = lipgloss.AdaptiveColor{Dark: "#5FD7D7", Light: "#2E8B8B"}
+	colorBlue     = lipgloss.AdaptiveColor{Dark: "#87AFFF", Light: "#3A5FCD"}
+	colorOrange   = lipgloss.AdaptiveColor{Dark: "#FFAF5F", Light: "#B8661B"}
+	colorPink     = lipgloss.AdaptiveColor{Dark: "#FF87D7", Light: "#B0407F"}
 )
 
 // panelBorder is a rounded border for outer panels.
@@ -73,6 +80,10 @@ var ErrorStyle = lipgloss.NewStyle().
 var WarningStyle = lipgloss.NewStyle().
 	Foreground(colorYellow)
 
+// StreamStyle is blue text for media streams such as RTSP.
+var StreamStyle = lipgloss.NewStyle().
+	Foreground(colorBlue)
+
 // SelectedStyle is the highlighted row in lists.
 var SelectedStyle = lipgloss.NewStyle().
 	Foreground(colorFg).
@@ -126,6 +137,13 @@ var InnerPanelStyle = lipgloss.NewStyle().
 	BorderForeground(colorDim).
 	Padding(0, 1)
 
+// TourStyle boxes the guided tour's hints apart from the screen itself.
+var TourStyle = lipgloss.NewStyle().
+	BorderStyle(lipgloss.DoubleBorder()).
+	BorderForeground(colorPrimary).
+	Padding(0, 1).
+	Margin(0, 2)
+
 // StatusBarStyle is the bottom status bar.
 var StatusBarStyle = lipgloss.NewStyle().
 	Foreground(colorFg).
@@ -143,6 +161,26 @@ var AccentStyle = lipgloss.NewStyle().
 	Foreground(colorPrimary).
 	Bold(true)
 
+// classStyles colors a dashboard host by device class so cameras and
+// network gear stand apart at a glance. Classes without an entry use
+// ActiveStyle.
+var classStyles = map[string]lipgloss.Style{
+	discovery.ClassCamera.String():        lipgloss.NewStyle().Foreground(colorCyan).Bold(true),
+	discovery.ClassNVR.String():           lipgloss.NewStyle().Foreground(colorBlue).Bold(true),
+	discovery.ClassRouter.String():        lipgloss.NewStyle().Foreground(colorOrange).Bold(true),
+	discovery.ClassNetworkDevice.String(): lipgloss.NewStyle().Foreground(colorOrange).Bold(true),
+	discovery.ClassServer.String():        lipgloss.NewStyle().Foreground(colorPink).Bold(true),
+}
+
+// classStyle returns the host style for a device class label as carried
+// on ssh.TunnelSpec.
+func classStyle(class string) lipgloss.Style {
+	if s, ok := classStyles[class]; ok {
+		return s
+	}
+	return ActiveStyle
+}
+
 // renderPanel wraps content in a bordered panel with a title in the top border.
 func renderPanel(title, content string) string {
 	titleStr := " " + AccentStyle.Render(title) + " "
@@ -184,3 +222,47 @@ func renderStatusBar(items ...string) string {
 	sep := DimStyle.Render(" | ")
 	return StatusBarStyle.Render(strings.Join(items, sep))
 }
+
+// statusBarWidth keeps a status bar, with its padding and ContentStyle's,
+// inside an 80-column terminal.
+const statusBarWidth = 76
+
+// renderWrappedStatusBar renders items like renderStatusBar, starting a
+// new bar whenever the next item would run past statusBarWidth.
+func renderWrappedStatusBar(items ...string) string {
+	const sep = 3 // " | "
+	avail := statusBarWidth - StatusBarStyle.GetHorizontalPadding()
+	var bars []string
+	var line []string
+	width := 0
+	for _, item := range items {
+		w := lipgloss.Width(item)
+		if len(line) > 0 && width+sep+w > avail {
+			bars = append(bars, renderStatusBar(line...))
+			line, width = nil, 0
+		}
+		if len(line) > 0 {
+			width += sep
+		}
+		line = append(line, item)
+		width += w
+	}
+	if len(line) > 0 {
+		bars = append(bars, renderStatusBar(line...))
+	}
+	return strings.Join(bars, "\n")
+}
+
+// keyHints turns key binding groups into "key: action" status bar items.
+func keyHints(groups ...[][]key.Binding) []string {
+	var hints []string
+	for _, g := range groups {
+		for _, row := range g {
+			for _, b := range row {
+				h := b.Help()
+				hints = append(hints, h.Key+": "+h.Desc)
+			}
+		}
+	}
+	return hints
+}
@@ -9,7 +9,17 @@ import (
 )
 
 // ScanRequestMsg is sent when the user presses Enter to start scanning.
-type ScanRequestMsg struct{}
+// Networks lists the checked LANs on a multi-LAN gateway; it is empty
+// when the gateway has a single LAN.
+type ScanRequestMsg struct {
+	Networks []LANConfig
+}
+
+// NoticeAckMsg is sent when the user acknowledges the login banner.
+type NoticeAckMsg struct{}
+
+// noticeHeight is the number of banner lines visible at once.
+const noticeHeight = 15
 
 // WANConfig holds WAN interface details for display.
 type WANConfig struct {
@@ -25,6 +35,9 @@ type LANConfig struct {
 	Gateway   string
 	DHCPStart string
 	DHCPEnd   string
+	Warning   string // e.g. scan limited to a /24 of a wider LAN
+	Prefix    string // 3-octet prefix the scan covers, e.g. "10.0.0"
+	PoolSize  int    // DHCP addresses, 0 if unknown
 }
 
 // SurveyModel displays the network survey results.
@@ -35,7 +48,24 @@ type SurveyModel struct {
 	wan         *WANConfig
 	lan         *LANConfig
 	keys        NavigationKeys
+	selKeys     SelectionKeys
 	globals     GlobalKeys
+
+	// Every LAN on a multi-LAN gateway, with the ones to scan checked.
+	// Empty for single-LAN gateways, which show lan alone as before.
+	networks  []LANConfig
+	checked   []bool
+	netCursor int
+
+	// Login banner overlay, shown until acknowledged.
+	notice       []string
+	noticeOffset int
+
+	// Progress or outcome of a phase re-run ('D' or 'R').
+	rerun string
+
+	// Set when another instance is connected to the same gateway.
+	warning string
 }
 
 // NewSurveyModel creates the survey display screen.
@@ -47,10 +77,60 @@ func NewSurveyModel(gateway, gatewayType, hostname string, wan *WANConfig, lan *
 		wan:         wan,
 		lan:         lan,
 		keys:        DefaultNavigationKeys,
+		selKeys:     DefaultSelectionKeys,
 		globals:     DefaultGlobalKeys,
 	}
 }
 
+// SetNetworks lists every LAN found on the gateway for the user to pick
+// from. Fewer than two leaves the single-LAN display alone. The network
+// with the largest DHCP pool starts checked, else the first.
+func (m *SurveyModel) SetNetworks(nets []LANConfig) {
+	if len(nets) < 2 {
+		return
+	}
+	m.networks = nets
+	m.checked = make([]bool, len(nets))
+	best := 0
+	for i, n := range nets {
+		if n.PoolSize > nets[best].PoolSize {
+			best = i
+		}
+	}
+	m.checked[best] = true
+	m.netCursor = best
+}
+
+// selectedNetworks returns the checked LANs in list order.
+func (m SurveyModel) selectedNetworks() []LANConfig {
+	var sel []LANConfig
+	for i, n := range m.networks {
+		if m.checked[i] {
+			sel = append(sel, n)
+		}
+	}
+	return sel
+}
+
+// ShowNotice displays the gateway's login banner over the survey until
+// the user acknowledges it.
+func (m *SurveyModel) ShowNotice(banner string) {
+	banner = strings.ReplaceAll(strings.TrimSpace(banner), "\r\n", "\n")
+	m.notice = strings.Split(banner, "\n")
+	m.noticeOffset = 0
+}
+
+// SetWarning shows a warning under the survey, such as another instance
+// being connected to the same gateway.
+func (m *SurveyModel) SetWarning(text string) {
+	m.warning = text
+}
+
+// SetRerun shows the progress or outcome of a phase re-run.
+func (m *SurveyModel) SetRerun(status string) {
+	m.rerun = status
+}
+
 // Init does nothing for the survey screen.
 func (m SurveyModel) Init() tea.Cmd {
 	return nil
@@ -60,16 +140,63 @@ func (m SurveyModel) Init() tea.Cmd {
 func (m SurveyModel) Update(msg tea.Msg) (SurveyModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.notice != nil {
+			return m.updateNotice(msg)
+		}
 		switch {
+		case key.Matches(msg, m.keys.Up) && len(m.networks) > 0:
+			if m.netCursor > 0 {
+				m.netCursor--
+			}
+		case key.Matches(msg, m.keys.Down) && len(m.networks) > 0:
+			if m.netCursor < len(m.networks)-1 {
+				m.netCursor++
+			}
+		case key.Matches(msg, m.selKeys.Toggle) && len(m.networks) > 0:
+			m.checked[m.netCursor] = !m.checked[m.netCursor]
+		case key.Matches(msg, key.NewBinding(key.WithKeys("D"))):
+			return m, func() tea.Msg { return RerunPhaseMsg{Phase: phaseDetect} }
+		case key.Matches(msg, key.NewBinding(key.WithKeys("R"))):
+			return m, func() tea.Msg { return RerunPhaseMsg{Phase: phaseSurvey} }
 		case key.Matches(msg, m.keys.Enter):
-			return m, func() tea.Msg { return ScanRequestMsg{} }
+			if len(m.networks) == 0 {
+				return m, func() tea.Msg { return ScanRequestMsg{} }
+			}
+			sel := m.selectedNetworks()
+			if len(sel) == 0 {
+				return m, nil
+			}
+			return m, func() tea.Msg { return ScanRequestMsg{Networks: sel} }
 		}
 	}
 	return m, nil
 }
 
+// updateNotice scrolls the login banner and dismisses it on Enter.
+func (m SurveyModel) updateNotice(msg tea.KeyMsg) (SurveyModel, tea.Cmd) {
+	maxOffset := max(len(m.notice)-noticeHeight, 0)
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.noticeOffset > 0 {
+			m.noticeOffset--
+		}
+	case key.Matches(msg, m.keys.Down):
+		if m.noticeOffset < maxOffset {
+			m.noticeOffset++
+		}
+	case key.Matches(msg, m.keys.Enter):
+		m.notice = nil
+		return m, func() tea.Msg { return NoticeAckMsg{} }
+	}
+	return m, nil
+}
+
 // View renders the tree-style network survey display.
 func (m SurveyModel) View() string {
+	if m.notice != nil {
+		return m.noticeView()
+	}
+
 	var b strings.Builder
 
 	// Gateway summary line.
@@ -99,12 +226,18 @@ func (m SurveyModel) View() string {
 
 	// LAN section in inner panel.
 	var lan strings.Builder
-	if m.lan != nil {
+	if len(m.networks) > 0 {
+		lan.WriteString(m.networkList())
+	} else if m.lan != nil {
 		lan.WriteString(m.treeLine(false, "Interface", m.lan.Interface))
 		lan.WriteString(m.treeLine(false, "Subnet", m.lan.Subnet))
 		lan.WriteString(m.treeLine(false, "Gateway", m.lan.Gateway))
 		dhcp := m.lan.DHCPStart + " - " + m.lan.DHCPEnd
 		lan.WriteString(m.treeLine(true, "DHCP Pool", dhcp))
+		if m.lan.Warning != "" {
+			lan.WriteString(WarningStyle.Render(m.lan.Warning))
+			lan.WriteByte('\n')
+		}
 	} else {
 		lan.WriteString(m.treeLine(true, "Status", "not available"))
 	}
@@ -112,11 +245,68 @@ func (m SurveyModel) View() string {
 		ActiveStyle.Render("LAN") + "\n" + lan.String(),
 	))
 
+	if m.warning != "" {
+		b.WriteString("\n" + WarningStyle.Render(m.warning))
+	}
+	if m.rerun != "" {
+		b.WriteString("\n" + DimStyle.Render(m.rerun))
+	}
+
 	panel := renderPanel("Network Survey", b.String())
 
 	// Status bar.
-	bar := renderStatusBar("Enter: scan network", "Esc: disconnect")
+	bar := renderStatusBar("Enter: scan network", "D/R: re-run detection/survey", "Esc: disconnect")
+	if len(m.networks) > 0 {
+		bar = renderStatusBar(fmt.Sprintf("%d/%d networks", len(m.selectedNetworks()), len(m.networks)),
+			"Space: toggle", "Enter: scan selected", "D/R: re-run detection/survey", "Esc: disconnect")
+	}
+
+	return ContentStyle.Render(panel + "\n" + bar)
+}
+
+// networkList renders one checkbox line per LAN on a multi-LAN gateway.
+func (m SurveyModel) networkList() string {
+	var b strings.Builder
+	for i, n := range m.networks {
+		cursor := "  "
+		if i == m.netCursor {
+			cursor = AccentStyle.Render("> ")
+		}
+		box := "[ ]"
+		if m.checked[i] {
+			box = SuccessStyle.Render("[x]")
+		}
+		line := fmt.Sprintf(" %-10s %-18s", n.Interface, n.Subnet)
+		if n.DHCPStart != "" {
+			line += DimStyle.Render(fmt.Sprintf(" DHCP %s - %s", n.DHCPStart, n.DHCPEnd))
+		}
+		b.WriteString(cursor + box + line + "\n")
+		if n.Warning != "" && m.checked[i] {
+			b.WriteString("      " + WarningStyle.Render(n.Warning) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// noticeView renders the login banner with a scroll window.
+func (m SurveyModel) noticeView() string {
+	var b strings.Builder
+	b.WriteString(DimStyle.Render("The gateway presented this notice at login:"))
+	b.WriteString("\n\n")
+
+	end := min(m.noticeOffset+noticeHeight, len(m.notice))
+	for _, line := range m.notice[m.noticeOffset:end] {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	if len(m.notice) > noticeHeight {
+		b.WriteString(DimStyle.Render(fmt.Sprintf(
+			"[%d-%d of %d]", m.noticeOffset+1, end, len(m.notice))))
+		b.WriteByte('\n')
+	}
 
+	panel := renderPanel("Login Banner", b.String())
+	bar := renderStatusBar("Up/Down: scroll", "Enter: acknowledge", "Esc: disconnect")
 	return ContentStyle.Render(panel + "\n" + bar)
 }
 
@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/406-mot-acceptable/lmtm/internal/store"
+)
+
+// tourHints is the guided tour: one hint per wizard screen, shown under
+// it while the tour is on. Keep the text here so a reworded screen only
+// needs its hint touched in one place.
+var tourHints = map[wizardState]string{
+	stateConnect: "Enter the gateway's address and its SSH login. The password is " +
+		"never saved -- press Enter to connect.",
+	stateDetecting: "lmtm is logging in and working out whether this is a MikroTik " +
+		"or a Ubiquiti. This takes a few seconds.",
+	stateSurvey: "This shows what the router knows about its own network: its WAN " +
+		"address and the LAN it serves. Press Enter to look for devices on that LAN.",
+	stateScanning: "The gateway pings every address on the LAN and reads back its ARP " +
+		"table. Devices that never answer a ping still show up if the router has seen them.",
+	stateDevices: "Pick the devices you need with Space, then press Enter. Each one " +
+		"gets a local port per service, e.g. its web page on localhost:4435.",
+	stateBuilding: "lmtm is opening a local port for every forward you picked. Ports " +
+		"that fail are listed with the reason; the rest keep working.",
+	stateTunnels: "Your tunnels are up. Click a localhost link or open it in a browser; " +
+		"the device sees the connection coming from the gateway. q disconnects.",
+	stateError: "Something went wrong talking to the gateway. Read the error, then " +
+		"press r to start over, or b to write a bug report for the team.",
+}
+
+// tourMinWidth and tourMinSpare are the smallest terminal width, and the
+// spare rows under a screen, that still fit the hint box. Below either
+// the hint collapses to one status-bar line.
+const (
+	tourMinWidth = 60
+	tourMinSpare = 5
+)
+
+// tourState is what the tour remembers between launches.
+type tourState struct {
+	Dismissed bool `json:"dismissed"`
+}
+
+func tourPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".tunneler", "cache", "tour.json")
+}
+
+// tourDismissed reports whether the tour was turned off in an earlier
+// session. A first run has no file and gets the tour.
+func tourDismissed() bool {
+	var s tourState
+	if err := store.Load(tourPath(), &s); err != nil {
+		return false
+	}
+	return s.Dismissed
+}
+
+// dismissTour turns the tour off for later launches too.
+func dismissTour() error {
+	return store.Save(tourPath(), &tourState{Dismissed: true})
+}
+
+// withTourHint adds the current screen's hint to view: as a box below it
+// when the terminal has room, otherwise as a single status-bar line. A
+// zero width or height means the size isn't known yet and is treated as
+// roomy.
+func withTourHint(view string, state wizardState, width, height int) string {
+	hint, ok := tourHints[state]
+	if !ok {
+		return view
+	}
+	dismiss := DefaultGlobalKeys.Tour.Help().Key + ": end tour"
+
+	small := width > 0 && width < tourMinWidth ||
+		height > 0 && height-lipgloss.Height(view) < tourMinSpare
+	if small {
+		line := "Tour: " + hint
+		if width > 0 {
+			limit := width - len(dismiss) - 6 // status bar padding and separator
+			if limit < 10 {
+				limit = 10
+			}
+			if r := []rune(line); len(r) > limit {
+				line = string(r[:limit-3]) + "..."
+			}
+		}
+		return view + "\n" + renderStatusBar(line, dismiss)
+	}
+
+	boxWidth := tourMinWidth
+	if width > 0 {
+		boxWidth = min(width-8, 80)
+	}
+	text := TourStyle.Width(boxWidth).Render(
+		AccentStyle.Render("Tour") + "\n" + hint + "\n" + DimStyle.Render(dismiss))
+	return strings.TrimRight(view, "\n") + "\n" + text
+}
@@ -0,0 +1,86 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestTourToggle(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctrlT := tea.KeyMsg{Type: tea.KeyCtrlT}
+
+	m := NewAppModel()
+	if !m.tour {
+		t.Fatal("first run started without the tour")
+	}
+	next, _ := m.update(ctrlT)
+	if m = next.(AppModel); m.tour || !tourDismissed() {
+		t.Errorf("after Ctrl+T: tour %v, dismissed %v; want off and dismissed", m.tour, tourDismissed())
+	}
+	if NewAppModel().tour {
+		t.Error("a dismissed tour came back on the next launch")
+	}
+
+	// Bringing it back is for this session only.
+	next, _ = m.update(ctrlT)
+	if m = next.(AppModel); !m.tour || !tourDismissed() {
+		t.Errorf("after a second Ctrl+T: tour %v, dismissed %v; want on and still dismissed", m.tour, tourDismissed())
+	}
+	if !strings.Contains(m.View(), tourHints[stateConnect][:20]) {
+		t.Error("connect screen shows no tour hint with the tour on")
+	}
+}
+
+func TestTourHintsCoverEveryScreen(t *testing.T) {
+	for state := stateConnect; state <= stateError; state++ {
+		if tourHints[state] == "" {
+			t.Errorf("state %d has no tour hint", state)
+		}
+	}
+	if got := withTourHint("view", wizardState(99), 100, 40); got != "view" {
+		t.Errorf("state without a hint rendered %q", got)
+	}
+}
+
+func TestTourHintLayout(t *testing.T) {
+	view := "line 1\nline 2\nline 3"
+	dismiss := DefaultGlobalKeys.Tour.Help().Key + ": end tour"
+
+	tests := []struct {
+		name          string
+		width, height int
+		box           bool
+	}{
+		{"size unknown", 0, 0, true},
+		{"roomy", 100, 40, true},
+		{"narrow", 50, 40, false},
+		{"short", 100, 6, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withTourHint(view, stateDevices, tt.width, tt.height)
+			if !strings.HasPrefix(got, view+"\n") || !strings.Contains(got, dismiss) {
+				t.Fatalf("hint not added under the view:\n%s", got)
+			}
+			extra := lipgloss.Height(got) - lipgloss.Height(view)
+			if tt.box {
+				if extra < 4 {
+					t.Errorf("box added %d lines, want the title, hint and dismiss key", extra)
+				}
+				return
+			}
+			if extra != 1 {
+				t.Errorf("status-bar hint added %d lines, want 1", extra)
+			}
+			if !strings.Contains(got, "Tour: ") {
+				t.Errorf("status-bar hint lacks its label:\n%s", got)
+			}
+			if tt.width > 0 && lipgloss.Width(got) > tt.width {
+				t.Errorf("status-bar hint is %d wide on a %d-column terminal", lipgloss.Width(got), tt.width)
+			}
+		})
+	}
+}
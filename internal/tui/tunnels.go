@@ -8,6 +8,11 @@ import (
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/406-mot-acceptable/lmtm/internal/browser"
+	"github.com/406-mot-acceptable/lmtm/internal/config"
+	"github.com/406-mot-acceptable/lmtm/internal/discovery"
+	"github.com/406-mot-acceptable/lmtm/internal/health"
+	"github.com/406-mot-acceptable/lmtm/internal/portmap"
 	"github.com/406-mot-acceptable/lmtm/internal/ssh"
 	"github.com/406-mot-acceptable/lmtm/internal/tui/components"
 )
@@ -23,21 +28,105 @@ type DisconnectMsg struct{}
 // ReconnectMsg signals the user wants to reconnect failed tunnels.
 type ReconnectMsg struct{}
 
+// CloseGroupMsg asks the app to close the tunnels on these local ports.
+type CloseGroupMsg struct {
+	Host  string
+	Ports []int
+}
+
+// RebuildGroupMsg asks the app to restart the inactive tunnels on these
+// local ports.
+type RebuildGroupMsg struct {
+	Ports []int
+}
+
+// tunnelNoticeMsg is a one-line message shown under the dashboard until
+// the next key press.
+type tunnelNoticeMsg string
+
+// closeConfirmAbove is the group size above which 'x' asks for confirmation.
+const closeConfirmAbove = 3
+
+// HealthProbeMsg asks the app to probe the HTTP/HTTPS tunnels. Gen ties it
+// to one enable of the probe toggle so stale ticks are dropped.
+type HealthProbeMsg struct {
+	Gen int
+}
+
+// HealthResultMsg carries probe results keyed by local port.
+type HealthResultMsg struct {
+	Gen         int
+	Results     map[int]health.Result
+	CertChanges map[int]health.CertChange // HTTPS ports whose certificate no longer matches its pin
+	RTSP        map[int]rtspCheck
+}
+
+// rtspCheck is an RTSP probe of one camera tunnel, with the vendor whose
+// stream path was tried ("" for the bare root).
+type rtspCheck struct {
+	health.RTSPResult
+	vendor string
+}
+
+// AcceptCertsMsg asks the app to re-pin these changed certificates.
+type AcceptCertsMsg struct {
+	Changes []health.CertChange
+}
+
+// RTSPPlaylistMsg asks the app to write a playlist of the active RTSP
+// tunnels and open it in the default player.
+type RTSPPlaylistMsg struct{}
+
+// WebDashboardMsg asks the app to start or stop the read-only web
+// dashboard.
+type WebDashboardMsg struct{}
+
+// GatewaySOCKSMsg asks the app to start or stop the SOCKS5 proxy that
+// reaches any address through the gateway.
+type GatewaySOCKSMsg struct{}
+
+// CopySSHCommandMsg asks for the session's equivalent OpenSSH command.
+type CopySSHCommandMsg struct{}
+
+// CopyPortTableMsg asks for the local-to-remote port table on the
+// clipboard, for documenting the session on a ticket.
+type CopyPortTableMsg struct{}
+
+// CycleQoSMsg asks for the next DSCP marking on the gateway connection.
+type CycleQoSMsg struct{}
+
+// PruneDeadMsg toggles closing tunnels whose device doesn't answer.
+type PruneDeadMsg struct{}
+
 // tunnelTickMsg is the elapsed time ticker.
 type tunnelTickMsg time.Time
 
 // tunnelGroup groups tunnels by remote device.
 type tunnelGroup struct {
 	RemoteHost string
+	Class      string // device class label, picks the host color
+	Vendor     string // picks the RTSP stream path in links
 	Tunnels    []tunnelEntry
+	Proxy      *proxyEntry // nested SOCKS5 proxy through the device, if any
+}
+
+// proxyEntry is a nested SOCKS5 proxy shown under its device.
+type proxyEntry struct {
+	ListenPort int
+	Status     ssh.TunnelStatus
+	Error      string
 }
 
 // tunnelEntry is a single tunnel in the dashboard.
 type tunnelEntry struct {
 	LocalPort  int
+	SpecPort   int // the TunnelSpec's port; events find the entry by it
 	RemotePort int
+	Protocol   string // from portmap.Protocol, e.g. "HTTPS"
 	Status     ssh.TunnelStatus
 	Error      string
+	Exposed    bool // also reachable on the LAN address; see ssh exposure checks
+	Verified   bool // a forwarded connection has reached the device
 }
 
 // TunnelsModel is the active tunnel dashboard.
@@ -46,8 +135,59 @@ type TunnelsModel struct {
 	startTime  time.Time
 	elapsed    time.Duration
 	tunnelKeys TunnelKeys
+	navKeys    NavigationKeys
 	globals    GlobalKeys
 	milestone  string
+	compact    bool // one line per device instead of the grouped tree
+
+	// Group operations act on the group under the cursor.
+	cursor       int
+	confirmClose bool   // 'x' pressed on a large group, awaiting y/x
+	notice       string // cleared on the next key press
+
+	// HTTP health probing, off by default to avoid waking sleepy devices.
+	probing  bool
+	probeGen int
+	health   map[int]health.Result // by local port
+	certs    map[int]health.CertChange
+	rtsp     map[int]rtspCheck
+
+	// Forwards that differ from the device selection. Expanded on the
+	// first render when there are any; 'd' collapses it.
+	specDiff []ssh.SpecChange
+	showDiff bool
+
+	// OpenSSH command overlay, shown while non-empty.
+	sshCommand string
+	sshCopied  bool
+
+	// Notes: the session's own, and the device notes shared with the
+	// device list. macs maps a group's host to its MAC for the note key.
+	sessionNotes string
+	notes        *deviceNotes
+	macs         map[string]string
+	editing      bool // note editor open; ip is empty for session notes
+	note         noteEditor
+
+	// Peak gateway load hint for the status bar, set by the app.
+	gatewayLoad string
+
+	// Degraded-session warning, set by the app from the manager's
+	// health ratio; "" while healthy.
+	degraded string
+
+	// Disconnect drain progress, set by the app; "" unless draining.
+	draining string
+
+	// DSCP marking of the gateway connection, e.g. "AF41"; set by the app.
+	qos string
+
+	// Open every web tunnel once a build finishes; toggled with 'B'.
+	autoOpen bool
+
+	// Port of the SOCKS5 proxy through the gateway, 0 while it's off;
+	// set by the app.
+	socksPort int
 }
 
 // NewTunnelsModel creates the active tunnel dashboard from the current tunnels.
@@ -57,6 +197,7 @@ func NewTunnelsModel(tunnels []*ssh.Tunnel) TunnelsModel {
 		groups:     groups,
 		startTime:  time.Now(),
 		tunnelKeys: DefaultTunnelKeys,
+		navKeys:    DefaultNavigationKeys,
 		globals:    DefaultGlobalKeys,
 	}
 }
@@ -70,17 +211,174 @@ func (m TunnelsModel) Init() tea.Cmd {
 func (m TunnelsModel) Update(msg tea.Msg) (TunnelsModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.editing {
+			return m.updateNote(msg)
+		}
+		if m.sshCommand != "" {
+			// Any key dismisses the overlay.
+			m.sshCommand = ""
+			return m, nil
+		}
+		m.notice = ""
+		if m.confirmClose {
+			m.confirmClose = false
+			if msg.String() == "y" || key.Matches(msg, m.tunnelKeys.CloseGroup) {
+				return m, m.closeGroupCmd()
+			}
+			return m, nil
+		}
 		switch {
 		case key.Matches(msg, m.globals.Quit):
 			return m, func() tea.Msg { return DisconnectMsg{} }
+		case key.Matches(msg, m.navKeys.Up):
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case key.Matches(msg, m.navKeys.Down):
+			if m.cursor < len(m.groups)-1 {
+				m.cursor++
+			}
+		case key.Matches(msg, m.tunnelKeys.CloseGroup):
+			g, ok := m.selectedGroup()
+			if !ok {
+				return m, nil
+			}
+			if len(g.Tunnels) > closeConfirmAbove {
+				m.confirmClose = true
+				return m, nil
+			}
+			return m, m.closeGroupCmd()
 		case key.Matches(msg, m.tunnelKeys.Reconnect):
 			return m, func() tea.Msg { return ReconnectMsg{} }
+		case key.Matches(msg, m.tunnelKeys.RebuildGroup):
+			g, ok := m.selectedGroup()
+			if !ok {
+				return m, nil
+			}
+			ports := g.localPorts()
+			return m, func() tea.Msg { return RebuildGroupMsg{Ports: ports} }
+		case key.Matches(msg, m.tunnelKeys.OpenWeb):
+			g, ok := m.selectedGroup()
+			if !ok {
+				return m, nil
+			}
+			url := g.webURL()
+			if url == "" {
+				m.notice = "No HTTP or HTTPS tunnel for " + g.RemoteHost
+				return m, nil
+			}
+			return m, func() tea.Msg {
+				if err := browser.Open(url); err != nil {
+					return tunnelNoticeMsg(err.Error())
+				}
+				return nil
+			}
+		case key.Matches(msg, m.tunnelKeys.OpenAll):
+			return m.openAll()
+		case key.Matches(msg, m.tunnelKeys.AutoOpen):
+			m.autoOpen = !m.autoOpen
+			if err := saveAutoOpen(m.autoOpen); err != nil {
+				m.notice = "Could not save the setting: " + err.Error()
+			} else if m.autoOpen {
+				m.notice = "Web tunnels will open in the browser after each build"
+			} else {
+				m.notice = "Web tunnels will no longer open after builds"
+			}
+			return m, nil
+		case key.Matches(msg, m.tunnelKeys.AcceptCert):
+			g, ok := m.selectedGroup()
+			if !ok {
+				return m, nil
+			}
+			var changes []health.CertChange
+			for _, t := range g.Tunnels {
+				if c, ok := m.certs[t.LocalPort]; ok {
+					changes = append(changes, c)
+					delete(m.certs, t.LocalPort)
+				}
+			}
+			if len(changes) == 0 {
+				return m, nil
+			}
+			return m, func() tea.Msg { return AcceptCertsMsg{Changes: changes} }
+		case key.Matches(msg, m.tunnelKeys.SpecDiff):
+			if len(m.specDiff) > 0 {
+				m.showDiff = !m.showDiff
+			}
+			return m, nil
+		case key.Matches(msg, m.tunnelKeys.Playlist):
+			if !m.hasRTSP() {
+				m.notice = "No RTSP tunnels for a playlist"
+				return m, nil
+			}
+			return m, func() tea.Msg { return RTSPPlaylistMsg{} }
+		case key.Matches(msg, m.tunnelKeys.WebDash):
+			return m, func() tea.Msg { return WebDashboardMsg{} }
+		case key.Matches(msg, m.tunnelKeys.Compact):
+			m.compact = !m.compact
+			compact := m.compact
+			if err := config.UpdatePrefs(func(p *config.Prefs) { p.Compact = compact }); err != nil {
+				m.notice = "Could not save the setting: " + err.Error()
+			}
+			return m, nil
+		case key.Matches(msg, m.tunnelKeys.CopySSH):
+			return m, func() tea.Msg { return CopySSHCommandMsg{} }
+		case key.Matches(msg, m.tunnelKeys.PortTable):
+			return m, func() tea.Msg { return CopyPortTableMsg{} }
+		case key.Matches(msg, m.tunnelKeys.QoS):
+			return m, func() tea.Msg { return CycleQoSMsg{} }
+		case key.Matches(msg, m.tunnelKeys.PruneDead):
+			return m, func() tea.Msg { return PruneDeadMsg{} }
+		case key.Matches(msg, m.tunnelKeys.SOCKS):
+			return m, func() tea.Msg { return GatewaySOCKSMsg{} }
+		case key.Matches(msg, m.tunnelKeys.Notes):
+			m.note = newNoteEditor("Session Notes", m.sessionNotes, maxSessionNoteLen)
+			m.editing = true
+			return m, nil
+		case key.Matches(msg, m.tunnelKeys.DeviceNote):
+			g, ok := m.selectedGroup()
+			if !ok || m.notes == nil {
+				return m, nil
+			}
+			mac := m.macs[g.RemoteHost]
+			note, _ := m.notes.get(g.RemoteHost, mac)
+			m.note = newNoteEditor("Note -- "+g.RemoteHost, note.Text, discovery.MaxNoteLen)
+			m.note.ip, m.note.mac = g.RemoteHost, mac
+			m.editing = true
+			return m, nil
+		case key.Matches(msg, m.tunnelKeys.Health):
+			m.probing = !m.probing
+			m.probeGen++
+			if !m.probing {
+				m.health = nil
+				m.certs = nil
+				m.rtsp = nil
+				return m, nil
+			}
+			gen := m.probeGen
+			return m, func() tea.Msg { return HealthProbeMsg{Gen: gen} }
+		}
+
+	case HealthResultMsg:
+		if !m.probing || msg.Gen != m.probeGen {
+			return m, nil
 		}
+		m.health = msg.Results
+		m.certs = msg.CertChanges
+		m.rtsp = msg.RTSP
+		gen := m.probeGen
+		return m, tea.Tick(healthProbeInterval, func(time.Time) tea.Msg {
+			return HealthProbeMsg{Gen: gen}
+		})
 
 	case TunnelUpdateMsg:
 		m.applyUpdate(msg.Event)
 		return m, nil
 
+	case tunnelNoticeMsg:
+		m.notice = string(msg)
+		return m, nil
+
 	case tunnelTickMsg:
 		m.elapsed = time.Since(m.startTime)
 		return m, m.tickCmd()
@@ -89,23 +387,147 @@ func (m TunnelsModel) Update(msg tea.Msg) (TunnelsModel, tea.Cmd) {
 	return m, nil
 }
 
+// updateNote handles keys while a note editor is open.
+func (m TunnelsModel) updateNote(msg tea.KeyMsg) (TunnelsModel, tea.Cmd) {
+	var result noteResult
+	m.note, result = m.note.Update(msg)
+	switch result {
+	case noteSaved:
+		m.editing = false
+		if m.note.ip == "" {
+			m.sessionNotes = strings.TrimSpace(m.note.Value())
+			return m, nil
+		}
+		return m, m.notes.set(m.note.ip, m.note.mac, m.note.Value())
+	case noteCancelled:
+		m.editing = false
+	}
+	return m, nil
+}
+
+// SetNotes carries the session notes over from a previous dashboard and
+// shares the device notes with the device list.
+func (m *TunnelsModel) SetNotes(session string, notes *deviceNotes, macs map[string]string) {
+	m.sessionNotes = session
+	m.notes = notes
+	m.macs = macs
+}
+
+// ShowSSHCommand displays the generated OpenSSH command in an overlay.
+// copied reports whether it also made it onto the clipboard.
+func (m *TunnelsModel) ShowSSHCommand(command string, copied bool) {
+	m.sshCommand = command
+	m.sshCopied = copied
+}
+
+// SetSpecDiff records how the built tunnels differ from the selection and
+// expands the section if there is anything to show.
+func (m *TunnelsModel) SetSpecDiff(diff []ssh.SpecChange) {
+	m.specDiff = diff
+	m.showDiff = len(diff) > 0
+}
+
+// hasRTSP reports whether any tunnel forwards an RTSP port.
+func (m TunnelsModel) hasRTSP() bool {
+	for _, g := range m.groups {
+		for _, t := range g.Tunnels {
+			if t.Protocol == "RTSP" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// openAll opens every web tunnel in the browser, as 'O' does.
+func (m TunnelsModel) openAll() (TunnelsModel, tea.Cmd) {
+	urls := m.webURLs()
+	if len(urls) == 0 {
+		m.notice = "No HTTP or HTTPS tunnels to open"
+		return m, nil
+	}
+	m.notice = fmt.Sprintf("Opening %d tabs, %d at a time...", len(urls), browser.DefaultBatchSize)
+	return m, openAllCmd(urls)
+}
+
+// selectedGroup returns the group under the cursor.
+func (m TunnelsModel) selectedGroup() (tunnelGroup, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.groups) {
+		return tunnelGroup{}, false
+	}
+	return m.groups[m.cursor], true
+}
+
+// closeGroupCmd asks the app to close the group under the cursor.
+func (m TunnelsModel) closeGroupCmd() tea.Cmd {
+	g, ok := m.selectedGroup()
+	if !ok {
+		return nil
+	}
+	ports := g.localPorts()
+	host := g.RemoteHost
+	return func() tea.Msg { return CloseGroupMsg{Host: host, Ports: ports} }
+}
+
+// AddProxy lists a nested proxy under its device's group, as connecting.
+func (m *TunnelsModel) AddProxy(host string, listenPort int) {
+	for gi := range m.groups {
+		if m.groups[gi].RemoteHost == host {
+			m.groups[gi].Proxy = &proxyEntry{ListenPort: listenPort, Status: ssh.StatusConnecting}
+			return
+		}
+	}
+}
+
+// SetProxyStatus updates the nested proxy under host's group.
+func (m *TunnelsModel) SetProxyStatus(host string, status ssh.TunnelStatus, err error) {
+	for gi := range m.groups {
+		if p := m.groups[gi].Proxy; m.groups[gi].RemoteHost == host && p != nil {
+			p.Status = status
+			p.Error = ""
+			if err != nil {
+				p.Error = err.Error()
+			}
+			return
+		}
+	}
+}
+
 // applyUpdate updates a tunnel entry's status from an event.
 func (m *TunnelsModel) applyUpdate(ev ssh.TunnelEvent) {
-	port := ev.Tunnel.LocalPort
+	if ev.Tunnel == nil {
+		return
+	}
+	port := ev.LocalPort
 	for gi := range m.groups {
 		for ti := range m.groups[gi].Tunnels {
-			if m.groups[gi].Tunnels[ti].LocalPort == port {
+			if m.groups[gi].Tunnels[ti].SpecPort == ev.SpecPort {
+				if ev.Type == ssh.EventPruned {
+					m.removeTunnel(gi, ti)
+					m.notice = fmt.Sprintf("Closed localhost:%d: %s:%d did not answer",
+						port, ev.Tunnel.RemoteHost, ev.Tunnel.RemotePort)
+					return
+				}
+				// A rebuild can move the tunnel off a port taken meanwhile.
+				m.groups[gi].Tunnels[ti].LocalPort = port
 				switch ev.Type {
+				case ssh.EventStarted:
+					m.groups[gi].Tunnels[ti].Status = ssh.StatusConnecting
+					m.groups[gi].Tunnels[ti].Error = ""
 				case ssh.EventActive:
 					m.groups[gi].Tunnels[ti].Status = ssh.StatusActive
 					m.groups[gi].Tunnels[ti].Error = ""
-				case ssh.EventFailed:
+				case ssh.EventFailed, ssh.EventRestricted:
 					m.groups[gi].Tunnels[ti].Status = ssh.StatusFailed
-					if ev.Tunnel.Error != nil {
-						m.groups[gi].Tunnels[ti].Error = ev.Tunnel.Error.Error()
+					if ev.Err != nil {
+						m.groups[gi].Tunnels[ti].Error = ev.Err.Error()
 					}
 				case ssh.EventClosed:
 					m.groups[gi].Tunnels[ti].Status = ssh.StatusDisconnected
+				case ssh.EventExposed:
+					m.groups[gi].Tunnels[ti].Exposed = true
+				case ssh.EventVerified:
+					m.groups[gi].Tunnels[ti].Verified = true
 				}
 				return
 			}
@@ -113,39 +535,213 @@ func (m *TunnelsModel) applyUpdate(ev ssh.TunnelEvent) {
 	}
 }
 
+// removeTunnel drops one tunnel from the dashboard, and its group with it
+// when nothing else is left there.
+func (m *TunnelsModel) removeTunnel(gi, ti int) {
+	g := &m.groups[gi]
+	g.Tunnels = append(g.Tunnels[:ti], g.Tunnels[ti+1:]...)
+	if len(g.Tunnels) > 0 || g.Proxy != nil {
+		return
+	}
+	m.groups = append(m.groups[:gi], m.groups[gi+1:]...)
+	if gi < m.cursor {
+		m.cursor--
+	}
+	if m.cursor >= len(m.groups) {
+		m.cursor = max(len(m.groups)-1, 0)
+	}
+}
+
 // View renders the active tunnel dashboard.
 func (m TunnelsModel) View() string {
+	if m.editing {
+		return m.note.View()
+	}
+	if m.sshCommand != "" {
+		return m.sshCommandView()
+	}
+
 	var b strings.Builder
 
+	if m.socksPort != 0 {
+		b.WriteString(renderGatewaySOCKS(m.socksPort))
+		b.WriteByte('\n')
+	}
+
 	// Tunnel groups by device.
-	activeCount := 0
-	failedCount := 0
+	var activeCount, failedCount int
+	if m.compact {
+		activeCount, failedCount = m.renderCompact(&b)
+	} else {
+		activeCount, failedCount = m.renderDetailed(&b)
+	}
 
+	if n := len(m.specDiff); n > 0 {
+		b.WriteByte('\n')
+		if m.showDiff {
+			b.WriteString(WarningStyle.Render(fmt.Sprintf("%d changes since selection (d: hide)", n)))
+			b.WriteByte('\n')
+			b.WriteString(renderSpecDiff(m.specDiff))
+		} else {
+			b.WriteString(DimStyle.Render(fmt.Sprintf("%d changes since selection (d: show)", n)))
+			b.WriteByte('\n')
+		}
+	}
+
+	if m.sessionNotes != "" {
+		b.WriteByte('\n')
+		b.WriteString(m.renderSessionNotes())
+	}
+
+	panel := renderPanel("Active Tunnels", b.String())
+
+	// Milestone easter egg.
+	if m.milestone != "" {
+		panel += "\n" + SubtitleStyle.Render("  "+m.milestone)
+	}
+
+	if m.confirmClose {
+		if g, ok := m.selectedGroup(); ok {
+			panel += "\n" + WarningStyle.Render(fmt.Sprintf(
+				"  Close all %d tunnels to %s? y/x: confirm, any other key: cancel",
+				len(g.Tunnels), g.RemoteHost))
+		}
+	} else if m.notice != "" {
+		panel += "\n" + WarningStyle.Render("  "+m.notice)
+	}
+	if m.degraded != "" {
+		panel += "\n" + ErrorStyle.Render("  "+m.degraded)
+	}
+	if m.draining != "" {
+		panel += "\n" + WarningStyle.Render("  "+m.draining)
+	}
+
+	// Status bar.
+	uptime := fmt.Sprintf("UP %s", formatDuration(m.elapsed))
+	summary := fmt.Sprintf("%d active", activeCount)
+	if failedCount > 0 {
+		summary += fmt.Sprintf(", %d failed", failedCount)
+	}
+	if m.probing {
+		summary += ", http probes on"
+	}
+	viewHint := "c: compact"
+	if m.compact {
+		viewHint = "c: detailed"
+	}
+	hints := []string{uptime, summary, "q: disconnect", "x: close", "r: rebuild", "o/O: open/all", viewHint, "y: ssh command", "T: port table", "h: health probe", "w: web view", "N: session notes", "m: device note"}
+	if m.hasRTSP() {
+		hints = append(hints, "v: cameras playlist")
+	}
+	if m.gatewayLoad != "" {
+		hints = append(hints, m.gatewayLoad)
+	}
+	qos := m.qos
+	if qos == "" {
+		qos = "off"
+	}
+	hints = append(hints, "Q: QoS "+qos)
+	if m.socksPort != 0 {
+		hints = append(hints, fmt.Sprintf("SOCKS5 :%d (s: stop)", m.socksPort))
+	} else {
+		hints = append(hints, "s: SOCKS5 proxy")
+	}
+	bar := renderStatusBar(hints...)
+
+	return ContentStyle.Render(panel + "\n" + bar)
+}
+
+// sessionNoteLines is how many lines of session notes the dashboard
+// shows before eliding the rest.
+const sessionNoteLines = 4
+
+// renderSessionNotes writes the session notes, one summarized line per
+// note line, under the tunnel groups.
+func (m TunnelsModel) renderSessionNotes() string {
+	var b strings.Builder
+	b.WriteString(AccentStyle.Render("Session notes (N: edit)"))
+	b.WriteByte('\n')
+	lines := strings.Split(m.sessionNotes, "\n")
+	for i, l := range lines {
+		if i == sessionNoteLines {
+			b.WriteString(DimStyle.Render(fmt.Sprintf("  ... %d more lines", len(lines)-i)))
+			b.WriteByte('\n')
+			break
+		}
+		b.WriteString("  " + noteSummary(l, noteSummaryLen+10))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// groupNote returns the one-line note for a group's device, or "".
+func (m TunnelsModel) groupNote(g tunnelGroup) string {
+	note, ok := m.notes.get(g.RemoteHost, m.macs[g.RemoteHost])
+	if !ok {
+		return ""
+	}
+	return noteSummary(note.Text, noteSummaryLen)
+}
+
+// sshCommandView renders the OpenSSH command overlay.
+func (m TunnelsModel) sshCommandView() string {
+	var b strings.Builder
+	b.WriteString(m.sshCommand)
+	b.WriteString("\n\n")
+	if m.sshCopied {
+		b.WriteString(SuccessStyle.Render("Copied to clipboard."))
+	} else {
+		b.WriteString(WarningStyle.Render("Clipboard unavailable -- copy the command above manually."))
+	}
+	panel := renderPanel("OpenSSH Command", b.String())
+	bar := renderStatusBar("any key: close")
+	return ContentStyle.Render(panel + "\n" + bar)
+}
+
+// renderDetailed writes the grouped tree view with one line per tunnel.
+// Returns the active and failed tunnel counts.
+func (m TunnelsModel) renderDetailed(b *strings.Builder) (active, failed int) {
 	for gi, g := range m.groups {
 		var group strings.Builder
 		for i, t := range g.Tunnels {
 			last := i == len(g.Tunnels)-1
 			connector := "├─ "
-			if last {
+			if last && g.Proxy == nil {
 				connector = "└─ "
 			}
 			group.WriteString(DimStyle.Render(connector))
 
-			// LOCAL:PORT --> REMOTE:PORT with clickable hyperlink.
-			link := portLink(t.LocalPort, t.RemotePort)
-			group.WriteString(link)
+			// [PROTO] LOCAL:PORT --> REMOTE:PORT with clickable hyperlink.
+			group.WriteString(protocolBadge(t.Protocol))
+			group.WriteByte(' ')
+			group.WriteString(portLink(t.LocalPort, t.Protocol, g.Vendor))
 			group.WriteString(DimStyle.Render(" --> "))
 			group.WriteString(fmt.Sprintf("%s:%d", g.RemoteHost, t.RemotePort))
 
 			// Status indicator.
 			group.WriteString("  ")
+			if r, ok := m.health[t.LocalPort]; ok {
+				group.WriteString(healthBadge(r))
+				group.WriteString("  ")
+			}
+			if _, ok := m.certs[t.LocalPort]; ok {
+				group.WriteString(WarningStyle.Render("[cert changed]"))
+				group.WriteString("  ")
+			}
+			if t.Exposed {
+				group.WriteString(ErrorStyle.Render("[exposed]"))
+				group.WriteString("  ")
+			}
 			switch t.Status {
 			case ssh.StatusActive:
 				group.WriteString(SuccessStyle.Render("[active]"))
-				activeCount++
+				if t.Verified {
+					group.WriteString(SuccessStyle.Render(" [verified]"))
+				}
+				active++
 			case ssh.StatusFailed:
 				group.WriteString(ErrorStyle.Render("[failed]"))
-				failedCount++
+				failed++
 				if t.Error != "" {
 					group.WriteString(DimStyle.Render(" " + t.Error))
 				}
@@ -155,41 +751,243 @@ func (m TunnelsModel) View() string {
 				group.WriteString(DimStyle.Render("[closed]"))
 			}
 			group.WriteByte('\n')
+			if c, ok := m.certs[t.LocalPort]; ok {
+				indent := "│  "
+				if last && g.Proxy == nil {
+					indent = "   "
+				}
+				group.WriteString(DimStyle.Render(indent + c.String() + " -- a: accept"))
+				group.WriteByte('\n')
+			}
+			if c, ok := m.rtsp[t.LocalPort]; ok {
+				indent := "│  "
+				if last && g.Proxy == nil {
+					indent = "   "
+				}
+				group.WriteString(rtspLine(indent, c))
+				group.WriteByte('\n')
+			}
+			if t.Exposed {
+				indent := "│  "
+				if last && g.Proxy == nil {
+					indent = "   "
+				}
+				group.WriteString(DimStyle.Render(fmt.Sprintf(
+					"%sport %d also answers on the LAN address -- another process or a redirect is listening there", indent, t.LocalPort)))
+				group.WriteByte('\n')
+			}
+		}
+		if g.Proxy != nil {
+			group.WriteString(renderProxy(*g.Proxy))
 		}
 
-		b.WriteString(InnerPanelStyle.Render(
-			ActiveStyle.Render(g.RemoteHost) + "\n" + group.String(),
-		))
+		header := m.cursorMark(gi) + classStyle(g.Class).Render(g.RemoteHost) + "  " + groupChip(g)
+		if note := m.groupNote(g); note != "" {
+			header += "  " + DimStyle.Render(note)
+		}
+		b.WriteString(InnerPanelStyle.Render(header + "\n" + group.String()))
 		if gi < len(m.groups)-1 {
 			b.WriteByte('\n')
 		}
 	}
+	return active, failed
+}
 
-	panel := renderPanel("Active Tunnels", b.String())
+// renderCompact writes one line per device group: the host, each
+// remote->local port pair (failed ports in red), and an ok count.
+// Returns the active and failed tunnel counts.
+func (m TunnelsModel) renderCompact(b *strings.Builder) (active, failed int) {
+	for gi, g := range m.groups {
+		ok := 0
+		b.WriteString(m.cursorMark(gi))
+		b.WriteString(classStyle(g.Class).Render(fmt.Sprintf("%-15s", g.RemoteHost)))
+		for _, t := range g.Tunnels {
+			pair := fmt.Sprintf("%d→%d", t.RemotePort, t.LocalPort)
+			b.WriteByte(' ')
+			switch t.Status {
+			case ssh.StatusActive:
+				b.WriteString(pair)
+				ok++
+			case ssh.StatusFailed:
+				b.WriteString(ErrorStyle.Render(pair))
+				failed++
+			default:
+				b.WriteString(DimStyle.Render(pair))
+			}
+		}
+		if g.Proxy != nil {
+			pair := fmt.Sprintf("socks:%d", g.Proxy.ListenPort)
+			b.WriteByte(' ')
+			switch g.Proxy.Status {
+			case ssh.StatusActive:
+				b.WriteString(AccentStyle.Render(pair))
+			case ssh.StatusFailed:
+				b.WriteString(ErrorStyle.Render(pair))
+			default:
+				b.WriteString(DimStyle.Render(pair))
+			}
+		}
+		b.WriteString("   ")
+		if ok == len(g.Tunnels) {
+			b.WriteString(SuccessStyle.Render(fmt.Sprintf("[%d ok]", ok)))
+		} else {
+			b.WriteString(WarningStyle.Render(fmt.Sprintf("[%d/%d ok]", ok, len(g.Tunnels))))
+		}
+		active += ok
+		if gi < len(m.groups)-1 {
+			b.WriteByte('\n')
+		}
+	}
+	return active, failed
+}
 
-	// Milestone easter egg.
-	if m.milestone != "" {
-		panel += "\n" + SubtitleStyle.Render("  "+m.milestone)
+// renderGatewaySOCKS renders the SOCKS5 proxy through the gateway as a
+// row of its own above the device groups.
+func renderGatewaySOCKS(port int) string {
+	return AccentStyle.Render("[SOCKS5]") + " " +
+		fmt.Sprintf("socks5://127.0.0.1:%d", port) +
+		DimStyle.Render(" --> any address the gateway reaches") + "  " +
+		SuccessStyle.Render("[active]") + "\n"
+}
+
+// renderProxy writes a group's nested proxy as a sub-group below its
+// tunnels.
+func renderProxy(p proxyEntry) string {
+	var b strings.Builder
+	b.WriteString(DimStyle.Render("└─ "))
+	b.WriteString(AccentStyle.Render("[SOCKS5]"))
+	b.WriteString(DimStyle.Render(" via device SSH"))
+	b.WriteByte('\n')
+	b.WriteString(DimStyle.Render("   └─ "))
+	b.WriteString(fmt.Sprintf("socks5://127.0.0.1:%d", p.ListenPort))
+	b.WriteString("  ")
+	switch p.Status {
+	case ssh.StatusActive:
+		b.WriteString(SuccessStyle.Render("[active]"))
+	case ssh.StatusFailed:
+		b.WriteString(ErrorStyle.Render("[failed]"))
+		if p.Error != "" {
+			b.WriteString(DimStyle.Render(" " + p.Error))
+		}
+	case ssh.StatusConnecting:
+		b.WriteString(WarningStyle.Render("[connecting]"))
+	default:
+		b.WriteString(DimStyle.Render("[closed]"))
 	}
+	b.WriteByte('\n')
+	return b.String()
+}
 
-	// Status bar.
-	uptime := fmt.Sprintf("UP %s", formatDuration(m.elapsed))
-	summary := fmt.Sprintf("%d active", activeCount)
-	if failedCount > 0 {
-		summary += fmt.Sprintf(", %d failed", failedCount)
+// cursorMark returns the cursor prefix for group gi.
+func (m TunnelsModel) cursorMark(gi int) string {
+	if gi == m.cursor {
+		return AccentStyle.Render("> ")
 	}
-	bar := renderStatusBar(uptime, summary, "q: disconnect", "r: reconnect")
+	return "  "
+}
 
-	return ContentStyle.Render(panel + "\n" + bar)
+// groupChip renders the group's aggregate status, e.g. "[3/4 active]":
+// green when all are active, red when none are, yellow in between.
+func groupChip(g tunnelGroup) string {
+	active := 0
+	for _, t := range g.Tunnels {
+		if t.Status == ssh.StatusActive {
+			active++
+		}
+	}
+	chip := fmt.Sprintf("[%d/%d active]", active, len(g.Tunnels))
+	switch active {
+	case len(g.Tunnels):
+		return SuccessStyle.Render(chip)
+	case 0:
+		return ErrorStyle.Render(chip)
+	default:
+		return WarningStyle.Render(chip)
+	}
 }
 
-// portLink returns a clickable OSC8 hyperlink appropriate for the remote port.
-func portLink(localPort, remotePort int) string {
-	switch remotePort {
-	case 443:
+// localPorts returns the group's local listener ports.
+func (g tunnelGroup) localPorts() []int {
+	ports := make([]int, len(g.Tunnels))
+	for i, t := range g.Tunnels {
+		ports[i] = t.LocalPort
+	}
+	return ports
+}
+
+// webURL returns the URL of the group's primary web port: the first HTTPS
+// tunnel, else the first HTTP one. Empty if the device has neither.
+func (g tunnelGroup) webURL() string {
+	for _, proto := range []string{"HTTPS", "HTTP"} {
+		for _, t := range g.Tunnels {
+			if t.Protocol == proto {
+				return fmt.Sprintf("%s://localhost:%d", strings.ToLower(proto), t.LocalPort)
+			}
+		}
+	}
+	return ""
+}
+
+// portLink returns a clickable OSC8 hyperlink for the tunnel's protocol.
+// SSH, WinBox and unrecognized TCP ports have no URL a browser or player
+// would open, so they render as plain text. RTSP links carry the vendor's
+// stream path, if it has a known one.
+func portLink(localPort int, protocol, vendor string) string {
+	switch protocol {
+	case "HTTPS":
 		return components.HTTPSLink(localPort)
-	default:
+	case "HTTP":
 		return components.HTTPLink(localPort)
+	case "RTSP":
+		return components.RTSPLink(localPort, browser.RTSPPath(vendor))
+	default:
+		return fmt.Sprintf("localhost:%d", localPort)
+	}
+}
+
+// healthBadge colors a probe result: 2xx/3xx green, 4xx yellow,
+// 5xx, timeouts and errors red.
+func healthBadge(r health.Result) string {
+	text := r.String()
+	switch {
+	case r.Err != nil || r.Status >= 500:
+		return ErrorStyle.Render(text)
+	case r.Status >= 400:
+		return WarningStyle.Render(text)
+	default:
+		return SuccessStyle.Render(text)
+	}
+}
+
+// rtspLine renders an RTSP probe under its tunnel: red when the server
+// never answered, yellow when it wants a login or a different path.
+func rtspLine(indent string, c rtspCheck) string {
+	text := c.String()
+	if c.vendor != "" {
+		text = c.vendor + ": " + text
+	}
+	switch {
+	case c.Err != nil:
+		return DimStyle.Render(indent) + ErrorStyle.Render(text)
+	case c.Status >= 200 && c.Status < 300:
+		return DimStyle.Render(indent + text)
+	default:
+		return DimStyle.Render(indent) + WarningStyle.Render(text)
+	}
+}
+
+// protocolBadge renders a protocol as a colored "[PROTO]" badge.
+func protocolBadge(protocol string) string {
+	badge := "[" + protocol + "]"
+	switch protocol {
+	case "HTTPS":
+		return SuccessStyle.Render(badge)
+	case "HTTP":
+		return WarningStyle.Render(badge)
+	case "RTSP":
+		return StreamStyle.Render(badge)
+	default:
+		return DimStyle.Render(badge)
 	}
 }
 
@@ -197,19 +995,28 @@ func portLink(localPort, remotePort int) string {
 func groupTunnels(tunnels []*ssh.Tunnel) []tunnelGroup {
 	order := make([]string, 0)
 	byHost := make(map[string][]tunnelEntry)
+	classes := make(map[string]string)
+	vendors := make(map[string]string)
 
 	for _, t := range tunnels {
 		entry := tunnelEntry{
-			LocalPort:  t.LocalPort,
+			LocalPort:  t.Port(),
+			SpecPort:   t.SpecPort(),
 			RemotePort: t.RemotePort,
-			Status:     t.Status,
+			Protocol:   portmap.Protocol(t.RemotePort),
+			Exposed:    t.Exposed(),
+			Verified:   t.FirstConnectOK(),
 		}
-		if t.Error != nil {
-			entry.Error = t.Error.Error()
+		var err error
+		entry.Status, err = t.State()
+		if err != nil {
+			entry.Error = err.Error()
 		}
 
 		if _, exists := byHost[t.RemoteHost]; !exists {
 			order = append(order, t.RemoteHost)
+			classes[t.RemoteHost] = t.Class
+			vendors[t.RemoteHost] = t.Vendor
 		}
 		byHost[t.RemoteHost] = append(byHost[t.RemoteHost], entry)
 	}
@@ -218,6 +1025,8 @@ func groupTunnels(tunnels []*ssh.Tunnel) []tunnelGroup {
 	for i, host := range order {
 		groups[i] = tunnelGroup{
 			RemoteHost: host,
+			Class:      classes[host],
+			Vendor:     vendors[host],
 			Tunnels:    byHost[host],
 		}
 	}
@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/406-mot-acceptable/lmtm/internal/discovery"
+	"github.com/406-mot-acceptable/lmtm/internal/ssh"
+)
+
+func TestDashboardReconnectAndRebuildKeys(t *testing.T) {
+	m := NewTunnelsModel([]*ssh.Tunnel{
+		ssh.NewTunnel(nil, 10080, "192.168.1.10", 80),
+		ssh.NewTunnel(nil, 10443, "192.168.1.10", 443),
+	})
+
+	tests := []struct {
+		key  string
+		want tea.Msg
+	}{
+		{"r", ReconnectMsg{}},
+		{"g", RebuildGroupMsg{Ports: []int{10080, 10443}}},
+	}
+	for _, tt := range tests {
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(tt.key)})
+		if cmd == nil {
+			t.Errorf("%q: no command", tt.key)
+			continue
+		}
+		if got := cmd(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q sent %#v, want %#v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestPortLink(t *testing.T) {
+	tests := []struct {
+		protocol string
+		want     string // substring of the rendered link
+		link     bool   // rendered as an OSC8 hyperlink
+	}{
+		{"HTTPS", "https://localhost:10443", true},
+		{"HTTP", "http://localhost:10443", true},
+		{"RTSP", "rtsp://localhost:10443", true},
+		{"SSH", "localhost:10443", false},
+		{"WinBox", "localhost:10443", false},
+		{"TCP", "localhost:10443", false},
+	}
+	for _, tt := range tests {
+		got := portLink(10443, tt.protocol, "")
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("portLink(%s) = %q, want it to contain %q", tt.protocol, got, tt.want)
+		}
+		if isLink := strings.Contains(got, "\x1b]8;"); isLink != tt.link {
+			t.Errorf("portLink(%s) = %q, hyperlink %v, want %v", tt.protocol, got, isLink, tt.link)
+		}
+	}
+}
+
+func TestGroupClassStyle(t *testing.T) {
+	tunnel := func(host string, port int, class discovery.DeviceClass) *ssh.Tunnel {
+		tun := ssh.NewTunnel(nil, 10000+port, host, port)
+		tun.Class = class.String()
+		return tun
+	}
+	m := NewTunnelsModel([]*ssh.Tunnel{
+		tunnel("192.168.1.64", 554, discovery.ClassCamera),
+		tunnel("192.168.1.64", 443, discovery.ClassCamera),
+		tunnel("192.168.1.2", 80, discovery.ClassNVR),
+		tunnel("192.168.1.1", 8291, discovery.ClassRouter),
+		tunnel("192.168.1.3", 443, discovery.ClassNetworkDevice),
+		tunnel("192.168.1.5", 22, discovery.ClassServer),
+		tunnel("192.168.1.9", 80, discovery.ClassUnknown),
+		tunnel("192.168.1.8", 80, discovery.ClassCustom),
+	})
+
+	want := map[string]lipgloss.TerminalColor{
+		"192.168.1.64": colorCyan,
+		"192.168.1.2":  colorBlue,
+		"192.168.1.1":  colorOrange,
+		"192.168.1.3":  colorOrange,
+		"192.168.1.5":  colorPink,
+		"192.168.1.9":  ActiveStyle.GetForeground(),
+		"192.168.1.8":  ActiveStyle.GetForeground(),
+	}
+	if len(m.groups) != len(want) {
+		t.Fatalf("%d groups, want %d", len(m.groups), len(want))
+	}
+	for _, g := range m.groups {
+		if got := classStyle(g.Class).GetForeground(); got != want[g.RemoteHost] {
+			t.Errorf("%s (class %q) styled %v, want %v", g.RemoteHost, g.Class, got, want[g.RemoteHost])
+		}
+	}
+}
+
+func TestWebURLs(t *testing.T) {
+	m := NewTunnelsModel([]*ssh.Tunnel{
+		ssh.NewTunnel(nil, 5546, "192.168.1.20", 554), // camera stream only
+		ssh.NewTunnel(nil, 10080, "192.168.1.10", 80),
+		ssh.NewTunnel(nil, 2230, "192.168.1.10", 22),
+		ssh.NewTunnel(nil, 10443, "192.168.1.10", 443),
+		ssh.NewTunnel(nil, 8031, "192.168.1.9", 80),
+		ssh.NewTunnel(nil, 1111, "192.168.1.1", 8291), // WinBox
+	})
+	want := []string{"https://localhost:10443", "http://localhost:8031"}
+	if got := m.webURLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("webURLs() = %q, want %q", got, want)
+	}
+	if got := NewTunnelsModel(nil).webURLs(); got != nil {
+		t.Errorf("webURLs() with no tunnels = %q", got)
+	}
+}
+
+func TestAutoOpenToggle(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if autoOpenEnabled() {
+		t.Fatal("auto-open on before it was ever turned on")
+	}
+	m := NewTunnelsModel(nil)
+	for _, want := range []bool{true, false} {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("B")})
+		if m.autoOpen != want || autoOpenEnabled() != want {
+			t.Errorf("after B: model %v, saved %v; want %v", m.autoOpen, autoOpenEnabled(), want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Package webdash serves a read-only web page mirroring the tunnel
+// dashboard, so someone else on the tech's machine (a screen share, a
+// remote desktop) can see what is open without touching the TUI.
+package webdash
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// refreshSeconds is how often the page reloads itself.
+const refreshSeconds = 5
+
+// Snapshot is everything the page shows. The TUI publishes a new one
+// whenever its own view of the session changes.
+type Snapshot struct {
+	Gateway     string    `json:"gateway"`
+	Identity    string    `json:"identity,omitempty"`
+	GatewayType string    `json:"gateway_type,omitempty"`
+	State       string    `json:"state"`
+	Started     time.Time `json:"started"`
+	Devices     int       `json:"devices_scanned"`
+	Notes       string    `json:"notes,omitempty"`
+	Groups      []Group   `json:"groups"`
+}
+
+// Group is one device and its tunnels.
+type Group struct {
+	Host    string   `json:"host"`
+	Note    string   `json:"note,omitempty"`
+	Tunnels []Tunnel `json:"tunnels"`
+}
+
+// Tunnel is one forward as the dashboard shows it.
+type Tunnel struct {
+	LocalPort   int    `json:"local_port"`
+	RemotePort  int    `json:"remote_port"`
+	Protocol    string `json:"protocol"`
+	Status      string `json:"status"`
+	Connections int64  `json:"connections"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Server serves the page. It has no handlers that change anything.
+type Server struct {
+	mu   sync.RWMutex
+	snap Snapshot
+
+	srv *http.Server
+	ln  net.Listener
+}
+
+// New creates an unstarted server.
+func New() *Server {
+	return &Server{}
+}
+
+// Start serves the page on 127.0.0.1:port. Like the tunnels it binds to
+// loopback only; port 0 picks a free port.
+func (s *Server) Start(port int) error {
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("webdash: listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handlePage)
+	mux.HandleFunc("/snapshot.json", s.handleJSON)
+	s.ln = ln
+	s.srv = &http.Server{Handler: readOnly(mux), ReadHeaderTimeout: 5 * time.Second}
+	go s.srv.Serve(ln)
+	return nil
+}
+
+// URL returns the page address, or "" if the server isn't running.
+func (s *Server) URL() string {
+	if s.ln == nil {
+		return ""
+	}
+	return "http://" + s.ln.Addr().String() + "/"
+}
+
+// Publish replaces the snapshot the page shows.
+func (s *Server) Publish(snap Snapshot) {
+	s.mu.Lock()
+	s.snap = snap
+	s.mu.Unlock()
+}
+
+// Stop closes the listener and any open requests.
+func (s *Server) Stop() error {
+	if s.srv == nil {
+		return nil
+	}
+	return s.srv.Close()
+}
+
+func (s *Server) snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snap
+}
+
+// readOnly rejects every method but GET and HEAD.
+func readOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.snapshot())
+}
+
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	snap := s.snapshot()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	pageTmpl.Execute(w, struct {
+		Snapshot
+		Refresh int
+		Uptime  string
+	}{snap, refreshSeconds, uptime(snap.Started)})
+}
+
+// uptime formats the time since start like the TUI's status bar.
+func uptime(start time.Time) string {
+	if start.IsZero() {
+		return "-"
+	}
+	d := time.Since(start).Round(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+var pageTmpl = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>lmtm -- {{.Gateway}}</title>
+<style>
+body { font-family: monospace; background: #1a1a1a; color: #E0E0E0; margin: 2em; }
+h1 { color: #AF87FF; font-size: 1.2em; }
+td, th { padding: 0 1em 0 0; text-align: left; }
+.active { color: #5FD75F; }
+.failed { color: #FF5F5F; }
+.connecting { color: #FFD75F; }
+.dim { color: #585858; }
+pre { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>lmtm {{.Gateway}}{{if .Identity}} ({{.Identity}}){{end}}</h1>
+<p class="dim">{{.GatewayType}} -- {{.State}} -- up {{.Uptime}} -- {{.Devices}} devices scanned -- read-only, refreshes every {{.Refresh}}s</p>
+{{if .Notes}}<h2>Session notes</h2>
+<pre>{{.Notes}}</pre>
+{{end}}{{range .Groups}}
+<h2>{{.Host}}</h2>
+{{if .Note}}<pre class="dim">{{.Note}}</pre>
+{{end}}<table>
+<tr class="dim"><th>Local</th><th>Remote</th><th>Protocol</th><th>Status</th><th>Connections</th><th></th></tr>
+{{range .Tunnels}}<tr><td>:{{.LocalPort}}</td><td>{{.RemotePort}}</td><td>{{.Protocol}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Connections}}</td><td class="dim">{{.Error}}</td></tr>
+{{end}}</table>
+{{else}}
+<p class="dim">No tunnels.</p>
+{{end}}
+</body>
+</html>
+`))
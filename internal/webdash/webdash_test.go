@@ -0,0 +1,153 @@
+package webdash
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves snap on a free loopback port.
+func startServer(t *testing.T, snap Snapshot) *Server {
+	t.Helper()
+	s := New()
+	if err := s.Start(0); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	s.Publish(snap)
+	return s
+}
+
+// get fetches path from the server and returns the status and body.
+func get(t *testing.T, s *Server, method, path string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL()+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+var testSnapshot = Snapshot{
+	Gateway:     "192.168.88.1",
+	Identity:    "core-rtr",
+	GatewayType: "mikrotik",
+	State:       "Tunnels",
+	Started:     time.Now().Add(-90 * time.Minute),
+	Devices:     12,
+	Notes:       "ticket 4411 <camera swap>",
+	Groups: []Group{
+		{
+			Host: "192.168.88.64 Hikvision",
+			Note: "lobby",
+			Tunnels: []Tunnel{
+				{LocalPort: 10443, RemotePort: 443, Protocol: "HTTPS", Status: "active", Connections: 3},
+				{LocalPort: 10554, RemotePort: 554, Protocol: "RTSP", Status: "failed", Error: "connection refused"},
+			},
+		},
+		{
+			Host:    "192.168.88.20",
+			Tunnels: []Tunnel{{LocalPort: 10080, RemotePort: 80, Protocol: "HTTP", Status: "connecting"}},
+		},
+	},
+}
+
+func TestPageShowsSnapshot(t *testing.T) {
+	s := startServer(t, testSnapshot)
+	status, page := get(t, s, http.MethodGet, "/")
+	if status != http.StatusOK {
+		t.Fatalf("GET / = %d", status)
+	}
+	for _, want := range []string{
+		"<title>lmtm -- 192.168.88.1</title>",
+		"lmtm 192.168.88.1 (core-rtr)",
+		"mikrotik -- Tunnels -- up 01:30:",
+		"12 devices scanned",
+		"ticket 4411 &lt;camera swap&gt;",
+		"<h2>192.168.88.64 Hikvision</h2>",
+		`<pre class="dim">lobby</pre>`,
+		`<tr><td>:10443</td><td>443</td><td>HTTPS</td><td class="active">active</td><td>3</td><td class="dim"></td></tr>`,
+		`<td class="failed">failed</td><td>0</td><td class="dim">connection refused</td>`,
+		`<td class="connecting">connecting</td>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page is missing %q:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "<camera swap>") {
+		t.Error("notes were not HTML-escaped")
+	}
+}
+
+func TestPageWithoutTunnels(t *testing.T) {
+	s := startServer(t, Snapshot{Gateway: "10.0.0.1", State: "Devices"})
+	_, page := get(t, s, http.MethodGet, "/")
+	if !strings.Contains(page, "No tunnels.") || !strings.Contains(page, "up -") {
+		t.Errorf("empty snapshot page:\n%s", page)
+	}
+}
+
+func TestSnapshotJSON(t *testing.T) {
+	s := startServer(t, testSnapshot)
+	status, body := get(t, s, http.MethodGet, "/snapshot.json")
+	if status != http.StatusOK {
+		t.Fatalf("GET /snapshot.json = %d", status)
+	}
+	var got Snapshot
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("snapshot.json: %v\n%s", err, body)
+	}
+	want := testSnapshot
+	if !got.Started.Equal(want.Started) {
+		t.Errorf("started = %v, want %v", got.Started, want.Started)
+	}
+	got.Started, want.Started = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot.json =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestPublishReplacesSnapshot(t *testing.T) {
+	s := startServer(t, testSnapshot)
+	next := testSnapshot
+	next.Groups = nil
+	next.State = "Disconnecting"
+	s.Publish(next)
+
+	_, page := get(t, s, http.MethodGet, "/")
+	if strings.Contains(page, "Hikvision") || !strings.Contains(page, "Disconnecting") {
+		t.Errorf("page still shows the old snapshot:\n%s", page)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	s := startServer(t, testSnapshot)
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodHead, "/", http.StatusOK},
+		{http.MethodPost, "/", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/snapshot.json", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if status, _ := get(t, s, tt.method, tt.path); status != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, status, tt.want)
+		}
+	}
+	if !strings.HasPrefix(s.URL(), "http://127.0.0.1:") {
+		t.Errorf("URL() = %q, want loopback", s.URL())
+	}
+}